@@ -8,11 +8,13 @@ import (
 	"os"
 	"runtime"
 	"sync"
+	"time"
 
 	"pfm/internal/controller"
 	"pfm/internal/daemon"
 	"pfm/internal/engine"
 	"pfm/internal/hotkey"
+	"pfm/internal/i18n"
 	"pfm/internal/ipc"
 	"pfm/internal/models"
 	"pfm/internal/storage"
@@ -55,6 +57,9 @@ func (a *App) startup(ctx context.Context) {
 		a.isService = true
 		a.controller = controller.NewRemote(ipcClient)
 		log.Println("[App] Connected to background service successfully!")
+		if config, err := a.controller.GetConfig(); err == nil {
+			i18n.SetLocale(config.Locale)
+		}
 
 		// Initialize tray and hotkey
 		a.InitTray()
@@ -196,6 +201,14 @@ func (a *App) StopRule(id string) error {
 	return a.controller.StopRule(id)
 }
 
+// ExtendRule moves the expiry of a rule by the given number of minutes
+func (a *App) ExtendRule(id string, minutes int) (*models.Rule, error) {
+	if a.controller == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return a.controller.ExtendRule(id, time.Duration(minutes)*time.Minute)
+}
+
 // ==================== Chain Operations ====================
 
 // GetChains returns all chains
@@ -255,7 +268,20 @@ func (a *App) UpdateConfig(config *models.AppConfig) error {
 	if a.controller == nil {
 		return models.ErrServiceNotRunning
 	}
-	return a.controller.UpdateConfig(config)
+	if err := a.controller.UpdateConfig(config); err != nil {
+		return err
+	}
+	i18n.SetLocale(config.Locale)
+	return nil
+}
+
+// GetMessages returns the message catalog of a locale, the locale of the
+// application when empty, to render log entries by their key and params
+func (a *App) GetMessages(locale string) map[string]string {
+	if locale == "" {
+		locale = i18n.Locale()
+	}
+	return i18n.Messages(locale)
 }
 
 // ==================== Status Operations ====================
@@ -283,6 +309,52 @@ func (a *App) IsServiceMode() bool {
 	return a.isService
 }
 
+// ConnectRemote manages the service of another machine instead, reached
+// over TLS. Rules running in embedded mode keep running.
+func (a *App) ConnectRemote(target models.RemoteTarget) error {
+	remote, err := controller.DialRemote(target)
+	if err != nil {
+		return err
+	}
+	return a.useService(remote, target.Addr)
+}
+
+// ConnectContext manages the service of a context of the CLI instead, by
+// its name in the contexts file. Rules running in embedded mode keep
+// running.
+func (a *App) ConnectContext(name string) error {
+	config, err := storage.LoadContexts()
+	if err != nil {
+		return err
+	}
+	ctx, err := config.Get(name)
+	if err != nil {
+		return err
+	}
+	remote, err := controller.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	return a.useService(remote, ctx.Name)
+}
+
+// useService switches to a connected service once it answers
+func (a *App) useService(service *controller.RemoteController, name string) error {
+	if _, err := service.GetStatus(); err != nil {
+		service.Close()
+		return err
+	}
+
+	previous := a.controller
+	a.controller = service
+	a.isService = true
+	if old, ok := previous.(*controller.RemoteController); ok {
+		old.Close()
+	}
+	log.Printf("[App] Connected to service %s", name)
+	return nil
+}
+
 // ==================== Service Management ====================
 
 // InstallService installs and starts the background service
@@ -421,6 +493,74 @@ func (a *App) GetAllRuleStats() map[string]*models.RuleStats {
 	return a.controller.GetAllRuleStats()
 }
 
+// GetStatsHistory returns the recent traffic samples of a rule, resolution
+// being "second" (last 10 minutes) or "minute" (last 24 hours)
+func (a *App) GetStatsHistory(ruleID, resolution string) (*models.StatsHistory, error) {
+	if a.controller == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return a.controller.GetStatsHistory(ruleID, models.HistoryResolution(resolution))
+}
+
+// GetAccounting returns the persistent daily or monthly traffic totals
+func (a *App) GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	if a.controller == nil {
+		return []models.TrafficUsage{}, nil
+	}
+	return a.controller.GetAccounting(q)
+}
+
+// ExportAccounting returns the traffic totals as "csv" or "json" text
+func (a *App) ExportAccounting(q models.AccountingQuery, format string) (string, error) {
+	if a.controller == nil {
+		return "", models.ErrServiceNotRunning
+	}
+	data, err := a.controller.ExportAccounting(q, format)
+	return string(data), err
+}
+
+// ==================== Connection Operations ====================
+
+// GetActiveConnections returns the connections currently handled by a rule
+func (a *App) GetActiveConnections(ruleID string) []models.ActiveConnection {
+	if a.controller == nil {
+		return []models.ActiveConnection{}
+	}
+	conns, err := a.controller.GetActiveConnections(ruleID)
+	if err != nil {
+		log.Printf("[App] GetActiveConnections error: %v", err)
+		return []models.ActiveConnection{}
+	}
+	return conns
+}
+
+// CloseConnection forcibly closes an active connection
+func (a *App) CloseConnection(ruleID, connID string) error {
+	if a.controller == nil {
+		return models.ErrServiceNotRunning
+	}
+	return a.controller.CloseConnection(ruleID, connID)
+}
+
+// CloseClientConnections forcibly closes all active connections from a client
+func (a *App) CloseClientConnections(ruleID, client string) (int, error) {
+	if a.controller == nil {
+		return 0, models.ErrServiceNotRunning
+	}
+	return a.controller.CloseClientConnections(ruleID, client)
+}
+
+// ==================== Validation ====================
+
+// Validate runs the pre-flight checks on a rule before it is saved, or on
+// all rules and chains if rule is null
+func (a *App) Validate(rule *models.Rule) (*models.ValidationReport, error) {
+	if a.controller == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return a.controller.Validate(rule)
+}
+
 // ==================== Log Operations ====================
 
 // GetLogs returns recent log entries
@@ -462,13 +602,42 @@ func (a *App) GetLogsByRule(ruleID string) []models.LogEntry {
 	return logs
 }
 
-// ClearLogs clears all log entries
+// QueryLogs searches the persisted log entries, newest first
+func (a *App) QueryLogs(q models.LogQuery) (*models.LogPage, error) {
+	if a.controller == nil {
+		return &models.LogPage{Entries: []models.LogEntry{}}, nil
+	}
+	return a.controller.QueryLogs(q)
+}
+
+// SetRuleDebug turns debug logging of a rule on or off
+func (a *App) SetRuleDebug(ruleID string, enabled bool) error {
+	if a.controller == nil {
+		return models.ErrServiceNotRunning
+	}
+	return a.controller.SetRuleDebug(ruleID, enabled)
+}
+
+// ClearLogs clears the log entries in memory, persisted entries are kept
 func (a *App) ClearLogs() {
 	if a.controller != nil {
 		a.controller.ClearLogs()
 	}
 }
 
+// GetConnections returns the most recent access records of a rule
+func (a *App) GetConnections(ruleID string, limit int) []models.ConnectionRecord {
+	if a.controller == nil {
+		return []models.ConnectionRecord{}
+	}
+	records, err := a.controller.GetConnections(ruleID, limit)
+	if err != nil {
+		log.Printf("[App] GetConnections error: %v", err)
+		return []models.ConnectionRecord{}
+	}
+	return records
+}
+
 // ==================== Window Operations ====================
 
 // ShowWindow shows the main window
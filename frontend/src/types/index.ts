@@ -1,12 +1,26 @@
 // Rule types
-export type RuleType = 'forward' | 'reverse' | 'chain'
-export type RuleStatus = 'stopped' | 'running' | 'error'
+export type RuleType = 'forward' | 'reverse' | 'chain' | 'transparent'
+export type RuleStatus = 'stopped' | 'running' | 'error' | 'backoff' | 'expired' | 'quota'
 export type Protocol = 'tcp' | 'udp' | 'http' | 'https' | 'socks5' | 'ss'
 
 export interface Target {
   host: string
   port: number
   weight: number
+  backup?: boolean             // 仅在其他目标不可用时使用
+}
+
+export type DiscoveryType = 'srv' | 'dns' | 'docker'
+
+export interface TargetDiscovery {
+  type: DiscoveryType
+  name?: string                // SRV 记录、域名或容器名
+  port?: number                // dns 的目标端口, docker 的容器端口
+  interval?: number            // 刷新间隔 (秒), 默认 30
+  resolver?: string            // DNS 服务器 host:port
+  label?: string               // docker 容器标签 key 或 key=value
+  network?: string             // docker 容器网络
+  dockerHost?: string          // unix://path 或 tcp://host:port, 默认 DOCKER_HOST
 }
 
 export interface Auth {
@@ -23,6 +37,61 @@ export interface TLSConfig {
   secure: boolean
 }
 
+export type TransparentMode = 'redirect' | 'tproxy'
+
+export interface TransparentConfig {
+  mode: TransparentMode
+  mark?: number
+  destPorts?: number[]
+  excludeCidrs?: string[]
+}
+
+export interface MirrorConfig {
+  enabled: boolean
+  host: string
+  port: number
+  samplePercent?: number       // 1-100，省略时为 100
+}
+
+export type RestartMode = 'never' | 'on-failure' | 'always'
+
+export interface RestartPolicy {
+  mode: RestartMode
+  maxRetries?: number          // 0: unlimited
+  initialDelay?: number        // Seconds
+  maxDelay?: number            // Seconds
+}
+
+// Time windows in which a rule runs
+export interface TimeWindow {
+  days?: number[]              // 0 = Sunday ... 6 = Saturday (empty: every day)
+  start: string                // "HH:MM"
+  end: string                  // "HH:MM", before start when spanning midnight
+}
+
+export interface CronWindow {
+  expr: string                 // minute hour day month weekday
+  duration: number             // Minutes
+}
+
+export interface Schedule {
+  timezone?: string            // IANA name (default: local time)
+  windows?: TimeWindow[]
+  cron?: CronWindow[]
+}
+
+// Traffic quota, counting both directions
+export type QuotaPeriod = 'day' | 'week' | 'month' | 'total'
+export type QuotaAction = 'stop' | 'throttle'
+
+export interface Quota {
+  bytes: number                // 每个周期允许的流量
+  period: QuotaPeriod
+  warn?: number[]              // Warning thresholds in percent (default: 80, 90)
+  action?: QuotaAction         // Default: stop
+  throttleRate?: number        // Bytes per second once throttled
+}
+
 export interface Rule {
   id: string
   name: string                 // 用途
@@ -33,10 +102,20 @@ export interface Rule {
   targetHost: string           // 目标 IP/域名
   targetPort: number           // 目标端口
   targets: Target[]            // 保留用于负载均衡场景
+  discovery?: TargetDiscovery  // 从 DNS 发现目标
   chainId?: string
   chain?: Chain | null         // For UI convenience
   auth?: Auth
   tls?: TLSConfig
+  transparent?: TransparentConfig
+  mirror?: MirrorConfig        // 流量镜像
+  restart?: RestartPolicy      // 失败自动重启
+  schedule?: Schedule          // 可用时段
+  expiresAt?: string           // 到期时间
+  ttl?: number                 // Seconds until expiry, converted to expiresAt when saved
+  deleteOnExpiry?: boolean     // 到期后删除
+  dependsOn?: string[]         // 依赖的规则, 启动顺序在其之后
+  quota?: Quota                // 流量配额
   status: string
   errorMsg?: string
   description?: string         // 用途描述
@@ -68,6 +147,8 @@ export interface AppConfig {
   logLevel: string
   autoStart: boolean
   startMinimized: boolean
+  // Language settings
+  locale?: Locale // Empty: language of the system
   // Tray settings
   trayEnabled: boolean
   // Hotkey settings
@@ -82,6 +163,133 @@ export interface AppConfig {
   apiAuth?: Auth
   metricsEnabled: boolean
   metricsAddr: string
+  // Engine settings
+  drainTimeout?: number        // seconds, TCP only, 0 = default (30s), <0 = close immediately
+  bootParallel?: number        // rules started at once at boot, 0 = default (4)
+  // Log settings
+  logMaxSize?: number          // MB of log files kept, 0 = default (50)
+  logMaxAge?: number           // days log files are kept, 0 = default (30)
+  logOutputs?: LogOutput[]     // destinations the rule and access logs are shipped to
+  // Notification settings
+  notifiers?: NotifierConfig[]
+  // Management socket settings
+  ipcAccess?: IPCAccess        // unset: pfm and administrator groups are admin, others have no access
+  // Remote management settings (service only)
+  remoteAccess?: RemoteAccess
+}
+
+// Roles of the local users of the management socket, by name or numeric ID.
+// Root and the user of the service are always admin.
+export type IPCRole = 'none' | 'readonly' | 'admin'
+
+export interface IPCAccess {
+  adminUsers?: string[]
+  adminGroups?: string[]
+  readOnlyUsers?: string[]
+  readOnlyGroups?: string[]
+  defaultRole?: IPCRole        // role of the other users, default none
+}
+
+export interface IPCPeer {
+  uid: number                  // -1 for remote clients
+  user?: string                // certificate or token name of remote clients
+  groups?: string[]
+  role: IPCRole
+  remote?: string              // address of remote clients
+}
+
+// Management API on a TCP port secured by TLS, for other machines
+export interface RemoteAccess {
+  enabled: boolean
+  addr: string                 // e.g. ":19850"
+  certFile?: string            // empty: self-signed, kept in the data directory
+  keyFile?: string
+  clientCAFile?: string        // CA of the accepted client certificates, empty: tokens only
+  clientCertRole?: IPCRole     // default readonly
+  tokens?: RemoteToken[]
+}
+
+export interface RemoteToken {
+  name: string
+  token: string                // at least 16 characters
+  role?: IPCRole               // default readonly
+}
+
+// Remote service the GUI connects to with ConnectRemote
+export interface RemoteTarget {
+  addr: string                 // host:port
+  serverName?: string          // default: host of addr
+  caFile?: string              // empty: system roots
+  fingerprint?: string         // SHA-256 of the server certificate, trusted instead of a CA
+  certFile?: string            // client certificate
+  keyFile?: string
+  token?: string
+}
+
+export interface RemoteStatus {
+  addr: string
+  fingerprint: string          // SHA-256 of the server certificate
+  error?: string
+}
+
+// Notifications of rule and service events
+export type EventType = 'rule.status' | 'target.health' | 'quota' | 'daemon'
+export type NotifierType = 'webhook' | 'slack' | 'command'
+
+export interface NotifierConfig {
+  name: string
+  type: NotifierType
+  enabled: boolean
+  url?: string                 // webhook and slack
+  headers?: Record<string, string>
+  command?: string             // command, receives the event as JSON on stdin
+  args?: string[]
+  events?: EventType[]         // empty: all
+  rateLimit?: number           // events per minute, 0 = default (10)
+}
+
+export type LogOutputType = 'syslog' | 'journald' | 'file'
+
+export interface LogOutput {
+  name: string
+  type: LogOutputType
+  enabled: boolean
+  level?: LogLevel             // lowest level shipped, default info
+  access?: boolean             // also ship connection records
+  network?: 'udp' | 'tcp' | 'unix' // syslog, default udp
+  address?: string             // syslog host:port or socket path
+  facility?: string            // syslog, default daemon
+  tag?: string                 // app name, default pfm
+  path?: string                // file
+  maxSize?: number             // file, MB at which it is rotated, default 100
+}
+
+export interface ServiceEvent {
+  type: EventType
+  time: string
+  ruleId?: string
+  ruleName?: string
+  status: string
+  target?: string
+  message?: string
+  suppressed?: number          // events dropped by rate limiting since the previous one
+}
+
+export type StreamKind = 'event' | 'stats' | 'log'
+
+export interface StreamEvent {
+  id: number
+  kind: StreamKind
+  event?: ServiceEvent
+  stats?: Record<string, RuleStats> // rules whose counters changed
+  log?: LogEntry
+}
+
+export interface EventBatch {
+  stream: string               // changes when the service restarts
+  lastId: number               // resume after this ID
+  reset: boolean               // events were lost, reload the state
+  events: StreamEvent[] | null
 }
 
 // Status types
@@ -93,6 +301,80 @@ export interface ServiceStatus {
   rulesActive: number
   rulesTotal: number
   version: string
+  draining?: DrainStatus[]
+  backoff?: BackoffStatus[]
+  schedules?: ScheduleStatus[]
+  expiry?: ExpiryStatus[]
+  quotas?: QuotaStatus[]
+  boot?: BootReport
+  debugRules?: string[]
+  remote?: RemoteStatus        // remote access listener, when enabled
+}
+
+export interface DrainStatus {
+  ruleId: string
+  ruleName: string
+  startTime: string
+  deadline: string
+  initial: number
+  remaining: number
+}
+
+export interface BackoffStatus {
+  ruleId: string
+  ruleName: string
+  attempt: number
+  maxRetries?: number
+  nextRetry: string
+  lastError: string
+}
+
+export interface ScheduleStatus {
+  ruleId: string
+  ruleName: string
+  active: boolean
+  nextTransition?: string
+  override?: string            // 'running' | 'stopped' until the next transition
+}
+
+export interface ExpiryStatus {
+  ruleId: string
+  ruleName: string
+  expiresAt: string
+  expired: boolean
+  deleted?: boolean
+}
+
+export interface QuotaStatus {
+  ruleId: string
+  ruleName: string
+  period: QuotaPeriod
+  limit: number
+  used: number
+  percent: number
+  exceeded: boolean
+  action: QuotaAction
+  resetAt?: string             // End of the current period, none for a total quota
+}
+
+export type BootOutcome = 'started' | 'failed' | 'skipped'
+
+export interface BootResult {
+  ruleId: string
+  ruleName: string
+  level: number                // Dependency depth
+  outcome: BootOutcome
+  durationMs: number
+  error?: string
+}
+
+export interface BootReport {
+  startTime: string
+  durationMs: number
+  started: number
+  failed: number
+  skipped: number
+  results: BootResult[]
 }
 
 // Form types
@@ -127,7 +409,53 @@ export interface RuleStats {
   connections: number
   activeConns: number
   errors: number
+  rateIn: number               // Bytes per second
+  rateOut: number              // Bytes per second
   lastActivity?: string
+  mirror?: MirrorStats
+}
+
+export type HistoryResolution = 'second' | 'minute'
+
+export interface StatsSample {
+  time: string                 // Start of the interval
+  rateIn: number               // Bytes per second
+  rateOut: number              // Bytes per second
+  activeConns: number          // Peak of the interval
+  newConns: number
+  errors: number
+}
+
+export interface StatsHistory {
+  ruleId: string
+  resolution: HistoryResolution
+  samples: StatsSample[]
+}
+
+export type AccountingPeriod = 'day' | 'month'
+
+export interface TrafficUsage {
+  ruleId: string
+  ruleName: string             // 最后使用的名称, 规则删除后保留
+  period: string               // "2006-01-02" 或 "2006-01", 本地时间
+  bytesIn: number
+  bytesOut: number
+  connections: number
+}
+
+export interface AccountingQuery {
+  period: AccountingPeriod
+  ruleId?: string
+  from?: string                // 包含
+  to?: string                  // 包含
+}
+
+export interface MirrorStats {
+  sessions: number
+  skipped: number
+  bytesOut: number
+  dropped: number
+  errors: number
 }
 
 // Log types
@@ -139,6 +467,77 @@ export interface LogEntry {
   level: LogLevel
   ruleId?: string
   ruleName?: string
-  message: string
+  message: string // In the locale of the service
   details?: string
+  key?: string // Message key in the catalog returned by GetMessages
+  params?: Record<string, string>
+}
+
+// Languages of the messages
+export type Locale = 'en' | 'zh'
+
+// Search of the persisted log entries, newest first
+export interface LogQuery {
+  from?: string                // RFC 3339 time or 2006-01-02
+  to?: string
+  level?: LogLevel             // lowest level returned
+  ruleId?: string
+  search?: string
+  beforeId?: number            // entries older than this ID, for the next page
+  limit?: number               // default 100, at most 1000
+}
+
+export interface LogPage {
+  entries: LogEntry[]
+  nextBeforeId?: number        // beforeId of the next page, absent on the last page
+}
+
+// Connection currently handled by a rule
+export interface ActiveConnection {
+  id: string
+  ruleId: string
+  network: string
+  clientAddr: string
+  target?: string
+  route?: string
+  startTime: string
+  ageSeconds: number
+  bytesIn: number
+  bytesOut: number
+  rateIn: number
+  rateOut: number
+}
+
+// Connection access record
+export interface ConnectionRecord {
+  id: string
+  ruleId: string
+  ruleName?: string
+  network: string
+  clientAddr: string
+  target?: string
+  route?: string
+  startTime: string
+  endTime: string
+  durationMs: number
+  bytesIn: number
+  bytesOut: number
+  error?: string
+}
+
+// Pre-flight validation
+export type IssueSeverity = 'error' | 'warning'
+
+export interface ValidationIssue {
+  severity: IssueSeverity
+  ruleId?: string
+  ruleName?: string
+  chainId?: string
+  field: string
+  message: string
+}
+
+export interface ValidationReport {
+  valid: boolean
+  issues: ValidationIssue[]
 }
@@ -1,19 +1,34 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"pfm/internal/daemon"
+	"pfm/internal/engine"
+	"pfm/internal/i18n"
 	"pfm/internal/ipc"
 	"pfm/internal/models"
 )
 
 // Run executes the CLI command
 func Run(args []string) error {
+	i18n.SetLocale(i18n.FromEnv())
+
+	args, name, err := splitContextFlag(args)
+	if err != nil {
+		return err
+	}
+	selectedContext = name
+
 	if len(args) < 1 {
 		return showHelp()
 	}
@@ -29,56 +44,28 @@ func Run(args []string) error {
 	case "chain", "chains":
 		return handleChain(subArgs)
 	case "status":
-		return handleStatus()
+		return handleStatus(subArgs)
+	case "context", "contexts":
+		return handleContext(subArgs)
+	case "validate":
+		return handleValidate(subArgs)
+	case "traffic":
+		return handleTraffic(subArgs)
+	case "events":
+		return handleEvents(subArgs)
+	case "logs":
+		return handleLogs(subArgs)
 	case "version":
 		return handleVersion()
 	case "help", "-h", "--help":
 		return showHelp()
 	default:
-		return fmt.Errorf("unknown command: %s\nRun 'pfm help' for usage", cmd)
+		return i18n.Error("cli.unknownCommand", i18n.Params{"command": cmd})
 	}
 }
 
 func showHelp() error {
-	help := `Port Forward Manager - CLI
-
-Usage:
-  pfm <command> [arguments]
-
-Commands:
-  service     Manage the background service
-  rule        Manage port forwarding rules
-  chain       Manage proxy chains
-  status      Show service and rules status
-  version     Show version information
-  help        Show this help message
-
-Service Commands:
-  pfm service run         Run as foreground service (for systemd/init)
-  pfm service install     Install as system service
-  pfm service uninstall   Uninstall system service
-  pfm service status      Show service status
-
-Rule Commands:
-  pfm rule list                    List all rules
-  pfm rule show <id>               Show rule details
-  pfm rule start <id>              Start a rule
-  pfm rule stop <id>               Stop a rule
-  pfm rule delete <id>             Delete a rule
-  pfm rule create <json>           Create a rule from JSON
-
-Chain Commands:
-  pfm chain list                   List all chains
-  pfm chain show <id>              Show chain details
-  pfm chain delete <id>            Delete a chain
-
-Examples:
-  pfm service install              # Install and enable service
-  pfm rule list                    # List all forwarding rules
-  pfm rule start abc123            # Start rule with ID abc123
-  pfm status                       # Show overall status
-`
-	fmt.Println(help)
+	fmt.Println(helpText[i18n.Locale()])
 	return nil
 }
 
@@ -93,33 +80,33 @@ func handleService(args []string) error {
 		// This is handled in main.go directly
 		return fmt.Errorf("service run should be handled by main")
 	case "install":
-		fmt.Println("Installing service...")
+		fmt.Println(i18n.T("cli.installing", nil))
 		if err := daemon.Install(); err != nil {
-			return fmt.Errorf("failed to install service: %w", err)
+			return err
 		}
-		fmt.Println("Service installed successfully")
-		fmt.Println("Starting service...")
+		fmt.Println(i18n.T("cli.installed", nil))
+		fmt.Println(i18n.T("cli.starting", nil))
 		if err := daemon.Start(); err != nil {
-			return fmt.Errorf("service installed but failed to start: %w", err)
+			return i18n.Wrap(err, "cli.startFailed", nil)
 		}
-		fmt.Println("Service started successfully")
+		fmt.Println(i18n.T("cli.started", nil))
 		return nil
 	case "uninstall":
-		fmt.Println("Uninstalling service...")
+		fmt.Println(i18n.T("cli.uninstalling", nil))
 		if err := daemon.Uninstall(); err != nil {
-			return fmt.Errorf("failed to uninstall service: %w", err)
+			return err
 		}
-		fmt.Println("Service uninstalled successfully")
+		fmt.Println(i18n.T("cli.uninstalled", nil))
 		return nil
 	case "status":
 		if daemon.IsInstalled() {
 			if daemon.IsRunning() {
-				fmt.Println("Service: installed and running")
+				fmt.Println(i18n.T("cli.serviceRunning", nil))
 			} else {
-				fmt.Println("Service: installed but stopped")
+				fmt.Println(i18n.T("cli.serviceStopped", nil))
 			}
 		} else {
-			fmt.Println("Service: not installed")
+			fmt.Println(i18n.T("cli.serviceMissing", nil))
 		}
 		return nil
 	default:
@@ -129,13 +116,24 @@ func handleService(args []string) error {
 
 func handleRule(args []string) error {
 	if len(args) < 1 {
-		fmt.Println("Usage: pfm rule <list|show|start|stop|delete|create> [args]")
+		fmt.Println("Usage: pfm rule <list|show|start|stop|extend|delete|create|stats|nft|connections|active|kill|debug> [args]")
 		return nil
 	}
 
-	client := ipc.NewClient()
-	if err := client.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to service: %w\nIs the service running?", err)
+	if (args[0] == "list" || args[0] == "ls") && hasFlag(args[1:], "--all-contexts") {
+		return forEachContext(func(client *ipc.Client) error {
+			rules, err := client.GetRules()
+			if err != nil {
+				return fmt.Errorf("failed to get rules: %w", err)
+			}
+			printRules(rules)
+			return nil
+		})
+	}
+
+	client, err := connect()
+	if err != nil {
+		return err
 	}
 	defer client.Close()
 
@@ -157,6 +155,15 @@ func handleRule(args []string) error {
 			return fmt.Errorf("failed to get rule: %w", err)
 		}
 		printRuleDetail(rule)
+		if rule.Quota != nil {
+			if status, err := client.GetStatus(); err == nil {
+				for _, q := range status.Quotas {
+					if q.RuleID == rule.ID {
+						fmt.Printf("Quota Used:  %s\n", formatQuotaUsage(q))
+					}
+				}
+			}
+		}
 		return nil
 
 	case "start":
@@ -166,7 +173,7 @@ func handleRule(args []string) error {
 		if err := client.StartRule(args[1]); err != nil {
 			return fmt.Errorf("failed to start rule: %w", err)
 		}
-		fmt.Printf("Rule %s started\n", args[1])
+		fmt.Println(i18n.T("cli.ruleStarted", i18n.Params{"id": args[1]}))
 		return nil
 
 	case "stop":
@@ -176,7 +183,22 @@ func handleRule(args []string) error {
 		if err := client.StopRule(args[1]); err != nil {
 			return fmt.Errorf("failed to stop rule: %w", err)
 		}
-		fmt.Printf("Rule %s stopped\n", args[1])
+		fmt.Println(i18n.T("cli.ruleStopped", i18n.Params{"id": args[1]}))
+		return nil
+
+	case "extend":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: pfm rule extend <id> <duration>")
+		}
+		d, err := time.ParseDuration(args[2])
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration: %s", args[2])
+		}
+		rule, err := client.ExtendRule(args[1], d)
+		if err != nil {
+			return fmt.Errorf("failed to extend rule: %w", err)
+		}
+		fmt.Println(i18n.T("cli.ruleExtended", i18n.Params{"id": args[1], "time": rule.ExpiresAt.Local().Format("2006-01-02 15:04:05")}))
 		return nil
 
 	case "delete", "rm":
@@ -186,7 +208,7 @@ func handleRule(args []string) error {
 		if err := client.DeleteRule(args[1]); err != nil {
 			return fmt.Errorf("failed to delete rule: %w", err)
 		}
-		fmt.Printf("Rule %s deleted\n", args[1])
+		fmt.Println(i18n.T("cli.ruleDeleted", i18n.Params{"id": args[1]}))
 		return nil
 
 	case "create", "add":
@@ -201,7 +223,111 @@ func handleRule(args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to create rule: %w", err)
 		}
-		fmt.Printf("Rule created with ID: %s\n", id)
+		fmt.Println(i18n.T("cli.ruleCreated", i18n.Params{"id": id}))
+		return nil
+
+	case "stats":
+		if len(args) < 2 || (len(args) > 2 && args[2] != "--history") || len(args) > 4 {
+			return fmt.Errorf("usage: pfm rule stats <id> [--history [second|minute]]")
+		}
+		if len(args) > 2 {
+			var resolution string
+			if len(args) > 3 {
+				resolution = args[3]
+			}
+			res, err := models.ParseHistoryResolution(resolution)
+			if err != nil {
+				return err
+			}
+			history, err := client.GetStatsHistory(args[1], res)
+			if err != nil {
+				return fmt.Errorf("failed to get stats history: %w", err)
+			}
+			printStatsHistory(history)
+			return nil
+		}
+		stats, err := client.GetRuleStats(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get stats: %w", err)
+		}
+		printRuleStats(stats)
+		return nil
+
+	case "nft", "nftables":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pfm rule nft <id>")
+		}
+		rule, err := client.GetRule(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get rule: %w", err)
+		}
+		snippet, err := engine.NftablesSnippet(rule)
+		if err != nil {
+			return err
+		}
+		fmt.Print(snippet)
+		return nil
+
+	case "connections", "conns":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pfm rule connections <id> [n]")
+		}
+		limit := 50
+		if len(args) > 2 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid count: %s", args[2])
+			}
+			limit = n
+		}
+		records, err := client.GetConnections(args[1], limit)
+		if err != nil {
+			return fmt.Errorf("failed to get connections: %w", err)
+		}
+		printConnections(records)
+		return nil
+
+	case "active":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pfm rule active <id>")
+		}
+		conns, err := client.GetActiveConnections(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get active connections: %w", err)
+		}
+		printActiveConnections(conns)
+		return nil
+
+	case "kill":
+		if len(args) == 4 && args[2] == "--client" {
+			n, err := client.CloseClientConnections(args[1], args[3])
+			if err != nil {
+				return fmt.Errorf("failed to close connections: %w", err)
+			}
+			fmt.Println(i18n.T("cli.connsClosed", i18n.Params{"count": strconv.Itoa(n), "client": args[3]}))
+			return nil
+		}
+		if len(args) != 3 {
+			return fmt.Errorf("usage: pfm rule kill <id> <conn-id> | pfm rule kill <id> --client <ip>")
+		}
+		if err := client.CloseConnection(args[1], args[2]); err != nil {
+			return fmt.Errorf("failed to close connection: %w", err)
+		}
+		fmt.Println(i18n.T("cli.connClosed", i18n.Params{"id": args[2]}))
+		return nil
+
+	case "debug":
+		if len(args) != 3 || (args[2] != "on" && args[2] != "off") {
+			return fmt.Errorf("usage: pfm rule debug <id> on|off")
+		}
+		if err := client.SetRuleDebug(args[1], args[2] == "on"); err != nil {
+			return fmt.Errorf("failed to set debug logging: %w", err)
+		}
+		key := "cli.debugOff"
+		if args[2] == "on" {
+			key = "cli.debugOn"
+		}
+		fmt.Println(i18n.T(key, i18n.Params{"id": args[1]}))
 		return nil
 
 	default:
@@ -215,9 +341,9 @@ func handleChain(args []string) error {
 		return nil
 	}
 
-	client := ipc.NewClient()
-	if err := client.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to service: %w\nIs the service running?", err)
+	client, err := connect()
+	if err != nil {
+		return err
 	}
 	defer client.Close()
 
@@ -253,7 +379,7 @@ func handleChain(args []string) error {
 		if err := client.DeleteChain(args[1]); err != nil {
 			return fmt.Errorf("failed to delete chain: %w", err)
 		}
-		fmt.Printf("Chain %s deleted\n", args[1])
+		fmt.Println(i18n.T("cli.chainDeleted", i18n.Params{"id": args[1]}))
 		return nil
 
 	default:
@@ -261,35 +387,396 @@ func handleChain(args []string) error {
 	}
 }
 
-func handleStatus() error {
-	client := ipc.NewClient()
-	if err := client.Connect(); err != nil {
-		fmt.Println("Service: not running")
+func handleStatus(args []string) error {
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--all-contexts":
+		return forEachContext(printStatus)
+	default:
+		return fmt.Errorf("usage: pfm status [--all-contexts]")
+	}
+
+	ctx, err := currentContext()
+	if err != nil {
+		return err
+	}
+	client, err := connectTo(ctx)
+	if err != nil {
+		fmt.Println(i18n.T("cli.serviceDown", nil))
 		return nil
 	}
 	defer client.Close()
+	return printStatus(client)
+}
 
+func printStatus(client *ipc.Client) error {
 	status, err := client.GetStatus()
 	if err != nil {
 		return fmt.Errorf("failed to get status: %w", err)
 	}
 
-	fmt.Println("Service Status")
-	fmt.Println("==============")
-	fmt.Printf("Running:      %v\n", status.Running)
-	fmt.Printf("Version:      %s\n", status.Version)
-	fmt.Printf("Active Rules: %d / %d\n", status.RulesActive, status.RulesTotal)
+	title := i18n.T("cli.statusTitle", nil)
+	fmt.Println(title)
+	fmt.Println(strings.Repeat("=", len(title)))
+	fmt.Println(i18n.T("cli.statusRunning", i18n.Params{"running": strconv.FormatBool(status.Running)}))
+	fmt.Println(i18n.T("cli.statusVersion", i18n.Params{"version": status.Version}))
+	fmt.Println(i18n.T("cli.statusRules", i18n.Params{"active": strconv.Itoa(status.RulesActive), "total": strconv.Itoa(status.RulesTotal)}))
+	if peer, err := client.WhoAmI(); err == nil && (peer.UID >= 0 || peer.User != "") {
+		user := peer.User
+		if user == "" {
+			user = strconv.Itoa(peer.UID)
+		}
+		fmt.Println(i18n.T("cli.statusAccess", i18n.Params{"user": user, "role": string(peer.Role)}))
+	}
+
+	if b := status.Boot; b != nil {
+		fmt.Println(i18n.T("cli.statusBoot", i18n.Params{
+			"started": strconv.Itoa(b.Started), "failed": strconv.Itoa(b.Failed),
+			"skipped": strconv.Itoa(b.Skipped), "duration": strconv.FormatInt(b.Duration, 10),
+		}))
+		for _, r := range b.Results {
+			if r.Outcome != models.BootStarted {
+				fmt.Printf("  %s: %s (%s)\n", r.RuleName, r.Outcome, r.Error)
+			}
+		}
+	}
+
+	if len(status.Draining) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusDraining", nil))
+		for _, d := range status.Draining {
+			left := time.Until(d.Deadline).Round(time.Second)
+			if left < 0 {
+				left = 0
+			}
+			fmt.Println("  " + i18n.T("cli.drainItem", i18n.Params{
+				"rule": d.RuleName, "remaining": strconv.Itoa(d.Remaining), "initial": strconv.Itoa(d.Initial), "left": left.String(),
+			}))
+		}
+	}
+
+	if len(status.Backoff) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusRestarting", nil))
+		for _, b := range status.Backoff {
+			retries := fmt.Sprintf("%d", b.Attempt)
+			if b.MaxRetries > 0 {
+				retries = fmt.Sprintf("%d / %d", b.Attempt, b.MaxRetries)
+			}
+			fmt.Println("  " + i18n.T("cli.backoffItem", i18n.Params{
+				"rule": b.RuleName, "retries": retries, "time": b.NextRetry.Format("15:04:05"), "error": b.LastError,
+			}))
+		}
+	}
+
+	if len(status.Schedules) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusSchedules", nil))
+		for _, sc := range status.Schedules {
+			state := i18n.T("cli.scheduleInactive", nil)
+			if sc.Active {
+				state = i18n.T("cli.scheduleActive", nil)
+			}
+			next := i18n.T("cli.scheduleNoChange", nil)
+			if !sc.NextTransition.IsZero() {
+				next = i18n.T("cli.scheduleNext", i18n.Params{"time": sc.NextTransition.Local().Format("2006-01-02 15:04")})
+			}
+			if sc.Override != "" {
+				next += ", " + i18n.T("cli.scheduleOverride", i18n.Params{"override": sc.Override})
+			}
+			fmt.Printf("  %s: %s, %s\n", sc.RuleName, state, next)
+		}
+	}
+
+	if len(status.Expiry) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusExpiry", nil))
+		for _, e := range status.Expiry {
+			at := e.ExpiresAt.Local().Format("2006-01-02 15:04")
+			params := i18n.Params{"rule": e.RuleName, "time": at}
+			switch {
+			case e.Deleted:
+				fmt.Println("  " + i18n.T("cli.expiryDeleted", params))
+			case e.Expired:
+				fmt.Println("  " + i18n.T("cli.expiryExpired", params))
+			default:
+				params["left"] = time.Until(e.ExpiresAt).Round(time.Second).String()
+				fmt.Println("  " + i18n.T("cli.expiryPending", params))
+			}
+		}
+	}
+
+	if len(status.Quotas) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusQuotas", nil))
+		for _, q := range status.Quotas {
+			fmt.Printf("  %s: %s\n", q.RuleName, formatQuotaUsage(q))
+		}
+	}
+
+	if len(status.DebugRules) > 0 {
+		fmt.Println("\n" + i18n.T("cli.statusDebug", i18n.Params{"rules": strings.Join(status.DebugRules, ", ")}))
+	}
+
+	if r := status.Remote; r != nil {
+		if r.Error != "" {
+			fmt.Println("\n" + i18n.T("cli.statusRemoteErr", i18n.Params{"addr": r.Addr, "error": r.Error}))
+		} else {
+			fmt.Println("\n" + i18n.T("cli.statusRemote", i18n.Params{"addr": r.Addr, "fingerprint": r.Fingerprint}))
+		}
+	}
 
 	// Also list rules
 	rules, err := client.GetRules()
 	if err == nil && len(rules) > 0 {
-		fmt.Println("\nRules:")
+		fmt.Println("\n" + i18n.T("cli.statusRuleList", nil))
 		printRules(rules)
 	}
 
 	return nil
 }
 
+func handleValidate(args []string) error {
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var rule *models.Rule
+	if len(args) > 0 {
+		r, err := client.GetRule(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get rule: %w", err)
+		}
+		rule = r
+	}
+
+	report, err := client.Validate(rule)
+	if err != nil {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+	printValidationReport(report)
+	if !report.Valid {
+		return fmt.Errorf("validation failed")
+	}
+	return nil
+}
+
+func handleTraffic(args []string) error {
+	errUsage := fmt.Errorf("usage: pfm traffic [day|month] [--rule <id>] [--from <period>] [--to <period>] [--format csv|json]")
+	var q models.AccountingQuery
+	var format string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "day" || arg == "month" {
+			q.Period = models.AccountingPeriod(arg)
+			continue
+		}
+		if i+1 >= len(args) {
+			return errUsage
+		}
+		switch arg {
+		case "--rule":
+			q.RuleID = args[i+1]
+		case "--from":
+			q.From = args[i+1]
+		case "--to":
+			q.To = args[i+1]
+		case "--format":
+			format = args[i+1]
+		default:
+			return errUsage
+		}
+		i++
+	}
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if format != "" {
+		data, err := client.ExportAccounting(q, format)
+		if err != nil {
+			return fmt.Errorf("failed to export traffic: %w", err)
+		}
+		os.Stdout.Write(data)
+		return nil
+	}
+	usage, err := client.GetAccounting(q)
+	if err != nil {
+		return fmt.Errorf("failed to get traffic: %w", err)
+	}
+	printTrafficUsage(usage)
+	return nil
+}
+
+func handleEvents(args []string) error {
+	var logs, stats bool
+	for _, arg := range args {
+		switch arg {
+		case "--logs":
+			logs = true
+		case "--stats":
+			stats = true
+		default:
+			return fmt.Errorf("usage: pfm events [--logs] [--stats]")
+		}
+	}
+
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Println(i18n.T("cli.followingEvents", nil))
+	err = client.Subscribe(ctx, "", 0, func(batch *models.EventBatch) {
+		if batch.Reset {
+			fmt.Println(i18n.T("cli.eventsMissed", nil))
+		}
+		for _, ev := range batch.Events {
+			switch ev.Kind {
+			case models.StreamKindEvent:
+				fmt.Printf("%s  %-13s  %s\n", ev.Event.Time.Local().Format("15:04:05"), ev.Event.Type, ev.Event.Summary())
+			case models.StreamKindLog:
+				if logs {
+					printLogEvent(ev.Log)
+				}
+			case models.StreamKindStats:
+				if stats {
+					printStatsEvent(ev.Stats)
+				}
+			}
+		}
+	})
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+func printLogEvent(l *models.LogEntry) {
+	at := l.Timestamp
+	if t, err := time.Parse(time.RFC3339, l.Timestamp); err == nil {
+		at = t.Local().Format("15:04:05")
+	}
+	text := logMessage(l)
+	if l.RuleName != "" {
+		text = l.RuleName + ": " + text
+	}
+	if l.Details != "" {
+		text += " (" + l.Details + ")"
+	}
+	fmt.Printf("%s  %-13s  %s\n", at, "log."+string(l.Level), text)
+}
+
+// logMessage returns the message of a log entry in the language of the CLI
+func logMessage(l *models.LogEntry) string {
+	if l.Key == "" {
+		return l.Message
+	}
+	return i18n.T(l.Key, l.Params)
+}
+
+func printLogPage(page *models.LogPage) {
+	if len(page.Entries) == 0 {
+		fmt.Println("No log entries found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tLEVEL\tRULE\tMESSAGE")
+	fmt.Fprintln(w, "--\t----\t-----\t----\t-------")
+	for _, l := range page.Entries {
+		at := l.Timestamp
+		if t, err := time.Parse(time.RFC3339, l.Timestamp); err == nil {
+			at = t.Local().Format("2006-01-02 15:04:05")
+		}
+		rule := l.RuleName
+		if rule == "" {
+			rule = "-"
+		}
+		message := logMessage(&l)
+		if l.Details != "" {
+			message += " (" + l.Details + ")"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", l.ID, at, l.Level, rule, message)
+	}
+	w.Flush()
+	if page.NextBeforeID > 0 {
+		fmt.Printf("\nMore entries: add --before %d\n", page.NextBeforeID)
+	}
+}
+
+func printStatsEvent(stats map[string]*models.RuleStats) {
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	at := time.Now().Format("15:04:05")
+	for _, id := range ids {
+		s := stats[id]
+		fmt.Printf("%s  %-13s  %s: in %d B (%d B/s), out %d B (%d B/s), %d active connections\n",
+			at, "stats", id, s.BytesIn, s.RateIn, s.BytesOut, s.RateOut, s.ActiveConns)
+	}
+}
+
+func handleLogs(args []string) error {
+	errUsage := fmt.Errorf("usage: pfm logs [--from <time>] [--to <time>] [--level <level>] [--rule <id>] [--search <text>] [--limit <n>] [--before <id>]")
+	var q models.LogQuery
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errUsage
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--from":
+			q.From = value
+		case "--to":
+			q.To = value
+		case "--level":
+			q.Level = models.LogLevel(value)
+		case "--rule":
+			q.RuleID = value
+		case "--search":
+			q.Search = value
+		case "--limit", "--before":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid %s: %s", args[i], value)
+			}
+			if args[i] == "--limit" {
+				q.Limit = int(n)
+			} else {
+				q.BeforeID = n
+			}
+		default:
+			return errUsage
+		}
+	}
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	client, err := connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	page, err := client.QueryLogs(q)
+	if err != nil {
+		return fmt.Errorf("failed to query logs: %w", err)
+	}
+	printLogPage(page)
+	return nil
+}
+
 func handleVersion() error {
 	fmt.Println("Port Forward Manager v1.0.15")
 	fmt.Println("Core Engine: gost (go-gost/x)")
@@ -298,7 +785,7 @@ func handleVersion() error {
 
 func printRules(rules []*models.Rule) {
 	if len(rules) == 0 {
-		fmt.Println("No rules configured")
+		fmt.Println(i18n.T("cli.noRules", nil))
 		return
 	}
 
@@ -309,6 +796,11 @@ func printRules(rules []*models.Rule) {
 	for _, r := range rules {
 		local := fmt.Sprintf(":%d", r.LocalPort)
 		target := fmt.Sprintf("%s:%d", r.TargetHost, r.TargetPort)
+		if r.Type == models.RuleTypeTransparent {
+			target = "(original dst)"
+		} else if r.Discovery != nil {
+			target = r.Discovery.Source()
+		}
 		status := string(r.Status)
 		if status == "" {
 			status = "stopped"
@@ -332,17 +824,218 @@ func printRuleDetail(r *models.Rule) {
 	fmt.Printf("Type:        %s\n", r.Type)
 	fmt.Printf("Protocol:    %s\n", r.Protocol)
 	fmt.Printf("Local Port:  %d\n", r.LocalPort)
-	fmt.Printf("Target:      %s:%d\n", r.TargetHost, r.TargetPort)
+	if d := r.Discovery; d != nil {
+		source := d.Source()
+		if d.Type != models.DiscoverySRV {
+			source += fmt.Sprintf(" port %d", d.Port)
+		}
+		if d.Network != "" {
+			source += " on " + d.Network
+		}
+		if d.Resolver != "" {
+			source += " @" + d.Resolver
+		}
+		if d.Type == models.DiscoveryDocker {
+			source += " @" + d.GetDockerHost()
+		}
+		fmt.Printf("Discovery:   %s, every %s\n", source, d.GetInterval())
+	} else {
+		fmt.Printf("Target:      %s:%d\n", r.TargetHost, r.TargetPort)
+	}
 	fmt.Printf("Status:      %s\n", r.Status)
 	fmt.Printf("Enabled:     %v\n", r.Enabled)
 	if r.ChainID != "" {
 		fmt.Printf("Chain ID:    %s\n", r.ChainID)
 	}
+	if len(r.DependsOn) > 0 {
+		fmt.Printf("Depends On:  %s\n", strings.Join(r.DependsOn, ", "))
+	}
+	if r.Type == models.RuleTypeTransparent {
+		fmt.Printf("Mode:        %s\n", r.Transparent.GetMode())
+	}
+	if r.Mirror != nil && r.Mirror.Enabled {
+		fmt.Printf("Mirror:      %s (%d%%)\n", r.Mirror.GetAddr(), r.Mirror.GetSamplePercent())
+	}
+	if r.Schedule != nil {
+		state := "inactive"
+		if r.Schedule.ActiveAt(time.Now()) {
+			state = "active"
+		}
+		if next, ok := r.Schedule.NextTransition(time.Now()); ok {
+			state += ", next change " + next.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("Schedule:    %s\n", state)
+	}
+	if r.ExpiresAt != nil {
+		expiry := r.ExpiresAt.Local().Format("2006-01-02 15:04:05")
+		if r.DeleteOnExpiry {
+			expiry += " (deleted at expiry)"
+		}
+		fmt.Printf("Expires:     %s\n", expiry)
+	}
+	if q := r.Quota; q != nil {
+		action := "stop"
+		if q.GetAction() == models.QuotaThrottle {
+			action = fmt.Sprintf("throttle to %d B/s", q.ThrottleRate)
+		}
+		period := "per " + string(q.Period)
+		if q.Period == models.QuotaTotal {
+			period = "in total"
+		}
+		fmt.Printf("Quota:       %d bytes %s, %s when used up\n", q.Bytes, period, action)
+	}
 	if r.ErrorMsg != "" {
 		fmt.Printf("Error:       %s\n", r.ErrorMsg)
 	}
 }
 
+// formatQuotaUsage describes the traffic a rule used against its quota
+func formatQuotaUsage(q models.QuotaStatus) string {
+	text := fmt.Sprintf("%d / %d bytes (%d%%)", q.Used, q.Limit, q.Percent)
+	if q.Exceeded {
+		if q.Action == models.QuotaThrottle {
+			text += ", exceeded, throttled"
+		} else {
+			text += ", exceeded, stopped"
+		}
+	}
+	if q.ResetAt != nil {
+		text += ", resets " + q.ResetAt.Local().Format("2006-01-02 15:04")
+	}
+	return text
+}
+
+func printRuleStats(s *models.RuleStats) {
+	fmt.Printf("Bytes In:     %d\n", s.BytesIn)
+	fmt.Printf("Bytes Out:    %d\n", s.BytesOut)
+	fmt.Printf("Rate:         %d B/s in, %d B/s out\n", s.RateIn, s.RateOut)
+	fmt.Printf("Connections:  %d (%d active)\n", s.Connections, s.ActiveConns)
+	fmt.Printf("Errors:       %d\n", s.Errors)
+	if s.LastActivity != "" {
+		fmt.Printf("Last Active:  %s\n", s.LastActivity)
+	}
+	if m := s.Mirror; m != nil {
+		fmt.Println("\nMirror:")
+		fmt.Printf("  Sessions:   %d (%d skipped by sampling)\n", m.Sessions, m.Skipped)
+		fmt.Printf("  Bytes Out:  %d\n", m.BytesOut)
+		fmt.Printf("  Dropped:    %d\n", m.Dropped)
+		fmt.Printf("  Errors:     %d\n", m.Errors)
+	}
+}
+
+func printStatsHistory(h *models.StatsHistory) {
+	if len(h.Samples) == 0 {
+		fmt.Println("No traffic recorded")
+		return
+	}
+
+	layout := "15:04:05"
+	if h.Resolution == models.HistoryMinute {
+		layout = "2006-01-02 15:04"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tRATE IN\tRATE OUT\tACTIVE\tNEW\tERRORS")
+	fmt.Fprintln(w, "----\t-------\t--------\t------\t---\t------")
+
+	for _, s := range h.Samples {
+		fmt.Fprintf(w, "%s\t%d B/s\t%d B/s\t%d\t%d\t%d\n",
+			s.Time.Local().Format(layout), s.RateIn, s.RateOut, s.ActiveConns, s.NewConns, s.Errors)
+	}
+	w.Flush()
+}
+
+func printTrafficUsage(usage []models.TrafficUsage) {
+	if len(usage) == 0 {
+		fmt.Println("No traffic recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PERIOD\tRULE\tIN\tOUT\tCONNECTIONS")
+	fmt.Fprintln(w, "------\t----\t--\t---\t-----------")
+
+	for _, u := range usage {
+		name := u.RuleName
+		if name == "" {
+			name = u.RuleID
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\n", u.Period, name, u.BytesIn, u.BytesOut, u.Connections)
+	}
+	w.Flush()
+}
+
+func printConnections(records []models.ConnectionRecord) {
+	if len(records) == 0 {
+		fmt.Println("No connections recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tNET\tCLIENT\tTARGET\tDURATION\tIN\tOUT\tERROR")
+	fmt.Fprintln(w, "-----\t---\t------\t------\t--------\t--\t---\t-----")
+
+	for _, r := range records {
+		target := r.Target
+		if r.Route != "" {
+			target = fmt.Sprintf("%s via %s", r.Target, r.Route)
+		}
+		duration := (time.Duration(r.DurationMs) * time.Millisecond).String()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			r.StartTime.Local().Format("2006-01-02 15:04:05"), r.Network, r.ClientAddr,
+			target, duration, r.BytesIn, r.BytesOut, r.Error)
+	}
+	w.Flush()
+}
+
+func printActiveConnections(conns []models.ActiveConnection) {
+	if len(conns) == 0 {
+		fmt.Println("No active connections")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNET\tCLIENT\tTARGET\tAGE\tIN\tOUT\tRATE IN\tRATE OUT")
+	fmt.Fprintln(w, "--\t---\t------\t------\t---\t--\t---\t-------\t--------")
+
+	for _, c := range conns {
+		id := c.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		age := (time.Duration(c.AgeSeconds) * time.Second).String()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d B/s\t%d B/s\n",
+			id, c.Network, c.ClientAddr, c.Target, age, c.BytesIn, c.BytesOut, c.RateIn, c.RateOut)
+	}
+	w.Flush()
+}
+
+func printValidationReport(report *models.ValidationReport) {
+	if len(report.Issues) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tSUBJECT\tFIELD\tMESSAGE")
+	for _, issue := range report.Issues {
+		// Shorten IDs for display
+		subject := issue.RuleName
+		if issue.ChainID != "" {
+			id := issue.ChainID
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			subject = "chain " + id
+		} else if subject == "" && len(issue.RuleID) > 8 {
+			subject = issue.RuleID[:8]
+		} else if subject == "" {
+			subject = issue.RuleID
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Severity, subject, issue.Field, issue.Message)
+	}
+	w.Flush()
+}
+
 func printChains(chains []*models.Chain) {
 	if len(chains) == 0 {
 		fmt.Println("No chains configured")
@@ -391,11 +1084,17 @@ func IsCLICommand(args []string) bool {
 	}
 
 	cliCommands := []string{
-		"service", "rule", "rules", "chain", "chains",
-		"status", "version", "help", "-h", "--help",
+		"service", "rule", "rules", "chain", "chains", "context", "contexts",
+		"status", "validate", "traffic", "events", "logs", "version", "help", "-h", "--help",
+	}
+
+	// The global flags may come first, alone they still mean the CLI
+	rest, _, err := splitContextFlag(args)
+	if err != nil || len(rest) == 0 {
+		return true
 	}
 
-	cmd := strings.ToLower(args[0])
+	cmd := strings.ToLower(rest[0])
 	for _, c := range cliCommands {
 		if cmd == c {
 			return true
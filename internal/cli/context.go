@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"pfm/internal/i18n"
+	"pfm/internal/ipc"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+const (
+	// ContextEnv selects the context of the commands, like --context
+	ContextEnv = "PFM_CONTEXT"
+
+	// TokenEnv gives the token of pfm context set when --token is not
+	// given, so it stays out of the shell history and the process list
+	TokenEnv = "PFM_TOKEN"
+)
+
+// selectedContext is the context given by --context, empty for the
+// current one
+var selectedContext string
+
+// splitContextFlag removes the global --context flag from the arguments
+// and returns its value
+func splitContextFlag(args []string) ([]string, string, error) {
+	var rest []string
+	var name string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--context":
+			if i+1 >= len(args) {
+				return nil, "", errors.New("usage: --context <name>")
+			}
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--context="):
+			name = strings.TrimPrefix(arg, "--context=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, name, nil
+}
+
+// readToken reads a token from the first line of the standard input
+func readToken() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// currentContext returns the context of the commands: the one given by
+// --context or PFM_CONTEXT, else the current one of the contexts file
+func currentContext() (*models.Context, error) {
+	config, err := storage.LoadContexts()
+	if err != nil {
+		return nil, err
+	}
+	name := selectedContext
+	if name == "" {
+		name = os.Getenv(ContextEnv)
+	}
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" {
+		name = models.DefaultContext
+	}
+	return config.Get(name)
+}
+
+// connect connects to the service of the context of the command
+func connect() (*ipc.Client, error) {
+	ctx, err := currentContext()
+	if err != nil {
+		return nil, err
+	}
+	return connectTo(ctx)
+}
+
+// connectTo connects to the service of a context
+func connectTo(ctx *models.Context) (*ipc.Client, error) {
+	client, err := ipc.NewContextClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, i18n.Error("cli.connectFailed", i18n.Params{"error": err.Error()})
+	}
+	return client, nil
+}
+
+// forEachContext runs a command against the service of every context, the
+// failure of one not stopping the others
+func forEachContext(fn func(client *ipc.Client) error) error {
+	config, err := storage.LoadContexts()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, ctx := range config.All() {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(i18n.T("cli.contextHeader", i18n.Params{"name": ctx.Name, "endpoint": contextEndpoint(&ctx)}))
+		client, err := connectTo(&ctx)
+		if err == nil {
+			err = fn(client)
+			client.Close()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return i18n.Error("cli.contextsFailed", i18n.Params{"count": strconv.Itoa(failed)})
+	}
+	return nil
+}
+
+// contextEndpoint describes where the service of a context is reached
+func contextEndpoint(ctx *models.Context) string {
+	if endpoint := ctx.Endpoint(); endpoint != "" {
+		return endpoint
+	}
+	return ipc.GetSocketPath()
+}
+
+// hasFlag reports whether a flag is among the arguments
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func handleContext(args []string) error {
+	if len(args) < 1 {
+		fmt.Println("Usage: pfm context <list|current|use|set|delete> [args]")
+		return nil
+	}
+
+	config, err := storage.LoadContexts()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		current := config.Current
+		if current == "" {
+			current = models.DefaultContext
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tENDPOINT\tAUTH")
+		for _, ctx := range config.All() {
+			mark := ""
+			if ctx.Name == current {
+				mark = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mark, ctx.Name, contextEndpoint(&ctx), contextAuth(&ctx))
+		}
+		w.Flush()
+		return nil
+
+	case "current":
+		ctx, err := currentContext()
+		if err != nil {
+			return err
+		}
+		fmt.Println(ctx.Name)
+		return nil
+
+	case "use":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pfm context use <name>")
+		}
+		if _, err := config.Get(args[1]); err != nil {
+			return err
+		}
+		config.Current = args[1]
+		if err := storage.SaveContexts(config); err != nil {
+			return err
+		}
+		fmt.Println(i18n.T("cli.contextSwitched", i18n.Params{"name": args[1]}))
+		return nil
+
+	case "set":
+		if len(args) < 2 {
+			return errContextSetUsage
+		}
+		ctx, err := parseContext(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		config.Set(*ctx)
+		if err := storage.SaveContexts(config); err != nil {
+			return err
+		}
+		fmt.Println(i18n.T("cli.contextSaved", i18n.Params{"name": ctx.Name}))
+		return nil
+
+	case "delete", "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pfm context delete <name>")
+		}
+		if !config.Delete(args[1]) {
+			return fmt.Errorf("%w: %s", models.ErrContextNotFound, args[1])
+		}
+		if err := storage.SaveContexts(config); err != nil {
+			return err
+		}
+		fmt.Println(i18n.T("cli.contextDeleted", i18n.Params{"name": args[1]}))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown context command: %s", args[0])
+	}
+}
+
+var errContextSetUsage = errors.New("usage: pfm context set <name> [--socket <path>] | " +
+	"[--addr <host:port> (--token <token|-> | --cert <file> --key <file>) [--fingerprint <sha256>] [--ca <file>] [--server-name <name>]]")
+
+// parseContext builds a context from the flags of pfm context set
+func parseContext(name string, args []string) (*models.Context, error) {
+	ctx := &models.Context{Name: name}
+	var remote models.RemoteTarget
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, errContextSetUsage
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--socket":
+			ctx.Socket = value
+		case "--addr":
+			remote.Addr = value
+		case "--token":
+			if value == "-" {
+				token, err := readToken()
+				if err != nil {
+					return nil, err
+				}
+				value = token
+			}
+			remote.Token = value
+		case "--fingerprint":
+			remote.Fingerprint = value
+		case "--ca":
+			remote.CAFile = value
+		case "--cert":
+			remote.CertFile = value
+		case "--key":
+			remote.KeyFile = value
+		case "--server-name":
+			remote.ServerName = value
+		default:
+			return nil, errContextSetUsage
+		}
+	}
+	if remote.Addr != "" && remote.Token == "" && remote.CertFile == "" {
+		remote.Token = os.Getenv(TokenEnv)
+	}
+	if remote != (models.RemoteTarget{}) {
+		ctx.Remote = &remote
+	}
+	if err := ctx.Validate(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// contextAuth describes how the CLI authenticates to the service of a
+// context
+func contextAuth(ctx *models.Context) string {
+	switch {
+	case ctx.Remote == nil:
+		return "socket"
+	case ctx.Remote.CertFile != "":
+		return "certificate"
+	default:
+		return "token"
+	}
+}
@@ -0,0 +1,210 @@
+package cli
+
+import "pfm/internal/i18n"
+
+// helpText is the help of the CLI by locale
+var helpText = map[string]string{
+	i18n.English: `Port Forward Manager - CLI
+
+Usage:
+  pfm [--context <name>] <command> [arguments]
+
+Commands:
+  service     Manage the background service
+  rule        Manage port forwarding rules
+  chain       Manage proxy chains
+  status      Show service and rules status
+  validate    Check rules and chains for problems
+  traffic     Show or export the daily and monthly traffic totals
+  events      Follow rule status changes and other events as they happen
+  logs        Search the persisted log entries
+  context     Choose the service the commands manage
+  version     Show version information
+  help        Show this help message
+
+Service Commands:
+  pfm service run         Run as foreground service (for systemd/init)
+  pfm service install     Install as system service
+  pfm service uninstall   Uninstall system service
+  pfm service status      Show service status
+
+Rule Commands:
+  pfm rule list                    List all rules
+  pfm rule list --all-contexts     List the rules of the services of all contexts
+  pfm rule show <id>               Show rule details
+  pfm rule start <id>              Start a rule
+  pfm rule stop <id>               Stop a rule
+  pfm rule extend <id> <duration>  Move the expiry of a rule later (e.g. 1h, 30m)
+  pfm rule delete <id>             Delete a rule
+  pfm rule create <json>           Create a rule from JSON
+  pfm rule stats <id>              Show traffic statistics of a rule
+  pfm rule stats <id> --history    Show the traffic of the last 10 minutes per second
+                                   (--history minute: the last 24 hours per minute)
+  pfm rule nft <id>                Print the nftables snippet for a transparent rule
+  pfm rule connections <id> [n]    Show the last n connections of a rule (default 50)
+  pfm rule active <id>             Show the active connections of a rule
+  pfm rule kill <id> <conn-id>     Close an active connection
+  pfm rule kill <id> --client <ip> Close all active connections from a client
+  pfm rule debug <id> on|off       Turn debug logging of a rule on or off until the service restarts
+
+Chain Commands:
+  pfm chain list                   List all chains
+  pfm chain show <id>              Show chain details
+  pfm chain delete <id>            Delete a chain
+
+Validate Commands:
+  pfm validate                     Check all rules and chains
+  pfm validate <id>                Check a single rule
+
+Traffic Commands:
+  pfm traffic [day|month]          Show the traffic totals per day (default) or month
+    --rule <id>                    Only the given rule
+    --from <period> --to <period>  Only periods in the range, e.g. 2024-05-01 or 2024-05
+    --format csv|json              Export instead of showing a table
+
+Events Commands:
+  pfm events                       Follow rule status, target health, quota and service events
+    --logs                         Also show new log entries
+    --stats                        Also show traffic counters as they change
+
+Logs Commands:
+  pfm logs                         Show the latest log entries, newest first
+    --from <time> --to <time>      Only entries in the range, e.g. 2024-05-01 or 2024-05-01T22:00:00+02:00
+    --level <level>                Only entries of this level or above (debug, info, warn, error)
+    --rule <id>                    Only entries of the given rule
+    --search <text>                Only entries containing the text
+    --limit <n>                    Entries per page (default 100)
+    --before <id>                  Entries older than the given ID, for the next page
+
+Context Commands:
+  pfm context list                 List the contexts, * marks the current one
+  pfm context current              Show the name of the current context
+  pfm context use <name>           Make a context the current one
+  pfm context set <name>           Add or replace a context
+    --socket <path>                Service on this machine listening on another socket
+    --addr <host:port>             Remote service, with --token <token> or --cert <file> --key <file>
+    --token -                      Read the token from the standard input, else from PFM_TOKEN
+    --fingerprint <sha256>         Trust the certificate of the remote service shown by pfm status
+    --ca <file>                    Trust the certificates signed by this CA instead
+    --server-name <name>           Name verified in the certificate (default: host of --addr)
+  pfm context delete <name>        Delete a context
+  pfm status --all-contexts        Show the status of the services of all contexts
+
+Examples:
+  pfm service install              # Install and enable service
+  pfm rule list                    # List all forwarding rules
+  pfm rule start abc123            # Start rule with ID abc123
+  pfm status                       # Show overall status
+  pfm context set box --addr box:19850 --token - --fingerprint <sha256> < token.txt
+  pfm --context box rule list      # List the rules of a remote service
+
+--context, or the PFM_CONTEXT environment variable, selects the context of a single command. The
+contexts are kept in the pfm directory of the user configuration, or the file named by PFM_CONTEXTS.
+The context "local" is the service on this machine.
+
+The language follows the LANG environment variable (e.g. LANG=zh_CN.UTF-8 or LANG=en_US.UTF-8).
+Members of the pfm group and administrators may manage the service, see ipcAccess in the settings
+to give other users the admin or readonly role. On Windows, only administrators may manage the service.
+`,
+
+	i18n.Chinese: `端口转发管理器 - 命令行
+
+用法:
+  pfm [--context <名称>] <命令> [参数]
+
+命令:
+  service     管理后台服务
+  rule        管理端口转发规则
+  chain       管理代理链
+  status      显示服务和规则状态
+  validate    检查规则和代理链的问题
+  traffic     显示或导出每日和每月的流量统计
+  events      实时跟踪规则状态变化和其他事件
+  logs        搜索持久化的日志
+  context     选择命令管理的服务
+  version     显示版本信息
+  help        显示此帮助信息
+
+服务命令:
+  pfm service run         作为前台服务运行 (用于 systemd/init)
+  pfm service install     安装为系统服务
+  pfm service uninstall   卸载系统服务
+  pfm service status      显示服务状态
+
+规则命令:
+  pfm rule list                    列出所有规则
+  pfm rule list --all-contexts     列出所有上下文的服务的规则
+  pfm rule show <id>               显示规则详情
+  pfm rule start <id>              启动规则
+  pfm rule stop <id>               停止规则
+  pfm rule extend <id> <duration>  延后规则的到期时间 (如 1h, 30m)
+  pfm rule delete <id>             删除规则
+  pfm rule create <json>           从 JSON 创建规则
+  pfm rule stats <id>              显示规则的流量统计
+  pfm rule stats <id> --history    显示最近 10 分钟每秒的流量
+                                   (--history minute: 最近 24 小时每分钟)
+  pfm rule nft <id>                输出透明代理规则的 nftables 片段
+  pfm rule connections <id> [n]    显示规则最近的 n 个连接 (默认 50)
+  pfm rule active <id>             显示规则的活动连接
+  pfm rule kill <id> <conn-id>     关闭一个活动连接
+  pfm rule kill <id> --client <ip> 关闭来自某个客户端的所有活动连接
+  pfm rule debug <id> on|off       开启或关闭规则的调试日志, 直到服务重启
+
+代理链命令:
+  pfm chain list                   列出所有代理链
+  pfm chain show <id>              显示代理链详情
+  pfm chain delete <id>            删除代理链
+
+检查命令:
+  pfm validate                     检查所有规则和代理链
+  pfm validate <id>                检查单个规则
+
+流量命令:
+  pfm traffic [day|month]          按天 (默认) 或按月显示流量统计
+    --rule <id>                    仅显示指定规则
+    --from <period> --to <period>  仅显示范围内的周期, 如 2024-05-01 或 2024-05
+    --format csv|json              导出而不是显示表格
+
+事件命令:
+  pfm events                       跟踪规则状态、目标健康、流量配额和服务事件
+    --logs                         同时显示新的日志
+    --stats                        同时显示变化的流量计数
+
+日志命令:
+  pfm logs                         显示最新的日志, 最新的在前
+    --from <time> --to <time>      仅显示范围内的日志, 如 2024-05-01 或 2024-05-01T22:00:00+02:00
+    --level <level>                仅显示该级别及以上的日志 (debug, info, warn, error)
+    --rule <id>                    仅显示指定规则的日志
+    --search <text>                仅显示包含该文本的日志
+    --limit <n>                    每页的条数 (默认 100)
+    --before <id>                  早于指定 ID 的日志, 用于翻页
+
+上下文命令:
+  pfm context list                 列出上下文, * 表示当前上下文
+  pfm context current              显示当前上下文的名称
+  pfm context use <name>           切换当前上下文
+  pfm context set <name>           添加或替换上下文
+    --socket <path>                本机监听其他套接字的服务
+    --addr <host:port>             远程服务, 配合 --token <token> 或 --cert <file> --key <file>
+    --token -                      从标准输入读取令牌, 未指定 --token 时读取 PFM_TOKEN
+    --fingerprint <sha256>         信任 pfm status 显示的远程服务证书
+    --ca <file>                    改为信任该 CA 签发的证书
+    --server-name <name>           证书中校验的名称 (默认: --addr 的主机)
+  pfm context delete <name>        删除上下文
+  pfm status --all-contexts        显示所有上下文的服务状态
+
+示例:
+  pfm service install              # 安装并启用服务
+  pfm rule list                    # 列出所有转发规则
+  pfm rule start abc123            # 启动 ID 为 abc123 的规则
+  pfm status                       # 显示整体状态
+  pfm context set box --addr box:19850 --token - --fingerprint <sha256> < token.txt
+  pfm --context box rule list      # 列出远程服务的规则
+
+--context 或 PFM_CONTEXT 环境变量为单个命令选择上下文。上下文保存在用户配置的 pfm 目录中,
+或 PFM_CONTEXTS 指定的文件中。上下文 "local" 是本机的服务。
+
+语言跟随 LANG 环境变量 (如 LANG=zh_CN.UTF-8 或 LANG=en_US.UTF-8)。
+pfm 组的成员和管理员可以管理服务, 在设置的 ipcAccess 中可为其他用户分配 admin 或 readonly 角色。在 Windows 上只有管理员可以管理服务。
+`,
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	"pfm/internal/models"
 )
 
@@ -17,6 +19,7 @@ type ServiceController interface {
 	StopRule(id string) error
 	StartAllRules() error
 	StopAllRules() error
+	ExtendRule(id string, d time.Duration) (*models.Rule, error)
 
 	// Chain Operations
 	GetChains() ([]*models.Chain, error)
@@ -34,12 +37,26 @@ type ServiceController interface {
 	// Stats Operations
 	GetRuleStats(ruleID string) *models.RuleStats
 	GetAllRuleStats() map[string]*models.RuleStats
+	GetStatsHistory(ruleID string, resolution models.HistoryResolution) (*models.StatsHistory, error)
+	GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error)
+	ExportAccounting(q models.AccountingQuery, format string) ([]byte, error)
+
+	// Connection Operations
+	GetActiveConnections(ruleID string) ([]models.ActiveConnection, error)
+	CloseConnection(ruleID, connID string) error
+	CloseClientConnections(ruleID, client string) (int, error)
 
 	// Log Operations
 	GetLogs(count int) ([]models.LogEntry, error)
 	GetLogsSince(sinceID int64) ([]models.LogEntry, error)
 	GetLogsByRule(ruleID string) ([]models.LogEntry, error)
+	QueryLogs(q models.LogQuery) (*models.LogPage, error)
+	SetRuleDebug(ruleID string, enabled bool) error
 	ClearLogs() error
+	GetConnections(ruleID string, limit int) ([]models.ConnectionRecord, error)
+
+	// Validation
+	Validate(rule *models.Rule) (*models.ValidationReport, error)
 
 	// Data Operations
 	ImportData(data []byte, merge bool) error
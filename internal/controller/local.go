@@ -2,51 +2,116 @@ package controller
 
 import (
 	"encoding/json"
+	"path/filepath"
+	"time"
 
+	"pfm/internal/deps"
 	"pfm/internal/engine"
+	"pfm/internal/i18n"
+	"pfm/internal/logging"
+	"pfm/internal/logship"
 	"pfm/internal/models"
+	"pfm/internal/notify"
+	"pfm/internal/scheduler"
 	"pfm/internal/storage"
+	"pfm/internal/validation"
 )
 
+// logger is the logger of the embedded mode
+var logger = logging.For("controller")
+
 // LocalController implements ServiceController for embedded mode
 type LocalController struct {
-	engine *engine.Engine
-	store  *storage.Store
+	engine    *engine.Engine
+	store     *storage.Store
+	scheduler *scheduler.Scheduler
+	deps      *deps.Manager
+	notifier  *notify.Notifier
+	shipper   *logship.Shipper
 }
 
 // NewLocal creates a new LocalController
 func NewLocal(engine *engine.Engine, store *storage.Store) *LocalController {
-	return &LocalController{
-		engine: engine,
-		store:  store,
+	c := &LocalController{
+		engine:    engine,
+		store:     store,
+		scheduler: scheduler.New(engine, store),
+		deps:      deps.New(engine, store),
+		notifier:  notify.New(),
+		shipper:   logship.New(),
 	}
+	c.scheduler.SetStartCallback(func(ruleID string) {
+		c.deps.OnStatusChange(ruleID, string(models.RuleStatusRunning))
+	})
+	return c
 }
 
 // Init initializes the engine with data from store
 func (c *LocalController) Init() error {
+	logging.SetLevel(c.store.GetConfig().LogLevel)
+	i18n.SetLocale(c.store.GetConfig().Locale)
+
 	// Set chains
 	c.engine.SetChains(c.store.GetChains())
+	c.engine.SetDrainTimeout(c.store.GetConfig().GetDrainTimeout())
+
+	// Keep the connection access log next to the data file
+	c.engine.SetAccessLog(engine.NewAccessLog(filepath.Join(c.store.GetDataDir(), "access")))
+	if accounting, err := engine.NewAccounting(filepath.Join(c.store.GetDataDir(), "accounting.json")); err != nil {
+		c.engine.GetLogManager().Warn("", "", "storage.accountingUnavailable", nil, err.Error())
+	} else {
+		c.engine.SetAccounting(accounting)
+	}
+	if quotas, err := engine.NewQuotas(filepath.Join(c.store.GetDataDir(), "quota.json")); err != nil {
+		c.engine.GetLogManager().Warn("", "", "storage.quotasUnavailable", nil, err.Error())
+	} else {
+		quotas.SetRuleExists(func(ruleID string) bool {
+			_, err := c.store.GetRule(ruleID)
+			return err == nil
+		})
+		c.engine.SetQuotas(quotas)
+	}
+	if logs, err := engine.NewLogStore(filepath.Join(c.store.GetDataDir(), "logs")); err != nil {
+		c.engine.GetLogManager().Warn("", "", "storage.logsUnavailable", nil, err.Error())
+	} else {
+		c.engine.SetLogStore(logs)
+		c.engine.SetLogRetention(c.store.GetConfig().GetLogMaxSize(), c.store.GetConfig().GetLogMaxAge())
+	}
+
+	// Send rule events to the configured notification sinks
+	c.notifier.Configure(c.store.GetConfig().Notifiers)
+	c.engine.SetEventCallback(c.notifier.Notify)
+
+	// Ship the rule and access logs to the configured outputs
+	c.shipper.Configure(c.store.GetConfig().LogOutputs)
+	c.engine.GetLogManager().Subscribe(c.shipper.Log)
+	c.engine.SetAccessCallback(c.shipper.Access)
 
 	// Set status change callback to sync engine errors to store
 	c.engine.SetStatusChangeCallback(func(ruleID string, status string, errorMsg string) {
-		// Log? app.go logged it.
-		// log.Printf("[Controller] Status change: rule=%s, status=%s, error=%s", ruleID, status, errorMsg)
+		logger.Debug("Rule status changed", "rule", ruleID, "status", status, "error", errorMsg)
 		if status == "error" {
 			c.store.UpdateRuleStatus(ruleID, models.RuleStatusError, errorMsg)
+		} else if status == "backoff" {
+			c.store.UpdateRuleStatus(ruleID, models.RuleStatusBackoff, errorMsg)
+		} else if status == "running" {
+			c.store.UpdateRuleStatus(ruleID, models.RuleStatusRunning, "")
 		} else if status == "stopped" {
 			c.store.UpdateRuleStatus(ruleID, models.RuleStatusStopped, "")
+		} else if status == "quota" {
+			c.store.UpdateRuleStatus(ruleID, models.RuleStatusQuota, "")
 		}
+		c.deps.OnStatusChange(ruleID, status)
 	})
 
-	// Start enabled rules and sync status
+	// Start enabled rules in dependency order and sync status
+	var toStart []*models.Rule
 	for _, rule := range c.store.GetRules() {
-		if rule.Enabled {
-			if err := c.engine.StartRule(rule); err != nil {
-				// log.Printf("[Controller] Failed to start rule %s: %v", rule.Name, err)
-				c.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
-			} else {
-				c.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
-			}
+		if rule.Enabled && !c.scheduler.ShouldRun(rule) {
+			// Outside its schedule, expired or over its quota, the scheduler takes care of it
+			c.store.UpdateRuleStatus(rule.ID, c.scheduler.IdleStatus(rule), "")
+		} else if rule.Enabled {
+			toStart = append(toStart, rule)
 		} else {
 			// Sync status: if not enabled but status is running, reset to stopped
 			if rule.Status == models.RuleStatusRunning {
@@ -54,6 +119,10 @@ func (c *LocalController) Init() error {
 			}
 		}
 	}
+
+	c.deps.Boot(toStart, c.store.GetConfig().GetBootParallel())
+
+	c.scheduler.Start()
 	return nil
 }
 
@@ -68,26 +137,30 @@ func (c *LocalController) GetRule(id string) (*models.Rule, error) {
 }
 
 func (c *LocalController) CreateRule(rule *models.Rule) error {
+	if err := c.checkRule(rule).Err(); err != nil {
+		return err
+	}
 	return c.store.CreateRule(rule)
 }
 
 func (c *LocalController) UpdateRule(rule *models.Rule) error {
-	// Stop if running
-	if c.engine.IsRunning(rule.ID) {
-		c.engine.StopRule(rule.ID)
+	if err := c.checkRule(rule).Err(); err != nil {
+		return err
 	}
-
 	if err := c.store.UpdateRule(rule); err != nil {
 		return err
 	}
 
-	// Restart if enabled
-	if rule.Enabled {
-		if err := c.engine.StartRule(rule); err != nil {
-			c.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
+	// Apply to the running service, only what changed is rebuilt
+	if rule.Enabled && c.scheduler.ShouldRun(rule) {
+		if err := c.engine.UpdateRule(rule); err != nil {
+			c.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
 			return err
 		}
 		c.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+	} else if c.engine.IsActive(rule.ID) {
+		c.engine.StopRule(rule.ID)
+		c.store.UpdateRuleStatus(rule.ID, c.scheduler.IdleStatus(rule), "")
 	}
 
 	return nil
@@ -95,11 +168,15 @@ func (c *LocalController) UpdateRule(rule *models.Rule) error {
 
 func (c *LocalController) DeleteRule(id string) error {
 	// Stop if running
-	if c.engine.IsRunning(id) {
+	if c.engine.IsActive(id) {
 		c.engine.StopRule(id)
 	}
 
-	return c.store.DeleteRule(id)
+	if err := c.store.DeleteRule(id); err != nil {
+		return err
+	}
+	logging.SetRuleDebug(id, false)
+	return nil
 }
 
 func (c *LocalController) StartRule(id string) error {
@@ -107,13 +184,21 @@ func (c *LocalController) StartRule(id string) error {
 	if err != nil {
 		return err
 	}
+	if rule.IsExpired(time.Now()) {
+		return models.ErrRuleExpired
+	}
 
+	// Starting a scheduled rule outside its window overrides the schedule
+	c.scheduler.Override(rule, true)
+
+	c.deps.Forget(id)
 	if err := c.engine.StartRule(rule); err != nil {
-		c.store.UpdateRuleStatus(id, models.RuleStatusError, err.Error())
+		c.store.UpdateRuleStatus(id, models.FailureStatus(err), err.Error())
 		return err
 	}
 
 	c.store.UpdateRuleStatus(id, models.RuleStatusRunning, "")
+	c.deps.OnStatusChange(id, string(models.RuleStatusRunning))
 	return nil
 }
 
@@ -126,6 +211,12 @@ func (c *LocalController) StopRule(id string) error {
 		}
 	}
 
+	// Stopping a scheduled rule inside its window overrides the schedule
+	if rule, err := c.store.GetRule(id); err == nil {
+		c.scheduler.Override(rule, false)
+	}
+	c.deps.Forget(id)
+
 	// Always update status to stopped
 	c.store.UpdateRuleStatus(id, models.RuleStatusStopped, "")
 	return nil
@@ -149,11 +240,15 @@ func (c *LocalController) StartAllRules() error {
 		// Or if it's a "Start All" button, it usually enables them.
 		// Let's implement it as: Enable and Start All.
 
+		if rule.IsExpired(time.Now()) {
+			continue
+		}
 		rule.Enabled = true
 		c.store.UpdateRule(rule) // persist enabled state
+		c.scheduler.Override(rule, true)
 
 		if err := c.engine.StartRule(rule); err != nil {
-			c.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
+			c.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
 			lastErr = err
 		} else {
 			c.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
@@ -166,11 +261,12 @@ func (c *LocalController) StopAllRules() error {
 	rules := c.store.GetRules()
 	var lastErr error
 	for _, rule := range rules {
-		if c.engine.IsRunning(rule.ID) {
+		if c.engine.IsActive(rule.ID) {
 			if err := c.engine.StopRule(rule.ID); err != nil {
 				lastErr = err
 			}
 		}
+		c.scheduler.Override(rule, false)
 		// Update status and disable? Or just stop?
 		// Usually "Stop All" just stops them but keeps "Enabled" toggle if it was a persistent preference?
 		// But for pfm, "Enabled" usually tracks running state intent.
@@ -180,6 +276,10 @@ func (c *LocalController) StopAllRules() error {
 	return lastErr
 }
 
+func (c *LocalController) ExtendRule(id string, d time.Duration) (*models.Rule, error) {
+	return c.scheduler.Extend(id, d)
+}
+
 // ==================== Chain Operations ====================
 
 func (c *LocalController) GetChains() ([]*models.Chain, error) {
@@ -217,7 +317,19 @@ func (c *LocalController) GetConfig() (*models.AppConfig, error) {
 }
 
 func (c *LocalController) UpdateConfig(config *models.AppConfig) error {
-	return c.store.UpdateConfig(config)
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := c.store.UpdateConfig(config); err != nil {
+		return err
+	}
+	c.engine.SetDrainTimeout(config.GetDrainTimeout())
+	c.engine.SetLogRetention(config.GetLogMaxSize(), config.GetLogMaxAge())
+	c.notifier.Configure(config.Notifiers)
+	c.shipper.Configure(config.LogOutputs)
+	logging.SetLevel(config.LogLevel)
+	i18n.SetLocale(config.Locale)
+	return nil
 }
 
 // ==================== Status Operations ====================
@@ -231,6 +343,13 @@ func (c *LocalController) GetStatus() (*models.ServiceStatus, error) {
 		RulesActive: len(runningIDs),
 		RulesTotal:  len(rules),
 		Version:     "V1.1.0",
+		Draining:    c.engine.GetDrainStatus(),
+		Backoff:     c.engine.GetBackoffStatus(),
+		Schedules:   c.scheduler.Status(),
+		Expiry:      c.scheduler.ExpiryStatus(),
+		Quotas:      c.scheduler.QuotaStatus(),
+		Boot:        c.deps.Report(),
+		DebugRules:  logging.DebugRules(),
 	}, nil
 }
 
@@ -250,6 +369,27 @@ func (c *LocalController) GetAllRuleStats() map[string]*models.RuleStats {
 	return c.engine.GetAllRuleStats()
 }
 
+func (c *LocalController) GetStatsHistory(ruleID string, resolution models.HistoryResolution) (*models.StatsHistory, error) {
+	if c.engine == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return c.engine.GetStatsHistory(ruleID, resolution)
+}
+
+func (c *LocalController) GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	if c.engine == nil {
+		return []models.TrafficUsage{}, nil
+	}
+	return c.engine.GetAccounting(q)
+}
+
+func (c *LocalController) ExportAccounting(q models.AccountingQuery, format string) ([]byte, error) {
+	if c.engine == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return c.engine.ExportAccounting(q, format)
+}
+
 // ==================== Log Operations ====================
 
 func (c *LocalController) GetLogs(count int) ([]models.LogEntry, error) {
@@ -276,6 +416,23 @@ func (c *LocalController) GetLogsByRule(ruleID string) ([]models.LogEntry, error
 	return c.engine.GetLogsByRule(ruleID), nil
 }
 
+func (c *LocalController) QueryLogs(q models.LogQuery) (*models.LogPage, error) {
+	if c.engine == nil {
+		return &models.LogPage{Entries: []models.LogEntry{}}, nil
+	}
+	return c.engine.QueryLogs(q)
+}
+
+func (c *LocalController) SetRuleDebug(ruleID string, enabled bool) error {
+	rule, err := c.store.GetRule(ruleID)
+	if err != nil {
+		return err
+	}
+	logging.SetRuleDebug(rule.ID, enabled)
+	logger.Info("Rule debug logging changed", "rule", rule.ID, "name", rule.Name, "enabled", enabled)
+	return nil
+}
+
 func (c *LocalController) ClearLogs() error {
 	if c.engine != nil {
 		c.engine.ClearLogs()
@@ -283,6 +440,44 @@ func (c *LocalController) ClearLogs() error {
 	return nil
 }
 
+func (c *LocalController) GetConnections(ruleID string, limit int) ([]models.ConnectionRecord, error) {
+	if c.engine == nil {
+		return []models.ConnectionRecord{}, nil
+	}
+	return c.engine.GetConnections(ruleID, limit)
+}
+
+// ==================== Connection Operations ====================
+
+func (c *LocalController) GetActiveConnections(ruleID string) ([]models.ActiveConnection, error) {
+	return c.engine.GetActiveConnections(ruleID)
+}
+
+func (c *LocalController) CloseConnection(ruleID, connID string) error {
+	return c.engine.CloseConnection(ruleID, connID)
+}
+
+func (c *LocalController) CloseClientConnections(ruleID, client string) (int, error) {
+	return c.engine.CloseClientConnections(ruleID, client)
+}
+
+// ==================== Validation ====================
+
+func (c *LocalController) Validate(rule *models.Rule) (*models.ValidationReport, error) {
+	if rule == nil {
+		return validation.CheckAll(c.store.GetRules(), c.store.GetChains(), c.validationOptions()), nil
+	}
+	return c.checkRule(rule), nil
+}
+
+func (c *LocalController) checkRule(rule *models.Rule) *models.ValidationReport {
+	return validation.CheckRule(rule, c.store.GetRules(), c.store.GetChains(), c.validationOptions())
+}
+
+func (c *LocalController) validationOptions() validation.Options {
+	return validation.Options{ProbePorts: true, OwnPort: c.engine.IsListening}
+}
+
 // ==================== Data Operations ====================
 
 func (c *LocalController) ImportData(data []byte, merge bool) error {
@@ -290,12 +485,15 @@ func (c *LocalController) ImportData(data []byte, merge bool) error {
 	if err := json.Unmarshal(data, &appData); err != nil {
 		return err
 	}
+	if err := validation.CheckImport(c.store.GetAllData(), &appData, merge, c.validationOptions()).Err(); err != nil {
+		return err
+	}
 
 	// Stop all current rules if not merging (full overwrite)
 	if !merge {
 		currentRules := c.store.GetRules()
 		for _, rule := range currentRules {
-			if c.engine.IsRunning(rule.ID) {
+			if c.engine.IsActive(rule.ID) {
 				c.engine.StopRule(rule.ID)
 			}
 		}
@@ -311,7 +509,7 @@ func (c *LocalController) ImportData(data []byte, merge bool) error {
 	// Start enabled rules from import
 	importedRules := c.store.GetRules()
 	for _, rule := range importedRules {
-		if rule.Enabled {
+		if rule.Enabled && c.scheduler.ShouldRun(rule) {
 			// If merging, rule might already be running.
 			// But ImportData (store) might have updated it.
 			// Safer to restart if running, or start if stopped.
@@ -319,7 +517,7 @@ func (c *LocalController) ImportData(data []byte, merge bool) error {
 				c.engine.StopRule(rule.ID)
 			}
 			if err := c.engine.StartRule(rule); err != nil {
-				c.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
+				c.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
 			} else {
 				c.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
 			}
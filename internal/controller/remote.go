@@ -2,6 +2,8 @@ package controller
 
 import (
 	"encoding/json"
+	"time"
+
 	"pfm/internal/ipc"
 	"pfm/internal/models"
 )
@@ -18,6 +20,35 @@ func NewRemote(client *ipc.Client) *RemoteController {
 	}
 }
 
+// DialRemote connects to the service of another machine over TLS
+func DialRemote(target models.RemoteTarget) (*RemoteController, error) {
+	client, err := ipc.NewRemoteClient(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return NewRemote(client), nil
+}
+
+// DialContext connects to the service of a context of the CLI
+func DialContext(ctx *models.Context) (*RemoteController, error) {
+	client, err := ipc.NewContextClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return NewRemote(client), nil
+}
+
+// Close closes the connection to the service
+func (c *RemoteController) Close() error {
+	return c.client.Close()
+}
+
 // ==================== Rule Operations ====================
 
 func (c *RemoteController) GetRules() ([]*models.Rule, error) {
@@ -83,6 +114,10 @@ func (c *RemoteController) StopAllRules() error {
 	return lastErr
 }
 
+func (c *RemoteController) ExtendRule(id string, d time.Duration) (*models.Rule, error) {
+	return c.client.ExtendRule(id, d)
+}
+
 // ==================== Chain Operations ====================
 
 func (c *RemoteController) GetChains() ([]*models.Chain, error) {
@@ -121,15 +156,55 @@ func (c *RemoteController) GetStatus() (*models.ServiceStatus, error) {
 // ==================== Stats Operations ====================
 
 func (c *RemoteController) GetRuleStats(ruleID string) *models.RuleStats {
-	// PENDING: IPC client doesn't seem to implement GetRuleStats based on client.go view?
-	// Checking client.go... I didn't see GetRuleStats. app.go had TODOs.
-	// We will return empty stats for now as it seems not implemented in IPC layer yet.
-	return &models.RuleStats{RuleID: ruleID}
+	stats, err := c.client.GetRuleStats(ruleID)
+	if err != nil {
+		return &models.RuleStats{RuleID: ruleID}
+	}
+	return stats
 }
 
 func (c *RemoteController) GetAllRuleStats() map[string]*models.RuleStats {
-	// PENDING: Not implemented in IPC
-	return make(map[string]*models.RuleStats)
+	stats, err := c.client.GetAllRuleStats()
+	if err != nil || stats == nil {
+		return make(map[string]*models.RuleStats)
+	}
+	return stats
+}
+
+func (c *RemoteController) GetStatsHistory(ruleID string, resolution models.HistoryResolution) (*models.StatsHistory, error) {
+	return c.client.GetStatsHistory(ruleID, resolution)
+}
+
+func (c *RemoteController) GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	return c.client.GetAccounting(q)
+}
+
+func (c *RemoteController) ExportAccounting(q models.AccountingQuery, format string) ([]byte, error) {
+	return c.client.ExportAccounting(q, format)
+}
+
+// ==================== Connection Operations ====================
+
+func (c *RemoteController) GetActiveConnections(ruleID string) ([]models.ActiveConnection, error) {
+	conns, err := c.client.GetActiveConnections(ruleID)
+	if err != nil {
+		return []models.ActiveConnection{}, err
+	}
+	return conns, nil
+}
+
+func (c *RemoteController) CloseConnection(ruleID, connID string) error {
+	return c.client.CloseConnection(ruleID, connID)
+}
+
+func (c *RemoteController) CloseClientConnections(ruleID, client string) (int, error) {
+	return c.client.CloseClientConnections(ruleID, client)
+}
+
+// ==================== Validation ====================
+
+func (c *RemoteController) Validate(rule *models.Rule) (*models.ValidationReport, error) {
+	return c.client.Validate(rule)
 }
 
 // ==================== Log Operations ====================
@@ -158,10 +233,26 @@ func (c *RemoteController) GetLogsByRule(ruleID string) ([]models.LogEntry, erro
 	return convertLogs(logs), nil
 }
 
+func (c *RemoteController) QueryLogs(q models.LogQuery) (*models.LogPage, error) {
+	return c.client.QueryLogs(q)
+}
+
+func (c *RemoteController) SetRuleDebug(ruleID string, enabled bool) error {
+	return c.client.SetRuleDebug(ruleID, enabled)
+}
+
 func (c *RemoteController) ClearLogs() error {
 	return c.client.ClearLogs()
 }
 
+func (c *RemoteController) GetConnections(ruleID string, limit int) ([]models.ConnectionRecord, error) {
+	records, err := c.client.GetConnections(ruleID, limit)
+	if err != nil {
+		return []models.ConnectionRecord{}, err
+	}
+	return records, nil
+}
+
 // Helper to convert []*models.LogEntry to []models.LogEntry
 func convertLogs(ptrs []*models.LogEntry) []models.LogEntry {
 	result := make([]models.LogEntry, len(ptrs))
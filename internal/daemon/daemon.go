@@ -1,17 +1,25 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
+	"pfm/internal/deps"
 	"pfm/internal/engine"
+	"pfm/internal/i18n"
 	"pfm/internal/ipc"
+	"pfm/internal/logging"
+	"pfm/internal/logship"
 	"pfm/internal/models"
+	"pfm/internal/notify"
+	"pfm/internal/scheduler"
 	"pfm/internal/storage"
 
 	"github.com/kardianos/service"
@@ -23,12 +31,23 @@ const (
 	ServiceDescription = "Port forwarding and proxy management service"
 )
 
+// notifyCloseTimeout is how long a stopping daemon waits for queued
+// notifications and log records
+const notifyCloseTimeout = 5 * time.Second
+
+// logger is the logger of the daemon and the service installation
+var logger = logging.For("daemon")
+
 // Daemon represents the background service
 type Daemon struct {
 	engine    *engine.Engine
 	store     *storage.Store
 	ipcServer *ipc.Server
-	logger    *log.Logger
+	scheduler *scheduler.Scheduler
+	deps      *deps.Manager
+	notifier  *notify.Notifier
+	shipper   *logship.Shipper
+	logger    *slog.Logger
 	service   service.Service
 }
 
@@ -57,69 +76,140 @@ func New() (*Daemon, error) {
 	// Initialize engine
 	eng := engine.New()
 
-	// Initialize IPC server
+	// Initialize scheduler, dependency manager and IPC server
+	sched := scheduler.New(eng, store)
+	depMgr := deps.New(eng, store)
+	sched.SetStartCallback(func(ruleID string) {
+		depMgr.OnStatusChange(ruleID, string(models.RuleStatusRunning))
+	})
 	ipcServer := ipc.NewServer(eng, store)
-
-	// Setup logger
+	ipcServer.SetScheduler(sched)
+	ipcServer.SetDeps(depMgr)
+
+	// Send rule and service events to the configured notification sinks
+	// and the clients subscribed to the event stream
+	notifier := notify.New()
+	eng.SetEventCallback(func(ev models.Event) {
+		notifier.Notify(ev)
+		ipcServer.Publish(ev)
+	})
+	ipcServer.SetNotifier(notifier)
+
+	// Ship the rule and access logs to the configured outputs
+	shipper := logship.New()
+	eng.GetLogManager().Subscribe(shipper.Log)
+	eng.SetAccessCallback(shipper.Access)
+	ipcServer.SetLogShipper(shipper)
+
+	// Log to service.log at the configured level
 	logFile := filepath.Join(store.GetDataDir(), "service.log")
 	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
-	logger := log.New(f, "", log.LstdFlags|log.Lshortfile)
+	logging.SetOutput(f)
+	logging.SetLevel(store.GetConfig().LogLevel)
+	i18n.SetLocale(store.GetConfig().Locale)
 
-	eng.SetLogger(logger)
-	ipcServer.SetLogger(logger)
+	eng.SetAccessLog(engine.NewAccessLog(filepath.Join(store.GetDataDir(), "access")))
+	if accounting, err := engine.NewAccounting(filepath.Join(store.GetDataDir(), "accounting.json")); err != nil {
+		logger.Warn("Traffic accounting disabled", "error", err)
+	} else {
+		eng.SetAccounting(accounting)
+	}
+	if quotas, err := engine.NewQuotas(filepath.Join(store.GetDataDir(), "quota.json")); err != nil {
+		logger.Warn("Traffic quotas disabled", "error", err)
+	} else {
+		quotas.SetRuleExists(func(ruleID string) bool {
+			_, err := store.GetRule(ruleID)
+			return err == nil
+		})
+		eng.SetQuotas(quotas)
+	}
+	if logs, err := engine.NewLogStore(filepath.Join(store.GetDataDir(), "logs")); err != nil {
+		logger.Warn("Log persistence disabled", "error", err)
+	} else {
+		eng.SetLogStore(logs)
+		eng.SetLogRetention(store.GetConfig().GetLogMaxSize(), store.GetConfig().GetLogMaxAge())
+	}
+	notifier.Configure(store.GetConfig().Notifiers)
+	shipper.Configure(store.GetConfig().LogOutputs)
 
 	return &Daemon{
 		engine:    eng,
 		store:     store,
 		ipcServer: ipcServer,
+		scheduler: sched,
+		deps:      depMgr,
+		notifier:  notifier,
+		shipper:   shipper,
 		logger:    logger,
 	}, nil
 }
 
 // run starts the daemon services
 func (d *Daemon) run() {
-	d.logger.Println("[Daemon] Starting...")
+	d.logger.Info("Starting")
 
 	// Start IPC server - this is important but not fatal
 	// If it fails, the GUI won't be able to communicate with the service,
 	// but the port forwarding rules can still work
 	if err := d.ipcServer.Start(); err != nil {
-		d.logger.Printf("[Daemon] Warning: Failed to start IPC server: %v", err)
-		d.logger.Println("[Daemon] Service will continue in degraded mode (no GUI communication)")
+		d.logger.Error("Failed to start IPC server, continuing without GUI communication", "error", err)
 		// Continue running - don't return
 	}
 
 	// Load chains into engine
 	chains := d.store.GetChains()
 	d.engine.SetChains(chains)
-
-	// Start enabled rules
-	rules := d.store.GetRules()
-	startedCount := 0
-	failedCount := 0
-	for _, rule := range rules {
+	d.engine.SetDrainTimeout(d.store.GetConfig().GetDrainTimeout())
+
+	// Keep the stored status in sync with failures and automatic restarts
+	d.engine.SetStatusChangeCallback(func(ruleID string, status string, errorMsg string) {
+		d.logger.Info("Rule status changed", "rule", ruleID, "status", status, "error", errorMsg)
+		d.store.UpdateRuleStatus(ruleID, models.RuleStatus(status), errorMsg)
+		d.deps.OnStatusChange(ruleID, status)
+	})
+
+	// Start enabled rules, dependencies first
+	var toStart []*models.Rule
+	for _, rule := range d.store.GetRules() {
+		if rule.Enabled && !d.scheduler.ShouldRun(rule) {
+			d.logger.Info("Rule is outside its schedule, expired or over its quota", "rule", rule.ID, "name", rule.Name)
+			d.store.UpdateRuleStatus(rule.ID, d.scheduler.IdleStatus(rule), "")
+			continue
+		}
 		if rule.Enabled {
-			if err := d.engine.StartRule(rule); err != nil {
-				d.logger.Printf("[Daemon] Failed to start rule %s: %v", rule.Name, err)
-				d.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
-				failedCount++
-			} else {
-				d.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
-				d.logger.Printf("[Daemon] Started rule: %s", rule.Name)
-				startedCount++
-			}
+			toStart = append(toStart, rule)
 		}
 	}
+	report := d.deps.Boot(toStart, d.store.GetConfig().GetBootParallel())
+	for _, r := range report.Results {
+		if r.Outcome == models.BootStarted {
+			d.logger.Info("Started rule", "rule", r.RuleID, "name", r.RuleName, "level", r.Level, "durationMs", r.Duration)
+		} else {
+			d.logger.Warn("Rule not started", "rule", r.RuleID, "name", r.RuleName, "outcome", r.Outcome, "error", r.Error)
+		}
+	}
+
+	// Start and stop scheduled rules as their windows open and close
+	d.scheduler.Start()
 
-	d.logger.Printf("[Daemon] Started successfully (%d rules started, %d failed)", startedCount, failedCount)
+	d.logger.Info("Started", "started", report.Started, "failed", report.Failed,
+		"skipped", report.Skipped, "durationMs", report.Duration)
+	d.engine.Emit(models.Event{
+		Type:    models.EventDaemon,
+		Status:  "started",
+		Message: fmt.Sprintf("%d rules started, %d failed, %d skipped", report.Started, report.Failed, report.Skipped),
+	})
 }
 
 // stop stops all daemon services
 func (d *Daemon) stop() error {
-	d.logger.Println("[Daemon] Stopping...")
+	d.logger.Info("Stopping")
+
+	// Stop the scheduler first so it does not restart rules
+	d.scheduler.Stop()
 
 	// Stop all rules
 	d.engine.StopAll()
@@ -127,7 +217,12 @@ func (d *Daemon) stop() error {
 	// Stop IPC server
 	d.ipcServer.Stop()
 
-	d.logger.Println("[Daemon] Stopped")
+	// Let the last notifications go out
+	d.notifier.Notify(models.Event{Type: models.EventDaemon, Status: "stopped"})
+	d.notifier.Close(notifyCloseTimeout)
+	d.shipper.Close(notifyCloseTimeout)
+
+	d.logger.Info("Stopped")
 	return nil
 }
 
@@ -153,40 +248,39 @@ func (d *Daemon) Run() error {
 func Install() error {
 	// Check for admin privileges on Windows
 	if runtime.GOOS == "windows" && !isAdmin() {
-		return fmt.Errorf("需要管理员权限。请右键点击程序，选择「以管理员身份运行」，或在管理员命令提示符中运行：pfm.exe service install")
+		return i18n.Error("install.adminRequired", nil)
 	}
 
 	// Get executable path
 	execPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
+		return i18n.Wrap(err, "install.executable", nil)
 	}
 
 	// Resolve any symlinks
 	execPath, err = filepath.EvalSymlinks(execPath)
 	if err != nil {
-		return fmt.Errorf("failed to resolve executable path: %w", err)
+		return i18n.Wrap(err, "install.resolveExecutable", nil)
 	}
 
-	log.Printf("[Daemon] Installing service with executable: %s", execPath)
+	logger.Info("Installing service", "executable", execPath)
 
 	// Check if service needs reinstall (installed but pointing to different path)
 	if NeedsReinstall() {
-		log.Printf("[Daemon] Service installed with different path, will reinstall...")
 		oldPath := GetInstalledServicePath()
-		log.Printf("[Daemon] Old path: %s, New path: %s", oldPath, execPath)
+		logger.Info("Service installed with a different path, reinstalling", "old", oldPath, "new", execPath)
 
 		// Stop and uninstall the old service first
 		if IsRunning() {
-			log.Printf("[Daemon] Stopping old service...")
+			logger.Info("Stopping old service")
 			if err := Stop(); err != nil {
-				log.Printf("[Daemon] Warning: failed to stop old service: %v", err)
+				logger.Warn("Failed to stop old service", "error", err)
 			}
 		}
 
-		log.Printf("[Daemon] Uninstalling old service...")
+		logger.Info("Uninstalling old service")
 		if err := Uninstall(); err != nil {
-			log.Printf("[Daemon] Warning: failed to uninstall old service: %v", err)
+			logger.Warn("Failed to uninstall old service", "error", err)
 			// Continue anyway, the install might still work
 		}
 	}
@@ -208,22 +302,22 @@ func Install() error {
 	prg := &program{}
 	s, err := service.New(prg, svcConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create service: %w", err)
+		return i18n.Wrap(err, "install.create", nil)
 	}
 
 	// Check current status
 	status, err := s.Status()
 	if err == nil && status == service.StatusRunning {
-		return fmt.Errorf("服务已在运行中，请先停止服务")
+		return i18n.Error("install.running", nil)
 	}
 
 	// If service exists (but not running), allow re-installation
 	// This handles cases where NeedsReinstall failed to detect path mismatch (e.g. localized sc output)
 	// or user just wants to repair/reinstall.
 	if err == nil && status != service.StatusUnknown {
-		log.Printf("[Daemon] Service exists (Status: %v), uninstalling before install...", status)
+		logger.Info("Service exists, uninstalling before install", "status", status)
 		if err := s.Uninstall(); err != nil {
-			log.Printf("[Daemon] Warning: failed to uninstall existing service: %v", err)
+			logger.Warn("Failed to uninstall existing service", "error", err)
 		} else {
 			// Wait a bit for Windows to release the service handle
 			// time.Sleep(1 * time.Second) // "time" package needed
@@ -232,12 +326,12 @@ func Install() error {
 
 	if err := s.Install(); err != nil {
 		if runtime.GOOS == "windows" {
-			return fmt.Errorf("安装服务失败: %w\n\n请确保：\n1. 以管理员身份运行程序\n2. 将程序复制到固定目录（如 C:\\Tools\\pfm）\n3. 程序路径: %s", err, execPath)
+			return i18n.Error("install.failedWindows", i18n.Params{"error": err.Error(), "path": execPath})
 		}
-		return fmt.Errorf("failed to install service: %w (executable: %s)", err, execPath)
+		return i18n.Wrap(err, "install.failed", i18n.Params{"path": execPath})
 	}
 
-	log.Printf("[Daemon] Service installed successfully")
+	logger.Info("Service installed")
 	return nil
 }
 
@@ -283,12 +377,12 @@ func installDarwinService(execPath string) error {
 	// Create temp file with plist content
 	tmpFile, err := os.CreateTemp("", "pfm-*.plist")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return i18n.Wrap(err, "install.tempFile", nil)
 	}
 	defer os.Remove(tmpFile.Name())
 
 	if _, err := tmpFile.WriteString(plistContent); err != nil {
-		return fmt.Errorf("failed to write plist: %w", err)
+		return i18n.Wrap(err, "install.plist", nil)
 	}
 	tmpFile.Close()
 
@@ -303,12 +397,12 @@ do shell script "cp '%s' '%s' && launchctl load -w '%s'" with administrator priv
 	if err != nil {
 		outputStr := strings.TrimSpace(string(output))
 		if strings.Contains(outputStr, "User canceled") || strings.Contains(outputStr, "-128") {
-			return fmt.Errorf("用户取消了授权")
+			return i18n.Error("install.cancelled", nil)
 		}
-		return fmt.Errorf("安装服务失败: %s", outputStr)
+		return i18n.Wrap(errors.New(outputStr), "install.installFailed", nil)
 	}
 
-	log.Printf("[Daemon] macOS service installed successfully")
+	logger.Info("macOS service installed")
 	return nil
 }
 
@@ -340,7 +434,7 @@ func uninstallDarwinService() error {
 
 	// Check if plist exists
 	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
-		return fmt.Errorf("服务未安装")
+		return i18n.Error("install.notInstalled", nil)
 	}
 
 	// Use AppleScript to run commands with admin privileges
@@ -353,12 +447,12 @@ do shell script "launchctl unload -w '%s' 2>/dev/null; rm -f '%s'" with administ
 	if err != nil {
 		outputStr := strings.TrimSpace(string(output))
 		if strings.Contains(outputStr, "User canceled") || strings.Contains(outputStr, "-128") {
-			return fmt.Errorf("用户取消了授权")
+			return i18n.Error("install.cancelled", nil)
 		}
-		return fmt.Errorf("卸载服务失败: %s", outputStr)
+		return i18n.Wrap(errors.New(outputStr), "install.uninstallFailed", nil)
 	}
 
-	log.Printf("[Daemon] macOS service uninstalled successfully")
+	logger.Info("macOS service uninstalled")
 	return nil
 }
 
@@ -387,13 +481,13 @@ func startDarwinService() error {
 	plistPath := fmt.Sprintf("/Library/LaunchDaemons/%s.plist", ServiceName)
 
 	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
-		return fmt.Errorf("服务未安装")
+		return i18n.Error("install.notInstalled", nil)
 	}
 
 	// Check if service is already running
 	status, _ := darwinServiceStatus()
 	if status == service.StatusRunning {
-		log.Printf("[Daemon] Service is already running, skipping start")
+		logger.Info("Service is already running, skipping start")
 		return nil
 	}
 
@@ -401,10 +495,10 @@ func startDarwinService() error {
 	// kickstart -k will restart a running service, or start a stopped one
 	kickstartCmd := exec.Command("launchctl", "kickstart", "-k", fmt.Sprintf("system/%s", ServiceName))
 	if output, err := kickstartCmd.CombinedOutput(); err == nil {
-		log.Printf("[Daemon] Service started via kickstart")
+		logger.Info("Service started via kickstart")
 		return nil
 	} else {
-		log.Printf("[Daemon] kickstart failed: %s, trying load with admin privileges", strings.TrimSpace(string(output)))
+		logger.Info("kickstart failed, trying load with admin privileges", "output", strings.TrimSpace(string(output)))
 	}
 
 	// Fallback to load with admin privileges (required if service was unloaded)
@@ -417,9 +511,9 @@ do shell script "launchctl load -w '%s'" with administrator privileges
 	if err != nil {
 		outputStr := strings.TrimSpace(string(output))
 		if strings.Contains(outputStr, "User canceled") || strings.Contains(outputStr, "-128") {
-			return fmt.Errorf("用户取消了授权")
+			return i18n.Error("install.cancelled", nil)
 		}
-		return fmt.Errorf("启动服务失败: %s", outputStr)
+		return i18n.Wrap(errors.New(outputStr), "install.startFailed", nil)
 	}
 	return nil
 }
@@ -450,13 +544,13 @@ func stopDarwinService() error {
 
 	// Check if plist exists
 	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
-		return fmt.Errorf("服务未安装")
+		return i18n.Error("install.notInstalled", nil)
 	}
 
 	// Check if service is already stopped
 	status, _ := darwinServiceStatus()
 	if status == service.StatusStopped {
-		log.Printf("[Daemon] Service is already stopped, skipping stop")
+		logger.Info("Service is already stopped, skipping stop")
 		return nil
 	}
 
@@ -471,9 +565,9 @@ do shell script "launchctl unload '%s'" with administrator privileges
 	if err != nil {
 		outputStr := strings.TrimSpace(string(output))
 		if strings.Contains(outputStr, "User canceled") || strings.Contains(outputStr, "-128") {
-			return fmt.Errorf("用户取消了授权")
+			return i18n.Error("install.cancelled", nil)
 		}
-		return fmt.Errorf("停止服务失败: %s", outputStr)
+		return i18n.Wrap(errors.New(outputStr), "install.stopFailed", nil)
 	}
 	return nil
 }
@@ -0,0 +1,224 @@
+// Package deps starts rules in dependency order and keeps dependent rules
+// in step with the rules they depend on
+package deps
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/i18n"
+	"pfm/internal/logging"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+// Manager starts rules after their dependencies, stops the dependents of a
+// failed rule and starts them again once it recovers
+type Manager struct {
+	engine *engine.Engine
+	store  *storage.Store
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	held   map[string]string // rule stopped or not started -> dependency it waits for
+	report *models.BootReport
+}
+
+// New creates a dependency manager
+func New(e *engine.Engine, s *storage.Store) *Manager {
+	return &Manager{
+		engine: e,
+		store:  s,
+		logger: logging.For("deps"),
+		held:   make(map[string]string),
+	}
+}
+
+// SetLogger sets the logger for the manager
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// Boot starts rules level by level in dependency order, the rules of a level
+// at most parallel at a time. A rule whose dependency is not running is
+// skipped and started once the dependency comes up.
+func (m *Manager) Boot(rules []*models.Rule, parallel int) *models.BootReport {
+	if parallel < 1 {
+		parallel = 1
+	}
+	report := &models.BootReport{StartTime: time.Now(), Results: []models.BootResult{}}
+	levels, blocked := models.DependencyLevels(rules)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for level, group := range levels {
+		results := make([]models.BootResult, len(group))
+		sem := make(chan struct{}, parallel)
+		var wg sync.WaitGroup
+		for i, rule := range group {
+			results[i] = models.BootResult{RuleID: rule.ID, RuleName: rule.Name, Level: level}
+			if dep := m.missingDependency(rule); dep != "" {
+				m.hold(rule, dep)
+				results[i].Outcome = models.BootSkipped
+				results[i].Error = fmt.Sprintf("dependency %s is not running", m.ruleName(dep))
+				continue
+			}
+
+			wg.Add(1)
+			go func(rule *models.Rule, result *models.BootResult) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				start := time.Now()
+				err := m.engine.StartRule(rule)
+				result.Duration = time.Since(start).Milliseconds()
+				if err != nil {
+					result.Outcome = models.BootFailed
+					result.Error = err.Error()
+					m.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
+					return
+				}
+				result.Outcome = models.BootStarted
+				m.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+			}(rule, &results[i])
+		}
+		wg.Wait()
+		report.Results = append(report.Results, results...)
+	}
+
+	for _, rule := range blocked {
+		msg := "dependency cycle"
+		if cycle := models.DependencyCycle(rules, rule.ID); cycle == nil {
+			msg = "depends on a dependency cycle"
+		}
+		m.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, msg)
+		report.Results = append(report.Results, models.BootResult{
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			Level:    len(levels),
+			Outcome:  models.BootFailed,
+			Error:    msg,
+		})
+	}
+
+	for _, r := range report.Results {
+		switch r.Outcome {
+		case models.BootStarted:
+			report.Started++
+		case models.BootFailed:
+			report.Failed++
+		case models.BootSkipped:
+			report.Skipped++
+		}
+	}
+	report.Duration = time.Since(report.StartTime).Milliseconds()
+	m.report = report
+	return report
+}
+
+// Report returns the report of the last boot, or nil
+func (m *Manager) Report() *models.BootReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.report
+}
+
+// OnStatusChange follows a rule's status: when it fails its running
+// dependents are stopped, when it runs again they are started
+func (m *Manager) OnStatusChange(ruleID, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch models.RuleStatus(status) {
+	case models.RuleStatusError, models.RuleStatusBackoff:
+		m.stopDependents(ruleID)
+	case models.RuleStatusRunning:
+		m.startDependents(ruleID)
+	}
+}
+
+// Forget drops a rule that no longer waits for its dependencies, e.g.
+// because it was stopped or started by hand. It reports whether the rule
+// was waiting.
+func (m *Manager) Forget(ruleID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.held[ruleID]
+	delete(m.held, ruleID)
+	return ok
+}
+
+// stopDependents stops the running rules depending on a failed rule, and
+// theirs in turn. Called with m.mu held.
+func (m *Manager) stopDependents(ruleID string) {
+	name := m.ruleName(ruleID)
+	for _, rule := range models.Dependents(m.store.GetRules(), ruleID) {
+		if !m.engine.IsActive(rule.ID) {
+			continue
+		}
+		if err := m.engine.StopRule(rule.ID); err != nil {
+			m.logger.Error("Failed to stop rule", "rule", rule.ID, "name", rule.Name, "error", err)
+			continue
+		}
+		m.engine.GetLogManager().Warn(rule.ID, rule.Name, "deps.failed", i18n.Params{"dependency": name})
+		m.hold(rule, ruleID)
+		m.stopDependents(rule.ID)
+	}
+}
+
+// startDependents starts the rules held for a rule that runs again, once
+// all their dependencies run. Called with m.mu held.
+func (m *Manager) startDependents(ruleID string) {
+	for _, rule := range models.Dependents(m.store.GetRules(), ruleID) {
+		if _, ok := m.held[rule.ID]; !ok || !rule.Enabled || m.engine.IsActive(rule.ID) {
+			continue
+		}
+		if now := time.Now(); !rule.InSchedule(now) || rule.IsExpired(now) {
+			delete(m.held, rule.ID) // the scheduler takes care of it
+			continue
+		}
+		if dep := m.missingDependency(rule); dep != "" {
+			m.held[rule.ID] = dep
+			continue
+		}
+		delete(m.held, rule.ID)
+
+		m.engine.GetLogManager().Info(rule.ID, rule.Name, "deps.recovered", i18n.Params{"dependency": m.ruleName(ruleID)})
+		if err := m.engine.StartRule(rule); err != nil {
+			m.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
+			continue
+		}
+		m.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+		m.startDependents(rule.ID)
+	}
+}
+
+// hold records that a rule waits for a dependency. Called with m.mu held.
+func (m *Manager) hold(rule *models.Rule, dep string) {
+	m.held[rule.ID] = dep
+	m.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, fmt.Sprintf("dependency %s is not running", m.ruleName(dep)))
+}
+
+// missingDependency returns the first dependency of a rule that is not
+// running, or ""
+func (m *Manager) missingDependency(rule *models.Rule) string {
+	for _, dep := range rule.DependsOn {
+		if !m.engine.IsRunning(dep) {
+			return dep
+		}
+	}
+	return ""
+}
+
+// ruleName returns the name of a rule for messages, or its ID if unknown
+func (m *Manager) ruleName(id string) string {
+	if rule, err := m.store.GetRule(id); err == nil {
+		return rule.Name
+	}
+	return id
+}
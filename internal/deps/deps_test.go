@@ -0,0 +1,103 @@
+package deps
+
+import (
+	"net"
+	"testing"
+
+	"pfm/internal/engine"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+func TestBootAndCascade(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	e := engine.New()
+	defer e.StopAll()
+	m := New(e, store)
+
+	// Hold the port of the tunnel so it fails to start
+	blocker, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	newRule := func(name string, port int, dependsOn ...string) *models.Rule {
+		if port == 0 {
+			l, err := net.Listen("tcp", ":0")
+			if err != nil {
+				t.Fatalf("listen: %v", err)
+			}
+			port = l.Addr().(*net.TCPAddr).Port
+			l.Close()
+		}
+		rule := models.NewRule(name, models.RuleTypeForward)
+		rule.Protocol = models.ProtocolTCP
+		rule.LocalPort = port
+		rule.TargetHost = "127.0.0.1"
+		rule.TargetPort = 9
+		rule.Enabled = true
+		rule.DependsOn = dependsOn
+		if err := store.CreateRule(rule); err != nil {
+			t.Fatalf("CreateRule() error = %v", err)
+		}
+		return rule
+	}
+	tunnel := newRule("tunnel", blocker.Addr().(*net.TCPAddr).Port)
+	web := newRule("web", 0, tunnel.ID)
+	other := newRule("other", 0)
+
+	report := m.Boot([]*models.Rule{web, tunnel, other}, 2)
+	if report.Started != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("Boot() = %+v", report)
+	}
+	if r := report.Results[len(report.Results)-1]; r.RuleID != web.ID || r.Level != 1 || r.Outcome != models.BootSkipped {
+		t.Errorf("web result = %+v", r)
+	}
+	if !e.IsRunning(other.ID) || e.IsRunning(web.ID) {
+		t.Fatal("only the independent rule should run")
+	}
+
+	// The dependent starts once the tunnel comes up
+	blocker.Close()
+	if err := e.StartRule(tunnel); err != nil {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+	m.OnStatusChange(tunnel.ID, string(models.RuleStatusRunning))
+	if !e.IsRunning(web.ID) {
+		t.Fatal("dependent not started after its dependency recovered")
+	}
+
+	// And stops when the tunnel fails
+	m.OnStatusChange(tunnel.ID, string(models.RuleStatusError))
+	if e.IsActive(web.ID) {
+		t.Fatal("dependent still running after its dependency failed")
+	}
+	if r, _ := store.GetRule(web.ID); r.Status != models.RuleStatusError {
+		t.Errorf("dependent status = %s, want error", r.Status)
+	}
+
+	// Stopping it by hand means it stays stopped
+	if !m.Forget(web.ID) {
+		t.Error("dependent should be waiting for its dependency")
+	}
+	m.OnStatusChange(tunnel.ID, string(models.RuleStatusRunning))
+	if e.IsActive(web.ID) {
+		t.Error("forgotten dependent was started")
+	}
+}
+
+func TestBootCycle(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	a := &models.Rule{ID: "a", Name: "a", DependsOn: []string{"b"}}
+	b := &models.Rule{ID: "b", Name: "b", DependsOn: []string{"a"}}
+
+	report := New(engine.New(), store).Boot([]*models.Rule{a, b}, 1)
+	if report.Failed != 2 || report.Results[0].Error != "dependency cycle" {
+		t.Errorf("Boot() = %+v", report)
+	}
+}
@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"pfm/internal/models"
+)
+
+const (
+	// accessLogName is the name of the current access log file, rotated
+	// files get a numeric suffix (access.log.1 is the most recent)
+	accessLogName = "access.log"
+
+	defaultAccessLogMaxSize  = 10 * 1024 * 1024
+	defaultAccessLogMaxFiles = 5
+
+	// accessReadBlock is how much of a file is read at once by Query, and
+	// maxAccessLine the length past which a line is dropped as malformed
+	accessReadBlock = 64 * 1024
+	maxAccessLine   = 1024 * 1024
+)
+
+// AccessLog writes connection records to rotating JSON lines files
+type AccessLog struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewAccessLog creates an access log in dir. The directory is created on
+// the first write.
+func NewAccessLog(dir string) *AccessLog {
+	return &AccessLog{
+		dir:      dir,
+		maxSize:  defaultAccessLogMaxSize,
+		maxFiles: defaultAccessLogMaxFiles,
+	}
+}
+
+// Write appends a record to the log, rotating the file when it is full
+func (l *AccessLog) Write(rec *models.ConnectionRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	return err
+}
+
+// open opens the current file for appending
+func (l *AccessLog) open() error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create access log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files by one, dropping the oldest, and starts
+// a new current file
+func (l *AccessLog) rotate() error {
+	l.file.Close()
+	l.file = nil
+
+	os.Remove(l.path(l.maxFiles - 1))
+	for i := l.maxFiles - 2; i >= 0; i-- {
+		if err := os.Rename(l.path(i), l.path(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate access log: %w", err)
+		}
+	}
+	return l.open()
+}
+
+// path returns the path of the i-th file, 0 being the current one
+func (l *AccessLog) path(i int) string {
+	if i == 0 {
+		return filepath.Join(l.dir, accessLogName)
+	}
+	return filepath.Join(l.dir, fmt.Sprintf("%s.%d", accessLogName, i))
+}
+
+// Query returns the most recent records of a rule, newest first. An empty
+// rule ID matches all rules, limit <= 0 returns everything.
+func (l *AccessLog) Query(ruleID string, limit int) ([]models.ConnectionRecord, error) {
+	// Read without holding the lock so connections are not held up. A
+	// record read twice because of a rotation meanwhile is skipped by its ID.
+	l.mu.Lock()
+	paths := make([]string, l.maxFiles)
+	for i := range paths {
+		paths[i] = l.path(i)
+	}
+	l.mu.Unlock()
+
+	result := []models.ConnectionRecord{}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		err := readAccessFile(path, ruleID, func(rec *models.ConnectionRecord) bool {
+			if rec.ID != "" {
+				if seen[rec.ID] {
+					return true
+				}
+				seen[rec.ID] = true
+			}
+			result = append(result, *rec)
+			return limit <= 0 || len(result) < limit
+		})
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Close closes the current file
+func (l *AccessLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readAccessFile calls fn with the records of a rule from one log file,
+// newest first, until it returns false. The file is read backwards by
+// blocks, so recent records are found without reading all of it. Malformed
+// lines (e.g. a partial write) are skipped.
+func readAccessFile(path, ruleID string, fn func(rec *models.ConnectionRecord) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// rest is the end of a line that starts in the previous block
+	var rest []byte
+	for end := info.Size(); end > 0; {
+		start := max(end-accessReadBlock, 0)
+		block := make([]byte, end-start, int(end-start)+len(rest))
+		if _, err := f.ReadAt(block, start); err != nil {
+			return err
+		}
+		block = append(block, rest...)
+		end = start
+
+		lines := bytes.Split(block, []byte{'\n'})
+		first := 0
+		rest = nil
+		if start > 0 {
+			rest, first = lines[0], 1
+			if len(rest) > maxAccessLine {
+				rest = nil
+			}
+		}
+		for i := len(lines) - 1; i >= first; i-- {
+			var rec models.ConnectionRecord
+			if len(lines[i]) == 0 || json.Unmarshal(lines[i], &rec) != nil {
+				continue
+			}
+			if ruleID != "" && rec.RuleID != ruleID {
+				continue
+			}
+			if !fn(&rec) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
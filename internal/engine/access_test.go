@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"pfm/internal/models"
+)
+
+func TestAccessLogRotateAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	l := NewAccessLog(dir)
+	l.maxSize = 512
+	l.maxFiles = 3
+	defer l.Close()
+
+	for i := 0; i < 30; i++ {
+		ruleID := "rule-a"
+		if i%2 == 1 {
+			ruleID = "rule-b"
+		}
+		rec := &models.ConnectionRecord{
+			ID:         fmt.Sprintf("conn-%d", i),
+			RuleID:     ruleID,
+			Network:    "tcp",
+			ClientAddr: "127.0.0.1:50000",
+			Target:     "127.0.0.1:80",
+		}
+		if err := l.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if _, err := os.Stat(l.path(3)); !os.IsNotExist(err) {
+		t.Errorf("expected at most %d files, found %s", l.maxFiles, l.path(3))
+	}
+	if _, err := os.Stat(l.path(2)); err != nil {
+		t.Errorf("expected rotated file %s: %v", l.path(2), err)
+	}
+
+	records, err := l.Query("rule-b", 3)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	want := []string{"conn-29", "conn-27", "conn-25"}
+	if len(records) != len(want) {
+		t.Fatalf("Query() returned %d records, want %d", len(records), len(want))
+	}
+	for i, id := range want {
+		if records[i].ID != id || records[i].RuleID != "rule-b" {
+			t.Errorf("record %d = %s (%s), want %s", i, records[i].ID, records[i].RuleID, id)
+		}
+	}
+
+	// Older records are lost with the dropped file, the rest stays ordered
+	all, err := l.Query("", 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(all) == 0 || len(all) >= 30 || all[0].ID != "conn-29" {
+		t.Errorf("unexpected records after rotation: %d, first %v", len(all), all)
+	}
+}
+
+func TestAccessLogQueryBackwards(t *testing.T) {
+	l := NewAccessLog(t.TempDir())
+	defer l.Close()
+
+	// Several read blocks, lines crossing their boundaries
+	const count = 2000
+	for i := 0; i < count; i++ {
+		rec := &models.ConnectionRecord{ID: fmt.Sprintf("conn-%d", i), RuleID: "rule-a", Network: "tcp", ClientAddr: "127.0.0.1:50000"}
+		if err := l.Write(rec); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if info, _ := os.Stat(l.path(0)); info.Size() < 2*accessReadBlock {
+		t.Fatalf("access log of %d bytes, too small for the test", info.Size())
+	}
+
+	all, err := l.Query("rule-a", 0)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(all) != count {
+		t.Fatalf("Query() returned %d records, want %d", len(all), count)
+	}
+	for i, rec := range all {
+		if want := fmt.Sprintf("conn-%d", count-1-i); rec.ID != want {
+			t.Fatalf("record %d = %s, want %s", i, rec.ID, want)
+		}
+	}
+
+	if recent, err := l.Query("", 2); err != nil || len(recent) != 2 || recent[0].ID != "conn-1999" {
+		t.Errorf("Query() with a limit = %v, %v", recent, err)
+	}
+}
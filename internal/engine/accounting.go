@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pfm/internal/models"
+)
+
+const (
+	// accountingSaveInterval is how often changed totals are written out
+	accountingSaveInterval = time.Minute
+
+	// accountingDays is how long daily totals are kept, monthly totals
+	// are kept forever
+	accountingDays = 400
+)
+
+// Accounting keeps the cumulative traffic of every rule per day and per
+// month in a JSON file, across rule restarts and daemon restarts
+type Accounting struct {
+	mu     sync.Mutex
+	path   string
+	days   map[string]*models.TrafficUsage // period|ruleID -> usage
+	months map[string]*models.TrafficUsage
+	last   map[string]*models.RuleStats // counters at the previous update
+	dirty  bool
+	saved  time.Time
+}
+
+// accountingFile is the on-disk format of the totals
+type accountingFile struct {
+	Days   []*models.TrafficUsage `json:"days"`
+	Months []*models.TrafficUsage `json:"months"`
+}
+
+// NewAccounting creates the accounting stored in path, loading the totals
+// saved before
+func NewAccounting(path string) (*Accounting, error) {
+	a := &Accounting{
+		path:   path,
+		days:   make(map[string]*models.TrafficUsage),
+		months: make(map[string]*models.TrafficUsage),
+		last:   make(map[string]*models.RuleStats),
+		saved:  time.Now(),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file accountingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, u := range file.Days {
+		a.days[u.Period+"|"+u.RuleID] = u
+	}
+	for _, u := range file.Months {
+		a.months[u.Period+"|"+u.RuleID] = u
+	}
+	return a, nil
+}
+
+// Update adds the traffic of a running rule since the previous update, from
+// its cumulative counters
+func (a *Accounting) Update(ruleID, ruleName string, now time.Time, stats *models.RuleStats) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	last := a.last[ruleID]
+	a.last[ruleID] = stats
+	if last == nil {
+		last = &models.RuleStats{}
+	}
+	in := counterDelta(stats.BytesIn, last.BytesIn)
+	out := counterDelta(stats.BytesOut, last.BytesOut)
+	conns := counterDelta(stats.Connections, last.Connections)
+	if in == 0 && out == 0 && conns == 0 {
+		return
+	}
+
+	now = now.Local()
+	for _, p := range []struct {
+		totals map[string]*models.TrafficUsage
+		period string
+	}{
+		{a.days, now.Format(models.AccountingDay.Layout())},
+		{a.months, now.Format(models.AccountingMonth.Layout())},
+	} {
+		key := p.period + "|" + ruleID
+		u := p.totals[key]
+		if u == nil {
+			u = &models.TrafficUsage{RuleID: ruleID, Period: p.period}
+			p.totals[key] = u
+		}
+		u.RuleName = ruleName
+		u.BytesIn += in
+		u.BytesOut += out
+		u.Connections += conns
+	}
+	a.dirty = true
+}
+
+// Forget drops the counters of a rule before it is started, its new
+// service counts from zero
+func (a *Accounting) Forget(ruleID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.last, ruleID)
+}
+
+// Query returns the totals selected by q, oldest period first
+func (a *Accounting) Query(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	totals := a.days
+	if q.Period == models.AccountingMonth {
+		totals = a.months
+	}
+	result := []models.TrafficUsage{}
+	for _, u := range totals {
+		if q.RuleID != "" && u.RuleID != q.RuleID {
+			continue
+		}
+		if (q.From != "" && u.Period < q.From) || (q.To != "" && u.Period > q.To) {
+			continue
+		}
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Period != result[j].Period {
+			return result[i].Period < result[j].Period
+		}
+		return result[i].RuleName < result[j].RuleName
+	})
+	return result, nil
+}
+
+// saveIfDue writes the totals when they changed and the last save is older
+// than accountingSaveInterval
+func (a *Accounting) saveIfDue(now time.Time) error {
+	a.mu.Lock()
+	due := a.dirty && now.Sub(a.saved) >= accountingSaveInterval
+	a.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return a.Save()
+}
+
+// Save writes the totals, dropping daily totals older than accountingDays
+func (a *Accounting) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oldest := time.Now().AddDate(0, 0, -accountingDays).Format(models.AccountingDay.Layout())
+	file := accountingFile{Days: []*models.TrafficUsage{}, Months: []*models.TrafficUsage{}}
+	for key, u := range a.days {
+		if u.Period < oldest {
+			delete(a.days, key)
+			continue
+		}
+		file.Days = append(file.Days, u)
+	}
+	for _, u := range a.months {
+		file.Months = append(file.Months, u)
+	}
+	for _, list := range [][]*models.TrafficUsage{file.Days, file.Months} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Period != list[j].Period {
+				return list[i].Period < list[j].Period
+			}
+			return list[i].RuleID < list[j].RuleID
+		})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	// Write a temporary file first so a crash never leaves half the totals
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write accounting: %w", err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("failed to write accounting: %w", err)
+	}
+	a.dirty = false
+	a.saved = time.Now()
+	return nil
+}
+
+// EncodeAccounting encodes traffic totals as "csv" or "json"
+func EncodeAccounting(usage []models.TrafficUsage, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(usage, "", "  ")
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"period", "rule_id", "rule_name", "bytes_in", "bytes_out", "connections"})
+		for _, u := range usage {
+			w.Write([]string{
+				u.Period, u.RuleID, u.RuleName,
+				strconv.FormatInt(u.BytesIn, 10),
+				strconv.FormatInt(u.BytesOut, 10),
+				strconv.FormatInt(u.Connections, 10),
+			})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+	return nil, fmt.Errorf("unknown export format %q, want csv or json", format)
+}
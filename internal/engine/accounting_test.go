@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+func TestAccounting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounting.json")
+	a, err := NewAccounting(path)
+	if err != nil {
+		t.Fatalf("NewAccounting() error = %v", err)
+	}
+
+	// The last minute of the previous month and the first of this one
+	now := time.Now()
+	day2 := time.Date(now.Year(), now.Month(), 1, 0, 1, 0, 0, time.Local)
+	day1 := day2.Add(-2 * time.Minute)
+	period1, period2 := day1.Format("2006-01-02"), day2.Format("2006-01-02")
+	a.Update("r1", "web", day1, &models.RuleStats{BytesIn: 100, BytesOut: 10, Connections: 1})
+	a.Update("r1", "web", day1, &models.RuleStats{BytesIn: 300, BytesOut: 30, Connections: 2})
+	// The rule is rebuilt, its counters start over
+	a.Forget("r1")
+	a.Update("r1", "web", day2, &models.RuleStats{BytesIn: 50, BytesOut: 5, Connections: 1})
+	a.Update("r2", "db", day2, &models.RuleStats{BytesIn: 7})
+
+	if err := a.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	// Totals survive a daemon restart
+	a, err = NewAccounting(path)
+	if err != nil {
+		t.Fatalf("NewAccounting() error = %v", err)
+	}
+
+	days, err := a.Query(models.AccountingQuery{RuleID: "r1"})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	want := []models.TrafficUsage{
+		{RuleID: "r1", RuleName: "web", Period: period1, BytesIn: 300, BytesOut: 30, Connections: 2},
+		{RuleID: "r1", RuleName: "web", Period: period2, BytesIn: 50, BytesOut: 5, Connections: 1},
+	}
+	if len(days) != len(want) || days[0] != want[0] || days[1] != want[1] {
+		t.Errorf("Query(day) = %+v, want %+v", days, want)
+	}
+
+	months, _ := a.Query(models.AccountingQuery{Period: models.AccountingMonth, From: day2.Format("2006-01")})
+	if len(months) != 2 || months[0].RuleName != "db" || months[1].BytesIn != 50 {
+		t.Errorf("Query(month) = %+v", months)
+	}
+	if _, err := a.Query(models.AccountingQuery{Period: models.AccountingMonth, From: period2}); err == nil {
+		t.Error("Query() accepted a day as month bound")
+	}
+
+	data, err := EncodeAccounting(days, "csv")
+	if err != nil {
+		t.Fatalf("EncodeAccounting() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[1] != period1+",r1,web,300,30,2" {
+		t.Errorf("csv = %q", data)
+	}
+}
@@ -2,7 +2,6 @@ package engine
 
 import (
 	"fmt"
-	"log"
 
 	"pfm/internal/models"
 
@@ -10,9 +9,18 @@ import (
 	"github.com/go-gost/core/service"
 	chain_parser "github.com/go-gost/x/config/parsing/chain"
 	service_parser "github.com/go-gost/x/config/parsing/service"
+	xlogger "github.com/go-gost/x/logger"
 	"github.com/go-gost/x/registry"
 )
 
+// setDefaultLogger installs the logger gost components fall back to, the
+// service parser requires one
+func setDefaultLogger() {
+	if logger.Default() == nil {
+		logger.SetDefault(xlogger.NewLogger(xlogger.LevelOption(logger.WarnLevel)))
+	}
+}
+
 // BuildService builds a GOST service from a Rule and Chains
 func BuildService(rule *models.Rule, chains []*models.Chain) (service.Service, error) {
 	// Convert rule to gost config
@@ -27,22 +35,19 @@ func BuildService(rule *models.Rule, chains []*models.Chain) (service.Service, e
 	}
 
 	svcCfg := cfg.Services[0]
-	log.Printf("[Builder] Building service for rule %s: Addr=%s, Handler=%s, Listener=%s",
-		rule.ID, svcCfg.Addr, svcCfg.Handler.Type, svcCfg.Listener.Type)
+	defaultLogger.Debug("Building service", "rule", rule.ID, "addr", svcCfg.Addr,
+		"handler", svcCfg.Handler.Type, "listener", svcCfg.Listener.Type)
 
 	// Parse and register chain if present
 	if len(cfg.Chains) > 0 {
 		for _, chainCfg := range cfg.Chains {
-			log.Printf("[Builder] Parsing chain: %s", chainCfg.Name)
 			chain, err := chain_parser.ParseChain(chainCfg, logger.Default())
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse chain %s: %w", chainCfg.Name, err)
 			}
 			// Register the chain so service_parser can resolve it
 			if err := registry.ChainRegistry().Register(chainCfg.Name, chain); err != nil {
-				log.Printf("[Builder] Chain %s already registered or error: %v", chainCfg.Name, err)
-			} else {
-				log.Printf("[Builder] Chain registered: %s", chainCfg.Name)
+				defaultLogger.Debug("Chain not registered", "rule", rule.ID, "chain", chainCfg.Name, "error", err)
 			}
 		}
 	}
@@ -62,6 +67,6 @@ func BuildService(rule *models.Rule, chains []*models.Chain) (service.Service, e
 		return nil, fmt.Errorf("failed to register service: %w", err)
 	}
 
-	log.Printf("[Builder] Service created and registered: %s, listening on %s", rule.ID, svc.Addr().String())
+	defaultLogger.Debug("Service registered", "rule", rule.ID, "addr", svc.Addr().String())
 	return svc, nil
 }
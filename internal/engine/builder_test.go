@@ -78,6 +78,20 @@ func TestRuleToGostConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Forward with Missing Chain",
+			rule: &models.Rule{
+				ID:         "rule-5",
+				Name:       "Forward Over Deleted Chain",
+				Type:       models.RuleTypeForward,
+				Protocol:   models.ProtocolTCP,
+				LocalPort:  8082,
+				TargetHost: "1.1.1.1",
+				TargetPort: 80,
+				ChainID:    "chain-deleted",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
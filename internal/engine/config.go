@@ -3,8 +3,11 @@ package engine
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"strconv"
 
+	"pfm/internal/logging"
 	"pfm/internal/models"
 
 	"github.com/go-gost/x/config"
@@ -33,19 +36,22 @@ func RuleToGostConfig(rule *models.Rule, chains []*models.Chain) (*config.Config
 				break
 			}
 		}
-		if chain != nil {
-			chainCfg, err := buildChainConfig(chain)
-			if err != nil {
-				return nil, err
-			}
-			cfg.Chains = append(cfg.Chains, chainCfg)
-			svc.Handler.Chain = chain.ID
+		if chain == nil {
+			// Never bypass a missing chain by connecting directly
+			return nil, fmt.Errorf("%w: %s", models.ErrChainNotFound, rule.ChainID)
+		}
+		chainCfg, err := buildChainConfig(chain)
+		if err != nil {
+			return nil, err
 		}
+		cfg.Chains = append(cfg.Chains, chainCfg)
+		svc.Handler.Chain = chain.ID
 	}
 
-	// Debug: print full config JSON
-	if cfgJSON, err := json.MarshalIndent(cfg, "", "  "); err == nil {
-		log.Printf("[Config] Full gost config for rule %s:\n%s", rule.ID, string(cfgJSON))
+	if logging.Enabled(slog.LevelDebug, rule.ID) {
+		if cfgJSON, err := json.Marshal(cfg); err == nil {
+			defaultLogger.Debug("Built gost config", "rule", rule.ID, "config", string(cfgJSON))
+		}
 	}
 
 	return cfg, nil
@@ -63,10 +69,6 @@ func buildServiceConfig(rule *models.Rule) (*config.ServiceConfig, error) {
 		},
 	}
 
-	// Debug: log the service config
-	log.Printf("[Config] Building service config for rule %s: Observer=%s, enableStats=%v",
-		rule.ID, svc.Observer, svc.Metadata["enableStats"])
-
 	// Configure handler based on rule type
 	switch rule.Type {
 	case models.RuleTypeForward:
@@ -84,10 +86,21 @@ func buildServiceConfig(rule *models.Rule) (*config.ServiceConfig, error) {
 		svc.Handler = buildProxyHandler(rule)
 		svc.Listener = buildListener(rule)
 
+	case models.RuleTypeTransparent:
+		if !transparentSupported {
+			return nil, fmt.Errorf("transparent proxy rules are only supported on Linux")
+		}
+		svc.Handler = buildRedirectHandler(rule)
+		svc.Listener = buildRedirectListener(rule)
+
 	default:
 		return nil, fmt.Errorf("unsupported rule type: %s", rule.Type)
 	}
 
+	// Connections are tracked (and mirrored) by our wrapper around the handler
+	wrapHandler(svc.Handler)
+	buildMirror(rule, svc.Handler.Metadata)
+
 	// Add authentication if configured
 	if rule.Auth != nil && rule.Auth.Username != "" {
 		svc.Handler.Auth = &config.AuthConfig{
@@ -147,6 +160,34 @@ func buildProxyHandler(rule *models.Rule) *config.HandlerConfig {
 	}
 }
 
+// buildRedirectHandler creates a handler that recovers the original destination
+// of a redirected connection and dials it through the rule's chain
+func buildRedirectHandler(rule *models.Rule) *config.HandlerConfig {
+	if rule.Protocol == models.ProtocolUDP {
+		return &config.HandlerConfig{Type: "redu"}
+	}
+	return &config.HandlerConfig{
+		Type: "red",
+		Metadata: map[string]any{
+			"tproxy": rule.Transparent.GetMode() == models.TransparentModeTProxy,
+		},
+	}
+}
+
+// buildRedirectListener creates a listener for REDIRECT/TPROXY diverted traffic
+func buildRedirectListener(rule *models.Rule) *config.ListenerConfig {
+	if rule.Protocol == models.ProtocolUDP {
+		// UDP can only be intercepted with TPROXY
+		return &config.ListenerConfig{Type: "redu"}
+	}
+	return &config.ListenerConfig{
+		Type: "red",
+		Metadata: map[string]any{
+			"tproxy": rule.Transparent.GetMode() == models.TransparentModeTProxy,
+		},
+	}
+}
+
 // buildListener creates a listener configuration
 func buildListener(rule *models.Rule) *config.ListenerConfig {
 	listenerType := "tcp"
@@ -192,8 +233,9 @@ func buildForwarder(rule *models.Rule) *config.ForwarderConfig {
 		nodes = make([]*config.ForwardNodeConfig, len(rule.Targets))
 		for i, target := range rule.Targets {
 			nodes[i] = &config.ForwardNodeConfig{
-				Name: fmt.Sprintf("target-%d", i),
-				Addr: fmt.Sprintf("%s:%d", target.Host, target.Port),
+				Name:     fmt.Sprintf("target-%d", i),
+				Addr:     net.JoinHostPort(target.Host, strconv.Itoa(target.Port)),
+				Metadata: targetMetadata(target),
 			}
 		}
 	} else {
@@ -204,16 +246,39 @@ func buildForwarder(rule *models.Rule) *config.ForwarderConfig {
 		Nodes: nodes,
 	}
 
-	// Add selector for load balancing if multiple targets
+	// Add selector for load balancing if multiple targets, weighted random
+	// when the targets have different weights
 	if len(nodes) > 1 {
+		strategy := "round"
+		for _, target := range rule.Targets {
+			if target.Weight != rule.Targets[0].Weight {
+				strategy = "rand"
+				break
+			}
+		}
 		forwarder.Selector = &config.SelectorConfig{
-			Strategy: "round",
+			Strategy: strategy,
 		}
 	}
 
 	return forwarder
 }
 
+// targetMetadata returns the node metadata read by the gost selector
+func targetMetadata(target models.Target) map[string]any {
+	md := map[string]any{}
+	if target.Weight > 0 {
+		md["weight"] = target.Weight
+	}
+	if target.Backup {
+		md["backup"] = true
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	return md
+}
+
 // buildChainConfig creates a gost chain configuration
 func buildChainConfig(chain *models.Chain) (*config.ChainConfig, error) {
 	cfg := &config.ChainConfig{
@@ -0,0 +1,527 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pfm/internal/models"
+
+	"github.com/go-gost/core/chain"
+	xctx "github.com/go-gost/x/ctx"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+)
+
+// ruleTracker follows the connections handled by a running rule
+type ruleTracker struct {
+	ruleID  string
+	route   string
+	onOpen  func(rec *models.ConnectionRecord) // called once the target is dialed
+	onClose func(rec *models.ConnectionRecord) // called when the connection is finished
+
+	// onHealth is called when a target goes down or comes back, nil when
+	// the rule does not dial fixed targets
+	onHealth func(target string, up bool, err error)
+
+	mu       sync.RWMutex
+	ruleName string // may change while the rule runs
+	active   map[string]*trackedConn
+	health   map[string]*targetHealth // by target address
+
+	throttle atomic.Pointer[rate.Limiter] // shared by all connections, nil when not throttled
+}
+
+// newRuleTracker creates a tracker with an empty active connection table
+func newRuleTracker(ruleID, ruleName, route string) *ruleTracker {
+	return &ruleTracker{
+		ruleID:   ruleID,
+		ruleName: ruleName,
+		route:    route,
+		active:   make(map[string]*trackedConn),
+		health:   make(map[string]*targetHealth),
+	}
+}
+
+// targetDownAfter is the number of consecutive failed dials after which a
+// target is reported down
+const targetDownAfter = 3
+
+// targetHealth follows the dials to a target
+type targetHealth struct {
+	fails int // consecutive failed dials
+	down  bool
+}
+
+// dialResult updates the health of a target after a dial, reporting the
+// target going down or coming back
+func (t *ruleTracker) dialResult(target string, err error) {
+	if t.onHealth == nil {
+		return
+	}
+
+	t.mu.Lock()
+	h := t.health[target]
+	if h == nil {
+		h = &targetHealth{}
+		t.health[target] = h
+	}
+	changed := false
+	if err != nil {
+		h.fails++
+		if h.fails >= targetDownAfter && !h.down {
+			h.down, changed = true, true
+		}
+	} else {
+		h.fails = 0
+		if h.down {
+			h.down, changed = false, true
+		}
+	}
+	t.mu.Unlock()
+
+	if changed {
+		t.onHealth(target, err == nil, err)
+	}
+}
+
+// trackers maps rule IDs to their trackers. Like the mirror counters they
+// live outside the engine because gost creates the handlers.
+var trackers sync.Map
+
+// setTracker installs the tracker of a rule
+func setTracker(t *ruleTracker) {
+	trackers.Store(t.ruleID, t)
+}
+
+// removeTracker uninstalls the tracker of a rule. Connections still in
+// flight keep reporting to the tracker they started with.
+func removeTracker(ruleID string) {
+	trackers.Delete(ruleID)
+}
+
+// getTracker returns the tracker of a rule, or nil
+func getTracker(ruleID string) *ruleTracker {
+	v, ok := trackers.Load(ruleID)
+	if !ok {
+		return nil
+	}
+	return v.(*ruleTracker)
+}
+
+// trackedConn is the state of one client connection
+type trackedConn struct {
+	tracker  *ruleTracker
+	id       string
+	network  string
+	client   string
+	start    time.Time
+	conn     net.Conn // client connection, closed to kill the connection
+	ctx      context.Context
+	cancel   context.CancelCauseFunc // interrupts a throttled read or write, on kill or close
+	bytesIn  int64
+	bytesOut int64
+	killErr  atomic.Pointer[error] // why the engine closed the connection
+
+	mu     sync.Mutex
+	target string
+	opened bool
+
+	// Throughput over the last sampling period, updated by sample
+	rateIn     int64
+	rateOut    int64
+	lastIn     int64
+	lastOut    int64
+	lastSample time.Time
+}
+
+// throttleBurst is the smallest burst of a throttled rule, in bytes
+const throttleBurst = 32 * 1024
+
+var (
+	// errConnKilled is recorded for connections closed on request
+	errConnKilled = errors.New("closed by administrator")
+	// errDrainTimeout is recorded for connections still open when a drain times out
+	errDrainTimeout = errors.New("closed after drain timeout")
+)
+
+type trackedConnKey struct{}
+
+// track starts following a client connection. The returned connection
+// counts the bytes exchanged with the client.
+func (t *ruleTracker) track(ctx context.Context, conn net.Conn) (context.Context, *trackedConn, net.Conn) {
+	tc := &trackedConn{
+		tracker: t,
+		id:      uuid.New().String(),
+		network: "tcp",
+		start:   time.Now(),
+		conn:    conn,
+	}
+	tc.ctx, tc.cancel = context.WithCancelCause(context.Background())
+	tc.lastSample = tc.start
+	if addr := xctx.SrcAddrFromContext(ctx); addr != nil {
+		tc.client = addr.String()
+	} else if addr := conn.RemoteAddr(); addr != nil {
+		tc.client = addr.String()
+	}
+
+	cc := &countingConn{Conn: conn, tc: tc}
+	if pc, ok := conn.(net.PacketConn); ok {
+		tc.network = "udp"
+		conn = &countingPacketConn{countingConn: cc, pc: pc}
+	} else {
+		conn = cc
+	}
+
+	t.mu.Lock()
+	t.active[tc.id] = tc
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, trackedConnKey{}, tc), tc, conn
+}
+
+// trackedConnFromContext returns the connection tracked in ctx, or nil
+func trackedConnFromContext(ctx context.Context) *trackedConn {
+	tc, _ := ctx.Value(trackedConnKey{}).(*trackedConn)
+	return tc
+}
+
+// dialed records the target of the connection. Only the first successful
+// dial opens the connection, handlers may dial more than once (e.g. HTTP
+// keep-alive through sniffing) and failed attempts still name the target.
+func (c *trackedConn) dialed(target string, err error) {
+	c.mu.Lock()
+	if !c.opened {
+		c.target = target
+	}
+	open := err == nil && !c.opened
+	if open {
+		c.opened = true
+	}
+	c.mu.Unlock()
+
+	if open && c.tracker.onOpen != nil {
+		c.tracker.onOpen(c.record(time.Time{}, nil))
+	}
+}
+
+// finish reports the end of the connection with the error returned by the handler
+func (c *trackedConn) finish(err error) {
+	c.cancel(net.ErrClosed)
+	c.tracker.mu.Lock()
+	delete(c.tracker.active, c.id)
+	c.tracker.mu.Unlock()
+
+	if killErr := c.killErr.Load(); killErr != nil {
+		err = *killErr
+	}
+	if c.tracker.onClose != nil {
+		c.tracker.onClose(c.record(time.Now(), err))
+	}
+}
+
+// record returns the access record of the connection, end is zero while
+// the connection is still open
+func (c *trackedConn) record(end time.Time, err error) *models.ConnectionRecord {
+	c.mu.Lock()
+	target := c.target
+	c.mu.Unlock()
+
+	rec := &models.ConnectionRecord{
+		ID:         c.id,
+		RuleID:     c.tracker.ruleID,
+		RuleName:   c.tracker.name(),
+		Network:    c.network,
+		ClientAddr: c.client,
+		Target:     target,
+		StartTime:  c.start,
+		EndTime:    end,
+		BytesIn:    atomic.LoadInt64(&c.bytesIn),
+		BytesOut:   atomic.LoadInt64(&c.bytesOut),
+	}
+	if !end.IsZero() {
+		rec.DurationMs = end.Sub(c.start).Milliseconds()
+	}
+	if target != "" {
+		rec.Route = c.tracker.route
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
+
+// kill forcibly closes the client connection, the handler then returns
+func (c *trackedConn) kill(reason error) {
+	c.killErr.CompareAndSwap(nil, &reason)
+	c.cancel(net.ErrClosed)
+	c.conn.Close()
+}
+
+// throttle waits until the throttle of the rule lets n more bytes of the
+// connection through. It returns err, else why the wait was interrupted.
+func (c *trackedConn) throttle(n int, err error) error {
+	if werr := c.tracker.wait(c.ctx, n); werr != nil && err == nil {
+		if cause := context.Cause(c.ctx); cause != nil {
+			return cause
+		}
+		return werr
+	}
+	return err
+}
+
+// sample updates the throughput of the connection
+func (c *trackedConn) sample(now time.Time) {
+	in, out := atomic.LoadInt64(&c.bytesIn), atomic.LoadInt64(&c.bytesOut)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elapsed := now.Sub(c.lastSample).Seconds(); elapsed > 0 {
+		atomic.StoreInt64(&c.rateIn, int64(float64(in-c.lastIn)/elapsed))
+		atomic.StoreInt64(&c.rateOut, int64(float64(out-c.lastOut)/elapsed))
+	}
+	c.lastIn, c.lastOut, c.lastSample = in, out, now
+}
+
+// snapshot returns the live state of the connection
+func (c *trackedConn) snapshot(now time.Time) models.ActiveConnection {
+	c.mu.Lock()
+	target := c.target
+	c.mu.Unlock()
+
+	ac := models.ActiveConnection{
+		ID:         c.id,
+		RuleID:     c.tracker.ruleID,
+		Network:    c.network,
+		ClientAddr: c.client,
+		Target:     target,
+		StartTime:  c.start,
+		AgeSeconds: int64(now.Sub(c.start).Seconds()),
+		BytesIn:    atomic.LoadInt64(&c.bytesIn),
+		BytesOut:   atomic.LoadInt64(&c.bytesOut),
+		RateIn:     atomic.LoadInt64(&c.rateIn),
+		RateOut:    atomic.LoadInt64(&c.rateOut),
+	}
+	if target != "" {
+		ac.Route = c.tracker.route
+	}
+	return ac
+}
+
+// setThrottle limits the traffic of all the connections of the rule to
+// bytesPerSec, 0 lifts the limit. It reports whether the limit changed.
+func (t *ruleTracker) setThrottle(bytesPerSec int64) bool {
+	cur := t.throttle.Load()
+	if bytesPerSec <= 0 {
+		return cur != nil && t.throttle.CompareAndSwap(cur, nil)
+	}
+	if cur != nil && cur.Limit() == rate.Limit(bytesPerSec) {
+		return false
+	}
+	// Allow a full read buffer at once so slow rates still move data
+	burst := max(int(bytesPerSec), throttleBurst)
+	t.throttle.Store(rate.NewLimiter(rate.Limit(bytesPerSec), burst))
+	return true
+}
+
+// wait blocks until the throttle of the rule lets n more bytes through,
+// or ctx is canceled
+func (t *ruleTracker) wait(ctx context.Context, n int) error {
+	limiter := t.throttle.Load()
+	for limiter != nil && n > 0 {
+		chunk := min(n, limiter.Burst())
+		if err := limiter.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// connections returns the active connections of the rule, oldest first
+func (t *ruleTracker) connections() []models.ActiveConnection {
+	now := time.Now()
+
+	t.mu.RLock()
+	result := make([]models.ActiveConnection, 0, len(t.active))
+	for _, c := range t.active {
+		result = append(result, c.snapshot(now))
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
+
+// sample updates the throughput of all active connections
+func (t *ruleTracker) sample() {
+	now := time.Now()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, c := range t.active {
+		c.sample(now)
+	}
+}
+
+// kill closes the active connection with the given ID. A unique ID prefix,
+// as shown by the CLI, is accepted as well.
+func (t *ruleTracker) kill(connID string) error {
+	// An empty prefix would match every connection
+	if connID == "" {
+		return models.ErrConnectionNotFound
+	}
+
+	t.mu.RLock()
+	var match *trackedConn
+	if c, ok := t.active[connID]; ok {
+		match = c
+	} else {
+		for id, c := range t.active {
+			if !strings.HasPrefix(id, connID) {
+				continue
+			}
+			if match != nil {
+				t.mu.RUnlock()
+				return fmt.Errorf("connection ID %s is ambiguous", connID)
+			}
+			match = c
+		}
+	}
+	t.mu.RUnlock()
+
+	if match == nil {
+		return models.ErrConnectionNotFound
+	}
+	match.kill(errConnKilled)
+	return nil
+}
+
+// killClient closes all active connections from a client, given as an IP
+// address or a full host:port address. It returns the number of connections closed.
+func (t *ruleTracker) killClient(client string) int {
+	t.mu.RLock()
+	var matches []*trackedConn
+	for _, c := range t.active {
+		host, _, err := net.SplitHostPort(c.client)
+		if err != nil {
+			host = c.client
+		}
+		if c.client == client || host == client {
+			matches = append(matches, c)
+		}
+	}
+	t.mu.RUnlock()
+
+	for _, c := range matches {
+		c.kill(errConnKilled)
+	}
+	return len(matches)
+}
+
+// setName updates the rule name reported in connection records
+func (t *ruleTracker) setName(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ruleName = name
+}
+
+// name returns the rule name reported in connection records
+func (t *ruleTracker) name() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ruleName
+}
+
+// count returns the number of active connections
+func (t *ruleTracker) count() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.active)
+}
+
+// killAll closes all active connections and returns how many were closed
+func (t *ruleTracker) killAll(reason error) int {
+	t.mu.RLock()
+	conns := make([]*trackedConn, 0, len(t.active))
+	for _, c := range t.active {
+		conns = append(conns, c)
+	}
+	t.mu.RUnlock()
+
+	for _, c := range conns {
+		c.kill(reason)
+	}
+	return len(conns)
+}
+
+// countingConn counts the bytes read from and written to the client
+type countingConn struct {
+	net.Conn
+	tc *trackedConn
+}
+
+func (c *countingConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	atomic.AddInt64(&c.tc.bytesIn, int64(n))
+	err = c.tc.throttle(n, err)
+	return
+}
+
+func (c *countingConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	atomic.AddInt64(&c.tc.bytesOut, int64(n))
+	err = c.tc.throttle(n, err)
+	return
+}
+
+func (c *countingConn) Close() error {
+	c.tc.cancel(net.ErrClosed)
+	return c.Conn.Close()
+}
+
+// countingPacketConn keeps the net.PacketConn interface of UDP client connections
+type countingPacketConn struct {
+	*countingConn
+	pc net.PacketConn
+}
+
+func (c *countingPacketConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(b)
+	atomic.AddInt64(&c.tc.bytesIn, int64(n))
+	err = c.tc.throttle(n, err)
+	return
+}
+
+func (c *countingPacketConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	n, err = c.pc.WriteTo(b, addr)
+	atomic.AddInt64(&c.tc.bytesOut, int64(n))
+	err = c.tc.throttle(n, err)
+	return
+}
+
+// trackingRouter reports the address dialed by a handler to the connection
+// tracked in the dial context
+type trackingRouter struct {
+	chain.Router
+}
+
+func (r *trackingRouter) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := r.Router.Dial(ctx, network, address)
+	if tc := trackedConnFromContext(ctx); tc != nil {
+		tc.dialed(address, err)
+		// A client that went away says nothing about the target
+		if ctx.Err() == nil {
+			tc.tracker.dialResult(address, err)
+		}
+	}
+	return conn, err
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+
+	"github.com/go-gost/core/chain"
+	xchain "github.com/go-gost/x/chain"
+	xctx "github.com/go-gost/x/ctx"
+	xlogger "github.com/go-gost/x/logger"
+)
+
+func TestConnectionTracking(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	var opened, closed *models.ConnectionRecord
+	tracker := newRuleTracker("rule-track", "Tracked", "hop1:1080")
+	tracker.onOpen = func(rec *models.ConnectionRecord) { opened = rec }
+	tracker.onClose = func(rec *models.ConnectionRecord) { closed = rec }
+
+	client, server := net.Pipe()
+	defer client.Close()
+	ctx, tc, conn := tracker.track(context.Background(), server)
+
+	router := &trackingRouter{Router: xchain.NewRouter(chain.LoggerRouterOption(xlogger.Nop()))}
+	cc, err := router.Dial(ctx, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	cc.Close()
+
+	if opened == nil || opened.Target != ln.Addr().String() || opened.Route != "hop1:1080" {
+		t.Fatalf("unexpected open record: %+v", opened)
+	}
+
+	go client.Write([]byte("ping"))
+	if _, err := io.ReadFull(conn, make([]byte, 4)); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	go io.ReadFull(client, make([]byte, 2))
+	if _, err := conn.Write([]byte("ok")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	tc.finish(errors.New("reset"))
+	if closed == nil {
+		t.Fatal("connection was not reported as closed")
+	}
+	if closed.RuleID != "rule-track" || closed.Network != "tcp" || closed.ClientAddr == "" {
+		t.Errorf("unexpected close record: %+v", closed)
+	}
+	if closed.BytesIn != 4 || closed.BytesOut != 2 || closed.Error != "reset" || closed.EndTime.IsZero() {
+		t.Errorf("unexpected close record: %+v", closed)
+	}
+}
+
+func TestKillConnections(t *testing.T) {
+	var closed []*models.ConnectionRecord
+	tracker := newRuleTracker("rule-kill", "Kill", "")
+	tracker.onClose = func(rec *models.ConnectionRecord) { closed = append(closed, rec) }
+
+	track := func(client string) (*trackedConn, net.Conn) {
+		addr, _ := net.ResolveTCPAddr("tcp", client)
+		ctx := xctx.ContextWithSrcAddr(context.Background(), addr)
+		peer, server := net.Pipe()
+		t.Cleanup(func() { peer.Close() })
+		_, tc, conn := tracker.track(ctx, server)
+		return tc, conn
+	}
+	tc1, conn1 := track("10.0.0.1:40001")
+	tc2, _ := track("10.0.0.1:40002")
+	tc3, conn3 := track("10.0.0.2:40001")
+
+	active := tracker.connections()
+	if len(active) != 3 || active[0].ID != tc1.id || active[2].ID != tc3.id {
+		t.Fatalf("unexpected active connections: %+v", active)
+	}
+	if err := tracker.kill(""); err != models.ErrConnectionNotFound {
+		t.Errorf("kill(\"\") error = %v", err)
+	}
+
+	if n := tracker.killClient("10.0.0.1"); n != 2 {
+		t.Errorf("killClient() = %d, want 2", n)
+	}
+	if _, err := conn1.Read(make([]byte, 1)); err == nil {
+		t.Error("killed connection is still readable")
+	}
+	tc1.finish(nil)
+	tc2.finish(nil)
+	if len(tracker.connections()) != 1 {
+		t.Errorf("killed connections still listed: %+v", tracker.connections())
+	}
+	if len(closed) != 2 || closed[0].Error != errConnKilled.Error() {
+		t.Errorf("unexpected close records: %+v", closed)
+	}
+
+	if err := tracker.kill("unknown"); err != models.ErrConnectionNotFound {
+		t.Errorf("kill(unknown) error = %v", err)
+	}
+	if err := tracker.kill(tc3.id[:8]); err != nil {
+		t.Errorf("kill(prefix) error = %v", err)
+	}
+	if _, err := conn3.Read(make([]byte, 1)); err == nil {
+		t.Error("killed connection is still readable")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	e := New()
+	defer e.StopAll()
+
+	var closed []*models.ConnectionRecord
+	var mu sync.Mutex
+	newTracker := func(id string) (*ruleTracker, *trackedConn, net.Conn) {
+		tracker := newRuleTracker(id, id, "")
+		tracker.onClose = func(rec *models.ConnectionRecord) {
+			mu.Lock()
+			closed = append(closed, rec)
+			mu.Unlock()
+		}
+		peer, server := net.Pipe()
+		t.Cleanup(func() { peer.Close() })
+		_, tc, conn := tracker.track(context.Background(), server)
+		return tracker, tc, conn
+	}
+	waitDrained := func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for len(e.GetDrainStatus()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if status := e.GetDrainStatus(); len(status) > 0 {
+			t.Fatalf("drain did not finish: %+v", status)
+		}
+	}
+
+	// The connection finishes on its own before the deadline
+	tracker, tc, _ := newTracker("rule-drain")
+	e.mu.Lock()
+	e.startDrain(&models.Rule{ID: "rule-drain", Name: "rule-drain", Protocol: models.ProtocolTCP}, tracker)
+	e.mu.Unlock()
+	status := e.GetDrainStatus()
+	if len(status) != 1 || status[0].Initial != 1 || status[0].Remaining != 1 {
+		t.Fatalf("unexpected drain status: %+v", status)
+	}
+	tc.finish(nil)
+	waitDrained()
+
+	// The connection is closed at the deadline
+	e.SetDrainTimeout(100 * time.Millisecond)
+	tracker, tc, conn := newTracker("rule-timeout")
+	go func() {
+		// Stands in for the handler, which returns once the client connection is closed
+		conn.Read(make([]byte, 1))
+		tc.finish(nil)
+	}()
+	e.mu.Lock()
+	e.startDrain(&models.Rule{ID: "rule-timeout", Name: "rule-timeout", Protocol: models.ProtocolTCP}, tracker)
+	e.mu.Unlock()
+	waitDrained()
+
+	// The handler returns right after the kill
+	deadline := time.Now().Add(time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	for len(closed) < 2 && time.Now().Before(deadline) {
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+	}
+	if len(closed) != 2 || closed[0].Error != "" || closed[1].Error != errDrainTimeout.Error() {
+		t.Errorf("unexpected close records: %+v", closed)
+	}
+	mu.Unlock()
+
+	// UDP sessions end with the listener, there is nothing to drain
+	e.SetDrainTimeout(time.Minute)
+	tracker, tc, conn = newTracker("rule-udp")
+	go func() {
+		conn.Read(make([]byte, 1))
+		tc.finish(nil)
+	}()
+	e.mu.Lock()
+	e.startDrain(&models.Rule{ID: "rule-udp", Name: "rule-udp", Protocol: models.ProtocolUDP}, tracker)
+	e.mu.Unlock()
+	if status := e.GetDrainStatus(); len(status) != 0 {
+		t.Errorf("UDP rule draining: %+v", status)
+	}
+	deadline = time.Now().Add(time.Second)
+	mu.Lock()
+	for len(closed) < 3 && time.Now().Before(deadline) {
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+	}
+	if len(closed) != 3 || closed[2].Error != errConnKilled.Error() {
+		t.Errorf("UDP session not closed at once: %+v", closed)
+	}
+}
+
+func TestTargetHealth(t *testing.T) {
+	type change struct {
+		target string
+		up     bool
+	}
+	var changes []change
+	tracker := newRuleTracker("rule-health", "Health", "")
+	tracker.onHealth = func(target string, up bool, err error) {
+		changes = append(changes, change{target, up})
+	}
+
+	refused := errors.New("connection refused")
+	for i := 0; i < targetDownAfter-1; i++ {
+		tracker.dialResult("10.0.0.1:80", refused)
+	}
+	tracker.dialResult("10.0.0.2:80", nil)
+	if len(changes) != 0 {
+		t.Fatalf("reported %v before %d failures", changes, targetDownAfter)
+	}
+
+	tracker.dialResult("10.0.0.1:80", refused)
+	tracker.dialResult("10.0.0.1:80", refused)
+	tracker.dialResult("10.0.0.1:80", nil)
+	want := []change{{"10.0.0.1:80", false}, {"10.0.0.1:80", true}}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// discoveryTimeout bounds a single resolution of a rule's targets
+const discoveryTimeout = 5 * time.Second
+
+// resolveTargets looks up the targets of a discovery source. The result is
+// sorted so that unchanged records compare equal across refreshes.
+func resolveTargets(ctx context.Context, d *models.TargetDiscovery) ([]models.Target, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	resolver := net.DefaultResolver
+	if d.Resolver != "" {
+		dialer := &net.Dialer{}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, d.Resolver)
+			},
+		}
+	}
+
+	var targets []models.Target
+	switch d.Type {
+	case models.DiscoverySRV:
+		_, records, err := resolver.LookupSRV(ctx, "", "", d.Name)
+		if err != nil {
+			return nil, err
+		}
+		// Records of the lowest priority are served, the others are backups
+		priority := -1
+		for _, srv := range records {
+			if priority < 0 || int(srv.Priority) < priority {
+				priority = int(srv.Priority)
+			}
+		}
+		for _, srv := range records {
+			if srv.Target == "." {
+				continue // the service is decidedly not available
+			}
+			targets = append(targets, models.Target{
+				Host:   strings.TrimSuffix(srv.Target, "."),
+				Port:   int(srv.Port),
+				Weight: int(srv.Weight),
+				Backup: int(srv.Priority) != priority,
+			})
+		}
+	case models.DiscoveryDNS:
+		addrs, err := resolver.LookupIPAddr(ctx, d.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			targets = append(targets, models.Target{Host: addr.IP.String(), Port: d.Port, Weight: 1})
+		}
+	case models.DiscoveryDocker:
+		found, err := resolveDocker(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		targets = found
+	default:
+		return nil, fmt.Errorf("unknown discovery type %q", d.Type)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets found for %s", d.Source())
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.Backup != b.Backup {
+			return !a.Backup
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.Port < b.Port
+	})
+	return targets, nil
+}
+
+// withTargets returns a copy of a rule forwarding to the given targets
+func withTargets(rule *models.Rule, targets []models.Target) *models.Rule {
+	r := rule.Clone()
+	r.TargetHost, r.TargetPort = "", 0
+	r.Targets = targets
+	return r
+}
+
+// discovered holds the targets of a rule, resolved before the rule is
+// started
+type discovered struct {
+	targets []models.Target
+	err     error
+}
+
+// discover resolves the targets of a rule, nil without discovery. Called
+// without e.mu as lookups take up to discoveryTimeout; they are canceled
+// when the engine stops.
+func (e *Engine) discover(rule *models.Rule) *discovered {
+	if rule.Discovery == nil {
+		return nil
+	}
+	targets, err := resolveTargets(e.pollCtx, rule.Discovery)
+	return &discovered{targets: targets, err: err}
+}
+
+// refreshTargets resolves the targets of a running rule every interval, and
+// on container events for docker, and swaps changed targets into its
+// forwarder, until ctx is canceled. Failed lookups keep the current targets.
+func (e *Engine) refreshTargets(ctx context.Context, entry *serviceEntry, targets []models.Target) {
+	e.mu.Lock()
+	discovery := *entry.rule.Discovery
+	e.mu.Unlock()
+
+	ticker := time.NewTicker(discovery.GetInterval())
+	defer ticker.Stop()
+
+	var changed chan struct{}
+	if discovery.Type == models.DiscoveryDocker {
+		changed = make(chan struct{}, 1)
+		go e.watchDocker(ctx, &discovery, changed)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+
+		e.mu.Lock()
+		rule := entry.rule
+		e.mu.Unlock()
+
+		found, err := resolveTargets(ctx, rule.Discovery)
+		if err != nil {
+			if ctx.Err() == nil {
+				e.logMgr.Warn(rule.ID, rule.Name, "discovery.failedKeep", nil, err.Error())
+			}
+			continue
+		}
+		if reflect.DeepEqual(found, targets) {
+			continue
+		}
+
+		h, err := buildHop(withTargets(rule, found))
+		if err != nil {
+			e.logMgr.Warn(rule.ID, rule.Name, "discovery.failedKeep", nil, err.Error())
+			continue
+		}
+		e.mu.Lock()
+		sh := getSwapHop(rule.ID)
+		current := e.services[rule.ID] == entry && ctx.Err() == nil
+		if current && sh != nil {
+			sh.swap(h)
+		}
+		e.mu.Unlock()
+		if !current || sh == nil {
+			return
+		}
+
+		targets = found
+		e.logMgr.Info(rule.ID, rule.Name, "discovery.updated", nil, formatTargets(found))
+	}
+}
+
+// formatTargets lists targets for the log, backups marked
+func formatTargets(targets []models.Target) string {
+	parts := make([]string, len(targets))
+	for i, t := range targets {
+		parts[i] = net.JoinHostPort(t.Host, fmt.Sprint(t.Port))
+		if t.Backup {
+			parts[i] += " (backup)"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS is a local DNS server answering SRV and A queries from its records
+type fakeDNS struct {
+	mu  sync.Mutex
+	srv map[string][]net.SRV
+	a   map[string][]net.IP
+}
+
+// startFakeDNS serves the records over UDP and returns its address
+func startFakeDNS(t *testing.T, d *fakeDNS) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) == 0 {
+				continue
+			}
+			if resp, err := d.answer(msg); err == nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func (d *fakeDNS) set(srv map[string][]net.SRV, a map[string][]net.IP) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.srv, d.a = srv, a
+}
+
+func (d *fakeDNS) answer(req dnsmessage.Message) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q := req.Questions[0]
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: req.Header.ID, Response: true, Authoritative: true})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	hdr := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 1}
+	switch q.Type {
+	case dnsmessage.TypeSRV:
+		for _, r := range d.srv[q.Name.String()] {
+			target := dnsmessage.MustNewName(r.Target)
+			b.SRVResource(hdr, dnsmessage.SRVResource{Priority: r.Priority, Weight: r.Weight, Port: r.Port, Target: target})
+		}
+	case dnsmessage.TypeA:
+		for _, ip := range d.a[q.Name.String()] {
+			var res dnsmessage.AResource
+			copy(res.A[:], ip.To4())
+			b.AResource(hdr, res)
+		}
+	}
+	return b.Finish()
+}
+
+func TestResolveTargets(t *testing.T) {
+	dns := &fakeDNS{}
+	dns.set(map[string][]net.SRV{
+		"_web._tcp.test.": {
+			{Target: "web-2.test.", Port: 8080, Priority: 10, Weight: 1},
+			{Target: "web-3.test.", Port: 8080, Priority: 20, Weight: 1},
+			{Target: "web-1.test.", Port: 8080, Priority: 10, Weight: 5},
+		},
+	}, map[string][]net.IP{
+		"web.test.": {net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")},
+	})
+	resolver := startFakeDNS(t, dns)
+
+	tests := []struct {
+		name      string
+		discovery models.TargetDiscovery
+		want      []models.Target
+	}{
+		{"srv", models.TargetDiscovery{Type: models.DiscoverySRV, Name: "_web._tcp.test.", Resolver: resolver}, []models.Target{
+			{Host: "web-1.test", Port: 8080, Weight: 5},
+			{Host: "web-2.test", Port: 8080, Weight: 1},
+			{Host: "web-3.test", Port: 8080, Weight: 1, Backup: true},
+		}},
+		{"dns", models.TargetDiscovery{Type: models.DiscoveryDNS, Name: "web.test.", Port: 80, Resolver: resolver}, []models.Target{
+			{Host: "10.0.0.1", Port: 80, Weight: 1},
+			{Host: "10.0.0.2", Port: 80, Weight: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTargets(t.Context(), &tt.discovery)
+			if err != nil {
+				t.Fatalf("resolveTargets() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveTargets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	missing := models.TargetDiscovery{Type: models.DiscoveryDNS, Name: "missing.test.", Port: 80, Resolver: resolver}
+	if _, err := resolveTargets(t.Context(), &missing); err == nil {
+		t.Error("resolveTargets() of a name without records succeeded")
+	}
+}
+
+func TestDiscoveryRefresh(t *testing.T) {
+	_, portA, _ := net.SplitHostPort(echoServer(t, "a"))
+	_, portB, _ := net.SplitHostPort(echoServer(t, "b"))
+	srvTo := func(port string) map[string][]net.SRV {
+		var p uint16
+		fmt.Sscan(port, &p)
+		return map[string][]net.SRV{"_echo._tcp.test.": {{Target: "localhost.", Port: p, Priority: 10, Weight: 1}}}
+	}
+
+	dns := &fakeDNS{}
+	dns.set(srvTo(portA), nil)
+	resolver := startFakeDNS(t, dns)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	rule := &models.Rule{
+		ID:        "rule-discovery",
+		Name:      "Discovery",
+		Type:      models.RuleTypeForward,
+		Protocol:  models.ProtocolTCP,
+		LocalPort: port,
+		Discovery: &models.TargetDiscovery{
+			Type:     models.DiscoverySRV,
+			Name:     "_echo._tcp.test.",
+			Interval: 1,
+			Resolver: resolver,
+		},
+	}
+
+	e := New()
+	defer e.StopAll()
+	if err := e.StartRule(rule); err != nil {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+
+	ask := func() string {
+		c, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprintf(c, "ping\n")
+		line, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		return line[:len(line)-1]
+	}
+	if got := ask(); got != "a" {
+		t.Fatalf("connection reached %q, want a", got)
+	}
+
+	// The record moves, new connections follow it without a restart
+	dns.set(srvTo(portB), nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for ask() != "b" {
+		if time.Now().After(deadline) {
+			t.Fatal("targets not refreshed")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	// A failed lookup keeps the current targets
+	dns.set(nil, nil)
+	time.Sleep(1500 * time.Millisecond)
+	if got := ask(); got != "b" {
+		t.Errorf("connection reached %q after a failed lookup, want b", got)
+	}
+}
+
+func TestDiscoveryWithoutLock(t *testing.T) {
+	// A resolver that never answers
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer silent.Close()
+
+	rule := &models.Rule{
+		ID:        "rule-slow-discovery",
+		Name:      "Slow discovery",
+		Type:      models.RuleTypeForward,
+		Protocol:  models.ProtocolTCP,
+		LocalPort: 18089,
+		Discovery: &models.TargetDiscovery{
+			Type:     models.DiscoveryDNS,
+			Name:     "web.test.",
+			Port:     80,
+			Resolver: silent.LocalAddr().String(),
+		},
+	}
+
+	e := New()
+	done := make(chan error, 1)
+	go func() { done <- e.StartRule(rule) }()
+	time.Sleep(200 * time.Millisecond)
+
+	// The engine answers while the targets are looked up
+	answered := make(chan struct{})
+	go func() {
+		e.GetBackoffStatus()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Fatal("engine locked during discovery")
+	}
+
+	// Stopping the engine cancels the lookup
+	start := time.Now()
+	e.StopAll()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("StartRule() succeeded without targets")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("lookup canceled after %v", elapsed)
+		}
+	case <-time.After(discoveryTimeout):
+		t.Fatal("lookup not canceled by StopAll")
+	}
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// dockerRetryDelay is the wait before the event stream is opened again
+const dockerRetryDelay = 5 * time.Second
+
+// dockerContainer is the part of a /containers/json entry used for targets
+type dockerContainer struct {
+	Names           []string          `json:"Names"`
+	Labels          map[string]string `json:"Labels"`
+	State           string            `json:"State"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// dockerClient returns an HTTP client talking to the Docker Engine API of
+// the discovery settings
+func dockerClient(d *models.TargetDiscovery) (*http.Client, error) {
+	network, addr, err := models.ParseDockerHost(d.GetDockerHost())
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: discoveryTimeout}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}, nil
+}
+
+// resolveDocker lists the running containers selected by the discovery
+// settings and returns their addresses on the configured network
+func resolveDocker(ctx context.Context, d *models.TargetDiscovery) ([]models.Target, error) {
+	client, err := dockerClient(d)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker: list containers: %s", resp.Status)
+	}
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("docker: %w", err)
+	}
+
+	var targets []models.Target
+	for _, c := range containers {
+		if c.State != "running" || !d.MatchContainer(c.Names, c.Labels) {
+			continue
+		}
+		networks := make([]string, 0, len(c.NetworkSettings.Networks))
+		for name := range c.NetworkSettings.Networks {
+			if d.Network == "" || name == d.Network {
+				networks = append(networks, name)
+			}
+		}
+		sort.Strings(networks)
+		for _, name := range networks {
+			n := c.NetworkSettings.Networks[name]
+			ip := n.IPAddress
+			if ip == "" {
+				ip = n.GlobalIPv6Address
+			}
+			if ip != "" {
+				targets = append(targets, models.Target{Host: ip, Port: d.Port, Weight: 1})
+				break
+			}
+		}
+	}
+	return targets, nil
+}
+
+// watchDocker signals on changed whenever a container event is received,
+// reopening the event stream until ctx is canceled
+func (e *Engine) watchDocker(ctx context.Context, d *models.TargetDiscovery, changed chan<- struct{}) {
+	for {
+		err := streamDockerEvents(ctx, d, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		e.logger.Warn("Docker event stream closed", "error", err)
+
+		// Containers may have changed while the stream was down
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dockerRetryDelay):
+		}
+	}
+}
+
+// streamDockerEvents signals container events until the stream ends
+func streamDockerEvents(ctx context.Context, d *models.TargetDiscovery, changed chan<- struct{}) error {
+	client, err := dockerClient(d)
+	if err != nil {
+		return err
+	}
+	defer client.CloseIdleConnections()
+
+	// Events that change the address of a container, not exec or health checks
+	filters := url.QueryEscape(`{"type":["container","network"],"event":["start","die","pause","unpause","rename","connect","disconnect"]}`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/events?filters="+filters, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("events: %s", resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event json.RawMessage
+		if err := dec.Decode(&event); err != nil {
+			return err
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
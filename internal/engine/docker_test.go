@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// fakeDocker serves the parts of the Docker Engine API used for discovery
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers []map[string]any
+	events     chan string
+}
+
+// startFakeDocker serves the API on a unix socket and returns its host
+func startFakeDocker(t *testing.T, d *fakeDocker) string {
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	d.events = make(chan string, 10)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		json.NewEncoder(w).Encode(d.containers)
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case action := <-d.events:
+				fmt.Fprintf(w, `{"Type":"container","Action":%q}`+"\n", action)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "unix://" + path
+}
+
+func (d *fakeDocker) set(containers ...map[string]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.containers = containers
+}
+
+// container describes a container as listed by the Docker API
+func container(name, state string, labels map[string]string, networks map[string]string) map[string]any {
+	nets := map[string]any{}
+	for network, ip := range networks {
+		nets[network] = map[string]any{"IPAddress": ip}
+	}
+	return map[string]any{
+		"Id":              name + "-id",
+		"Names":           []string{"/" + name},
+		"Labels":          labels,
+		"State":           state,
+		"NetworkSettings": map[string]any{"Networks": nets},
+	}
+}
+
+func TestResolveDocker(t *testing.T) {
+	docker := &fakeDocker{}
+	host := startFakeDocker(t, docker)
+	web := map[string]string{"com.docker.compose.service": "web"}
+	docker.set(
+		container("app-web-1", "running", web, map[string]string{"app": "172.20.0.2", "bridge": "172.17.0.2"}),
+		container("app-web-2", "running", web, map[string]string{"app": "172.20.0.3"}),
+		container("app-web-3", "exited", web, map[string]string{"app": "172.20.0.4"}),
+		container("app-db-1", "running", map[string]string{"com.docker.compose.service": "db"}, map[string]string{"app": "172.20.0.5"}),
+	)
+
+	tests := []struct {
+		name      string
+		discovery models.TargetDiscovery
+		want      []string
+	}{
+		{"by label", models.TargetDiscovery{Label: "com.docker.compose.service=web", Network: "app"}, []string{"172.20.0.2", "172.20.0.3"}},
+		{"by label key", models.TargetDiscovery{Label: "com.docker.compose.service", Network: "app"}, []string{"172.20.0.2", "172.20.0.3", "172.20.0.5"}},
+		{"by name", models.TargetDiscovery{Name: "app-web-1"}, []string{"172.20.0.2"}},
+		{"by name on network", models.TargetDiscovery{Name: "app-web-1", Network: "bridge"}, []string{"172.17.0.2"}},
+		{"by name and label", models.TargetDiscovery{Name: "app-web-1", Label: "com.docker.compose.service=db"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.discovery
+			d.Type, d.Port, d.DockerHost = models.DiscoveryDocker, 80, host
+			got, err := resolveTargets(t.Context(), &d)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("resolveTargets() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTargets() error = %v", err)
+			}
+			var hosts []string
+			for _, target := range got {
+				if target.Port != 80 {
+					t.Errorf("target %s has port %d, want 80", target.Host, target.Port)
+				}
+				hosts = append(hosts, target.Host)
+			}
+			if !reflect.DeepEqual(hosts, tt.want) {
+				t.Errorf("resolveTargets() = %v, want %v", hosts, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerEventsRefresh(t *testing.T) {
+	_, targetPort, _ := net.SplitHostPort(echoServerAt(t, "127.0.0.1:0", "a"))
+	echoServerAt(t, "127.0.0.2:"+targetPort, "b")
+
+	docker := &fakeDocker{}
+	host := startFakeDocker(t, docker)
+	docker.set(container("web", "running", nil, map[string]string{"bridge": "127.0.0.1"}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	rule := &models.Rule{
+		ID:        "rule-docker",
+		Name:      "Docker",
+		Type:      models.RuleTypeForward,
+		Protocol:  models.ProtocolTCP,
+		LocalPort: port,
+		Discovery: &models.TargetDiscovery{
+			Type:       models.DiscoveryDocker,
+			Name:       "web",
+			Interval:   3600, // only events refresh the targets
+			DockerHost: host,
+		},
+	}
+	fmt.Sscan(targetPort, &rule.Discovery.Port)
+
+	e := New()
+	defer e.StopAll()
+	if err := e.StartRule(rule); err != nil {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+
+	ask := func() string {
+		c, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprintf(c, "ping\n")
+		line, err := bufio.NewReader(c).ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		return line[:len(line)-1]
+	}
+	if got := ask(); got != "a" {
+		t.Fatalf("connection reached %q, want a", got)
+	}
+
+	// The container is recreated with a new address
+	docker.set(container("web", "running", nil, map[string]string{"bridge": "127.0.0.2"}))
+	docker.events <- "start"
+	deadline := time.Now().Add(5 * time.Second)
+	for ask() != "b" {
+		if time.Now().After(deadline) {
+			t.Fatal("targets not refreshed on the container event")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
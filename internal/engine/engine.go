@@ -2,11 +2,16 @@ package engine
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"pfm/internal/i18n"
+	"pfm/internal/logging"
 	"pfm/internal/models"
 
 	"github.com/go-gost/core/observer/stats"
@@ -23,23 +28,45 @@ type Statuser interface {
 const (
 	// observerName is the name used to register our stats observer
 	observerName = "pfm-stats-observer"
+
+	// drainPollInterval is how often a draining rule checks its connections
+	drainPollInterval = 200 * time.Millisecond
 )
 
+// defaultLogger is the logger of the engine and its package-level code
+var defaultLogger = logging.For("engine")
+
 // StatusChangeCallback is called when a service status changes
 type StatusChangeCallback func(ruleID string, status string, errorMsg string)
 
+// EventCallback is called for status changes, target health changes and
+// quota events
+type EventCallback func(ev models.Event)
+
+// AccessCallback is called with the record of every finished connection
+type AccessCallback func(rec models.ConnectionRecord)
+
 // Engine manages gost services for port forwarding
 type Engine struct {
 	mu             sync.RWMutex
 	services       map[string]*serviceEntry
 	chains         []*models.Chain
-	logger         *log.Logger
+	logger         *slog.Logger
 	stats          *StatsTracker
+	history        *statsHistory
 	logMgr         *LogManager
+	access         *AccessLog
+	accounting     *Accounting
+	quotas         *Quotas
+	drainTimeout   time.Duration
+	draining       map[*ruleTracker]*drainEntry
+	restarts       map[string]*restartEntry
 	observer       *StatsObserver
 	pollCtx        context.Context
 	pollCancel     context.CancelFunc
 	onStatusChange StatusChangeCallback
+	onEvent        atomic.Pointer[EventCallback] // read without e.mu, events are emitted with and without it
+	onAccess       atomic.Pointer[AccessCallback]
 }
 
 // serviceEntry holds a running service and its metadata
@@ -47,21 +74,38 @@ type serviceEntry struct {
 	service service.Service
 	rule    *models.Rule
 	cancel  context.CancelFunc
+	attempt int // restart attempt that started the service, 0 when started on request
+	started time.Time
+}
+
+// drainEntry holds a stopped rule whose connections are still finishing
+type drainEntry struct {
+	ruleID   string
+	ruleName string
+	tracker  *ruleTracker
+	start    time.Time
+	deadline time.Time
+	initial  int
 }
 
 // New creates a new Engine instance
 func New() *Engine {
 	ctx, cancel := context.WithCancel(context.Background())
+	setDefaultLogger()
 
 	e := &Engine{
-		services:   make(map[string]*serviceEntry),
-		chains:     []*models.Chain{},
-		logger:     log.Default(),
-		stats:      NewStatsTracker(),
-		logMgr:     NewLogManager(1000),
-		observer:   NewStatsObserver(),
-		pollCtx:    ctx,
-		pollCancel: cancel,
+		services:     make(map[string]*serviceEntry),
+		chains:       []*models.Chain{},
+		logger:       defaultLogger,
+		draining:     make(map[*ruleTracker]*drainEntry),
+		restarts:     make(map[string]*restartEntry),
+		drainTimeout: models.DefaultDrainTimeout,
+		stats:        NewStatsTracker(),
+		history:      newStatsHistory(),
+		logMgr:       NewLogManager(1000),
+		observer:     NewStatsObserver(),
+		pollCtx:      ctx,
+		pollCancel:   cancel,
 	}
 
 	// Set up observer callback to sync with StatsTracker
@@ -73,16 +117,9 @@ func New() *Engine {
 	// Unregister first to handle restart/test scenarios
 	registry.ObserverRegistry().Unregister(observerName)
 	if err := registry.ObserverRegistry().Register(observerName, e.observer); err != nil {
-		log.Printf("[Engine] Failed to register observer: %v", err)
-	} else {
-		log.Printf("[Engine] Observer '%s' registered successfully", observerName)
-	}
-
-	// Verify registration
-	if obs := registry.ObserverRegistry().Get(observerName); obs != nil {
-		log.Printf("[Engine] Observer '%s' verified in registry", observerName)
-	} else {
-		log.Printf("[Engine] WARNING: Observer '%s' NOT found in registry!", observerName)
+		e.logger.Error("Failed to register observer", "observer", observerName, "error", err)
+	} else if registry.ObserverRegistry().Get(observerName) == nil {
+		e.logger.Warn("Observer not found in registry", "observer", observerName)
 	}
 
 	// Start polling goroutine to collect stats from gost services
@@ -92,10 +129,50 @@ func New() *Engine {
 }
 
 // SetLogger sets the logger for the engine
-func (e *Engine) SetLogger(logger *log.Logger) {
+func (e *Engine) SetLogger(logger *slog.Logger) {
 	e.logger = logger
 }
 
+// SetAccessLog sets the log receiving a record for every finished connection
+func (e *Engine) SetAccessLog(access *AccessLog) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.access = access
+}
+
+// SetLogStore sets the store persisting log entries
+func (e *Engine) SetLogStore(store *LogStore) {
+	e.logMgr.SetStore(store)
+}
+
+// SetLogRetention sets how many bytes of log files are kept and for how long
+func (e *Engine) SetLogRetention(maxSize int64, maxAge time.Duration) {
+	e.logMgr.SetRetention(maxSize, maxAge)
+}
+
+// SetAccounting sets the persistent traffic totals updated by the stats
+// polling
+func (e *Engine) SetAccounting(accounting *Accounting) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.accounting = accounting
+}
+
+// SetQuotas sets the persistent quota usage updated by the stats polling
+func (e *Engine) SetQuotas(quotas *Quotas) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.quotas = quotas
+}
+
+// SetDrainTimeout sets how long a stopped rule lets its connections finish
+// before closing them, 0 closes them immediately
+func (e *Engine) SetDrainTimeout(timeout time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.drainTimeout = timeout
+}
+
 // SetStatusChangeCallback sets the callback for status changes
 func (e *Engine) SetStatusChangeCallback(callback StatusChangeCallback) {
 	e.mu.Lock()
@@ -103,6 +180,44 @@ func (e *Engine) SetStatusChangeCallback(callback StatusChangeCallback) {
 	e.onStatusChange = callback
 }
 
+// SetEventCallback sets the callback receiving events
+func (e *Engine) SetEventCallback(callback EventCallback) {
+	e.onEvent.Store(&callback)
+}
+
+// SetAccessCallback sets the callback receiving the record of every
+// finished connection
+func (e *Engine) SetAccessCallback(callback AccessCallback) {
+	e.onAccess.Store(&callback)
+}
+
+// Emit sends an event to the event callback
+func (e *Engine) Emit(ev models.Event) {
+	callback := e.onEvent.Load()
+	if callback == nil || *callback == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	(*callback)(ev)
+}
+
+// statusChanged reports a status change of a rule to the status callback
+// and as an event. Called without e.mu held.
+func (e *Engine) statusChanged(callback StatusChangeCallback, ruleID, ruleName, status, errMsg string) {
+	if callback != nil {
+		callback(ruleID, status, errMsg)
+	}
+	e.Emit(models.Event{
+		Type:     models.EventRuleStatus,
+		RuleID:   ruleID,
+		RuleName: ruleName,
+		Status:   status,
+		Message:  errMsg,
+	})
+}
+
 // SetChains updates the chain configurations
 func (e *Engine) SetChains(chains []*models.Chain) {
 	e.mu.Lock()
@@ -122,11 +237,11 @@ func (e *Engine) GetLogManager() *LogManager {
 
 // StartRule starts a forwarding rule
 func (e *Engine) StartRule(rule *models.Rule) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	// Check if already running
-	if _, exists := e.services[rule.ID]; exists {
+	e.mu.RLock()
+	_, exists := e.services[rule.ID]
+	e.mu.RUnlock()
+	if exists {
 		return models.ErrServiceRunning
 	}
 
@@ -135,16 +250,65 @@ func (e *Engine) StartRule(rule *models.Rule) error {
 		return err
 	}
 
+	found := e.discover(rule)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Started meanwhile
+	if _, exists := e.services[rule.ID]; exists {
+		return models.ErrServiceRunning
+	}
+
+	// A start on request supersedes a pending restart
+	e.cancelRestart(rule.ID)
+
+	return e.start(rule, 0, found)
+}
+
+// start builds and runs the service of a rule. attempt is the restart
+// attempt, 0 when started on request. found holds the discovered targets
+// of the rule. Called with e.mu held.
+func (e *Engine) start(rule *models.Rule, attempt int, found *discovered) error {
+	if e.quotas.blocks(rule, time.Now()) {
+		e.logMgr.Warn(rule.ID, rule.Name, "quota.exhaustedNotStarted", nil)
+		return models.ErrQuotaExceeded
+	}
+
+	// Build the forwarder with the discovered targets
+	build := rule
+	var targets []models.Target
+	if found != nil {
+		if err := found.err; err != nil {
+			e.logMgr.Error(rule.ID, rule.Name, "discovery.failed", nil, err.Error())
+			return &models.EngineError{
+				RuleID:  rule.ID,
+				Op:      "discover",
+				Message: "failed to discover targets",
+				Err:     err,
+			}
+		}
+		targets = found.targets
+		build = withTargets(rule, targets)
+	}
+
 	// Build service using builder
-	svc, err := BuildService(rule, e.chains)
+	svc, err := BuildService(build, e.chains)
 	if err != nil {
-		e.logMgr.Error(rule.ID, rule.Name, "启动失败", err.Error())
-		return &models.EngineError{
+		e.logMgr.Error(rule.ID, rule.Name, "service.startFailed", nil, err.Error())
+		err = &models.EngineError{
 			RuleID:  rule.ID,
 			Op:      "build",
 			Message: "failed to build service",
 			Err:     err,
 		}
+		// Only listener failures may go away, configuration errors fail for good
+		if isListenerError(err) && rule.Restart.ShouldRestart(err) {
+			if next, ok := e.scheduleRestart(rule, attempt+1, err); ok {
+				return &models.BackoffError{Err: err, Attempt: attempt + 1, NextRetry: next}
+			}
+		}
+		return err
 	}
 
 	// Create context for the service
@@ -152,81 +316,152 @@ func (e *Engine) StartRule(rule *models.Rule) error {
 
 	// Initialize stats for this rule
 	e.stats.InitRule(rule.ID)
+	if e.accounting != nil {
+		e.accounting.Forget(rule.ID)
+	}
+	if e.quotas != nil {
+		e.quotas.Forget(rule.ID)
+	}
+	resetMirrorStats(rule.ID)
+	tracker := e.newTracker(rule)
+	tracker.setThrottle(e.quotas.throttle(rule, time.Now()))
+	setTracker(tracker)
 
 	// Store the service entry
-	e.services[rule.ID] = &serviceEntry{
+	entry := &serviceEntry{
 		service: svc,
 		rule:    rule.Clone(),
 		cancel:  cancel,
+		attempt: attempt,
+		started: time.Now(),
 	}
+	e.services[rule.ID] = entry
 
 	// Log service start
 	e.logMgr.LogServiceStart(rule.ID, rule.Name, rule.GetListenAddr())
 
 	// Start the service in a goroutine
-	go func() {
-		ruleID := rule.ID
-		ruleName := rule.Name
-		e.logger.Printf("[Engine] Starting service: %s (%s)", ruleName, ruleID)
-		if err := svc.Serve(); err != nil {
-			select {
-			case <-ctx.Done():
-				// Service was stopped intentionally
-			default:
-				errMsg := err.Error()
-				// "use of closed network connection" is a normal shutdown signal, not an error
-				// Target unreachable errors should not stop the service
-				if strings.Contains(errMsg, "use of closed network connection") {
-					e.logger.Printf("[Engine] Service closed normally: %s", ruleID)
-					return
-				}
+	go e.serve(ctx, entry)
+	if rule.Discovery != nil {
+		e.logMgr.Info(rule.ID, rule.Name, "discovery.found", nil, formatTargets(targets))
+		go e.refreshTargets(ctx, entry, targets)
+	}
 
-				e.logger.Printf("[Engine] Service error: %s - %v", ruleID, err)
-				e.logMgr.LogError(ruleID, ruleName, err)
-				e.stats.IncrementErrors(ruleID)
-
-				// Only mark as error and remove for critical listener errors
-				// (e.g., port already in use, permission denied)
-				if strings.Contains(errMsg, "address already in use") ||
-					strings.Contains(errMsg, "permission denied") ||
-					strings.Contains(errMsg, "bind:") {
-					// Notify status change to error
-					e.mu.RLock()
-					callback := e.onStatusChange
-					e.mu.RUnlock()
-					if callback != nil {
-						callback(ruleID, "error", errMsg)
-					}
-
-					// Remove from running services
-					e.mu.Lock()
-					delete(e.services, ruleID)
-					e.mu.Unlock()
-				}
-			}
+	return nil
+}
+
+// isListenerError reports whether err is a critical listener error (e.g.,
+// port already in use, permission denied), the only start failure a retry
+// may overcome
+func isListenerError(err error) bool {
+	if err == nil {
+		return false
+	}
+	errMsg := err.Error()
+	return strings.Contains(errMsg, "address already in use") ||
+		strings.Contains(errMsg, "permission denied") ||
+		strings.Contains(errMsg, "bind:")
+}
+
+// serve runs a service until it is stopped or fails
+func (e *Engine) serve(ctx context.Context, entry *serviceEntry) {
+	ruleID := entry.rule.ID
+	ruleName := entry.rule.Name
+	e.logger.Info("Starting service", "rule", ruleID, "name", ruleName)
+
+	err := entry.service.Serve()
+	select {
+	case <-ctx.Done():
+		// Service was stopped intentionally
+		return
+	default:
+	}
+
+	errMsg := ""
+	critical := isListenerError(err)
+	if err != nil {
+		errMsg = err.Error()
+		// "use of closed network connection" is a normal shutdown signal, not an error
+		// Target unreachable errors should not stop the service
+		if strings.Contains(errMsg, "use of closed network connection") {
+			e.logger.Debug("Service closed normally", "rule", ruleID)
+			return
 		}
-	}()
 
-	return nil
+		e.logger.Error("Service error", "rule", ruleID, "error", err)
+		e.logMgr.LogError(ruleID, ruleName, err)
+		e.stats.IncrementErrors(ruleID)
+	}
+
+	// Other errors leave the rule marked as running unless its policy restarts it
+	e.mu.RLock()
+	restart := entry.rule.Restart.ShouldRestart(err)
+	e.mu.RUnlock()
+	if !critical && !restart {
+		return
+	}
+
+	e.mu.Lock()
+	if e.services[ruleID] != entry {
+		// Stopped or replaced meanwhile
+		e.mu.Unlock()
+		return
+	}
+
+	// Remove from running services
+	delete(e.services, ruleID)
+	entry.cancel()
+	entry.service.Close()
+	registry.ServiceRegistry().Unregister(ruleID)
+	removeSwapHop(ruleID)
+	if t := getTracker(ruleID); t != nil {
+		removeTracker(ruleID)
+		e.startDrain(entry.rule, t)
+	}
+
+	status := string(models.RuleStatusError)
+	if restart {
+		if err == nil {
+			err = errServiceExited
+			errMsg = err.Error()
+		}
+		// A service that ran long enough starts a new backoff sequence
+		attempt := entry.attempt + 1
+		if time.Since(entry.started) >= restartResetAfter {
+			attempt = 1
+		}
+		if next, ok := e.scheduleRestart(entry.rule, attempt, err); ok {
+			status = string(models.RuleStatusBackoff)
+			errMsg = (&models.BackoffError{Err: err, Attempt: attempt, NextRetry: next}).Error()
+		}
+	}
+	callback := e.onStatusChange
+	e.mu.Unlock()
+
+	// Notify status change
+	e.statusChanged(callback, ruleID, ruleName, status, errMsg)
 }
 
-// StopRule stops a running rule
+// StopRule stops a running rule. The listener is closed at once, in-flight
+// TCP connections are drained in the background up to the drain timeout.
+// UDP sessions share the listener and end with it.
 func (e *Engine) StopRule(id string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	entry, exists := e.services[id]
 	if !exists {
+		// A failed rule waiting to be restarted stops retrying
+		if r := e.restarts[id]; r != nil {
+			e.cancelRestart(id)
+			e.logMgr.LogServiceStop(id, r.rule.Name)
+			return nil
+		}
 		return models.ErrServiceNotRunning
 	}
 
-	ruleName := ""
-	if entry.rule != nil {
-		ruleName = entry.rule.Name
-	}
-
-	e.logger.Printf("[Engine] Stopping service: %s", id)
-	e.logMgr.LogServiceStop(id, ruleName)
+	e.logger.Info("Stopping service", "rule", id)
+	e.logMgr.LogServiceStop(id, entry.rule.Name)
 
 	// Cancel the context
 	if entry.cancel != nil {
@@ -240,6 +475,11 @@ func (e *Engine) StopRule(id string) error {
 
 	// Unregister from registry
 	registry.ServiceRegistry().Unregister(id)
+	removeSwapHop(id)
+	if t := getTracker(id); t != nil {
+		removeTracker(id)
+		e.startDrain(entry.rule, t)
+	}
 
 	// Remove stats (keep them for history view)
 	// e.stats.RemoveRule(id)
@@ -250,6 +490,137 @@ func (e *Engine) StopRule(id string) error {
 	return nil
 }
 
+// startDrain lets the connections of a stopped rule finish, then closes the
+// remaining ones at the deadline. UDP sessions are not drained, they are
+// served by the listener that was just closed. Called with e.mu held.
+func (e *Engine) startDrain(rule *models.Rule, t *ruleTracker) {
+	ruleID, ruleName := rule.ID, rule.Name
+	n := t.count()
+	if n == 0 {
+		return
+	}
+	if rule.Protocol == models.ProtocolUDP {
+		e.logMgr.Info(ruleID, ruleName, "drain.udp", i18n.Params{"count": strconv.Itoa(n)})
+		t.killAll(errConnKilled)
+		return
+	}
+	if e.drainTimeout <= 0 {
+		t.killAll(errConnKilled)
+		return
+	}
+
+	now := time.Now()
+	d := &drainEntry{
+		ruleID:   ruleID,
+		ruleName: ruleName,
+		tracker:  t,
+		start:    now,
+		deadline: now.Add(e.drainTimeout),
+		initial:  n,
+	}
+	e.draining[t] = d
+	e.logMgr.Info(ruleID, ruleName, "drain.start", i18n.Params{"count": strconv.Itoa(n), "timeout": e.drainTimeout.String()})
+
+	go e.drain(d)
+}
+
+// drain waits for the connections of a draining rule to finish
+func (e *Engine) drain(d *drainEntry) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n := d.tracker.count()
+		if n == 0 {
+			e.logMgr.Info(d.ruleID, d.ruleName, "drain.done", nil)
+			break
+		}
+		if time.Now().After(d.deadline) {
+			d.tracker.killAll(errDrainTimeout)
+			e.logMgr.Warn(d.ruleID, d.ruleName, "drain.timeout", i18n.Params{"count": strconv.Itoa(n)})
+			break
+		}
+	}
+
+	e.mu.Lock()
+	delete(e.draining, d.tracker)
+	e.mu.Unlock()
+}
+
+// GetDrainStatus returns the progress of the rules currently draining
+func (e *Engine) GetDrainStatus() []models.DrainStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]models.DrainStatus, 0, len(e.draining))
+	for _, d := range e.draining {
+		result = append(result, models.DrainStatus{
+			RuleID:    d.ruleID,
+			RuleName:  d.ruleName,
+			StartTime: d.start,
+			Deadline:  d.deadline,
+			Initial:   d.initial,
+			Remaining: d.tracker.count(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
+
+// UpdateRule applies a new configuration to a rule, starting it if it is not
+// running. The running service is only rebuilt (with a drain) when the
+// listener or handler configuration changed: cosmetic changes are recorded
+// as is and target changes are swapped into the running forwarder.
+func (e *Engine) UpdateRule(rule *models.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	entry, exists := e.services[rule.ID]
+	if !exists {
+		e.mu.Unlock()
+		return e.StartRule(rule)
+	}
+
+	switch diffRule(entry.rule, rule) {
+	case changeNone:
+		e.mu.Unlock()
+		return nil
+
+	case changeMetadata:
+		entry.rule = rule.Clone()
+		e.mu.Unlock()
+		if t := getTracker(rule.ID); t != nil {
+			t.setName(rule.Name)
+		}
+		return nil
+
+	case changeTargets:
+		if sh := getSwapHop(rule.ID); sh != nil {
+			h, err := buildHop(rule)
+			if err != nil {
+				e.mu.Unlock()
+				return err
+			}
+			sh.swap(h)
+			entry.rule = rule.Clone()
+			e.mu.Unlock()
+			if t := getTracker(rule.ID); t != nil {
+				t.setName(rule.Name)
+			}
+			e.logMgr.Info(rule.ID, rule.Name, "target.updated", nil)
+			return nil
+		}
+	}
+	e.mu.Unlock()
+
+	e.logMgr.Info(rule.ID, rule.Name, "service.rebuild", nil)
+	return e.RestartRule(rule)
+}
+
 // RestartRule restarts a rule
 func (e *Engine) RestartRule(rule *models.Rule) error {
 	// Stop if running
@@ -261,16 +632,19 @@ func (e *Engine) RestartRule(rule *models.Rule) error {
 
 // StopAll stops all running services
 func (e *Engine) StopAll() {
-	// Stop the stats polling goroutine
+	// Stop the stats polling goroutine, accounting the last traffic
 	if e.pollCancel != nil {
 		e.pollCancel()
 	}
+	if e.accounting != nil || e.quotas != nil {
+		e.collectStats()
+	}
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.logger.Printf("[Engine] Stopping all services...")
-	e.logMgr.Info("", "", "停止所有服务")
+	e.logger.Info("Stopping all services")
+	e.logMgr.Info("", "", "service.stopAll", nil)
 
 	for id, entry := range e.services {
 		if entry.cancel != nil {
@@ -280,9 +654,37 @@ func (e *Engine) StopAll() {
 			entry.service.Close()
 		}
 		registry.ServiceRegistry().Unregister(id)
+		removeSwapHop(id)
+		if t := getTracker(id); t != nil {
+			removeTracker(id)
+			t.killAll(errConnKilled)
+		}
 	}
 
 	e.services = make(map[string]*serviceEntry)
+
+	for id := range e.restarts {
+		e.cancelRestart(id)
+	}
+
+	// Shutting down, don't wait for draining rules
+	for t := range e.draining {
+		t.killAll(errDrainTimeout)
+	}
+
+	if e.access != nil {
+		e.access.Close()
+	}
+	if e.accounting != nil {
+		if err := e.accounting.Save(); err != nil {
+			e.logger.Error("Failed to save accounting", "error", err)
+		}
+	}
+	if e.quotas != nil {
+		if err := e.quotas.Save(); err != nil {
+			e.logger.Error("Failed to save quota usage", "error", err)
+		}
+	}
 }
 
 // GetRunningRuleIDs returns IDs of all running rules
@@ -305,6 +707,27 @@ func (e *Engine) IsRunning(id string) bool {
 	return exists
 }
 
+// IsActive checks if a rule is running or waiting to be restarted
+func (e *Engine) IsActive(id string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, running := e.services[id]
+	_, pending := e.restarts[id]
+	return running || pending
+}
+
+// IsListening checks if a running rule listens on the given port
+func (e *Engine) IsListening(network string, port int) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, entry := range e.services {
+		if entry.rule != nil && entry.rule.LocalPort == port && entry.rule.GetNetwork() == network {
+			return true
+		}
+	}
+	return false
+}
+
 // GetStatus returns the status of all running services
 func (e *Engine) GetStatus() map[string]*models.ServiceStatus {
 	e.mu.RLock()
@@ -332,12 +755,77 @@ func (e *Engine) GetRunningCount() int {
 
 // GetRuleStats returns statistics for a specific rule
 func (e *Engine) GetRuleStats(ruleID string) *models.RuleStats {
-	return e.stats.GetStats(ruleID)
+	stats := e.stats.GetStats(ruleID)
+	stats.Mirror = getMirrorStats(ruleID)
+	stats.RateIn, stats.RateOut = e.history.current(ruleID, time.Now())
+	return stats
 }
 
 // GetAllRuleStats returns statistics for all rules
 func (e *Engine) GetAllRuleStats() map[string]*models.RuleStats {
-	return e.stats.GetAllStats()
+	all := e.stats.GetAllStats()
+	now := time.Now()
+	for ruleID, stats := range all {
+		stats.Mirror = getMirrorStats(ruleID)
+		stats.RateIn, stats.RateOut = e.history.current(ruleID, now)
+	}
+	return all
+}
+
+// GetStatsHistory returns the recent traffic samples of a rule
+func (e *Engine) GetStatsHistory(ruleID string, resolution models.HistoryResolution) (*models.StatsHistory, error) {
+	resolution, err := models.ParseHistoryResolution(string(resolution))
+	if err != nil {
+		return nil, err
+	}
+	return &models.StatsHistory{
+		RuleID:     ruleID,
+		Resolution: resolution,
+		Samples:    e.history.get(ruleID, resolution, time.Now()),
+	}, nil
+}
+
+// GetAccounting returns the persistent traffic totals selected by q
+func (e *Engine) GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	e.mu.RLock()
+	accounting := e.accounting
+	e.mu.RUnlock()
+
+	if accounting == nil {
+		return []models.TrafficUsage{}, q.Validate()
+	}
+	return accounting.Query(q)
+}
+
+// ExportAccounting returns the traffic totals selected by q as "csv" or "json"
+func (e *Engine) ExportAccounting(q models.AccountingQuery, format string) ([]byte, error) {
+	usage, err := e.GetAccounting(q)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeAccounting(usage, format)
+}
+
+// GetQuotaStatus returns the traffic a rule used against its quota, nil for
+// a rule without a quota
+func (e *Engine) GetQuotaStatus(rule *models.Rule) *models.QuotaStatus {
+	e.mu.RLock()
+	quotas := e.quotas
+	e.mu.RUnlock()
+	if rule.Quota == nil || quotas == nil {
+		return nil
+	}
+	st := quotas.Status(rule, time.Now())
+	return &st
+}
+
+// QuotaBlocks reports whether a rule used up a quota that stops it, it may
+// not run until the quota starts over or is raised
+func (e *Engine) QuotaBlocks(rule *models.Rule) bool {
+	e.mu.RLock()
+	quotas := e.quotas
+	e.mu.RUnlock()
+	return quotas.blocks(rule, time.Now())
 }
 
 // GetLogs returns recent log entries
@@ -355,11 +843,120 @@ func (e *Engine) GetLogsByRule(ruleID string) []models.LogEntry {
 	return e.logMgr.GetByRule(ruleID)
 }
 
-// ClearLogs clears all log entries
+// QueryLogs returns a page of the persisted log entries matching q
+func (e *Engine) QueryLogs(q models.LogQuery) (*models.LogPage, error) {
+	return e.logMgr.Query(q)
+}
+
+// ClearLogs clears the log entries in memory
 func (e *Engine) ClearLogs() {
 	e.logMgr.Clear()
 }
 
+// GetConnections returns the most recent access records of a rule, newest first
+func (e *Engine) GetConnections(ruleID string, limit int) ([]models.ConnectionRecord, error) {
+	e.mu.RLock()
+	access := e.access
+	e.mu.RUnlock()
+
+	if access == nil {
+		return []models.ConnectionRecord{}, nil
+	}
+	return access.Query(ruleID, limit)
+}
+
+// GetActiveConnections returns the connections currently handled by a rule, oldest first
+func (e *Engine) GetActiveConnections(ruleID string) ([]models.ActiveConnection, error) {
+	t := getTracker(ruleID)
+	if t == nil {
+		return nil, models.ErrServiceNotRunning
+	}
+	return t.connections(), nil
+}
+
+// CloseConnection forcibly closes an active connection of a rule
+func (e *Engine) CloseConnection(ruleID, connID string) error {
+	t := getTracker(ruleID)
+	if t == nil {
+		return models.ErrServiceNotRunning
+	}
+	return t.kill(connID)
+}
+
+// CloseClientConnections forcibly closes all active connections of a rule
+// from a client IP or address, and returns how many were closed
+func (e *Engine) CloseClientConnections(ruleID, client string) (int, error) {
+	t := getTracker(ruleID)
+	if t == nil {
+		return 0, models.ErrServiceNotRunning
+	}
+	return t.killClient(client), nil
+}
+
+// newTracker creates the connection tracker of a rule. Called with e.mu held.
+func (e *Engine) newTracker(rule *models.Rule) *ruleTracker {
+	access := e.access
+
+	t := newRuleTracker(rule.ID, rule.Name, e.chainRoute(rule.ChainID))
+	t.onOpen = func(rec *models.ConnectionRecord) {
+		e.logMgr.LogConnection(rec.RuleID, rec.RuleName, rec.ClientAddr, rec.Target)
+	}
+	t.onClose = func(rec *models.ConnectionRecord) {
+		switch rec.Error {
+		case "":
+			e.logMgr.LogDisconnection(rec.RuleID, rec.RuleName, rec.ClientAddr, rec.BytesIn, rec.BytesOut)
+		case errConnKilled.Error(), errDrainTimeout.Error():
+			e.logMgr.Warn(rec.RuleID, rec.RuleName, "conn.killed", i18n.Params{"client": rec.ClientAddr}, rec.Error)
+		default:
+			e.logMgr.Warn(rec.RuleID, rec.RuleName, "conn.failed", i18n.Params{"client": rec.ClientAddr, "target": rec.Target}, rec.Error)
+		}
+		if access != nil {
+			if err := access.Write(rec); err != nil {
+				e.logger.Warn("Failed to write access log", "rule", rec.RuleID, "error", err)
+			}
+		}
+		if callback := e.onAccess.Load(); callback != nil && *callback != nil {
+			(*callback)(*rec)
+		}
+	}
+
+	// Proxies dial whatever their clients ask for, only forwarders have
+	// targets whose health means something
+	if rule.Type == models.RuleTypeForward || rule.Type == models.RuleTypeReverse {
+		t.onHealth = func(target string, up bool, err error) {
+			ev := models.Event{Type: models.EventTargetHealth, RuleID: t.ruleID, RuleName: t.name(), Target: target}
+			if up {
+				e.logMgr.Info(ev.RuleID, ev.RuleName, "target.up", i18n.Params{"target": target})
+				ev.Status = "up"
+			} else {
+				e.logMgr.Warn(ev.RuleID, ev.RuleName, "target.down", i18n.Params{"target": target}, err.Error())
+				ev.Status, ev.Message = "down", err.Error()
+			}
+			e.Emit(ev)
+		}
+	}
+	return t
+}
+
+// chainRoute describes the hops of a chain, e.g. "hop1:1080 > hop2:1080".
+// Called with e.mu held.
+func (e *Engine) chainRoute(chainID string) string {
+	if chainID == "" {
+		return ""
+	}
+	for _, c := range e.chains {
+		if c.ID != chainID {
+			continue
+		}
+		addrs := make([]string, len(c.Hops))
+		for i, hop := range c.Hops {
+			addrs[i] = hop.Addr
+		}
+		return strings.Join(addrs, " > ")
+	}
+	return ""
+}
+
 // AddTrafficIn adds incoming traffic stats for a rule
 func (e *Engine) AddTrafficIn(ruleID string, bytes int64) {
 	e.stats.AddBytesIn(ruleID, bytes)
@@ -380,17 +977,21 @@ func (e *Engine) DecrementActiveConnections(ruleID string) {
 	e.stats.DecrementActiveConnections(ruleID)
 }
 
+// statsInterval is how often pollStats collects statistics, and the
+// resolution of the finest traffic history
+const statsInterval = time.Second
+
 // pollStats periodically polls gost services for statistics
 func (e *Engine) pollStats() {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(statsInterval)
 	defer ticker.Stop()
 
-	log.Printf("[Engine] Stats polling started (every 2s)")
+	e.logger.Debug("Stats polling started", "interval", statsInterval)
 
 	for {
 		select {
 		case <-e.pollCtx.Done():
-			log.Printf("[Engine] Stats polling stopped")
+			e.logger.Debug("Stats polling stopped")
 			return
 		case <-ticker.C:
 			e.collectStats()
@@ -398,16 +999,26 @@ func (e *Engine) pollStats() {
 	}
 }
 
-// collectStats collects statistics from all running gost services
+// collectStats collects statistics from all running gost services and
+// records them in the traffic history
 func (e *Engine) collectStats() {
 	e.mu.RLock()
 	serviceIDs := make([]string, 0, len(e.services))
-	for id := range e.services {
+	rules := make(map[string]*models.Rule, len(e.services))
+	for id, entry := range e.services {
 		serviceIDs = append(serviceIDs, id)
+		rules[id] = entry.rule
 	}
+	accounting := e.accounting
+	quotas := e.quotas
+	callback := e.onStatusChange
 	e.mu.RUnlock()
 
 	for _, id := range serviceIDs {
+		if t := getTracker(id); t != nil {
+			t.sample()
+		}
+
 		svc := registry.ServiceRegistry().Get(id)
 		if svc == nil {
 			continue
@@ -435,13 +1046,45 @@ func (e *Engine) collectStats() {
 						TotalErrs:    totalErrs,
 					})
 
-					// Debug log for first few updates to verify it's working
-					if bytesIn > 0 || bytesOut > 0 {
-						log.Printf("[Engine] Stats for %s: In=%d, Out=%d, Conns=%d/%d, Errs=%d",
-							id, bytesIn, bytesOut, currentConns, totalConns, totalErrs)
-					}
+					e.logger.Debug("Collected stats", "rule", id, "in", bytesIn, "out", bytesOut,
+						"conns", currentConns, "totalConns", totalConns, "errors", totalErrs)
 				}
 			}
 		}
 	}
+
+	// Record the updated counters in the traffic history, accounting and
+	// quotas
+	now := time.Now()
+	var exceeded []*models.Rule
+	for _, id := range serviceIDs {
+		stats := e.stats.GetStats(id)
+		e.history.record(id, now, stats)
+		if accounting != nil {
+			accounting.Update(id, rules[id].Name, now, stats)
+		}
+		if quotas != nil && e.enforceQuota(quotas, rules[id], now, stats) {
+			exceeded = append(exceeded, rules[id])
+		}
+	}
+	e.history.prune(now)
+	if accounting != nil {
+		if err := accounting.saveIfDue(now); err != nil {
+			e.logger.Error("Failed to save accounting", "error", err)
+		}
+	}
+	if quotas != nil {
+		if err := quotas.saveIfDue(now); err != nil {
+			e.logger.Error("Failed to save quota usage", "error", err)
+		}
+	}
+
+	// Stop the rules that used up their quota
+	for _, rule := range exceeded {
+		if err := e.StopRule(rule.ID); err != nil {
+			continue
+		}
+		e.logMgr.Warn(rule.ID, rule.Name, "quota.exhaustedStopped", nil)
+		e.statusChanged(callback, rule.ID, rule.Name, string(models.RuleStatusQuota), "")
+	}
 }
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-gost/core/handler"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/metadata"
+	"github.com/go-gost/x/config"
+	mdutil "github.com/go-gost/x/metadata/util"
+	"github.com/go-gost/x/registry"
+)
+
+const (
+	// wrapHandlerType is the gost handler type of pfmHandler
+	wrapHandlerType = "pfm"
+	// mdKeyInnerHandler is the handler metadata key naming the wrapped gost handler
+	mdKeyInnerHandler = "pfm.handler"
+)
+
+func init() {
+	registry.HandlerRegistry().Register(wrapHandlerType, newPFMHandler)
+}
+
+// pfmHandler wraps a regular gost handler so PFM can hook into every
+// accepted connection before the inner handler takes over. All rules are
+// served through it to keep the connection access log.
+type pfmHandler struct {
+	opts    []handler.Option
+	service string
+	hop     hop.Hop
+	inner   handler.Handler
+	mirror  *mirror
+}
+
+// newPFMHandler creates the wrapper, the inner handler is created in Init
+// once its type is known from the metadata
+func newPFMHandler(opts ...handler.Option) handler.Handler {
+	options := handler.Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Router != nil {
+		// Options apply in order, this one replaces the router given by gost
+		opts = append(opts, handler.RouterOption(&trackingRouter{Router: options.Router}))
+	}
+	return &pfmHandler{
+		opts:    opts,
+		service: options.Service,
+	}
+}
+
+// Forward implements handler.Forwarder. The hop is made swappable so
+// target changes apply without rebuilding the service.
+func (h *pfmHandler) Forward(hop hop.Hop) {
+	if hop == nil {
+		return
+	}
+	h.hop = newSwapHop(h.service, hop)
+}
+
+// Init implements handler.Handler
+func (h *pfmHandler) Init(md metadata.Metadata) error {
+	innerType := mdutil.GetString(md, mdKeyInnerHandler)
+	newHandler := registry.HandlerRegistry().Get(innerType)
+	if newHandler == nil {
+		return fmt.Errorf("unknown handler: %s", innerType)
+	}
+
+	h.inner = newHandler(h.opts...)
+	if f, ok := h.inner.(handler.Forwarder); ok && h.hop != nil {
+		f.Forward(h.hop)
+	}
+	h.mirror = parseMirror(h.service, md)
+
+	return h.inner.Init(md)
+}
+
+// Handle implements handler.Handler
+func (h *pfmHandler) Handle(ctx context.Context, conn net.Conn, opts ...handler.HandleOption) error {
+	var tc *trackedConn
+	if t := getTracker(h.service); t != nil {
+		ctx, tc, conn = t.track(ctx, conn)
+	}
+	if h.mirror != nil {
+		conn = h.mirror.wrapConn(conn)
+	}
+
+	err := h.inner.Handle(ctx, conn, opts...)
+	if tc != nil {
+		tc.finish(err)
+	}
+	return err
+}
+
+// wrapHandler turns a handler configuration into a pfmHandler configuration
+// that delegates to the original handler type
+func wrapHandler(cfg *config.HandlerConfig) {
+	if cfg.Metadata == nil {
+		cfg.Metadata = map[string]any{}
+	}
+	cfg.Metadata[mdKeyInnerHandler] = cfg.Type
+	cfg.Type = wrapHandlerType
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"pfm/internal/models"
+)
+
+const (
+	// secondSamples and minuteSamples size the ring buffers of a rule:
+	// 10 minutes of 1-second samples and 24 hours of 1-minute samples
+	secondSamples = 600
+	minuteSamples = 24 * 60
+
+	// historyGap is the longest time between two samples of a rule, after
+	// a longer pause the next sample only sets the baseline
+	historyGap = 5 * time.Second
+
+	// historyRetention is how long the history of a rule that stopped
+	// running is kept
+	historyRetention = 24 * time.Hour
+)
+
+// sampleRing is a fixed-size ring buffer of samples
+type sampleRing struct {
+	samples []models.StatsSample
+	next    int
+	full    bool
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{samples: make([]models.StatsSample, size)}
+}
+
+func (r *sampleRing) add(s models.StatsSample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// latest returns the last sample added
+func (r *sampleRing) latest() (models.StatsSample, bool) {
+	if !r.full && r.next == 0 {
+		return models.StatsSample{}, false
+	}
+	return r.samples[(r.next-1+len(r.samples))%len(r.samples)], true
+}
+
+// list returns the samples from since on, oldest first
+func (r *sampleRing) list(since time.Time) []models.StatsSample {
+	var ordered []models.StatsSample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+	ordered = append(ordered, r.samples[:r.next]...)
+
+	result := make([]models.StatsSample, 0, len(ordered))
+	for _, s := range ordered {
+		if !s.Time.Before(since) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// ruleHistory holds the time series of one rule
+type ruleHistory struct {
+	last     *models.RuleStats // counters at the previous sample
+	lastTime time.Time
+
+	seconds *sampleRing
+	minutes *sampleRing
+
+	// The minute being aggregated
+	minute   models.StatsSample
+	bytesIn  int64
+	bytesOut int64
+	covered  time.Duration
+}
+
+// statsHistory records the traffic of the running rules from the cumulative
+// counters collected by pollStats
+type statsHistory struct {
+	mu    sync.Mutex
+	rules map[string]*ruleHistory
+}
+
+func newStatsHistory() *statsHistory {
+	return &statsHistory{rules: make(map[string]*ruleHistory)}
+}
+
+// counterDelta returns how much a cumulative counter grew, a counter that
+// went down was reset when the rule was restarted
+func counterDelta(cur, prev int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// record adds a sample for a running rule from its cumulative counters
+func (h *statsHistory) record(ruleID string, now time.Time, stats *models.RuleStats) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rh, ok := h.rules[ruleID]
+	if !ok {
+		rh = &ruleHistory{seconds: newSampleRing(secondSamples), minutes: newSampleRing(minuteSamples)}
+		h.rules[ruleID] = rh
+	}
+	last, lastTime := rh.last, rh.lastTime
+	rh.last, rh.lastTime = stats, now
+
+	// The first sample, or the first after a pause, only sets the baseline
+	elapsed := now.Sub(lastTime)
+	if last == nil || elapsed <= 0 || elapsed > historyGap {
+		return
+	}
+
+	bytesIn := counterDelta(stats.BytesIn, last.BytesIn)
+	bytesOut := counterDelta(stats.BytesOut, last.BytesOut)
+	sample := models.StatsSample{
+		Time:        lastTime.Truncate(time.Second),
+		RateIn:      int64(float64(bytesIn) / elapsed.Seconds()),
+		RateOut:     int64(float64(bytesOut) / elapsed.Seconds()),
+		ActiveConns: stats.ActiveConns,
+		NewConns:    counterDelta(stats.Connections, last.Connections),
+		Errors:      counterDelta(stats.Errors, last.Errors),
+	}
+	rh.seconds.add(sample)
+
+	// Aggregate into the current minute, flushing the previous one
+	minute := lastTime.Truncate(time.Minute)
+	if !rh.minute.Time.Equal(minute) {
+		rh.flushMinute()
+		rh.minute = models.StatsSample{Time: minute}
+	}
+	rh.bytesIn += bytesIn
+	rh.bytesOut += bytesOut
+	rh.covered += elapsed
+	rh.minute.NewConns += sample.NewConns
+	rh.minute.Errors += sample.Errors
+	if sample.ActiveConns > rh.minute.ActiveConns {
+		rh.minute.ActiveConns = sample.ActiveConns
+	}
+}
+
+// flushMinute adds the aggregated minute to the minute series
+func (rh *ruleHistory) flushMinute() {
+	if rh.covered <= 0 {
+		return
+	}
+	rh.minute.RateIn = int64(float64(rh.bytesIn) / rh.covered.Seconds())
+	rh.minute.RateOut = int64(float64(rh.bytesOut) / rh.covered.Seconds())
+	rh.minutes.add(rh.minute)
+	rh.bytesIn, rh.bytesOut, rh.covered = 0, 0, 0
+}
+
+// prune drops the history of rules without a sample for historyRetention
+func (h *statsHistory) prune(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, rh := range h.rules {
+		if now.Sub(rh.lastTime) > historyRetention {
+			delete(h.rules, id)
+		}
+	}
+}
+
+// get returns the samples of a rule. The minute being aggregated is only
+// included once it is complete.
+func (h *statsHistory) get(ruleID string, resolution models.HistoryResolution, now time.Time) []models.StatsSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rh, ok := h.rules[ruleID]
+	if !ok {
+		return []models.StatsSample{}
+	}
+	if resolution == models.HistoryMinute {
+		// A minute with no sample since has ended
+		if rh.covered > 0 && now.Truncate(time.Minute).After(rh.minute.Time) {
+			rh.flushMinute()
+		}
+		return rh.minutes.list(now.Add(-minuteSamples * time.Minute))
+	}
+	return rh.seconds.list(now.Add(-secondSamples * time.Second))
+}
+
+// current returns the rates of the latest sample of a rule, 0 if the rule
+// had no recent sample
+func (h *statsHistory) current(ruleID string, now time.Time) (rateIn, rateOut int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rh, ok := h.rules[ruleID]
+	if !ok || now.Sub(rh.lastTime) > historyGap {
+		return 0, 0
+	}
+	s, ok := rh.seconds.latest()
+	if !ok {
+		return 0, 0
+	}
+	return s.RateIn, s.RateOut
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+func TestStatsHistory(t *testing.T) {
+	h := newStatsHistory()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 90 seconds of 1000 B/s in and 500 B/s out, one new connection a second
+	var in, out, conns int64
+	for i := 0; i <= 90; i++ {
+		h.record("r1", start.Add(time.Duration(i)*time.Second), &models.RuleStats{
+			BytesIn: in, BytesOut: out, Connections: conns, ActiveConns: i % 5,
+		})
+		in, out, conns = in+1000, out+500, conns+1
+	}
+	now := start.Add(90 * time.Second)
+
+	seconds := h.get("r1", models.HistorySecond, now)
+	if len(seconds) != 90 {
+		t.Fatalf("got %d second samples, want 90", len(seconds))
+	}
+	for _, s := range seconds {
+		if s.RateIn != 1000 || s.RateOut != 500 || s.NewConns != 1 {
+			t.Fatalf("sample = %+v", s)
+		}
+	}
+	if first := seconds[0].Time; !first.Equal(start) {
+		t.Errorf("first sample at %v, want %v", first, start)
+	}
+	if rateIn, rateOut := h.current("r1", now); rateIn != 1000 || rateOut != 500 {
+		t.Errorf("current() = %d, %d", rateIn, rateOut)
+	}
+
+	// The first minute is complete, the second one is still being aggregated
+	minutes := h.get("r1", models.HistoryMinute, now)
+	if len(minutes) != 1 {
+		t.Fatalf("got %d minute samples, want 1", len(minutes))
+	}
+	if m := minutes[0]; !m.Time.Equal(start) || m.RateIn != 1000 || m.NewConns != 60 || m.ActiveConns != 4 {
+		t.Errorf("minute sample = %+v", m)
+	}
+	if minutes := h.get("r1", models.HistoryMinute, start.Add(2*time.Minute)); len(minutes) != 2 {
+		t.Errorf("got %d minute samples after the second minute ended, want 2", len(minutes))
+	}
+
+	// A restart resets the counters, a pause leaves a gap
+	h.record("r1", now.Add(time.Second), &models.RuleStats{BytesIn: 300})
+	h.record("r1", now.Add(time.Minute), &models.RuleStats{BytesIn: 400})
+	seconds = h.get("r1", models.HistorySecond, now.Add(time.Minute))
+	if last := seconds[len(seconds)-1]; last.RateIn != 300 {
+		t.Errorf("sample after a counter reset = %+v, want 300 B/s", last)
+	}
+	if rateIn, _ := h.current("r1", now.Add(time.Hour)); rateIn != 0 {
+		t.Errorf("current() of an idle rule = %d, want 0", rateIn)
+	}
+
+	// The ring keeps the last 10 minutes
+	for i := 0; i <= 2*secondSamples; i++ {
+		h.record("r2", start.Add(time.Duration(i)*time.Second), &models.RuleStats{})
+	}
+	end := start.Add(2 * secondSamples * time.Second)
+	seconds = h.get("r2", models.HistorySecond, end)
+	if len(seconds) != secondSamples || !seconds[len(seconds)-1].Time.Equal(end.Add(-time.Second)) {
+		t.Errorf("got %d second samples ending at %v", len(seconds), seconds[len(seconds)-1].Time)
+	}
+
+	h.prune(end.Add(historyRetention + time.Second))
+	if got := h.get("r2", models.HistorySecond, end); len(got) != 0 {
+		t.Errorf("history kept after the retention, %d samples", len(got))
+	}
+}
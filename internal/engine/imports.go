@@ -2,6 +2,8 @@ package engine
 
 import (
 	// Register listeners
+	_ "github.com/go-gost/x/listener/redirect/tcp"
+	_ "github.com/go-gost/x/listener/redirect/udp"
 	_ "github.com/go-gost/x/listener/tcp"
 	_ "github.com/go-gost/x/listener/udp"
 
@@ -9,6 +11,8 @@ import (
 	_ "github.com/go-gost/x/handler/forward/local" // forward handler
 	_ "github.com/go-gost/x/handler/forward/remote"
 	_ "github.com/go-gost/x/handler/http"
+	_ "github.com/go-gost/x/handler/redirect/tcp"
+	_ "github.com/go-gost/x/handler/redirect/udp"
 	_ "github.com/go-gost/x/handler/socks/v5"
 	_ "github.com/go-gost/x/handler/ss"
 
@@ -2,9 +2,12 @@ package engine
 
 import (
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
+	"pfm/internal/i18n"
+	"pfm/internal/logging"
 	"pfm/internal/models"
 )
 
@@ -14,7 +17,12 @@ type LogManager struct {
 	entries  []models.LogEntry
 	maxSize  int
 	nextID   int64
+	store    *LogStore
 	onChange func(entry models.LogEntry)
+
+	// Callbacks added by Subscribe, by subscription number
+	subscribers   map[int]func(entry models.LogEntry)
+	nextSubscribe int
 }
 
 // NewLogManager creates a new log manager
@@ -36,8 +44,55 @@ func (m *LogManager) SetOnChange(fn func(entry models.LogEntry)) {
 	m.onChange = fn
 }
 
-// Add adds a new log entry
-func (m *LogManager) Add(level models.LogLevel, ruleID, ruleName, message string, details ...string) {
+// Subscribe adds a callback called with every new entry, in addition to
+// the one set by SetOnChange. It returns a function removing the callback.
+func (m *LogManager) Subscribe(fn func(entry models.LogEntry)) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.subscribers == nil {
+		m.subscribers = make(map[int]func(entry models.LogEntry))
+	}
+	id := m.nextSubscribe
+	m.nextSubscribe++
+	m.subscribers[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// SetStore sets the store persisting new entries. IDs continue after the
+// last persisted entry.
+func (m *LogManager) SetStore(store *LogStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	if store != nil && store.LastID() >= m.nextID {
+		m.nextID = store.LastID() + 1
+	}
+}
+
+// SetRetention sets how many bytes of persisted entries are kept and for
+// how long
+func (m *LogManager) SetRetention(maxSize int64, maxAge time.Duration) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store != nil {
+		store.SetRetention(maxSize, maxAge)
+	}
+}
+
+// Add adds a new log entry. The message is given by its key in the i18n
+// catalog and its parameters, free text being kept as is. Debug entries are
+// only added at the debug level or for rules in debug.
+func (m *LogManager) Add(level models.LogLevel, ruleID, ruleName, key string, params i18n.Params, details ...string) {
+	if level == models.LogLevelDebug && !logging.Enabled(slog.LevelDebug, ruleID) {
+		return
+	}
+
 	m.mu.Lock()
 
 	entry := models.LogEntry{
@@ -46,7 +101,9 @@ func (m *LogManager) Add(level models.LogLevel, ruleID, ruleName, message string
 		Level:     level,
 		RuleID:    ruleID,
 		RuleName:  ruleName,
-		Message:   message,
+		Message:   i18n.T(key, params),
+		Key:       key,
+		Params:    params,
 	}
 
 	if len(details) > 0 {
@@ -55,6 +112,13 @@ func (m *LogManager) Add(level models.LogLevel, ruleID, ruleName, message string
 
 	m.nextID++
 
+	// Persist in ID order
+	if m.store != nil {
+		if err := m.store.Write(&entry); err != nil {
+			defaultLogger.Error("Failed to persist log entry", "error", err)
+		}
+	}
+
 	// Circular buffer behavior
 	if len(m.entries) >= m.maxSize {
 		m.entries = append(m.entries[1:], entry)
@@ -63,66 +127,71 @@ func (m *LogManager) Add(level models.LogLevel, ruleID, ruleName, message string
 	}
 
 	callback := m.onChange
+	subscribers := make([]func(entry models.LogEntry), 0, len(m.subscribers))
+	for _, fn := range m.subscribers {
+		subscribers = append(subscribers, fn)
+	}
 	m.mu.Unlock()
 
 	// Call callback outside of lock
 	if callback != nil {
 		callback(entry)
 	}
+	for _, fn := range subscribers {
+		fn(entry)
+	}
 }
 
 // Debug adds a debug level log entry
-func (m *LogManager) Debug(ruleID, ruleName, message string, details ...string) {
-	m.Add(models.LogLevelDebug, ruleID, ruleName, message, details...)
+func (m *LogManager) Debug(ruleID, ruleName, key string, params i18n.Params, details ...string) {
+	m.Add(models.LogLevelDebug, ruleID, ruleName, key, params, details...)
 }
 
 // Info adds an info level log entry
-func (m *LogManager) Info(ruleID, ruleName, message string, details ...string) {
-	m.Add(models.LogLevelInfo, ruleID, ruleName, message, details...)
+func (m *LogManager) Info(ruleID, ruleName, key string, params i18n.Params, details ...string) {
+	m.Add(models.LogLevelInfo, ruleID, ruleName, key, params, details...)
 }
 
 // Warn adds a warning level log entry
-func (m *LogManager) Warn(ruleID, ruleName, message string, details ...string) {
-	m.Add(models.LogLevelWarn, ruleID, ruleName, message, details...)
+func (m *LogManager) Warn(ruleID, ruleName, key string, params i18n.Params, details ...string) {
+	m.Add(models.LogLevelWarn, ruleID, ruleName, key, params, details...)
 }
 
 // Error adds an error level log entry
-func (m *LogManager) Error(ruleID, ruleName, message string, details ...string) {
-	m.Add(models.LogLevelError, ruleID, ruleName, message, details...)
+func (m *LogManager) Error(ruleID, ruleName, key string, params i18n.Params, details ...string) {
+	m.Add(models.LogLevelError, ruleID, ruleName, key, params, details...)
 }
 
-// LogConnection logs a connection event
+// LogConnection logs a connection event, at the debug level as busy rules
+// would flood the logs
 func (m *LogManager) LogConnection(ruleID, ruleName, clientAddr, targetAddr string) {
-	message := fmt.Sprintf("新连接: %s -> %s", clientAddr, targetAddr)
-	m.Info(ruleID, ruleName, message)
+	m.Debug(ruleID, ruleName, "conn.new", i18n.Params{"client": clientAddr, "target": targetAddr})
 }
 
-// LogDisconnection logs a disconnection event
+// LogDisconnection logs a disconnection event, at the debug level like
+// LogConnection
 func (m *LogManager) LogDisconnection(ruleID, ruleName, clientAddr string, bytesIn, bytesOut int64) {
-	message := fmt.Sprintf("连接断开: %s (接收: %s, 发送: %s)", clientAddr, formatBytes(bytesIn), formatBytes(bytesOut))
-	m.Info(ruleID, ruleName, message)
+	m.Debug(ruleID, ruleName, "conn.closed", i18n.Params{"client": clientAddr, "in": formatBytes(bytesIn), "out": formatBytes(bytesOut)})
 }
 
 // LogTransfer logs data transfer
 func (m *LogManager) LogTransfer(ruleID, ruleName, direction string, bytes int64) {
-	message := fmt.Sprintf("数据传输 [%s]: %s", direction, formatBytes(bytes))
-	m.Debug(ruleID, ruleName, message)
+	m.Debug(ruleID, ruleName, "conn.transfer", i18n.Params{"direction": direction, "bytes": formatBytes(bytes)})
 }
 
 // LogError logs an error
 func (m *LogManager) LogError(ruleID, ruleName string, err error) {
-	m.Error(ruleID, ruleName, "错误", err.Error())
+	m.Error(ruleID, ruleName, "service.error", nil, err.Error())
 }
 
 // LogServiceStart logs service start
 func (m *LogManager) LogServiceStart(ruleID, ruleName, listenAddr string) {
-	message := fmt.Sprintf("服务启动: 监听 %s", listenAddr)
-	m.Info(ruleID, ruleName, message)
+	m.Info(ruleID, ruleName, "service.start", i18n.Params{"addr": listenAddr})
 }
 
 // LogServiceStop logs service stop
 func (m *LogManager) LogServiceStop(ruleID, ruleName string) {
-	m.Info(ruleID, ruleName, "服务停止")
+	m.Info(ruleID, ruleName, "service.stop", nil)
 }
 
 // GetAll returns all log entries
@@ -180,7 +249,43 @@ func (m *LogManager) GetSince(sinceID int64) []models.LogEntry {
 	return result
 }
 
-// Clear clears all log entries
+// Query returns a page of the persisted entries matching q, newest first.
+// Without a store, the entries in memory are searched.
+func (m *LogManager) Query(q models.LogQuery) (*models.LogPage, error) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store != nil {
+		return store.Query(q)
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	from, to, _ := q.Range()
+	page := &models.LogPage{Entries: []models.LogEntry{}}
+	entries := m.GetAll()
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if !q.Matches(e) {
+			continue
+		}
+		if !from.IsZero() || !to.IsZero() {
+			t, err := time.Parse(time.RFC3339, e.Timestamp)
+			if err != nil || (!from.IsZero() && t.Before(from)) || (!to.IsZero() && t.After(to)) {
+				continue
+			}
+		}
+		if len(page.Entries) == q.Limit {
+			page.NextBeforeID = page.Entries[q.Limit-1].ID
+			break
+		}
+		page.Entries = append(page.Entries, *e)
+	}
+	return page, nil
+}
+
+// Clear clears the log entries in memory, persisted entries are kept
 func (m *LogManager) Clear() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
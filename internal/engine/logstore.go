@@ -0,0 +1,307 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pfm/internal/models"
+)
+
+const (
+	// logStoreName is the name of the current log file, rotated files get
+	// a numeric suffix (pfm.log.1 is the most recent)
+	logStoreName = "pfm.log"
+
+	// logFileSize is the size at which the current file is rotated
+	logFileSize = 10 * 1024 * 1024
+
+	// logPruneInterval is how often files past the maximum age are removed
+	// when no rotation happens
+	logPruneInterval = time.Hour
+)
+
+// LogStore persists log entries to rotating JSON lines files, keeping them
+// up to a total size and age
+type LogStore struct {
+	mu        sync.Mutex
+	dir       string
+	fileSize  int64
+	maxFiles  int
+	maxAge    time.Duration
+	file      *os.File
+	size      int64
+	lastID    int64
+	lastPrune time.Time
+}
+
+// NewLogStore opens the log files in dir with the default retention
+func NewLogStore(dir string) (*LogStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	s := &LogStore{dir: dir}
+	s.setRetention(models.DefaultLogMaxSize<<20, models.DefaultLogMaxAge*24*time.Hour)
+
+	for i := 0; i < s.maxFiles && s.lastID == 0; i++ {
+		id, err := lastLogID(s.path(i))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		s.lastID = id
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetRetention sets how many bytes of log files are kept and for how long,
+// removing the files beyond
+func (s *LogStore) SetRetention(maxSize int64, maxAge time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setRetention(maxSize, maxAge)
+	s.prune(time.Now())
+}
+
+func (s *LogStore) setRetention(maxSize int64, maxAge time.Duration) {
+	// Keep at least the current file and one rotated file
+	s.fileSize = logFileSize
+	if maxSize < 2*s.fileSize {
+		s.fileSize = maxSize / 2
+	}
+	if s.fileSize <= 0 {
+		s.fileSize = 1
+	}
+	s.maxFiles = int(maxSize / s.fileSize)
+	s.maxAge = maxAge
+}
+
+// LastID returns the ID of the last persisted entry
+func (s *LogStore) LastID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID
+}
+
+// Write appends an entry, rotating the current file when it is full
+func (s *LogStore) Write(entry *models.LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(data)) > s.fileSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		s.prune(now)
+	} else if now.Sub(s.lastPrune) >= logPruneInterval {
+		s.prune(now)
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	if entry.ID > s.lastID {
+		s.lastID = entry.ID
+	}
+	return err
+}
+
+// open opens the current file for appending
+func (s *LogStore) open() error {
+	f, err := os.OpenFile(s.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files by one, dropping the oldest, and starts
+// a new current file
+func (s *LogStore) rotate() error {
+	s.file.Close()
+	s.file = nil
+
+	os.Remove(s.path(s.maxFiles - 1))
+	for i := s.maxFiles - 2; i >= 0; i-- {
+		if err := os.Rename(s.path(i), s.path(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	return s.open()
+}
+
+// prune removes the rotated files beyond the maximum count, and those
+// last written before the maximum age
+func (s *LogStore) prune(now time.Time) {
+	s.lastPrune = now
+	matches, _ := filepath.Glob(filepath.Join(s.dir, logStoreName+".*"))
+	for _, path := range matches {
+		var i int
+		if _, err := fmt.Sscanf(filepath.Ext(path), ".%d", &i); err != nil || i < 1 {
+			continue
+		}
+		if i >= s.maxFiles {
+			os.Remove(path)
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > s.maxAge {
+			os.Remove(path)
+		}
+	}
+}
+
+// path returns the path of the i-th file, 0 being the current one
+func (s *LogStore) path(i int) string {
+	if i == 0 {
+		return filepath.Join(s.dir, logStoreName)
+	}
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%d", logStoreName, i))
+}
+
+// Query returns a page of the entries matching q, newest first
+func (s *LogStore) Query(q models.LogQuery) (*models.LogPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	from, to, _ := q.Range()
+
+	// Read without holding the lock so logging is not held up. An entry
+	// read twice because of a rotation meanwhile is skipped by its ID.
+	s.mu.Lock()
+	maxFiles := s.maxFiles
+	s.mu.Unlock()
+
+	page := &models.LogPage{Entries: []models.LogEntry{}}
+	for i := 0; i < maxFiles; i++ {
+		entries, err := readLogFile(s.path(i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		// Files are chronological, walk them backwards
+		for j := len(entries) - 1; j >= 0; j-- {
+			e := &entries[j]
+			if n := len(page.Entries); n > 0 && e.ID >= page.Entries[n-1].ID {
+				continue
+			}
+			if !q.Matches(e) {
+				continue
+			}
+			if !from.IsZero() || !to.IsZero() {
+				t, err := time.Parse(time.RFC3339, e.Timestamp)
+				if err != nil {
+					continue
+				}
+				if !from.IsZero() && t.Before(from) {
+					// Everything further back is older
+					return page, nil
+				}
+				if !to.IsZero() && t.After(to) {
+					continue
+				}
+			}
+			if len(page.Entries) == q.Limit {
+				page.NextBeforeID = page.Entries[q.Limit-1].ID
+				return page, nil
+			}
+			page.Entries = append(page.Entries, *e)
+		}
+	}
+	return page, nil
+}
+
+// Close closes the current file
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// readLogFile reads the entries of one log file. Malformed lines (e.g. a
+// partial write) are skipped.
+func readLogFile(path string) ([]models.LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []models.LogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e models.LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// lastLogID returns the ID of the last entry of a log file, reading only
+// its end
+func lastLogID(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	const tail = 64 * 1024
+	offset := info.Size() - tail
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return 0, err
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var e models.LogEntry
+		if err := json.Unmarshal(lines[i], &e); err == nil {
+			return e.ID, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pfm/internal/logging"
+	"pfm/internal/models"
+)
+
+func TestLogStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLogStore(dir)
+	if err != nil {
+		t.Fatalf("NewLogStore() error = %v", err)
+	}
+
+	m := NewLogManager(10)
+	m.SetStore(store)
+	for i := 1; i <= 30; i++ {
+		switch {
+		case i%10 == 0:
+			m.Error("r1", "web", fmt.Sprintf("dial failed %d", i), nil, "connection refused")
+		case i%2 == 0:
+			m.Info("r1", "web", fmt.Sprintf("connection %d", i), nil)
+		default:
+			m.Info("r2", "ssh", fmt.Sprintf("connection %d", i), nil)
+		}
+	}
+
+	// Everything is persisted, not only what is kept in memory
+	page, err := m.Query(models.LogQuery{Limit: 1000})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(page.Entries) != 30 || page.Entries[0].ID != 30 || page.Entries[29].ID != 1 {
+		t.Fatalf("Query() returned %d entries, want 30 newest first", len(page.Entries))
+	}
+
+	tests := []struct {
+		name  string
+		query models.LogQuery
+		ids   []int64
+		next  int64
+	}{
+		{"level", models.LogQuery{Level: models.LogLevelWarn}, []int64{30, 20, 10}, 0},
+		{"rule and search", models.LogQuery{RuleID: "r1", Search: "REFUSED"}, []int64{30, 20, 10}, 0},
+		{"rule name", models.LogQuery{Search: "ssh", Limit: 2}, []int64{29, 27}, 27},
+		{"next page", models.LogQuery{Search: "ssh", Limit: 2, BeforeID: 27}, []int64{25, 23}, 23},
+		{"future", models.LogQuery{From: time.Now().Add(time.Hour).Format(time.RFC3339)}, nil, 0},
+		{"today", models.LogQuery{From: time.Now().Format("2006-01-02"), To: time.Now().Format("2006-01-02"), Level: models.LogLevelError}, []int64{30, 20, 10}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			var ids []int64
+			for _, e := range page.Entries {
+				ids = append(ids, e.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.ids) || page.NextBeforeID != tt.next {
+				t.Errorf("Query() = %v next %d, want %v next %d", ids, page.NextBeforeID, tt.ids, tt.next)
+			}
+		})
+	}
+
+	if _, err := store.Query(models.LogQuery{Level: "fatal"}); err == nil {
+		t.Error("Query() with an unknown level succeeded")
+	}
+
+	// Clearing the view in memory keeps the persisted entries, and IDs go
+	// on after a restart
+	m.Clear()
+	store.Close()
+	store, err = NewLogStore(dir)
+	if err != nil {
+		t.Fatalf("NewLogStore() error = %v", err)
+	}
+	defer store.Close()
+	m = NewLogManager(10)
+	m.SetStore(store)
+	m.Info("", "", "restarted", nil)
+	page, _ = m.Query(models.LogQuery{Limit: 2})
+	if len(page.Entries) != 2 || page.Entries[0].ID != 31 || page.Entries[1].ID != 30 {
+		t.Errorf("entries after a restart = %+v", page.Entries)
+	}
+}
+
+func TestLogStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLogStore(dir)
+	if err != nil {
+		t.Fatalf("NewLogStore() error = %v", err)
+	}
+	defer store.Close()
+
+	// About 130 bytes per entry: two files of 2 KB
+	store.SetRetention(4096, time.Hour)
+	for i := int64(1); i <= 100; i++ {
+		if err := store.Write(&models.LogEntry{ID: i, Timestamp: time.Now().Format(time.RFC3339), Level: models.LogLevelInfo, Message: "a message of some length"}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, logStoreName+"*"))
+	var size int64
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			size += info.Size()
+		}
+	}
+	if len(files) != 2 || size > 4096 {
+		t.Errorf("%d files of %d bytes kept, want 2 within 4096 bytes", len(files), size)
+	}
+	page, _ := store.Query(models.LogQuery{Limit: 1000})
+	if len(page.Entries) < 10 || len(page.Entries) >= 100 || page.Entries[0].ID != 100 {
+		t.Errorf("%d entries kept", len(page.Entries))
+	}
+
+	// Rotated files past the maximum age are removed, the current one is
+	// kept
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(store.path(1), old, old)
+	os.Chtimes(store.path(0), old, old)
+	store.SetRetention(4096, time.Hour)
+	if _, err := os.Stat(store.path(1)); !os.IsNotExist(err) {
+		t.Error("old log file not removed")
+	}
+	if _, err := os.Stat(store.path(0)); err != nil {
+		t.Errorf("current log file removed: %v", err)
+	}
+}
+
+func TestConnectionLogs(t *testing.T) {
+	m := NewLogManager(10)
+	m.LogConnection("r1", "web", "10.0.0.1:5000", "10.0.0.2:80")
+	m.LogDisconnection("r1", "web", "10.0.0.1:5000", 100, 200)
+	if got := m.GetAll(); len(got) != 0 {
+		t.Fatalf("connections logged without debug: %+v", got)
+	}
+
+	logging.SetRuleDebug("r1", true)
+	defer logging.SetRuleDebug("r1", false)
+	m.LogConnection("r1", "web", "10.0.0.1:5000", "10.0.0.2:80")
+	m.LogDisconnection("r1", "web", "10.0.0.1:5000", 100, 200)
+	m.LogConnection("r2", "db", "10.0.0.1:5001", "10.0.0.3:5432")
+	got := m.GetAll()
+	if len(got) != 2 || got[0].Key != "conn.new" || got[1].Key != "conn.closed" || got[0].Level != models.LogLevelDebug {
+		t.Errorf("connections logged for a rule in debug: %+v", got)
+	}
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pfm/internal/models"
+
+	"github.com/go-gost/core/metadata"
+	mdutil "github.com/go-gost/x/metadata/util"
+)
+
+const (
+	// Handler metadata keys describing the mirror target
+	mdKeyMirrorAddr   = "mirror.addr"
+	mdKeyMirrorSample = "mirror.sample"
+
+	// mirrorQueueSize is the number of chunks buffered per mirrored connection
+	// before new chunks are dropped
+	mirrorQueueSize = 256
+
+	mirrorDialTimeout = 5 * time.Second
+)
+
+// mirrorCounters holds the statistics of a rule's mirror target
+type mirrorCounters struct {
+	sessions int64
+	skipped  int64
+	bytesOut int64
+	dropped  int64
+	errors   int64
+}
+
+// mirrorStats maps rule IDs to their mirror counters. Counters live outside
+// the engine because gost creates the handlers while parsing the service.
+var mirrorStats sync.Map
+
+// getMirrorCounters returns the mirror counters of a rule, creating them if needed
+func getMirrorCounters(ruleID string) *mirrorCounters {
+	c, _ := mirrorStats.LoadOrStore(ruleID, &mirrorCounters{})
+	return c.(*mirrorCounters)
+}
+
+// resetMirrorStats clears the mirror counters of a rule
+func resetMirrorStats(ruleID string) {
+	mirrorStats.Delete(ruleID)
+}
+
+// getMirrorStats returns a snapshot of a rule's mirror counters, or nil if
+// the rule never mirrored any traffic
+func getMirrorStats(ruleID string) *models.MirrorStats {
+	v, ok := mirrorStats.Load(ruleID)
+	if !ok {
+		return nil
+	}
+	c := v.(*mirrorCounters)
+	return &models.MirrorStats{
+		Sessions: atomic.LoadInt64(&c.sessions),
+		Skipped:  atomic.LoadInt64(&c.skipped),
+		BytesOut: atomic.LoadInt64(&c.bytesOut),
+		Dropped:  atomic.LoadInt64(&c.dropped),
+		Errors:   atomic.LoadInt64(&c.errors),
+	}
+}
+
+// buildMirror adds the mirror node of a forward rule to its handler metadata
+func buildMirror(rule *models.Rule, md map[string]any) {
+	if rule.Mirror == nil || !rule.Mirror.Enabled {
+		return
+	}
+	md[mdKeyMirrorAddr] = rule.Mirror.GetAddr()
+	md[mdKeyMirrorSample] = rule.Mirror.GetSamplePercent()
+}
+
+// mirror copies client-to-server traffic to a shadow target
+type mirror struct {
+	addr     string
+	sample   int
+	counters *mirrorCounters
+}
+
+// parseMirror reads the mirror settings from handler metadata
+func parseMirror(ruleID string, md metadata.Metadata) *mirror {
+	addr := mdutil.GetString(md, mdKeyMirrorAddr)
+	if addr == "" {
+		return nil
+	}
+	return &mirror{
+		addr:     addr,
+		sample:   mdutil.GetInt(md, mdKeyMirrorSample),
+		counters: getMirrorCounters(ruleID),
+	}
+}
+
+// sampled decides whether a connection or datagram is copied
+func (m *mirror) sampled() bool {
+	if m.sample >= 100 || rand.IntN(100) < m.sample {
+		return true
+	}
+	atomic.AddInt64(&m.counters.skipped, 1)
+	return false
+}
+
+// wrapConn returns a connection that tees everything read from the client
+// to the mirror. TCP connections are sampled as a whole, UDP sessions per datagram.
+func (m *mirror) wrapConn(conn net.Conn) net.Conn {
+	pc, isPacket := conn.(net.PacketConn)
+	if !isPacket && !m.sampled() {
+		return conn
+	}
+
+	network := "tcp"
+	if isPacket {
+		network = "udp"
+	}
+
+	mc := &mirrorConn{
+		Conn:     conn,
+		mirror:   m,
+		perChunk: isPacket,
+		queue:    make(chan []byte, mirrorQueueSize),
+		done:     make(chan struct{}),
+	}
+	atomic.AddInt64(&m.counters.sessions, 1)
+	go mc.run(network)
+
+	if isPacket {
+		return &mirrorPacketConn{mirrorConn: mc, pc: pc}
+	}
+	return mc
+}
+
+// mirrorConn tees reads from the client connection into a queue drained by run
+type mirrorConn struct {
+	net.Conn
+	mirror   *mirror
+	perChunk bool // sample every chunk (datagram) instead of the whole connection
+	queue    chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (c *mirrorConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	c.tee(b[:n])
+	return
+}
+
+func (c *mirrorConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+// tee queues a copy of b for the mirror, never blocking the client
+func (c *mirrorConn) tee(b []byte) {
+	if len(b) == 0 || (c.perChunk && !c.mirror.sampled()) {
+		return
+	}
+	buf := make([]byte, len(b))
+	copy(buf, b)
+
+	select {
+	case <-c.done:
+	case c.queue <- buf:
+	default:
+		atomic.AddInt64(&c.mirror.counters.dropped, 1)
+	}
+}
+
+// run dials the mirror target and writes the queued chunks until the client
+// connection is closed. Responses from the mirror are discarded.
+func (c *mirrorConn) run(network string) {
+	cc, err := net.DialTimeout(network, c.mirror.addr, mirrorDialTimeout)
+	if err != nil {
+		atomic.AddInt64(&c.mirror.counters.errors, 1)
+		// Keep draining so the client side never notices
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-c.queue:
+			}
+		}
+	}
+	defer cc.Close()
+	go io.Copy(io.Discard, cc)
+
+	broken := false
+	for {
+		select {
+		case <-c.done:
+			return
+		case buf := <-c.queue:
+			if broken {
+				continue
+			}
+			n, err := cc.Write(buf)
+			atomic.AddInt64(&c.mirror.counters.bytesOut, int64(n))
+			if err != nil {
+				atomic.AddInt64(&c.mirror.counters.errors, 1)
+				broken = true
+			}
+		}
+	}
+}
+
+// mirrorPacketConn keeps the net.PacketConn interface of UDP client
+// connections, gost relies on it to pick the UDP code path
+type mirrorPacketConn struct {
+	*mirrorConn
+	pc net.PacketConn
+}
+
+func (c *mirrorPacketConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.pc.ReadFrom(b)
+	c.tee(b[:n])
+	return
+}
+
+func (c *mirrorPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.pc.WriteTo(b, addr)
+}
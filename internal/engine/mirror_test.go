@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+func TestMirrorConfig(t *testing.T) {
+	rule := &models.Rule{
+		ID:         "rule-mirror",
+		Name:       "Mirrored",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  8080,
+		TargetHost: "127.0.0.1",
+		TargetPort: 80,
+		Mirror:     &models.MirrorConfig{Enabled: true, Host: "127.0.0.1", Port: 8081, SamplePercent: 50},
+	}
+
+	cfg, err := RuleToGostConfig(rule, nil)
+	if err != nil {
+		t.Fatalf("RuleToGostConfig() error = %v", err)
+	}
+	h := cfg.Services[0].Handler
+	if h.Type != wrapHandlerType {
+		t.Errorf("handler type = %s, want %s", h.Type, wrapHandlerType)
+	}
+	if h.Metadata[mdKeyInnerHandler] != "forward" {
+		t.Errorf("inner handler = %v, want forward", h.Metadata[mdKeyInnerHandler])
+	}
+	if h.Metadata[mdKeyMirrorAddr] != "127.0.0.1:8081" || h.Metadata[mdKeyMirrorSample] != 50 {
+		t.Errorf("unexpected mirror metadata: %v", h.Metadata)
+	}
+}
+
+func TestMirrorConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		b, _ := io.ReadAll(io.LimitReader(c, 5))
+		received <- b
+	}()
+
+	const ruleID = "rule-mirror-conn"
+	defer resetMirrorStats(ruleID)
+	m := &mirror{addr: ln.Addr().String(), sample: 100, counters: getMirrorCounters(ruleID)}
+
+	client, server := net.Pipe()
+	conn := m.wrapConn(server)
+	go client.Write([]byte("hello"))
+
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	select {
+	case b := <-received:
+		if string(b) != "hello" {
+			t.Errorf("mirror received %q, want %q", b, "hello")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("mirror received nothing")
+	}
+
+	// The counter is updated right after the write returns
+	deadline := time.Now().Add(time.Second)
+	stats := getMirrorStats(ruleID)
+	for stats.BytesOut != 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		stats = getMirrorStats(ruleID)
+	}
+	if stats.Sessions != 1 || stats.BytesOut != 5 {
+		t.Errorf("unexpected mirror stats: %+v", stats)
+	}
+	conn.Close()
+	client.Close()
+}
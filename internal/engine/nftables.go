@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pfm/internal/models"
+)
+
+// NftablesSnippet returns the nftables ruleset that diverts traffic to a
+// transparent rule. The snippet is only generated, never applied: the
+// firewall stays under the administrator's control.
+func NftablesSnippet(rule *models.Rule) (string, error) {
+	if rule.Type != models.RuleTypeTransparent {
+		return "", fmt.Errorf("rule %s is not a transparent proxy rule", rule.ID)
+	}
+	if err := rule.Validate(); err != nil {
+		return "", err
+	}
+
+	proto := "tcp"
+	if rule.Protocol == models.ProtocolUDP {
+		proto = "udp"
+	}
+
+	// Table names only allow a restricted charset, keep a short ID prefix
+	table := "pfm_" + strings.ReplaceAll(shortID(rule.ID), "-", "")
+	mode := rule.Transparent.GetMode()
+
+	var match string
+	if rule.Transparent != nil && len(rule.Transparent.DestPorts) > 0 {
+		ports := make([]string, len(rule.Transparent.DestPorts))
+		for i, p := range rule.Transparent.DestPorts {
+			ports[i] = strconv.Itoa(p)
+		}
+		match = fmt.Sprintf("%s dport { %s } ", proto, strings.Join(ports, ", "))
+	} else {
+		match = fmt.Sprintf("meta l4proto %s ", proto)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# PFM transparent rule %q (%s), mode %s\n", rule.Name, rule.ID, mode)
+	fmt.Fprintf(&b, "table ip %s {\n", table)
+	b.WriteString("\tchain prerouting {\n")
+	if mode == models.TransparentModeTProxy {
+		b.WriteString("\t\ttype filter hook prerouting priority mangle; policy accept;\n")
+	} else {
+		b.WriteString("\t\ttype nat hook prerouting priority dstnat; policy accept;\n")
+	}
+
+	// Never intercept traffic addressed to this host or to excluded networks
+	b.WriteString("\t\tfib daddr type local return\n")
+	excludes := []string{"127.0.0.0/8"}
+	if rule.Transparent != nil {
+		excludes = append(excludes, rule.Transparent.ExcludeCIDRs...)
+	}
+	fmt.Fprintf(&b, "\t\tip daddr { %s } return\n", strings.Join(excludes, ", "))
+
+	if mode == models.TransparentModeTProxy {
+		fmt.Fprintf(&b, "\t\t%stproxy to :%d meta mark set %d accept\n", match, rule.LocalPort, rule.Transparent.GetMark())
+	} else {
+		fmt.Fprintf(&b, "\t\t%sredirect to :%d\n", match, rule.LocalPort)
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("}\n")
+
+	if mode == models.TransparentModeTProxy {
+		mark := rule.Transparent.GetMark()
+		b.WriteString("\n# TPROXY also needs policy routing for marked packets (run once):\n")
+		fmt.Fprintf(&b, "#   ip rule add fwmark %d lookup 100\n", mark)
+		b.WriteString("#   ip route add local 0.0.0.0/0 dev lo table 100\n")
+	}
+
+	return b.String(), nil
+}
+
+// shortID returns the first 8 characters of an ID
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
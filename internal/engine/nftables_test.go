@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"pfm/internal/models"
+)
+
+func TestNftablesSnippet(t *testing.T) {
+	rule := &models.Rule{
+		ID:        "abcdef12-3456",
+		Name:      "Transparent",
+		Type:      models.RuleTypeTransparent,
+		Protocol:  models.ProtocolTCP,
+		LocalPort: 12345,
+		ChainID:   "chain-1",
+	}
+
+	snippet, err := NftablesSnippet(rule)
+	if err != nil {
+		t.Fatalf("NftablesSnippet() error = %v", err)
+	}
+	if !strings.Contains(snippet, "table ip pfm_abcdef12") {
+		t.Errorf("snippet missing table name:\n%s", snippet)
+	}
+	if !strings.Contains(snippet, "meta l4proto tcp redirect to :12345") {
+		t.Errorf("snippet missing redirect statement:\n%s", snippet)
+	}
+
+	rule.Protocol = models.ProtocolUDP
+	rule.Transparent = &models.TransparentConfig{
+		Mode:      models.TransparentModeTProxy,
+		Mark:      7,
+		DestPorts: []int{53},
+	}
+	snippet, err = NftablesSnippet(rule)
+	if err != nil {
+		t.Fatalf("NftablesSnippet() error = %v", err)
+	}
+	if !strings.Contains(snippet, "udp dport { 53 } tproxy to :12345 meta mark set 7 accept") {
+		t.Errorf("snippet missing tproxy statement:\n%s", snippet)
+	}
+	if !strings.Contains(snippet, "ip rule add fwmark 7 lookup 100") {
+		t.Errorf("snippet missing policy routing hint:\n%s", snippet)
+	}
+
+	rule.Type = models.RuleTypeForward
+	if _, err := NftablesSnippet(rule); err == nil {
+		t.Error("NftablesSnippet() should reject non-transparent rules")
+	}
+}
@@ -2,7 +2,6 @@ package engine
 
 import (
 	"context"
-	"log"
 	"sync"
 
 	"github.com/go-gost/core/observer"
@@ -42,15 +41,11 @@ func (o *StatsObserver) SetOnUpdate(fn func(serviceName string, stats *ServiceSt
 
 // Observe implements the observer.Observer interface
 func (o *StatsObserver) Observe(ctx context.Context, events []observer.Event, opts ...observer.Option) error {
-	log.Printf("[StatsObserver] Received %d events", len(events))
 	for _, event := range events {
-		log.Printf("[StatsObserver] Event type: %T, EventType: %s", event, event.Type())
 		switch e := event.(type) {
 		case xstats.StatsEvent:
-			log.Printf("[StatsObserver] StatsEvent: Service=%s, In=%d, Out=%d", e.Service, e.InputBytes, e.OutputBytes)
 			o.handleStatsEvent(e)
 		case *xstats.StatsEvent:
-			log.Printf("[StatsObserver] *StatsEvent: Service=%s, In=%d, Out=%d", e.Service, e.InputBytes, e.OutputBytes)
 			o.handleStatsEvent(*e)
 		}
 	}
@@ -63,6 +58,8 @@ func (o *StatsObserver) handleStatsEvent(e xstats.StatsEvent) {
 	if serviceName == "" {
 		return
 	}
+	defaultLogger.Debug("Stats event", "rule", serviceName, "in", e.InputBytes, "out", e.OutputBytes,
+		"conns", e.CurrentConns, "totalConns", e.TotalConns, "errors", e.TotalErrs)
 
 	o.mu.Lock()
 	defer o.mu.Unlock()
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"pfm/internal/i18n"
+	"pfm/internal/models"
+)
+
+// quotaSaveInterval is how often changed usage is written out, crossing a
+// threshold writes it at once
+const quotaSaveInterval = time.Minute
+
+// quotaUsage is the traffic a rule used during the current period of its
+// quota
+type quotaUsage struct {
+	RuleID string `json:"ruleId"`
+	Period string `json:"period"` // Key of the period the bytes were counted in
+	Bytes  int64  `json:"bytes"`
+	Warned int    `json:"warned,omitempty"` // Highest threshold warned about, in percent
+}
+
+// quotaEvent is what changed when the usage of a rule was updated
+type quotaEvent struct {
+	warn     int  // threshold just crossed, in percent, 0 for none
+	exceeded bool // the quota was just used up
+	reset    bool // a new period started with the quota used up
+}
+
+// Quotas keeps the traffic every rule used against its quota in a JSON
+// file, so the usage of the current period survives daemon restarts
+type Quotas struct {
+	mu    sync.Mutex
+	path  string
+	usage map[string]*quotaUsage       // by rule ID
+	last  map[string]*models.RuleStats // counters at the previous update
+	dirty bool
+	saved time.Time
+
+	// exists reports whether a rule still exists, nil when unknown
+	exists func(ruleID string) bool
+}
+
+// NewQuotas creates the quota usage stored in path, loading the usage saved
+// before
+func NewQuotas(path string) (*Quotas, error) {
+	q := &Quotas{
+		path:  path,
+		usage: make(map[string]*quotaUsage),
+		last:  make(map[string]*models.RuleStats),
+		saved: time.Now(),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var usage []*quotaUsage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, u := range usage {
+		q.usage[u.RuleID] = u
+	}
+	return q, nil
+}
+
+// Update adds the traffic of a running rule since the previous update, from
+// its cumulative counters, and reports the thresholds it crossed
+func (q *Quotas) Update(rule *models.Rule, now time.Time, stats *models.RuleStats) quotaEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	last := q.last[rule.ID]
+	q.last[rule.ID] = stats
+	if last == nil {
+		last = &models.RuleStats{}
+	}
+	quota := rule.Quota
+	if quota == nil {
+		return quotaEvent{}
+	}
+
+	var ev quotaEvent
+	key := quota.PeriodKey(now)
+	u := q.usage[rule.ID]
+	if u == nil {
+		u = &quotaUsage{RuleID: rule.ID, Period: key}
+		q.usage[rule.ID] = u
+	}
+	if u.Period != key {
+		ev.reset = u.Bytes >= quota.Bytes
+		u.Period, u.Bytes, u.Warned = key, 0, 0
+		q.dirty = true
+	}
+
+	delta := counterDelta(stats.BytesIn, last.BytesIn) + counterDelta(stats.BytesOut, last.BytesOut)
+	if delta == 0 {
+		return ev
+	}
+	before := u.Bytes
+	u.Bytes += delta
+	q.dirty = true
+
+	ev.exceeded = before < quota.Bytes && u.Bytes >= quota.Bytes
+	percent := int(u.Bytes * 100 / quota.Bytes)
+	for _, w := range quota.GetWarn() {
+		if percent >= w && w > u.Warned && percent < 100 {
+			ev.warn = w
+		}
+	}
+	if ev.warn > 0 {
+		u.Warned = ev.warn
+	}
+	if ev.exceeded {
+		u.Warned = 100
+	}
+	return ev
+}
+
+// SetRuleExists sets how Save finds out whether a rule still exists. The
+// usage of deleted rules is dropped when it is saved.
+func (q *Quotas) SetRuleExists(exists func(ruleID string) bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.exists = exists
+}
+
+// Forget drops the counters of a rule before it is started, its new
+// service counts from zero
+func (q *Quotas) Forget(ruleID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.last, ruleID)
+}
+
+// Exceeded reports whether a rule used up its quota for the period
+// containing now
+func (q *Quotas) Exceeded(rule *models.Rule, now time.Time) bool {
+	return rule.Quota != nil && q.Status(rule, now).Exceeded
+}
+
+// Status returns the usage of a rule with a quota during the period
+// containing now
+func (q *Quotas) Status(rule *models.Rule, now time.Time) models.QuotaStatus {
+	quota := rule.Quota
+	st := models.QuotaStatus{
+		RuleID:   rule.ID,
+		RuleName: rule.Name,
+		Period:   quota.Period,
+		Limit:    quota.Bytes,
+		Action:   quota.GetAction(),
+	}
+	if next, ok := quota.NextReset(now); ok {
+		st.ResetAt = &next
+	}
+
+	q.mu.Lock()
+	if u := q.usage[rule.ID]; u != nil && u.Period == quota.PeriodKey(now) {
+		st.Used = u.Bytes
+	}
+	q.mu.Unlock()
+
+	st.Percent = int(st.Used * 100 / quota.Bytes)
+	st.Exceeded = st.Used >= quota.Bytes
+	return st
+}
+
+// saveIfDue writes the usage when it changed and the last save is older
+// than quotaSaveInterval
+func (q *Quotas) saveIfDue(now time.Time) error {
+	q.mu.Lock()
+	due := q.dirty && now.Sub(q.saved) >= quotaSaveInterval
+	q.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return q.Save()
+}
+
+// Save writes the usage of all rules that still exist
+func (q *Quotas) Save() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	usage := make([]*quotaUsage, 0, len(q.usage))
+	for id, u := range q.usage {
+		if q.exists != nil && !q.exists(id) {
+			delete(q.usage, id)
+			delete(q.last, id)
+			continue
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].RuleID < usage[j].RuleID
+	})
+
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("failed to write quota usage: %w", err)
+	}
+	q.dirty = false
+	q.saved = time.Now()
+	return nil
+}
+
+// blocks reports whether a rule used up a quota that stops it, false
+// without quotas
+func (q *Quotas) blocks(rule *models.Rule, now time.Time) bool {
+	return q != nil && rule.Quota != nil && rule.Quota.GetAction() == models.QuotaStop && q.Exceeded(rule, now)
+}
+
+// throttle returns the rate a rule that used up its quota is limited to, 0
+// when it is not throttled
+func (q *Quotas) throttle(rule *models.Rule, now time.Time) int64 {
+	if q == nil || rule.Quota == nil || rule.Quota.GetAction() != models.QuotaThrottle || !q.Exceeded(rule, now) {
+		return 0
+	}
+	return rule.Quota.ThrottleRate
+}
+
+// enforceQuota counts the traffic of a running rule against its quota,
+// warning at the thresholds and throttling the rule once the quota is used
+// up. It reports whether the rule must be stopped.
+func (e *Engine) enforceQuota(quotas *Quotas, rule *models.Rule, now time.Time, stats *models.RuleStats) bool {
+	ev := quotas.Update(rule, now, stats)
+	if ev != (quotaEvent{}) {
+		st := quotas.Status(rule, now)
+		usage := fmt.Sprintf("%d / %d bytes", st.Used, st.Limit)
+		event := models.Event{Type: models.EventQuota, RuleID: rule.ID, RuleName: rule.Name, Message: usage}
+		switch {
+		case ev.exceeded:
+			e.logMgr.Warn(rule.ID, rule.Name, "quota.exhausted", nil, usage)
+			event.Status = "exceeded"
+		case ev.warn > 0:
+			e.logMgr.Warn(rule.ID, rule.Name, "quota.warning", i18n.Params{"percent": strconv.Itoa(ev.warn)}, usage)
+			event.Status = "warning"
+			event.Message = fmt.Sprintf("%d%% used, %s", ev.warn, usage)
+		case ev.reset:
+			e.logMgr.Info(rule.ID, rule.Name, "quota.reset", nil)
+			event.Status = "reset"
+		}
+		e.Emit(event)
+		// Thresholds are only crossed once, don't lose them in a crash
+		if err := quotas.Save(); err != nil {
+			e.logger.Error("Failed to save quota usage", "error", err)
+		}
+	}
+
+	if t := getTracker(rule.ID); t != nil {
+		rate := quotas.throttle(rule, now)
+		if t.setThrottle(rate) {
+			if rate > 0 {
+				e.logMgr.Warn(rule.ID, rule.Name, "quota.throttled", i18n.Params{"rate": strconv.FormatInt(rate, 10)})
+			} else {
+				e.logMgr.Info(rule.ID, rule.Name, "quota.unthrottled", nil)
+			}
+		}
+	}
+	return quotas.blocks(rule, now)
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+func TestQuotas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	q, err := NewQuotas(path)
+	if err != nil {
+		t.Fatalf("NewQuotas() error = %v", err)
+	}
+
+	rule := &models.Rule{ID: "r1", Name: "metered", Quota: &models.Quota{Bytes: 1000, Period: models.QuotaDay}}
+	now := time.Now()
+	steps := []struct {
+		stats models.RuleStats
+		want  quotaEvent
+	}{
+		{models.RuleStats{BytesIn: 500}, quotaEvent{}},
+		{models.RuleStats{BytesIn: 700, BytesOut: 150}, quotaEvent{warn: 80}},
+		{models.RuleStats{BytesIn: 700, BytesOut: 250}, quotaEvent{warn: 90}},
+		{models.RuleStats{BytesIn: 700, BytesOut: 260}, quotaEvent{}},
+		{models.RuleStats{BytesIn: 800, BytesOut: 260}, quotaEvent{exceeded: true}},
+	}
+	for i, step := range steps {
+		if got := q.Update(rule, now, &step.stats); got != step.want {
+			t.Errorf("step %d: Update() = %+v, want %+v", i, got, step.want)
+		}
+	}
+
+	st := q.Status(rule, now)
+	if st.Used != 1060 || st.Percent != 106 || !st.Exceeded || st.ResetAt == nil {
+		t.Errorf("Status() = %+v", st)
+	}
+	if !q.blocks(rule, now) {
+		t.Error("a used up quota does not stop the rule")
+	}
+
+	// The usage survives a daemon restart
+	if err := q.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	q, err = NewQuotas(path)
+	if err != nil {
+		t.Fatalf("NewQuotas() error = %v", err)
+	}
+	if !q.Exceeded(rule, now) {
+		t.Error("usage lost across a restart")
+	}
+
+	// Raising the quota lets the rule run again
+	raised := rule.Clone()
+	raised.Quota.Bytes = 2000
+	if q.Exceeded(raised, now) {
+		t.Error("raised quota still exceeded")
+	}
+
+	// The next day starts over
+	tomorrow := *st.ResetAt
+	if q.Exceeded(rule, tomorrow) {
+		t.Error("quota still exceeded in the next period")
+	}
+	if got := q.Update(rule, tomorrow, &models.RuleStats{BytesIn: 50}); !got.reset {
+		t.Errorf("Update() in the next period = %+v, want a reset", got)
+	}
+	if st := q.Status(rule, tomorrow); st.Used != 50 {
+		t.Errorf("used %d in the next period, want 50", st.Used)
+	}
+
+	throttled := &models.Rule{ID: "r2", Quota: &models.Quota{
+		Bytes: 10, Period: models.QuotaTotal, Action: models.QuotaThrottle, ThrottleRate: 4096,
+	}}
+	q.Update(throttled, now, &models.RuleStats{BytesOut: 20})
+	if q.blocks(throttled, now) || q.throttle(throttled, now) != 4096 {
+		t.Error("used up throttle quota not throttled")
+	}
+
+	// The usage of deleted rules is dropped when saved
+	q.SetRuleExists(func(ruleID string) bool { return ruleID != rule.ID })
+	if err := q.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	q, err = NewQuotas(path)
+	if err != nil {
+		t.Fatalf("NewQuotas() error = %v", err)
+	}
+	if st := q.Status(rule, tomorrow); st.Used != 0 {
+		t.Errorf("deleted rule still used %d bytes", st.Used)
+	}
+	if !q.Exceeded(throttled, now) {
+		t.Error("usage of a remaining rule dropped")
+	}
+}
+
+func TestQuotaStopsRule(t *testing.T) {
+	addr := echoServer(t, "pong")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	host, targetPort, _ := net.SplitHostPort(addr)
+	rule := &models.Rule{
+		ID:         "rule-quota",
+		Name:       "Quota",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  port,
+		TargetHost: host,
+		Quota:      &models.Quota{Bytes: 10, Period: models.QuotaMonth},
+	}
+	fmt.Sscan(targetPort, &rule.TargetPort)
+
+	quotas, err := NewQuotas(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatalf("NewQuotas() error = %v", err)
+	}
+	e := New()
+	defer e.StopAll()
+	e.SetQuotas(quotas)
+
+	var mu sync.Mutex
+	var status string
+	e.SetStatusChangeCallback(func(ruleID, s, errorMsg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = s
+	})
+
+	if err := e.StartRule(rule); err != nil {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+	c, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	fmt.Fprintf(c, "ping with more than ten bytes\n")
+	if _, err := bufio.NewReader(c).ReadString('\n'); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for e.IsRunning(rule.ID) && time.Now().Before(deadline) {
+		e.collectStats()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if e.IsRunning(rule.ID) {
+		t.Fatal("rule still running over its quota")
+	}
+	mu.Lock()
+	if status != string(models.RuleStatusQuota) {
+		t.Errorf("status = %q, want quota", status)
+	}
+	mu.Unlock()
+
+	if err := e.StartRule(rule); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Errorf("StartRule() over the quota error = %v", err)
+	}
+	if got := e.GetQuotaStatus(rule); got == nil || !got.Exceeded {
+		t.Errorf("GetQuotaStatus() = %+v", got)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	tracker := newRuleTracker("rule-throttle", "Throttle", "")
+	if !tracker.setThrottle(64*1024) || tracker.setThrottle(64*1024) {
+		t.Fatal("setThrottle() did not report the change once")
+	}
+
+	// The burst goes through at once, the rest at the throttled rate
+	start := time.Now()
+	tracker.wait(t.Context(), 64*1024)
+	tracker.wait(t.Context(), 32*1024)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("96 KiB at 64 KiB/s took %v", elapsed)
+	}
+
+	if !tracker.setThrottle(0) {
+		t.Error("lifting the throttle not reported")
+	}
+	start = time.Now()
+	tracker.wait(t.Context(), 1<<20)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unthrottled wait took %v", elapsed)
+	}
+}
+
+func TestThrottleKill(t *testing.T) {
+	tracker := newRuleTracker("rule-throttle-kill", "Throttle", "")
+	tracker.setThrottle(1024)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	_, tc, conn := tracker.track(t.Context(), server)
+	go client.Write(make([]byte, 64*1024))
+
+	// The first read takes the burst, the next one waits for the throttle
+	// until the connection is killed
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+	tc.kill(errConnKilled)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("throttled Read() error = %v, want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("throttled Read() not interrupted by kill")
+	}
+}
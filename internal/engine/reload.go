@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"pfm/internal/models"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/hop"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/x/config"
+	hop_parser "github.com/go-gost/x/config/parsing/hop"
+)
+
+// ruleChange describes how a rule update must be applied to its running service
+type ruleChange int
+
+const (
+	changeNone     ruleChange = iota // identical rules
+	changeMetadata                   // cosmetic fields only, the service is untouched
+	changeTargets                    // the forwarder targets are swapped in place
+	changeService                    // the service must be rebuilt
+)
+
+// diffRule compares the running rule with its new configuration
+func diffRule(old, new *models.Rule) ruleChange {
+	a, b := old.Clone(), new.Clone()
+	if reflect.DeepEqual(a, b) {
+		return changeNone
+	}
+
+	for _, r := range []*models.Rule{a, b} {
+		r.Name, r.Description, r.Remark = "", "", ""
+		r.Enabled, r.Status, r.ErrorMsg = false, "", ""
+		// Read on failures, by the scheduler and when starting, not by the service
+		r.Restart, r.Schedule, r.DependsOn = nil, nil, nil
+		r.ExpiresAt, r.TTL, r.DeleteOnExpiry = nil, 0, false
+		r.Quota = nil // Enforced by the stats polling
+		r.CreatedAt, r.UpdatedAt = time.Time{}, time.Time{}
+	}
+	if reflect.DeepEqual(a, b) {
+		return changeMetadata
+	}
+
+	// Only rules served through a forwarder can swap their targets, and
+	// discovered targets are swapped by the refresher of the service
+	if a.Type != models.RuleTypeForward && a.Type != models.RuleTypeReverse {
+		return changeService
+	}
+	if a.Discovery != nil || b.Discovery != nil {
+		return changeService
+	}
+	for _, r := range []*models.Rule{a, b} {
+		r.TargetHost, r.TargetPort, r.Targets = "", 0, nil
+	}
+	if reflect.DeepEqual(a, b) {
+		return changeTargets
+	}
+	return changeService
+}
+
+// swapHop is the forwarder hop given to the inner handler, its target
+// nodes can be replaced while the service is running
+type swapHop struct {
+	mu  sync.RWMutex
+	hop hop.Hop
+}
+
+// hops maps rule IDs to the swappable hop of their service
+var hops sync.Map
+
+// newSwapHop wraps the hop of a rule's service and registers it
+func newSwapHop(ruleID string, h hop.Hop) *swapHop {
+	sh := &swapHop{hop: h}
+	hops.Store(ruleID, sh)
+	return sh
+}
+
+// getSwapHop returns the swappable hop of a rule, or nil
+func getSwapHop(ruleID string) *swapHop {
+	v, ok := hops.Load(ruleID)
+	if !ok {
+		return nil
+	}
+	return v.(*swapHop)
+}
+
+// removeSwapHop unregisters the hop of a rule
+func removeSwapHop(ruleID string) {
+	hops.Delete(ruleID)
+}
+
+// Select implements hop.Hop
+func (h *swapHop) Select(ctx context.Context, opts ...hop.SelectOption) *chain.Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hop.Select(ctx, opts...)
+}
+
+// Nodes implements hop.NodeList
+func (h *swapHop) Nodes() []*chain.Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if nl, ok := h.hop.(hop.NodeList); ok {
+		return nl.Nodes()
+	}
+	return nil
+}
+
+// swap replaces the target nodes, connections already established keep their target
+func (h *swapHop) swap(hop hop.Hop) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hop = hop
+}
+
+// buildHop creates the forwarder hop of a rule the same way gost does
+// when parsing the service
+func buildHop(rule *models.Rule) (hop.Hop, error) {
+	fwd := buildForwarder(rule)
+	if fwd == nil {
+		return nil, models.ErrNoTargets
+	}
+
+	hc := &config.HopConfig{
+		Name:     rule.ID,
+		Selector: fwd.Selector,
+	}
+	for _, node := range fwd.Nodes {
+		hc.Nodes = append(hc.Nodes, &config.NodeConfig{
+			Name:     node.Name,
+			Addr:     node.Addr,
+			Metadata: node.Metadata,
+		})
+	}
+	return hop_parser.ParseHop(hc, logger.Default())
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+
+	"pfm/internal/models"
+)
+
+func TestDiffRule(t *testing.T) {
+	base := &models.Rule{
+		ID:         "rule-diff",
+		Name:       "Diff",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  8080,
+		TargetHost: "10.0.0.1",
+		TargetPort: 80,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *models.Rule)
+		want   ruleChange
+	}{
+		{"unchanged", func(r *models.Rule) {}, changeNone},
+		{"remark", func(r *models.Rule) { r.Remark = "note"; r.Name = "Renamed" }, changeMetadata},
+		{"restart policy", func(r *models.Rule) { r.Restart = &models.RestartPolicy{Mode: models.RestartAlways} }, changeMetadata},
+		{"expiry", func(r *models.Rule) { r.TTL, r.DeleteOnExpiry = 3600, true }, changeMetadata},
+		{"quota", func(r *models.Rule) { r.Quota = &models.Quota{Bytes: 1 << 30, Period: models.QuotaMonth} }, changeMetadata},
+		{"target", func(r *models.Rule) { r.TargetHost = "10.0.0.2" }, changeTargets},
+		{"targets", func(r *models.Rule) {
+			r.TargetHost, r.TargetPort = "", 0
+			r.Targets = []models.Target{{Host: "10.0.0.2", Port: 80}, {Host: "10.0.0.3", Port: 80}}
+		}, changeTargets},
+		{"discovery", func(r *models.Rule) {
+			r.Discovery = &models.TargetDiscovery{Type: models.DiscoverySRV, Name: "_web._tcp.internal"}
+		}, changeService},
+		{"port", func(r *models.Rule) { r.LocalPort = 8081 }, changeService},
+		{"protocol", func(r *models.Rule) { r.Protocol = models.ProtocolUDP }, changeService},
+		{"tls", func(r *models.Rule) { r.TLS = &models.TLSConfig{Enabled: true} }, changeService},
+		{"port and target", func(r *models.Rule) { r.LocalPort = 8081; r.TargetPort = 81 }, changeService},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := base.Clone()
+			tt.modify(updated)
+			if got := diffRule(base, updated); got != tt.want {
+				t.Errorf("diffRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// echoServer answers every line with its own name
+func echoServer(t *testing.T, name string) string {
+	return echoServerAt(t, "127.0.0.1:0", name)
+}
+
+// echoServerAt is echoServer listening on addr
+func echoServerAt(t *testing.T, addr, name string) string {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+					fmt.Fprintf(c, "%s\n", name)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestUpdateRuleSwapsTargets(t *testing.T) {
+	addrA := echoServer(t, "a")
+	addrB := echoServer(t, "b")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	hostA, portA, _ := net.SplitHostPort(addrA)
+	rule := &models.Rule{
+		ID:         "rule-update",
+		Name:       "Update",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  port,
+		TargetHost: hostA,
+	}
+	fmt.Sscan(portA, &rule.TargetPort)
+
+	e := New()
+	defer e.StopAll()
+	if err := e.StartRule(rule); err != nil {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+
+	ask := func(c net.Conn, r *bufio.Reader) string {
+		fmt.Fprintf(c, "ping\n")
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return line[:len(line)-1]
+	}
+	dial := func() (net.Conn, *bufio.Reader) {
+		c, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c, bufio.NewReader(c)
+	}
+
+	c1, r1 := dial()
+	if got := ask(c1, r1); got != "a" {
+		t.Fatalf("first connection reached %s, want a", got)
+	}
+
+	updated := rule.Clone()
+	updated.Remark = "moved to b"
+	hostB, portB, _ := net.SplitHostPort(addrB)
+	updated.TargetHost = hostB
+	fmt.Sscan(portB, &updated.TargetPort)
+	if err := e.UpdateRule(updated); err != nil {
+		t.Fatalf("UpdateRule() error = %v", err)
+	}
+
+	// The established session is kept, new ones go to the new target
+	if got := ask(c1, r1); got != "a" {
+		t.Errorf("established connection now reaches %s, want a", got)
+	}
+	c2, r2 := dial()
+	if got := ask(c2, r2); got != "b" {
+		t.Errorf("new connection reached %s, want b", got)
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"pfm/internal/i18n"
+	"pfm/internal/models"
+)
+
+// restartResetAfter is how long a restarted service must run to be
+// considered healthy, its next failure then starts a new backoff sequence
+const restartResetAfter = time.Minute
+
+// errServiceExited is recorded for services that exit without an error
+var errServiceExited = errors.New("service exited")
+
+// restartEntry holds a failed rule waiting to be restarted
+type restartEntry struct {
+	rule      *models.Rule
+	attempt   int
+	nextRetry time.Time
+	lastError string
+	timer     *time.Timer
+}
+
+// scheduleRestart arranges for a failed rule to be started again after the
+// backoff delay of its policy. It returns false once the policy gives up.
+// Called with e.mu held.
+func (e *Engine) scheduleRestart(rule *models.Rule, attempt int, err error) (time.Time, bool) {
+	policy := rule.Restart
+	if policy.Exhausted(attempt) {
+		e.logMgr.Error(rule.ID, rule.Name, "restart.giveUp", i18n.Params{"attempts": strconv.Itoa(attempt - 1)}, err.Error())
+		return time.Time{}, false
+	}
+
+	delay := policy.Delay(attempt)
+	r := &restartEntry{
+		rule:      rule.Clone(),
+		attempt:   attempt,
+		nextRetry: time.Now().Add(delay),
+		lastError: err.Error(),
+	}
+	r.timer = time.AfterFunc(delay, func() { e.retry(r) })
+	e.restarts[rule.ID] = r
+
+	e.logMgr.Warn(rule.ID, rule.Name, "restart.scheduled", i18n.Params{"delay": delay.String(), "attempt": strconv.Itoa(attempt)}, err.Error())
+	return r.nextRetry, true
+}
+
+// cancelRestart drops the pending restart of a rule. Called with e.mu held.
+func (e *Engine) cancelRestart(ruleID string) bool {
+	r, ok := e.restarts[ruleID]
+	if !ok {
+		return false
+	}
+	r.timer.Stop()
+	delete(e.restarts, ruleID)
+	return true
+}
+
+// retry starts a rule waiting to be restarted
+func (e *Engine) retry(r *restartEntry) {
+	e.mu.RLock()
+	pending := e.restarts[r.rule.ID] == r
+	e.mu.RUnlock()
+	if !pending {
+		// Cancelled or superseded
+		return
+	}
+
+	e.logMgr.Info(r.rule.ID, r.rule.Name, "restart.attempt", i18n.Params{"attempt": strconv.Itoa(r.attempt)})
+	found := e.discover(r.rule)
+
+	e.mu.Lock()
+	if e.restarts[r.rule.ID] != r {
+		// Cancelled or superseded meanwhile
+		e.mu.Unlock()
+		return
+	}
+	delete(e.restarts, r.rule.ID)
+
+	err := e.start(r.rule, r.attempt, found)
+	callback := e.onStatusChange
+	e.mu.Unlock()
+
+	if err != nil {
+		e.statusChanged(callback, r.rule.ID, r.rule.Name, string(models.FailureStatus(err)), err.Error())
+	} else {
+		e.statusChanged(callback, r.rule.ID, r.rule.Name, string(models.RuleStatusRunning), "")
+	}
+}
+
+// GetBackoffStatus returns the failed rules waiting to be restarted
+func (e *Engine) GetBackoffStatus() []models.BackoffStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]models.BackoffStatus, 0, len(e.restarts))
+	for _, r := range e.restarts {
+		result = append(result, models.BackoffStatus{
+			RuleID:     r.rule.ID,
+			RuleName:   r.rule.Name,
+			Attempt:    r.attempt,
+			MaxRetries: r.rule.Restart.MaxRetries,
+			NextRetry:  r.nextRetry,
+			LastError:  r.lastError,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NextRetry.Before(result[j].NextRetry)
+	})
+	return result
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	addr := echoServer(t, "a")
+	host, port, _ := net.SplitHostPort(addr)
+
+	// Hold the listen port so the first start fails
+	blocker, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	localPort := blocker.Addr().(*net.TCPAddr).Port
+
+	rule := &models.Rule{
+		ID:         "rule-restart",
+		Name:       "Restart",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  localPort,
+		TargetHost: host,
+		Restart:    &models.RestartPolicy{Mode: models.RestartOnFailure, InitialDelay: 1},
+	}
+	fmt.Sscan(port, &rule.TargetPort)
+
+	var mu sync.Mutex
+	var statuses []string
+	e := New()
+	defer e.StopAll()
+	e.SetStatusChangeCallback(func(ruleID, status, errorMsg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, status)
+	})
+
+	err = e.StartRule(rule)
+	var backoff *models.BackoffError
+	if !errors.As(err, &backoff) {
+		t.Fatalf("StartRule() error = %v, want a BackoffError", err)
+	}
+	if backoff.Attempt != 1 || models.FailureStatus(err) != models.RuleStatusBackoff {
+		t.Errorf("attempt = %d, status = %s", backoff.Attempt, models.FailureStatus(err))
+	}
+	if !e.IsActive(rule.ID) || e.IsRunning(rule.ID) {
+		t.Error("rule should be waiting to restart")
+	}
+	if s := e.GetBackoffStatus(); len(s) != 1 || s[0].Attempt != 1 {
+		t.Fatalf("GetBackoffStatus() = %+v", s)
+	}
+
+	// The retry succeeds once the port is free
+	blocker.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !e.IsRunning(rule.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("rule was not restarted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	mu.Lock()
+	if len(statuses) != 1 || statuses[0] != "running" {
+		t.Errorf("status changes = %v, want [running]", statuses)
+	}
+	mu.Unlock()
+	if s := e.GetBackoffStatus(); len(s) != 0 {
+		t.Errorf("GetBackoffStatus() = %+v after restart", s)
+	}
+}
+
+func TestRestartCancelAndGiveUp(t *testing.T) {
+	blocker, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer blocker.Close()
+
+	rule := &models.Rule{
+		ID:         "rule-giveup",
+		Name:       "GiveUp",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  blocker.Addr().(*net.TCPAddr).Port,
+		TargetHost: "127.0.0.1",
+		TargetPort: 9,
+		Restart:    &models.RestartPolicy{Mode: models.RestartOnFailure, MaxRetries: 1, InitialDelay: 1},
+	}
+
+	status := make(chan string, 4)
+	e := New()
+	defer e.StopAll()
+	e.SetStatusChangeCallback(func(ruleID, s, errorMsg string) { status <- s })
+
+	// Stopping a rule waiting to restart cancels the retry
+	if err := e.StartRule(rule); models.FailureStatus(err) != models.RuleStatusBackoff {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+	if err := e.StopRule(rule.ID); err != nil {
+		t.Fatalf("StopRule() error = %v", err)
+	}
+	if e.IsActive(rule.ID) {
+		t.Error("rule still waiting to restart after StopRule")
+	}
+
+	// With a single retry allowed, the rule gives up after it
+	if err := e.StartRule(rule); models.FailureStatus(err) != models.RuleStatusBackoff {
+		t.Fatalf("StartRule() error = %v", err)
+	}
+	select {
+	case s := <-status:
+		if s != "error" {
+			t.Errorf("status = %s, want error", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status change after the retry")
+	}
+	if e.IsActive(rule.ID) {
+		t.Error("rule still active after giving up")
+	}
+}
+
+func TestRestartConfigError(t *testing.T) {
+	rule := &models.Rule{
+		ID:         "rule-nochain",
+		Name:       "NoChain",
+		Type:       models.RuleTypeForward,
+		Protocol:   models.ProtocolTCP,
+		LocalPort:  18093,
+		TargetHost: "127.0.0.1",
+		TargetPort: 9,
+		ChainID:    "missing",
+		Restart:    &models.RestartPolicy{Mode: models.RestartOnFailure, InitialDelay: 1},
+	}
+
+	e := New()
+	defer e.StopAll()
+
+	// A missing chain is not retried, even with unlimited retries
+	err := e.StartRule(rule)
+	if !errors.Is(err, models.ErrChainNotFound) {
+		t.Fatalf("StartRule() error = %v, want ErrChainNotFound", err)
+	}
+	if models.FailureStatus(err) != models.RuleStatusError {
+		t.Errorf("status = %s, want error", models.FailureStatus(err))
+	}
+	if e.IsActive(rule.ID) {
+		t.Error("rule waiting to restart after a configuration error")
+	}
+	if s := e.GetBackoffStatus(); len(s) != 0 {
+		t.Errorf("GetBackoffStatus() = %+v", s)
+	}
+}
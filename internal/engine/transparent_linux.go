@@ -0,0 +1,6 @@
+//go:build linux
+
+package engine
+
+// transparentSupported reports whether REDIRECT/TPROXY rules can run on this platform
+const transparentSupported = true
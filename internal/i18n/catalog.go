@@ -0,0 +1,279 @@
+package i18n
+
+// catalog holds the messages of every locale by key. Log entries keep the
+// key and parameters of their message, keys are therefore never renamed.
+var catalog = map[string]map[string]string{
+	English: {
+		// Connections
+		"conn.new":      "New connection: {client} -> {target}",
+		"conn.closed":   "Connection closed: {client} (received: {in}, sent: {out})",
+		"conn.transfer": "Data transfer [{direction}]: {bytes}",
+		"conn.killed":   "Connection closed by force: {client}",
+		"conn.failed":   "Connection failed: {client} -> {target}",
+
+		// Services
+		"service.error":       "Error",
+		"service.start":       "Service started: listening on {addr}",
+		"service.stop":        "Service stopped",
+		"service.startFailed": "Failed to start",
+		"service.rebuild":     "Configuration changed, rebuilding the service",
+		"service.stopAll":     "Stopping all services",
+
+		// Targets
+		"target.up":            "Target recovered: {target}",
+		"target.down":          "Target unreachable: {target}",
+		"target.updated":       "Forwarding targets updated",
+		"discovery.found":      "Discovered targets",
+		"discovery.updated":    "Discovered targets updated",
+		"discovery.failed":     "Target discovery failed",
+		"discovery.failedKeep": "Target discovery failed, keeping the current targets",
+
+		// Draining and restarts
+		"drain.start":       "Draining connections: {count} connections, timeout {timeout}",
+		"drain.done":        "Connections drained",
+		"drain.timeout":     "Drain timed out: closing {count} connections",
+		"drain.udp":         "UDP sessions end with the listener: closing {count} sessions",
+		"restart.giveUp":    "Restart failed {attempts} times, giving up",
+		"restart.scheduled": "Restarting in {delay} (attempt {attempt})",
+		"restart.attempt":   "Restarting (attempt {attempt})",
+
+		// Quotas
+		"quota.exhausted":           "Traffic quota used up",
+		"quota.exhaustedNotStarted": "Traffic quota used up, rule not started",
+		"quota.exhaustedStopped":    "Traffic quota used up, rule stopped",
+		"quota.warning":             "{percent}% of the traffic quota used",
+		"quota.reset":               "Traffic quota reset",
+		"quota.resetStart":          "Traffic quota reset, starting the rule",
+		"quota.throttled":           "Traffic quota used up, throttled to {rate} B/s",
+		"quota.unthrottled":         "Traffic throttle lifted",
+
+		// Schedules, expiry and dependencies
+		"schedule.start":           "Schedule window opened, starting the rule",
+		"schedule.stop":            "Schedule window closed, stopping the rule",
+		"schedule.override":        "Schedule manually overridden until the next change",
+		"schedule.overrideExpired": "Manual override expired, following the schedule again",
+		"expiry.soon":              "Rule expires at {time}",
+		"expiry.deleted":           "Rule expired and was deleted",
+		"expiry.stopped":           "Rule expired and was stopped",
+		"expiry.extended":          "Expiry extended to {time}",
+		"deps.failed":              "Dependency {dependency} failed, stopping the rule",
+		"deps.recovered":           "Dependency {dependency} recovered, starting the rule",
+
+		// Storage
+		"storage.accountingUnavailable": "Traffic accounting unavailable",
+		"storage.quotasUnavailable":     "Traffic quotas unavailable",
+		"storage.logsUnavailable":       "Log persistence unavailable",
+
+		// Service installation
+		"install.adminRequired":     "Administrator rights are required. Right-click the program and choose \"Run as administrator\", or run pfm.exe service install from an administrator command prompt",
+		"install.executable":        "failed to get executable path",
+		"install.resolveExecutable": "failed to resolve executable path",
+		"install.create":            "failed to create service",
+		"install.running":           "The service is running, stop it first",
+		"install.failed":            "failed to install service (executable: {path})",
+		"install.failedWindows":     "failed to install service: {error}\n\nPlease make sure that:\n1. The program runs as administrator\n2. The program is copied to a fixed directory (e.g. C:\\Tools\\pfm)\n3. Program path: {path}",
+		"install.tempFile":          "failed to create temp file",
+		"install.plist":             "failed to write plist",
+		"install.cancelled":         "The authorization was cancelled",
+		"install.notInstalled":      "The service is not installed",
+		"install.installFailed":     "failed to install service",
+		"install.uninstallFailed":   "failed to uninstall service",
+		"install.startFailed":       "failed to start service",
+		"install.stopFailed":        "failed to stop service",
+
+		// CLI
+		"cli.unknownCommand":   "unknown command: {command}\nRun 'pfm help' for usage",
+		"cli.connectFailed":    "failed to connect to service: {error}\nIs the service running?",
+		"cli.installing":       "Installing service...",
+		"cli.installed":        "Service installed successfully",
+		"cli.starting":         "Starting service...",
+		"cli.started":          "Service started successfully",
+		"cli.startFailed":      "service installed but failed to start",
+		"cli.uninstalling":     "Uninstalling service...",
+		"cli.uninstalled":      "Service uninstalled successfully",
+		"cli.serviceRunning":   "Service: installed and running",
+		"cli.serviceStopped":   "Service: installed but stopped",
+		"cli.serviceMissing":   "Service: not installed",
+		"cli.serviceDown":      "Service: not running",
+		"cli.ruleStarted":      "Rule {id} started",
+		"cli.ruleStopped":      "Rule {id} stopped",
+		"cli.ruleExtended":     "Rule {id} now expires at {time}",
+		"cli.ruleDeleted":      "Rule {id} deleted",
+		"cli.ruleCreated":      "Rule created with ID: {id}",
+		"cli.connsClosed":      "{count} connection(s) from {client} closed",
+		"cli.connClosed":       "Connection {id} closed",
+		"cli.debugOn":          "Debug logging of rule {id} turned on",
+		"cli.debugOff":         "Debug logging of rule {id} turned off",
+		"cli.chainDeleted":     "Chain {id} deleted",
+		"cli.noRules":          "No rules configured",
+		"cli.followingEvents":  "Following events, press Ctrl+C to stop",
+		"cli.eventsMissed":     "-- reconnected, some events were missed --",
+		"cli.statusTitle":      "Service Status",
+		"cli.statusRunning":    "Running:      {running}",
+		"cli.statusVersion":    "Version:      {version}",
+		"cli.statusRules":      "Active Rules: {active} / {total}",
+		"cli.statusAccess":     "Access:       {user} ({role})",
+		"cli.statusBoot":       "Boot:         {started} started, {failed} failed, {skipped} skipped in {duration}ms",
+		"cli.drainItem":        "{rule}: {remaining} / {initial} connections remaining, {left} left",
+		"cli.backoffItem":      "{rule}: retry {retries} at {time} ({error})",
+		"cli.scheduleActive":   "active",
+		"cli.scheduleInactive": "inactive",
+		"cli.scheduleNoChange": "no change within a week",
+		"cli.scheduleNext":     "next change {time}",
+		"cli.scheduleOverride": "manually {override} until then",
+		"cli.expiryDeleted":    "{rule}: expired at {time}, deleted",
+		"cli.expiryExpired":    "{rule}: expired at {time}",
+		"cli.expiryPending":    "{rule}: expires at {time} (in {left})",
+		"cli.statusDraining":   "Draining:",
+		"cli.statusRestarting": "Restarting:",
+		"cli.statusSchedules":  "Schedules:",
+		"cli.statusExpiry":     "Expiry:",
+		"cli.statusQuotas":     "Quotas:",
+		"cli.statusDebug":      "Debug Logging: {rules}",
+		"cli.statusRemote":     "Remote Access: {addr}, certificate SHA-256 {fingerprint}",
+		"cli.statusRemoteErr":  "Remote Access: {addr} unavailable: {error}",
+		"cli.contextHeader":    "== {name} ({endpoint}) ==",
+		"cli.contextSwitched":  "Switched to context {name}",
+		"cli.contextSaved":     "Context {name} saved",
+		"cli.contextDeleted":   "Context {name} deleted",
+		"cli.contextsFailed":   "{count} context(s) failed",
+		"cli.statusRuleList":   "Rules:",
+	},
+
+	Chinese: {
+		// Connections
+		"conn.new":      "新连接: {client} -> {target}",
+		"conn.closed":   "连接断开: {client} (接收: {in}, 发送: {out})",
+		"conn.transfer": "数据传输 [{direction}]: {bytes}",
+		"conn.killed":   "连接被强制关闭: {client}",
+		"conn.failed":   "连接失败: {client} -> {target}",
+
+		// Services
+		"service.error":       "错误",
+		"service.start":       "服务启动: 监听 {addr}",
+		"service.stop":        "服务停止",
+		"service.startFailed": "启动失败",
+		"service.rebuild":     "配置变更, 重建服务",
+		"service.stopAll":     "停止所有服务",
+
+		// Targets
+		"target.up":            "目标已恢复: {target}",
+		"target.down":          "目标不可达: {target}",
+		"target.updated":       "转发目标已更新",
+		"discovery.found":      "发现目标",
+		"discovery.updated":    "发现目标已更新",
+		"discovery.failed":     "目标发现失败",
+		"discovery.failedKeep": "目标发现失败, 保留当前目标",
+
+		// Draining and restarts
+		"drain.start":       "排空连接: {count} 个连接, 超时 {timeout}",
+		"drain.done":        "连接排空完成",
+		"drain.timeout":     "排空超时: 强制关闭 {count} 个连接",
+		"drain.udp":         "UDP 会话随监听一起结束: 关闭 {count} 个会话",
+		"restart.giveUp":    "重启失败 {attempts} 次, 放弃重启",
+		"restart.scheduled": "{delay} 后重启 (第 {attempt} 次)",
+		"restart.attempt":   "正在重启 (第 {attempt} 次)",
+
+		// Quotas
+		"quota.exhausted":           "流量配额已用尽",
+		"quota.exhaustedNotStarted": "流量配额已用尽, 不启动规则",
+		"quota.exhaustedStopped":    "流量配额已用尽, 已停止规则",
+		"quota.warning":             "流量已使用配额的 {percent}%",
+		"quota.reset":               "流量配额已重置",
+		"quota.resetStart":          "流量配额已重置, 启动规则",
+		"quota.throttled":           "流量配额已用尽, 限速 {rate} B/s",
+		"quota.unthrottled":         "流量限速已解除",
+
+		// Schedules, expiry and dependencies
+		"schedule.start":           "计划时段开始, 启动规则",
+		"schedule.stop":            "计划时段结束, 停止规则",
+		"schedule.override":        "手动覆盖计划, 下次切换时恢复",
+		"schedule.overrideExpired": "手动覆盖已到期, 恢复计划",
+		"expiry.soon":              "规则将于 {time} 到期",
+		"expiry.deleted":           "规则已到期, 已删除",
+		"expiry.stopped":           "规则已到期, 已停止",
+		"expiry.extended":          "有效期已延长至 {time}",
+		"deps.failed":              "依赖规则 {dependency} 失败, 停止规则",
+		"deps.recovered":           "依赖规则 {dependency} 已恢复, 启动规则",
+
+		// Storage
+		"storage.accountingUnavailable": "流量累计不可用",
+		"storage.quotasUnavailable":     "流量配额不可用",
+		"storage.logsUnavailable":       "日志持久化不可用",
+
+		// Service installation
+		"install.adminRequired":     "需要管理员权限。请右键点击程序，选择「以管理员身份运行」，或在管理员命令提示符中运行：pfm.exe service install",
+		"install.executable":        "无法获取程序路径",
+		"install.resolveExecutable": "无法解析程序路径",
+		"install.create":            "创建服务失败",
+		"install.running":           "服务已在运行中，请先停止服务",
+		"install.failed":            "安装服务失败 (程序路径: {path})",
+		"install.failedWindows":     "安装服务失败: {error}\n\n请确保：\n1. 以管理员身份运行程序\n2. 将程序复制到固定目录（如 C:\\Tools\\pfm）\n3. 程序路径: {path}",
+		"install.tempFile":          "无法创建临时文件",
+		"install.plist":             "无法写入 plist",
+		"install.cancelled":         "用户取消了授权",
+		"install.notInstalled":      "服务未安装",
+		"install.installFailed":     "安装服务失败",
+		"install.uninstallFailed":   "卸载服务失败",
+		"install.startFailed":       "启动服务失败",
+		"install.stopFailed":        "停止服务失败",
+
+		// CLI
+		"cli.unknownCommand":   "未知命令: {command}\n运行 'pfm help' 查看用法",
+		"cli.connectFailed":    "无法连接到服务: {error}\n服务是否在运行？",
+		"cli.installing":       "正在安装服务...",
+		"cli.installed":        "服务安装成功",
+		"cli.starting":         "正在启动服务...",
+		"cli.started":          "服务启动成功",
+		"cli.startFailed":      "服务已安装, 但启动失败",
+		"cli.uninstalling":     "正在卸载服务...",
+		"cli.uninstalled":      "服务卸载成功",
+		"cli.serviceRunning":   "服务: 已安装, 运行中",
+		"cli.serviceStopped":   "服务: 已安装, 已停止",
+		"cli.serviceMissing":   "服务: 未安装",
+		"cli.serviceDown":      "服务: 未运行",
+		"cli.ruleStarted":      "规则 {id} 已启动",
+		"cli.ruleStopped":      "规则 {id} 已停止",
+		"cli.ruleExtended":     "规则 {id} 将于 {time} 到期",
+		"cli.ruleDeleted":      "规则 {id} 已删除",
+		"cli.ruleCreated":      "规则已创建, ID: {id}",
+		"cli.connsClosed":      "已关闭来自 {client} 的 {count} 个连接",
+		"cli.connClosed":       "连接 {id} 已关闭",
+		"cli.debugOn":          "规则 {id} 的调试日志已开启",
+		"cli.debugOff":         "规则 {id} 的调试日志已关闭",
+		"cli.chainDeleted":     "代理链 {id} 已删除",
+		"cli.noRules":          "没有配置规则",
+		"cli.followingEvents":  "正在跟踪事件, 按 Ctrl+C 停止",
+		"cli.eventsMissed":     "-- 已重新连接, 部分事件已丢失 --",
+		"cli.statusTitle":      "服务状态",
+		"cli.statusRunning":    "运行中:   {running}",
+		"cli.statusVersion":    "版本:     {version}",
+		"cli.statusRules":      "活动规则: {active} / {total}",
+		"cli.statusAccess":     "访问权限: {user} ({role})",
+		"cli.statusBoot":       "启动:     {started} 个已启动, {failed} 个失败, {skipped} 个跳过, 用时 {duration}ms",
+		"cli.drainItem":        "{rule}: 剩余 {remaining} / {initial} 个连接, 还有 {left}",
+		"cli.backoffItem":      "{rule}: {time} 第 {retries} 次重试 ({error})",
+		"cli.scheduleActive":   "生效中",
+		"cli.scheduleInactive": "未生效",
+		"cli.scheduleNoChange": "一周内无变化",
+		"cli.scheduleNext":     "下次变化 {time}",
+		"cli.scheduleOverride": "在此之前手动{override}",
+		"cli.expiryDeleted":    "{rule}: 已于 {time} 到期, 已删除",
+		"cli.expiryExpired":    "{rule}: 已于 {time} 到期",
+		"cli.expiryPending":    "{rule}: 将于 {time} 到期 (还有 {left})",
+		"cli.statusDraining":   "排空中:",
+		"cli.statusRestarting": "等待重启:",
+		"cli.statusSchedules":  "计划:",
+		"cli.statusExpiry":     "有效期:",
+		"cli.statusQuotas":     "流量配额:",
+		"cli.statusDebug":      "调试日志: {rules}",
+		"cli.statusRemote":     "远程管理: {addr}, 证书 SHA-256 {fingerprint}",
+		"cli.statusRemoteErr":  "远程管理: {addr} 不可用: {error}",
+		"cli.contextHeader":    "== {name} ({endpoint}) ==",
+		"cli.contextSwitched":  "已切换到上下文 {name}",
+		"cli.contextSaved":     "上下文 {name} 已保存",
+		"cli.contextDeleted":   "上下文 {name} 已删除",
+		"cli.contextsFailed":   "{count} 个上下文失败",
+		"cli.statusRuleList":   "规则:",
+	},
+}
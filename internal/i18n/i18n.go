@@ -0,0 +1,129 @@
+// Package i18n translates the user-facing messages of the service, the log
+// entries and the CLI. Messages are identified by a key and take named
+// parameters written as {name} in their text.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Supported locales
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// Params are the named parameters of a message
+type Params map[string]string
+
+var (
+	mu      sync.RWMutex
+	current = English
+)
+
+// Supported reports whether a locale has a catalog
+func Supported(locale string) bool {
+	_, ok := catalog[locale]
+	return ok
+}
+
+// Normalize returns the supported locale of a locale name such as
+// "zh_CN.UTF-8" or "en-US", or "" when it is not supported
+func Normalize(name string) string {
+	lang := strings.ToLower(name)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if Supported(lang) {
+		return lang
+	}
+	return ""
+}
+
+// FromEnv returns the locale of the environment (LC_ALL, LC_MESSAGES or
+// LANG), English when none is set or supported
+func FromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			if locale := Normalize(v); locale != "" {
+				return locale
+			}
+			return English
+		}
+	}
+	return English
+}
+
+// SetLocale sets the locale messages are translated to, the locale of the
+// environment when empty or unsupported
+func SetLocale(locale string) {
+	if locale = Normalize(locale); locale == "" {
+		locale = FromEnv()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = locale
+}
+
+// Locale returns the locale messages are translated to
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T translates a message to the current locale
+func T(key string, params Params) string {
+	return Translate(Locale(), key, params)
+}
+
+// Translate translates a message to a locale, falling back to English. An
+// unknown key is returned as is, so free text can be passed as a key.
+func Translate(locale, key string, params Params) string {
+	text, ok := catalog[locale][key]
+	if !ok {
+		if text, ok = catalog[English][key]; !ok {
+			text = key
+		}
+	}
+	return format(text, params)
+}
+
+// format replaces the {name} placeholders of a text with their parameter
+func format(text string, params Params) string {
+	if len(params) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Messages returns the catalog of a locale for clients rendering messages
+// themselves, completed with the English messages it lacks
+func Messages(locale string) map[string]string {
+	messages := make(map[string]string, len(catalog[English]))
+	for key, text := range catalog[English] {
+		messages[key] = text
+	}
+	for key, text := range catalog[locale] {
+		messages[key] = text
+	}
+	return messages
+}
+
+// Error returns an error with a translated message
+func Error(key string, params Params) error {
+	return errors.New(T(key, params))
+}
+
+// Wrap returns err prefixed with a translated message
+func Wrap(err error, key string, params Params) error {
+	return fmt.Errorf("%s: %w", T(key, params), err)
+}
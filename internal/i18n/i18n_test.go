@@ -0,0 +1,116 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+var placeholder = regexp.MustCompile(`\{[a-zA-Z]+\}`)
+
+// placeholders returns the sorted placeholders of a text
+func placeholders(text string) []string {
+	names := placeholder.FindAllString(text, -1)
+	sort.Strings(names)
+	return names
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for locale, messages := range catalog {
+		for key, text := range catalog[English] {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s lacks %q", locale, key)
+				continue
+			}
+			if want, got := placeholders(text), placeholders(translated); len(want) != len(got) {
+				t.Errorf("%s %q placeholders = %v, want %v", locale, key, got, want)
+			} else {
+				for i := range want {
+					if want[i] != got[i] {
+						t.Errorf("%s %q placeholders = %v, want %v", locale, key, got, want)
+						break
+					}
+				}
+			}
+		}
+		for key := range messages {
+			if _, ok := catalog[English][key]; !ok {
+				t.Errorf("%s has %q, not in English", locale, key)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"zh_CN.UTF-8": Chinese,
+		"zh-TW":       Chinese,
+		"en_US":       English,
+		"EN":          English,
+		"fr_FR.UTF-8": "",
+		"C":           "",
+		"":            "",
+	}
+	for name, want := range tests {
+		if got := Normalize(name); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "zh_CN.UTF-8")
+	if got := FromEnv(); got != Chinese {
+		t.Errorf("FromEnv() with LANG = %q", got)
+	}
+
+	// LC_ALL overrides LANG, even when unsupported
+	t.Setenv("LC_ALL", "fr_FR.UTF-8")
+	if got := FromEnv(); got != English {
+		t.Errorf("FromEnv() with LC_ALL = %q", got)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	params := Params{"name": "web", "reason": "timeout"}
+	if got := Translate(English, "service.startFailed", params); got == "service.startFailed" || got == "" {
+		t.Errorf("English message not found: %q", got)
+	}
+	if en, zh := Translate(English, "conn.new", params), Translate(Chinese, "conn.new", params); en == zh {
+		t.Errorf("Chinese message is English: %q", zh)
+	}
+
+	// Unknown keys are free text
+	if got := Translate(Chinese, "restarted", nil); got != "restarted" {
+		t.Errorf("Translate(unknown) = %q", got)
+	}
+	// Unknown locales fall back to English
+	if got, want := Translate("fr", "conn.new", params), Translate(English, "conn.new", params); got != want {
+		t.Errorf("Translate(fr) = %q, want %q", got, want)
+	}
+	if got := format("{a} and {b}", Params{"a": "1", "b": "2"}); got != "1 and 2" {
+		t.Errorf("format() = %q", got)
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "")
+	t.Cleanup(func() { SetLocale(English) })
+
+	SetLocale("zh")
+	if Locale() != Chinese {
+		t.Errorf("Locale() = %q", Locale())
+	}
+	SetLocale("")
+	if Locale() != English {
+		t.Errorf("Locale() with no setting = %q", Locale())
+	}
+	if got := Messages(Chinese)["conn.new"]; got != catalog[Chinese]["conn.new"] {
+		t.Errorf("Messages(zh) = %q", got)
+	}
+}
@@ -1,6 +1,8 @@
 package ipc
 
 import (
+	"context"
+	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"sync"
@@ -13,11 +15,45 @@ import (
 type Client struct {
 	mu     sync.Mutex
 	client *rpc.Client
+	dial   func(timeout time.Duration) (net.Conn, error)
 }
 
-// NewClient creates a new IPC client
+// NewClient creates a new IPC client of the service on this machine
 func NewClient() *Client {
-	return &Client{}
+	return NewSocketClient(GetSocketPath())
+}
+
+// NewSocketClient creates a new IPC client of a service on this machine
+// listening on another socket
+func NewSocketClient(path string) *Client {
+	return &Client{dial: func(timeout time.Duration) (net.Conn, error) {
+		return dial(path, timeout)
+	}}
+}
+
+// NewContextClient creates a new IPC client of the service of a context
+func NewContextClient(ctx *models.Context) (*Client, error) {
+	if err := ctx.Validate(); err != nil {
+		return nil, err
+	}
+	if ctx.Remote != nil {
+		return NewRemoteClient(*ctx.Remote)
+	}
+	if ctx.Socket != "" {
+		return NewSocketClient(ctx.Socket), nil
+	}
+	return NewClient(), nil
+}
+
+// NewRemoteClient creates a new IPC client of a service on another machine,
+// reached over TLS
+func NewRemoteClient(target models.RemoteTarget) (*Client, error) {
+	if _, err := remoteTLSConfig(&target); err != nil {
+		return nil, err
+	}
+	return &Client{dial: func(timeout time.Duration) (net.Conn, error) {
+		return dialRemote(&target, timeout)
+	}}, nil
 }
 
 // Connect establishes a connection to the IPC server
@@ -29,8 +65,7 @@ func (c *Client) Connect() error {
 		return nil
 	}
 
-	socketPath := GetSocketPath()
-	conn, err := dial(socketPath, 5*time.Second)
+	conn, err := c.dial(5 * time.Second)
 	if err != nil {
 		return err
 	}
@@ -73,8 +108,7 @@ func (c *Client) call(method string, args interface{}, reply interface{}) error
 	defer c.mu.Unlock()
 
 	if c.client == nil {
-		socketPath := GetSocketPath()
-		conn, err := dial(socketPath, 5*time.Second)
+		conn, err := c.dial(5 * time.Second)
 		if err != nil {
 			return err
 		}
@@ -140,6 +174,15 @@ func (c *Client) StopRule(id string) error {
 	return c.call("StopRule", &id, &success)
 }
 
+// ExtendRule moves the expiry of a rule d later
+func (c *Client) ExtendRule(id string, d time.Duration) (*models.Rule, error) {
+	var rule models.Rule
+	if err := c.call("ExtendRule", &ExtendRuleArgs{ID: id, Duration: d}, &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
 // ==================== Chain Operations ====================
 
 // GetChains returns all chains
@@ -204,6 +247,87 @@ func (c *Client) Ping() bool {
 	return err == nil
 }
 
+// ==================== Stats Operations ====================
+
+// WhoAmI returns the user and role the service sees the client as
+func (c *Client) WhoAmI() (*models.IPCPeer, error) {
+	var peer models.IPCPeer
+	err := c.call("WhoAmI", &Empty{}, &peer)
+	return &peer, err
+}
+
+// GetRuleStats returns statistics for a specific rule
+func (c *Client) GetRuleStats(ruleID string) (*models.RuleStats, error) {
+	var stats models.RuleStats
+	err := c.call("GetRuleStats", &GetRuleStatsArgs{RuleID: ruleID}, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetAllRuleStats returns statistics for all rules
+func (c *Client) GetAllRuleStats() (map[string]*models.RuleStats, error) {
+	var stats map[string]*models.RuleStats
+	err := c.call("GetAllRuleStats", &Empty{}, &stats)
+	return stats, err
+}
+
+// GetStatsHistory returns the recent traffic samples of a rule
+func (c *Client) GetStatsHistory(ruleID string, resolution models.HistoryResolution) (*models.StatsHistory, error) {
+	var history models.StatsHistory
+	err := c.call("GetStatsHistory", &GetStatsHistoryArgs{RuleID: ruleID, Resolution: resolution}, &history)
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// GetAccounting returns the persistent traffic totals selected by a query
+func (c *Client) GetAccounting(q models.AccountingQuery) ([]models.TrafficUsage, error) {
+	var usage []models.TrafficUsage
+	err := c.call("GetAccounting", &q, &usage)
+	return usage, err
+}
+
+// ExportAccounting returns the traffic totals selected by a query as CSV or JSON
+func (c *Client) ExportAccounting(q models.AccountingQuery, format string) ([]byte, error) {
+	var data []byte
+	err := c.call("ExportAccounting", &ExportAccountingArgs{Query: q, Format: format}, &data)
+	return data, err
+}
+
+// ==================== Connection Operations ====================
+
+// GetActiveConnections returns the connections currently handled by a rule
+func (c *Client) GetActiveConnections(ruleID string) ([]models.ActiveConnection, error) {
+	var conns []models.ActiveConnection
+	err := c.call("GetActiveConnections", &ruleID, &conns)
+	return conns, err
+}
+
+// CloseConnection forcibly closes an active connection
+func (c *Client) CloseConnection(ruleID, connID string) error {
+	var success bool
+	return c.call("CloseConnection", &CloseConnectionArgs{RuleID: ruleID, ConnID: connID}, &success)
+}
+
+// CloseClientConnections forcibly closes all active connections from a client
+func (c *Client) CloseClientConnections(ruleID, client string) (int, error) {
+	var n int
+	err := c.call("CloseClientConnections", &CloseClientConnectionsArgs{RuleID: ruleID, Client: client}, &n)
+	return n, err
+}
+
+// ==================== Validation ====================
+
+// Validate runs the pre-flight checks on a rule, or on the whole configuration if rule is nil
+func (c *Client) Validate(rule *models.Rule) (*models.ValidationReport, error) {
+	var report models.ValidationReport
+	err := c.call("Validate", &ValidateArgs{Rule: rule}, &report)
+	return &report, err
+}
+
 // ==================== Import/Export Operations ====================
 
 // ExportData exports all data as JSON
@@ -242,12 +366,129 @@ func (c *Client) GetLogsByRule(ruleID string) ([]*models.LogEntry, error) {
 	return logs, err
 }
 
-// ClearLogs clears all log entries
+// QueryLogs returns a page of the persisted log entries matching q, newest first
+func (c *Client) QueryLogs(q models.LogQuery) (*models.LogPage, error) {
+	var page models.LogPage
+	if err := c.call("QueryLogs", &q, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SetRuleDebug turns debug logging of a rule on or off
+func (c *Client) SetRuleDebug(ruleID string, enabled bool) error {
+	var success bool
+	return c.call("SetRuleDebug", &SetRuleDebugArgs{RuleID: ruleID, Enabled: enabled}, &success)
+}
+
+// ClearLogs clears the log entries in memory
 func (c *Client) ClearLogs() error {
 	var success bool
 	return c.call("ClearLogs", &Empty{}, &success)
 }
 
+// GetConnections returns the most recent access records of a rule, newest first
+func (c *Client) GetConnections(ruleID string, limit int) ([]models.ConnectionRecord, error) {
+	var records []models.ConnectionRecord
+	err := c.call("GetConnections", &GetConnectionsArgs{RuleID: ruleID, Limit: limit}, &records)
+	return records, err
+}
+
+// ==================== Event Stream ====================
+
+const (
+	// subscribeWait is how long one WaitEvents call of Subscribe blocks
+	subscribeWait = 30 * time.Second
+
+	// subscribeRetry and subscribeMaxRetry bound the delay before
+	// reconnecting to the service
+	subscribeRetry    = time.Second
+	subscribeMaxRetry = 30 * time.Second
+)
+
+// WaitEvents returns the stream events after afterID, waiting up to timeout
+// for one. Pass the Stream and LastID of the previous batch, or an empty
+// stream to start with the events published from now on.
+func (c *Client) WaitEvents(stream string, afterID int64, timeout time.Duration) (*models.EventBatch, error) {
+	var batch models.EventBatch
+	err := c.call("WaitEvents", &WaitEventsArgs{Stream: stream, AfterID: afterID, Timeout: timeout}, &batch)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// Subscribe follows the event stream of the service until ctx is done,
+// calling fn with every batch of events in order. It starts after afterID
+// of stream, or with the events published from now on if stream is empty.
+// When the connection drops it reconnects and resumes after the last
+// delivered event; if events were lost meanwhile, or the service restarted,
+// fn receives a batch with Reset set and the caller should reload its state.
+//
+// Subscribe uses a connection of its own, so it does not hold up the other
+// calls of the client.
+func (c *Client) Subscribe(ctx context.Context, stream string, afterID int64, fn func(*models.EventBatch)) error {
+	var client *rpc.Client
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+
+	delay := subscribeRetry
+	for {
+		batch, err := c.waitEvents(ctx, &client, &WaitEventsArgs{Stream: stream, AfterID: afterID, Timeout: subscribeWait})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if client != nil {
+				client.Close()
+				client = nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > subscribeMaxRetry {
+				delay = subscribeMaxRetry
+			}
+			continue
+		}
+
+		delay = subscribeRetry
+		if batch.Reset || len(batch.Events) > 0 {
+			fn(batch)
+		}
+		stream, afterID = batch.Stream, batch.LastID
+	}
+}
+
+// waitEvents makes one WaitEvents call on *client, connecting first if
+// needed, and gives up when ctx is done
+func (c *Client) waitEvents(ctx context.Context, client **rpc.Client, args *WaitEventsArgs) (*models.EventBatch, error) {
+	if *client == nil {
+		conn, err := c.dial(5 * time.Second)
+		if err != nil {
+			return nil, err
+		}
+		*client = jsonrpc.NewClient(conn)
+	}
+
+	var batch models.EventBatch
+	call := (*client).Go("RPCHandler.WaitEvents", args, &batch, nil)
+	select {
+	case <-call.Done:
+		if call.Error != nil {
+			return nil, call.Error
+		}
+		return &batch, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // GetLogsArgs holds arguments for GetLogs
 type GetLogsArgs struct {
 	Count int `json:"count"`
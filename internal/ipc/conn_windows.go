@@ -8,9 +8,19 @@ import (
 	"time"
 )
 
-// dial creates a TCP connection on Windows
-// It first tries the port from the discovery file, then all known ports
+// dial creates a TCP connection on Windows, opened with the secret of the
+// service when the user can read it
 func dial(path string, timeout time.Duration) (net.Conn, error) {
+	conn, err := dialPort(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return hello(conn, readSecretFile())
+}
+
+// dialPort connects to the port of the service. It first tries the port
+// from the discovery file, then all known ports.
+func dialPort(timeout time.Duration) (net.Conn, error) {
 	// First, try the port from the discovery file
 	discoveredPort := readPortFile()
 	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", discoveredPort), timeout)
@@ -0,0 +1,206 @@
+package ipc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/models"
+)
+
+const (
+	// eventBufferSize is the number of stream events kept for subscribers
+	// resuming after a reconnect
+	eventBufferSize = 1000
+
+	// maxEventBatch is the most events delivered in one batch
+	maxEventBatch = 500
+
+	// eventPollInterval is how often statistics are compared for changes
+	eventPollInterval = time.Second
+
+	// defaultWaitTimeout and maxWaitTimeout bound how long WaitEvents
+	// blocks without events
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 60 * time.Second
+)
+
+// eventHub buffers the stream events of the service and wakes waiting
+// subscribers
+type eventHub struct {
+	mu     sync.Mutex
+	stream string
+	events []models.StreamEvent
+	nextID int64
+	wake   chan struct{} // closed when an event is added or the hub closes
+	closed bool
+
+	// Last published statistics, compared by poll
+	stats map[string]statsState
+}
+
+// statsState is the part of the statistics compared between stats events
+type statsState struct {
+	bytesIn, bytesOut, connections, errors int64
+	activeConns                            int
+	rateIn, rateOut                        int64
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		stream: strconv.FormatInt(time.Now().UnixNano(), 36),
+		nextID: 1,
+		wake:   make(chan struct{}),
+	}
+}
+
+// publish adds an event to the stream
+func (h *eventHub) publish(ev models.StreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	ev.ID = h.nextID
+	h.nextID++
+	if len(h.events) >= eventBufferSize {
+		h.events = append(h.events[1:], ev)
+	} else {
+		h.events = append(h.events, ev)
+	}
+	close(h.wake)
+	h.wake = make(chan struct{})
+}
+
+// publishEvent adds an engine event to the stream. Rule status changes
+// are left to publishStatus, which also sees manual and scheduled ones.
+func (h *eventHub) publishEvent(ev models.Event) {
+	if ev.Type == models.EventRuleStatus {
+		return
+	}
+	h.publish(models.StreamEvent{Kind: models.StreamKindEvent, Event: &ev})
+}
+
+// publishStatus adds a rule status change of the store to the stream, as
+// it happens
+func (h *eventHub) publishStatus(ev models.Event) {
+	h.publish(models.StreamEvent{Kind: models.StreamKindEvent, Event: &ev})
+}
+
+// publishLog adds a log entry to the stream
+func (h *eventHub) publishLog(entry models.LogEntry) {
+	h.publish(models.StreamEvent{Kind: models.StreamKindLog, Log: &entry})
+}
+
+// run polls statistics until ctx is done
+func (h *eventHub) run(ctx context.Context, e *engine.Engine) {
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	h.poll(e)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.poll(e)
+		}
+	}
+}
+
+// poll publishes the statistics changed since the previous poll. The first
+// poll only records them.
+func (h *eventHub) poll(e *engine.Engine) {
+	first := h.stats == nil
+
+	all := e.GetAllRuleStats()
+	stats := make(map[string]statsState, len(all))
+	changed := make(map[string]*models.RuleStats)
+	for id, rs := range all {
+		st := statsState{
+			bytesIn:     rs.BytesIn,
+			bytesOut:    rs.BytesOut,
+			connections: rs.Connections,
+			errors:      rs.Errors,
+			activeConns: rs.ActiveConns,
+			rateIn:      rs.RateIn,
+			rateOut:     rs.RateOut,
+		}
+		stats[id] = st
+		if !first && h.stats[id] != st {
+			changed[id] = rs
+		}
+	}
+	h.stats = stats
+	if len(changed) > 0 {
+		h.publish(models.StreamEvent{Kind: models.StreamKindStats, Stats: changed})
+	}
+}
+
+// wait returns the events after afterID, waiting up to timeout for one to
+// be published. An empty stream subscribes to the events published from
+// now on; a different stream, or events lost from the buffer, reset the
+// subscriber. Returns nil once the hub is closed.
+func (h *eventHub) wait(stream string, afterID int64, timeout time.Duration) *models.EventBatch {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	batch := &models.EventBatch{Stream: h.stream}
+	last := h.nextID - 1
+	if stream == "" {
+		afterID = last
+	} else if stream != h.stream || afterID > last {
+		batch.Reset = true
+		batch.LastID = last
+		return batch
+	}
+
+	for {
+		if afterID < last-int64(len(h.events)) {
+			batch.Reset = true
+			batch.LastID = last
+			return batch
+		}
+		if n := last - afterID; n > 0 {
+			start := len(h.events) - int(n)
+			end := start + maxEventBatch
+			if end > len(h.events) {
+				end = len(h.events)
+			}
+			batch.Events = append([]models.StreamEvent(nil), h.events[start:end]...)
+			batch.LastID = batch.Events[len(batch.Events)-1].ID
+			return batch
+		}
+		if h.closed {
+			return nil
+		}
+
+		wake := h.wake
+		h.mu.Unlock()
+		select {
+		case <-wake:
+		case <-timer.C:
+			h.mu.Lock()
+			batch.LastID = afterID
+			return batch
+		}
+		h.mu.Lock()
+		last = h.nextID - 1
+	}
+}
+
+// close wakes the waiting subscribers, stopping further events
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.wake)
+	}
+}
@@ -0,0 +1,108 @@
+package ipc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+func TestEventHub(t *testing.T) {
+	h := newEventHub()
+
+	// A new subscriber waits for the next event
+	done := make(chan *models.EventBatch)
+	go func() {
+		done <- h.wait("", 0, 5*time.Second)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	h.publishLog(models.LogEntry{ID: 7, Message: "hello"})
+	batch := <-done
+	if batch.Reset || len(batch.Events) != 1 || batch.Events[0].Log.Message != "hello" || batch.LastID != 1 {
+		t.Fatalf("first batch = %+v", batch)
+	}
+
+	// Rule status changes come from the store, not the engine
+	h.publishEvent(models.Event{Type: models.EventRuleStatus, Status: "running"})
+	h.publishEvent(models.Event{Type: models.EventTargetHealth, Target: "10.0.0.1:80", Status: "down"})
+	h.publishLog(models.LogEntry{ID: 8})
+
+	// Resuming returns what was published meanwhile
+	batch = h.wait(batch.Stream, batch.LastID, time.Second)
+	if len(batch.Events) != 2 || batch.Events[0].Event.Type != models.EventTargetHealth || batch.LastID != 3 {
+		t.Fatalf("resumed batch = %+v", batch)
+	}
+
+	// Nothing new: the wait times out with the same position
+	start := time.Now()
+	if batch = h.wait(batch.Stream, 3, 100*time.Millisecond); len(batch.Events) != 0 || batch.LastID != 3 || batch.Reset {
+		t.Errorf("empty batch = %+v", batch)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("wait returned before the timeout")
+	}
+
+	// Another stream, or events lost from the buffer, reset the subscriber
+	if batch = h.wait("other", 3, time.Second); !batch.Reset || batch.LastID != 3 {
+		t.Errorf("batch of another stream = %+v", batch)
+	}
+	for i := 0; i < eventBufferSize; i++ {
+		h.publishLog(models.LogEntry{})
+	}
+	if batch = h.wait(batch.Stream, 2, time.Second); !batch.Reset {
+		t.Errorf("batch after lost events = %+v", batch)
+	}
+	if batch = h.wait(batch.Stream, 3, time.Second); batch.Reset || len(batch.Events) != maxEventBatch {
+		t.Errorf("batch of the whole buffer: reset %v, %d events", batch.Reset, len(batch.Events))
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.close()
+	}()
+	if batch = h.wait(batch.Stream, h.nextID-1, 5*time.Second); batch != nil {
+		t.Errorf("wait on a closed hub = %+v", batch)
+	}
+}
+
+func TestEventHubStatus(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	e := engine.New()
+	defer e.StopAll()
+
+	rule := &models.Rule{ID: "r1", Name: "web", Status: models.RuleStatusStopped, Type: models.RuleTypeForward, Protocol: models.ProtocolTCP, LocalPort: 18080, TargetHost: "127.0.0.1", TargetPort: 80}
+	if err := store.CreateRule(rule); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	h := newEventHub()
+	h.poll(e)
+	if len(h.events) != 0 {
+		t.Fatalf("first poll published %d events", len(h.events))
+	}
+
+	// Every transition is published as it happens, however short
+	store.SetRuleStatusCallback(h.publishStatus)
+	store.UpdateRuleStatus(rule.ID, models.RuleStatusError, "bind failed")
+	store.UpdateRuleStatus(rule.ID, models.RuleStatusBackoff, "bind failed")
+	store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+	store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+	store.DeleteRule(rule.ID)
+	store.CreateRule(&models.Rule{ID: "r2", Name: "new", Status: models.RuleStatusStopped, Type: models.RuleTypeForward, Protocol: models.ProtocolTCP, LocalPort: 18081, TargetHost: "127.0.0.1", TargetPort: 80})
+	h.poll(e)
+
+	var got []string
+	for _, ev := range h.events {
+		got = append(got, ev.Event.RuleName+" "+ev.Event.Status)
+	}
+	want := []string{"web error", "web backoff", "web running", "web deleted", "new stopped"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("status events = %v, want %v", got, want)
+	}
+}
@@ -3,10 +3,37 @@
 package ipc
 
 import (
+	"fmt"
 	"net"
 	"os"
+	"os/user"
+	"strconv"
+	"syscall"
 )
 
+// ensureSocketDir creates the directory of the socket, owned by the user
+// of the service and writable by it only, so other users cannot remove the
+// socket and listen in its place
+func ensureSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("socket directory %s is owned by uid %d, not by the service", dir, st.Uid)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return os.Chmod(dir, 0755)
+	}
+	return nil
+}
+
 // createListener creates a platform-specific listener
 func createListener(path string) (net.Listener, error) {
 	// Remove existing socket file
@@ -17,12 +44,38 @@ func createListener(path string) (net.Listener, error) {
 		return nil, err
 	}
 
-	// Set permissions on socket - allow all users to connect
-	os.Chmod(path, 0666)
+	if peerCredentialsSupported {
+		// Allow all users to connect, the role of each is checked from the
+		// credentials of its connection
+		os.Chmod(path, 0666)
+	} else if err := restrictSocket(path); err != nil {
+		listener.Close()
+		return nil, err
+	}
 
 	return listener, nil
 }
 
+// restrictSocket allows only the user of the service and the members of
+// the pfm group to connect, where the kernel does not tell who the clients
+// are
+func restrictSocket(path string) error {
+	mode := os.FileMode(0600)
+	if g, err := user.LookupGroup("pfm"); err == nil {
+		gid, _ := strconv.Atoi(g.Gid)
+		if err := os.Chown(path, -1, gid); err != nil {
+			return fmt.Errorf("socket group: %w", err)
+		}
+		mode = 0660
+	}
+	return os.Chmod(path, mode)
+}
+
+// loopbackSecret is not used: clients connect through the socket
+func loopbackSecret() string {
+	return ""
+}
+
 // cleanupListener cleans up platform-specific resources
 func cleanupListener(path string) {
 	os.Remove(path)
@@ -3,13 +3,18 @@
 package ipc
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"pfm/internal/logging"
+
+	"golang.org/x/sys/windows"
 )
 
 // Windows IPC ports - try these in order if some are occupied
@@ -18,6 +23,17 @@ var windowsIPCPorts = []int{19846, 19856, 19866, 19876, 19886}
 // activeIPCPort stores the port that's actually being used
 var activeIPCPort int
 
+// ipcSecretName is the file next to the port file holding the secret local
+// clients administer the service with
+const ipcSecretName = "ipc_secret"
+
+// privateFileSDDL lets only SYSTEM, Administrators and the owner of a file,
+// the user of the service, access it
+const privateFileSDDL = "D:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;FA;;;OW)"
+
+// activeSecret stores the secret of the running service
+var activeSecret string
+
 // getPortFilePaths returns potential paths for the port discovery file
 // Order: Shared (ProgramData), User (AppData)
 func getPortFilePaths() []string {
@@ -51,12 +67,17 @@ func createListener(path string) (net.Listener, error) {
 			activeIPCPort = port
 			// Write port to file for client discovery
 			if err := writePortFile(port); err != nil {
-				log.Printf("[IPC] Warning: failed to write port file: %v", err)
+				logging.For("ipc").Warn("Failed to write port file", "error", err)
+			}
+			// Without the secret, clients have no access
+			if err := writeSecretFile(); err != nil {
+				listener.Close()
+				return nil, fmt.Errorf("failed to write IPC secret: %w", err)
 			}
-			log.Printf("[IPC] Successfully bound to port %d", port)
+			logging.For("ipc").Info("Bound to port", "port", port)
 			return listener, nil
 		}
-		log.Printf("[IPC] Port %d unavailable: %v, trying next...", port, err)
+		logging.For("ipc").Info("Port unavailable, trying next", "port", port, "error", err)
 	}
 
 	// All ports failed
@@ -90,6 +111,87 @@ func writePortFile(port int) error {
 	return nil
 }
 
+// getSecretFilePaths returns the paths of the secret file, next to the port
+// files
+func getSecretFilePaths() []string {
+	var paths []string
+	for _, path := range getPortFilePaths() {
+		paths = append(paths, filepath.Join(filepath.Dir(path), ipcSecretName))
+	}
+	return paths
+}
+
+// writeSecretFile generates the secret of the service and writes it to the
+// secret file(s)
+func writeSecretFile() error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	secret := hex.EncodeToString(buf)
+
+	var lastErr error
+	written := false
+	for _, path := range getSecretFilePaths() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := writePrivateFile(path, []byte(secret)); err != nil {
+			lastErr = err
+			continue
+		}
+		written = true
+	}
+	if !written {
+		return lastErr
+	}
+	activeSecret = secret
+	return nil
+}
+
+// writePrivateFile writes a file only SYSTEM, Administrators and the user
+// of the service can read. The file is restricted before the data is
+// written.
+func writePrivateFile(path string, data []byte) error {
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	sd, err := windows.SecurityDescriptorFromString(privateFileSDDL)
+	if err == nil {
+		var dacl *windows.ACL
+		if dacl, _, err = sd.DACL(); err == nil {
+			err = windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+				windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+		}
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// readSecretFile reads the secret of the service, empty when the user
+// cannot read it
+func readSecretFile() string {
+	for _, path := range getSecretFilePaths() {
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// loopbackSecret returns the secret of the running service
+func loopbackSecret() string {
+	return activeSecret
+}
+
 // readPortFile reads the active port from the discovery file
 // (Used by client in conn_windows.go, but defined here for package consistency)
 // NOTE: conn_windows.go implements its own logic using getPortFilePaths now
@@ -108,9 +210,14 @@ func readPortFile() int {
 
 // cleanupListener cleans up platform-specific resources
 func cleanupListener(path string) {
-	// Remove port files
-	paths := getPortFilePaths()
+	// Remove port and secret files
+	paths := append(getPortFilePaths(), getSecretFilePaths()...)
 	for _, p := range paths {
 		os.Remove(p)
 	}
 }
+
+// ensureSocketDir is not used: the service listens on loopback TCP
+func ensureSocketDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package ipc
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// Local clients on Windows connect over loopback TCP, where the system does
+// not tell who they are. They open the connection like remote clients, with
+// the secret the service writes to a file only Administrators, SYSTEM and
+// the user of the service can read as token.
+
+// authenticateLoopback reads the hello of a loopback client, admin with the
+// secret and without access otherwise. It returns the connection to serve.
+func authenticateLoopback(conn net.Conn, secret string) (*models.IPCPeer, net.Conn, error) {
+	reader := bufio.NewReaderSize(conn, maxHelloSize)
+	conn.SetDeadline(time.Now().Add(remoteHandshakeTimeout))
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("read hello: %w", err)
+	}
+	var hello remoteHello
+	if err := json.Unmarshal(line, &hello); err != nil {
+		return nil, nil, fmt.Errorf("invalid hello: %w", err)
+	}
+
+	peer := &models.IPCPeer{UID: -1, Role: models.IPCRoleNone}
+	if secret != "" && subtle.ConstantTimeCompare([]byte(hello.Token), []byte(secret)) == 1 {
+		peer.Role = models.IPCRoleAdmin
+	}
+	line, _ = json.Marshal(remoteWelcome{Peer: peer})
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return peer, &bufferedConn{Conn: conn, reader: reader}, nil
+}
@@ -0,0 +1,44 @@
+package ipc
+
+import (
+	"net"
+	"testing"
+
+	"pfm/internal/models"
+)
+
+func TestAuthenticateLoopback(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		want   models.IPCRole
+	}{
+		{"secret", "0123456789abcdef", "0123456789abcdef", models.IPCRoleAdmin},
+		{"wrong secret", "0123456789abcdef", "fedcba9876543210", models.IPCRoleNone},
+		{"no secret", "0123456789abcdef", "", models.IPCRoleNone},
+		{"service without secret", "", "", models.IPCRoleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			done := make(chan *models.IPCPeer, 1)
+			go func() {
+				peer, _, err := authenticateLoopback(server, tt.secret)
+				if err != nil {
+					t.Errorf("authenticateLoopback() error = %v", err)
+				}
+				done <- peer
+			}()
+			if _, err := hello(client, tt.token); err != nil {
+				t.Fatalf("hello() error = %v", err)
+			}
+			if peer := <-done; peer == nil || peer.Role != tt.want {
+				t.Errorf("peer = %+v, want role %s", peer, tt.want)
+			}
+		})
+	}
+}
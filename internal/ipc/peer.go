@@ -0,0 +1,42 @@
+package ipc
+
+import (
+	"errors"
+	"net"
+	"os/user"
+	"strconv"
+
+	"pfm/internal/models"
+)
+
+// errPeerUnsupported is returned where the user of a connection cannot be
+// known
+var errPeerUnsupported = errors.New("peer credentials not supported")
+
+// identifyPeer returns the local user at the other end of a connection,
+// with the names and IDs of its groups
+func identifyPeer(conn net.Conn) (*models.IPCPeer, error) {
+	uid, gids, err := peerCredentials(conn)
+	if err != nil {
+		return nil, err
+	}
+	peer := &models.IPCPeer{UID: uid}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		peer.User = u.Username
+		if ids, err := u.GroupIds(); err == nil {
+			gids = append(gids, ids...)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, gid := range gids {
+		if seen[gid] {
+			continue
+		}
+		seen[gid] = true
+		peer.Groups = append(peer.Groups, gid)
+		if g, err := user.LookupGroupId(gid); err == nil {
+			peer.Groups = append(peer.Groups, g.Name)
+		}
+	}
+	return peer, nil
+}
@@ -0,0 +1,130 @@
+//go:build linux || darwin
+
+package ipc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+func TestIdentifyPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pfm.sock")
+	ln, err := createListener(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if conn, err := net.Dial("unix", path); err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	peer, err := identifyPeer(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.UID != os.Getuid() {
+		t.Errorf("peer uid = %d, want %d", peer.UID, os.Getuid())
+	}
+	gid := strconv.Itoa(os.Getgid())
+	found := false
+	for _, g := range peer.Groups {
+		found = found || g == gid
+	}
+	if !found {
+		t.Errorf("peer groups %v lack gid %s", peer.Groups, gid)
+	}
+}
+
+func TestEnsureSocketDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(dir, 0777)
+	if err := ensureSocketDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0755 {
+		t.Errorf("socket directory mode = %v", info.Mode().Perm())
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	os.Symlink(dir, link)
+	if err := ensureSocketDir(link); err == nil {
+		t.Error("ensureSocketDir() accepts a symbolic link")
+	}
+}
+
+func TestRestrictSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pfm.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := restrictSocket(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm()&0007 != 0 {
+		t.Errorf("socket mode = %v, open to other users", info.Mode().Perm())
+	}
+}
+
+func TestHandlerRoles(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := &models.Rule{Name: "web", Type: models.RuleTypeForward, LocalPort: 18080, Protocol: models.ProtocolTCP,
+		TargetHost: "127.0.0.1", TargetPort: 80, Auth: &models.Auth{Username: "u", Password: "secret"}}
+	if err := store.CreateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly := &RPCHandler{store: store, peer: &models.IPCPeer{UID: 1001, User: "bob", Role: models.IPCRoleReadOnly}}
+	var rules []*models.Rule
+	if err := readOnly.GetRules(&Empty{}, &rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Auth.Password != models.RedactedValue {
+		t.Errorf("read-only rules = %+v", rules)
+	}
+	var ok bool
+	if err := readOnly.DeleteRule(&rule.ID, &ok); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("read-only DeleteRule() error = %v", err)
+	}
+	var data []byte
+	if err := readOnly.ExportData(&Empty{}, &data); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("read-only ExportData() error = %v", err)
+	}
+
+	none := &RPCHandler{store: store, peer: &models.IPCPeer{UID: 1002, Role: models.IPCRoleNone}}
+	if err := none.GetRules(&Empty{}, &rules); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("GetRules() without access error = %v", err)
+	}
+	var peer models.IPCPeer
+	if err := none.WhoAmI(&Empty{}, &peer); err != nil || peer.Role != models.IPCRoleNone {
+		t.Errorf("WhoAmI() = %+v, %v", peer, err)
+	}
+
+	admin := &RPCHandler{store: store, peer: &models.IPCPeer{UID: 0, Role: models.IPCRoleAdmin}}
+	if err := admin.GetRules(&Empty{}, &rules); err != nil || rules[0].Auth.Password != "secret" {
+		t.Errorf("admin rules = %+v, %v", rules, err)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build darwin || freebsd
+
+package ipc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported is true: the socket may be open to every user
+const peerCredentialsSupported = true
+
+// peerCredentials returns the user and groups of the process at the other
+// end of a Unix socket, given by the kernel (LOCAL_PEERCRED)
+func peerCredentials(conn net.Conn) (int, []string, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, nil, errPeerUnsupported
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, nil, err
+	}
+	var cred *unix.Xucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return 0, nil, err
+	}
+	if credErr != nil {
+		return 0, nil, fmt.Errorf("LOCAL_PEERCRED: %w", credErr)
+	}
+	gids := make([]string, 0, cred.Ngroups)
+	for _, gid := range cred.Groups[:cred.Ngroups] {
+		gids = append(gids, strconv.Itoa(int(gid)))
+	}
+	return int(cred.Uid), gids, nil
+}
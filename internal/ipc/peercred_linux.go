@@ -0,0 +1,38 @@
+//go:build linux
+
+package ipc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentialsSupported is true: the socket may be open to every user
+const peerCredentialsSupported = true
+
+// peerCredentials returns the user and group of the process at the other
+// end of a Unix socket, given by the kernel (SO_PEERCRED)
+func peerCredentials(conn net.Conn) (int, []string, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, nil, errPeerUnsupported
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, nil, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, nil, err
+	}
+	if credErr != nil {
+		return 0, nil, fmt.Errorf("SO_PEERCRED: %w", credErr)
+	}
+	return int(cred.Uid), []string{strconv.Itoa(int(cred.Gid))}, nil
+}
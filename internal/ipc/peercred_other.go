@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd
+
+package ipc
+
+import "net"
+
+// peerCredentialsSupported is false: the socket is restricted to the pfm
+// group, as its clients cannot be told apart
+const peerCredentialsSupported = false
+
+// peerCredentials is not supported: Windows clients connect over loopback
+// TCP, and the kernel does not give the user of a Unix socket on the other
+// systems
+func peerCredentials(conn net.Conn) (int, []string, error) {
+	return 0, nil, errPeerUnsupported
+}
@@ -0,0 +1,467 @@
+package ipc
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// Remote clients open their connection with a hello line, answered by a
+// welcome line, before the JSON-RPC calls
+type remoteHello struct {
+	Token string `json:"token,omitempty"` // Empty when authenticated by a certificate
+}
+
+type remoteWelcome struct {
+	Peer  *models.IPCPeer `json:"peer,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+const (
+	// remoteHandshakeTimeout bounds the TLS handshake and the hello
+	remoteHandshakeTimeout = 10 * time.Second
+
+	// maxHelloSize bounds the hello line
+	maxHelloSize = 4096
+
+	// Self-signed certificate generated when none is configured
+	remoteCertFile = "remote-cert.pem"
+	remoteKeyFile  = "remote-key.pem"
+)
+
+// remoteListener serves the API on a TCP port secured by TLS
+type remoteListener struct {
+	mu          sync.Mutex
+	server      *Server
+	config      *models.RemoteAccess // Applied settings, nil when disabled
+	listener    net.Listener
+	fingerprint string
+	err         error // Why the listener could not start
+	sessions    map[*remoteSession]struct{}
+}
+
+// remoteSession is an authenticated remote connection
+type remoteSession struct {
+	conn  net.Conn
+	token string            // Token the client authenticated with
+	cert  *x509.Certificate // Or its verified certificate
+	peer  *models.IPCPeer
+}
+
+// apply starts, restarts or stops the listener for new settings. Open
+// connections are kept, unless their token or certificate is no longer
+// accepted or their role changed.
+func (r *remoteListener) apply(config *models.RemoteAccess) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if config != nil && !config.Enabled {
+		config = nil
+	}
+	if reflect.DeepEqual(config, r.config) && (config == nil || r.listener != nil) {
+		return nil
+	}
+	if r.listener != nil {
+		r.listener.Close()
+		r.listener = nil
+	}
+	r.fingerprint, r.err = "", nil
+	previous := r.config
+	if config == nil {
+		r.config = nil
+		r.revoke(previous)
+		return nil
+	}
+	applied := *config
+	applied.Tokens = append([]models.RemoteToken(nil), config.Tokens...)
+	r.config = &applied
+	r.revoke(previous)
+
+	if r.err = r.listen(); r.err != nil {
+		return r.err
+	}
+	r.server.logger.Info("Remote access enabled", "addr", r.listener.Addr().String(), "fingerprint", r.fingerprint)
+	go r.acceptLoop(r.listener)
+	return nil
+}
+
+// listen creates the TLS listener of the applied settings
+func (r *remoteListener) listen() error {
+	cert, err := r.certificate()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	r.fingerprint = hex.EncodeToString(sum[:])
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA: no certificate in %s", r.config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	listener, err := tls.Listen("tcp", r.config.Addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	r.listener = listener
+	return nil
+}
+
+// certificate loads the configured certificate, or the self-signed one
+// kept in the data directory, generated the first time
+func (r *remoteListener) certificate() (tls.Certificate, error) {
+	if r.config.CertFile != "" {
+		return tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	}
+	dir := r.server.store.GetDataDir()
+	certFile, keyFile := filepath.Join(dir, remoteCertFile), filepath.Join(dir, remoteKeyFile)
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return cert, nil
+	}
+	if err := generateCertificate(certFile, keyFile); err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate certificate: %w", err)
+	}
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// generateCertificate writes a self-signed certificate for the names of the
+// machine, valid ten years
+func generateCertificate(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"pfm"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+				template.IPAddresses = append(template.IPAddresses, ipnet.IP)
+			}
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// status returns the state of the listener, nil when disabled
+func (r *remoteListener) status() *models.RemoteStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.config == nil {
+		return nil
+	}
+	status := &models.RemoteStatus{Addr: r.config.Addr, Fingerprint: r.fingerprint}
+	if r.listener != nil {
+		status.Addr = r.listener.Addr().String()
+	}
+	if r.err != nil {
+		status.Error = r.err.Error()
+	}
+	return status
+}
+
+// revoke closes the sessions the applied settings no longer accept as they
+// are: unknown token, certificates of another CA, or another role. Called
+// with r.mu held.
+func (r *remoteListener) revoke(previous *models.RemoteAccess) {
+	for s := range r.sessions {
+		peer, err := identify(r.config, s.token, s.cert)
+		if err == nil && s.cert != nil && (previous == nil || r.config.ClientCAFile != previous.ClientCAFile) {
+			err = errors.New("client CA changed")
+		}
+		if err == nil && (peer.User != s.peer.User || peer.Role != s.peer.Role) {
+			err = fmt.Errorf("role changed to %s", peer.Role)
+		}
+		if err == nil {
+			continue
+		}
+		r.server.logger.Info("Remote client disconnected", "remote", s.peer.Remote, "user", s.peer.User, "reason", err)
+		s.conn.Close()
+		delete(r.sessions, s)
+	}
+}
+
+// endSession forgets a closed session
+func (r *remoteListener) endSession(s *remoteSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, s)
+}
+
+// close stops the listener
+func (r *remoteListener) close() {
+	r.apply(nil)
+}
+
+// acceptLoop serves the connections of a listener until it is closed
+func (r *remoteListener) acceptLoop(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.server.logger.Warn("Remote accept error", "error", err)
+			continue
+		}
+		go r.serve(conn)
+	}
+}
+
+// serve authenticates a remote client and answers its calls
+func (r *remoteListener) serve(conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReaderSize(conn, maxHelloSize)
+	conn.SetDeadline(time.Now().Add(remoteHandshakeTimeout))
+
+	session, err := r.authenticate(conn.(*tls.Conn), reader)
+	var welcome remoteWelcome
+	if err != nil {
+		welcome.Error = err.Error()
+	} else {
+		welcome.Peer = session.peer
+		defer r.endSession(session)
+	}
+	line, _ := json.Marshal(welcome)
+	if _, werr := conn.Write(append(line, '\n')); werr != nil || err != nil {
+		if err != nil {
+			r.server.logger.Warn("Remote client rejected", "remote", remote, "error", err)
+		}
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	peer := session.peer
+	r.server.logger.Info("Remote client connected", "remote", remote, "user", peer.User, "role", peer.Role)
+	r.server.serveAs(&bufferedConn{Conn: conn, reader: reader}, peer)
+}
+
+// authenticate reads the hello of a client and opens its session, from its
+// token or its certificate
+func (r *remoteListener) authenticate(conn *tls.Conn, reader *bufio.Reader) (*remoteSession, error) {
+	if err := conn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake: %w", err)
+	}
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read hello: %w", err)
+	}
+	var hello remoteHello
+	if err := json.Unmarshal(line, &hello); err != nil {
+		return nil, fmt.Errorf("invalid hello: %w", err)
+	}
+
+	session := &remoteSession{conn: conn, token: hello.Token}
+	if session.token == "" {
+		if chains := conn.ConnectionState().VerifiedChains; len(chains) > 0 {
+			session.cert = chains[0][0]
+		}
+	}
+
+	// Registered under the lock of the settings, so a change of them
+	// cannot miss the session
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	peer, err := identify(r.config, session.token, session.cert)
+	if err != nil {
+		return nil, err
+	}
+	peer.Remote = conn.RemoteAddr().String()
+	session.peer = peer
+	if r.sessions == nil {
+		r.sessions = make(map[*remoteSession]struct{})
+	}
+	r.sessions[session] = struct{}{}
+	return session, nil
+}
+
+// identify returns who a client is under the settings, from its token or
+// its verified certificate
+func identify(config *models.RemoteAccess, token string, cert *x509.Certificate) (*models.IPCPeer, error) {
+	switch {
+	case config == nil:
+		return nil, errors.New("remote access disabled")
+	case token != "":
+		for _, t := range config.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+				return &models.IPCPeer{UID: -1, User: "token:" + t.Name, Role: roleOrReadOnly(t.Role)}, nil
+			}
+		}
+		return nil, errors.New("invalid token")
+	case cert != nil && config.ClientCAFile != "":
+		return &models.IPCPeer{UID: -1, User: cert.Subject.CommonName, Role: roleOrReadOnly(config.ClientCertRole)}, nil
+	default:
+		return nil, errors.New("authentication required: token or client certificate")
+	}
+}
+
+// roleOrReadOnly returns a role of the settings, readonly when not set
+func roleOrReadOnly(role models.IPCRole) models.IPCRole {
+	if role == "" {
+		return models.IPCRoleReadOnly
+	}
+	return role
+}
+
+// bufferedConn reads what was buffered with the hello before the
+// connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// dialRemote connects to a remote service and authenticates
+func dialRemote(target *models.RemoteTarget, timeout time.Duration) (net.Conn, error) {
+	tlsConfig, err := remoteTLSConfig(target)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", target.Addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return hello(conn, target.Token)
+}
+
+// hello sends the hello line of a client with its token, and returns the
+// connection once the service welcomes it. The connection is closed on
+// failure.
+func hello(conn net.Conn, token string) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(remoteHandshakeTimeout))
+	line, _ := json.Marshal(remoteHello{Token: token})
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read welcome: %w", err)
+	}
+	var welcome remoteWelcome
+	if err := json.Unmarshal(line, &welcome); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("invalid welcome: %w", err)
+	}
+	if welcome.Error != "" {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrPermissionDenied, welcome.Error)
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// remoteTLSConfig returns the TLS settings of a connection to a remote
+// service
+func remoteTLSConfig(target *models.RemoteTarget) (*tls.Config, error) {
+	if err := target.Validate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{ServerName: target.ServerName, MinVersion: tls.VersionTLS12}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(target.Addr)
+	}
+	if target.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(target.CertFile, target.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if target.Fingerprint != "" {
+		// The certificate is pinned, its names and issuer do not matter
+		want := models.NormalizeFingerprint(target.Fingerprint)
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw) == 0 {
+				return errors.New("no server certificate")
+			}
+			sum := sha256.Sum256(raw[0])
+			if got := hex.EncodeToString(sum[:]); got != want {
+				return fmt.Errorf("server certificate fingerprint %s, expected %s", got, want)
+			}
+			return nil
+		}
+		return config, nil
+	}
+	if target.CAFile != "" {
+		pem, err := os.ReadFile(target.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("server CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("server CA: no certificate in %s", target.CAFile)
+		}
+	}
+	return config, nil
+}
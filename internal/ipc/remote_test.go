@@ -0,0 +1,235 @@
+package ipc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+// writeClientCA writes a CA and a client certificate it signs, and returns
+// the paths of the CA, the certificate and its key
+func writeClientCA(t *testing.T, dir, name string) (string, string, string) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pfm test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0644)
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return caFile, certFile, keyFile
+}
+
+func TestRemoteAccess(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(SocketEnv, filepath.Join(dir, "run", "pfm.sock"))
+	caFile, certFile, keyFile := writeClientCA(t, dir, "laptop-cert")
+
+	store, err := storage.NewWithPath(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := *store.GetConfig()
+	config.RemoteAccess = &models.RemoteAccess{
+		Enabled:        true,
+		Addr:           "127.0.0.1:0",
+		ClientCAFile:   caFile,
+		ClientCertRole: models.IPCRoleAdmin,
+		Tokens: []models.RemoteToken{
+			{Name: "laptop", Token: "0123456789abcdef", Role: models.IPCRoleAdmin},
+			{Name: "monitor", Token: "fedcba9876543210", Role: models.IPCRoleReadOnly},
+			{Name: "kiosk", Token: "00112233445566778899"},
+		},
+	}
+	if err := store.UpdateConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(engine.New(), store)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	status := server.remote.status()
+	if status == nil || status.Error != "" || len(status.Fingerprint) != 64 {
+		t.Fatalf("remote status = %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data", remoteCertFile)); err != nil {
+		t.Errorf("self-signed certificate not kept: %v", err)
+	}
+
+	connect := func(target models.RemoteTarget) (*Client, error) {
+		target.Addr = status.Addr
+		client, err := NewRemoteClient(target)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Connect(); err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { client.Close() })
+		return client, nil
+	}
+
+	// Admin token, the self-signed certificate is pinned
+	client, err := connect(models.RemoteTarget{Token: "0123456789abcdef", Fingerprint: strings.ToUpper(status.Fingerprint)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := client.WhoAmI()
+	if err != nil || peer.User != "token:laptop" || peer.Role != models.IPCRoleAdmin || peer.Remote == "" {
+		t.Errorf("WhoAmI() = %+v, %v", peer, err)
+	}
+	if got, err := client.GetStatus(); err != nil || got.Remote == nil || got.Remote.Fingerprint != status.Fingerprint {
+		t.Errorf("GetStatus() = %+v, %v", got, err)
+	}
+
+	// Remote admins cannot run commands, write files or grant access
+	current := *store.GetConfig()
+	current.DrainTimeout = 5
+	if err := client.UpdateConfig(&current); err != nil {
+		t.Errorf("remote UpdateConfig() error = %v", err)
+	}
+	for name, change := range map[string]func(c *models.AppConfig){
+		"command notifier": func(c *models.AppConfig) {
+			c.Notifiers = append(c.Notifiers, models.NotifierConfig{Name: "run", Type: models.NotifierCommand, Enabled: true, Command: "/bin/sh"})
+		},
+		"file log output": func(c *models.AppConfig) {
+			c.LogOutputs = append(c.LogOutputs, models.LogOutput{Name: "file", Type: models.LogOutputFile, Enabled: true, Path: "/etc/pfm.log"})
+		},
+		"ipcAccess":    func(c *models.AppConfig) { c.IPCAccess = &models.IPCAccess{DefaultRole: models.IPCRoleAdmin} },
+		"remoteAccess": func(c *models.AppConfig) { c.RemoteAccess = nil },
+	} {
+		updated := *store.GetConfig()
+		change(&updated)
+		if err := client.UpdateConfig(&updated); err == nil || !strings.Contains(err.Error(), ErrPermissionDenied.Error()) {
+			t.Errorf("remote UpdateConfig() of a %s error = %v", name, err)
+		}
+	}
+	if got := store.GetConfig(); len(got.Notifiers) != 0 || len(got.LogOutputs) != 0 || got.RemoteAccess == nil {
+		t.Errorf("settings changed by a remote client: %+v", got)
+	}
+
+	// Read-only token
+	client, err = connect(models.RemoteTarget{Token: "fedcba9876543210", Fingerprint: status.Fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.GetConfig(); err != nil || got.RemoteAccess.Tokens[0].Token != models.RedactedValue {
+		t.Errorf("read-only GetConfig() = %+v, %v", got, err)
+	}
+	if err := client.UpdateConfig(&config); err == nil || !strings.Contains(err.Error(), ErrPermissionDenied.Error()) {
+		t.Errorf("read-only UpdateConfig() error = %v", err)
+	}
+
+	// A token without a role is read-only
+	client, err = connect(models.RemoteTarget{Token: "00112233445566778899", Fingerprint: status.Fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer, err := client.WhoAmI(); err != nil || peer.Role != models.IPCRoleReadOnly {
+		t.Errorf("WhoAmI() with a token without role = %+v, %v", peer, err)
+	}
+
+	// Client certificate
+	client, err = connect(models.RemoteTarget{CertFile: certFile, KeyFile: keyFile, Fingerprint: status.Fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer, err := client.WhoAmI(); err != nil || peer.User != "laptop-cert" || peer.Role != models.IPCRoleAdmin {
+		t.Errorf("WhoAmI() with a certificate = %+v, %v", peer, err)
+	}
+
+	// Rejected clients
+	if _, err := connect(models.RemoteTarget{Token: "not-the-right-token", Fingerprint: status.Fingerprint}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Connect() with a wrong token error = %v", err)
+	}
+	if _, err := connect(models.RemoteTarget{Token: "0123456789abcdef", Fingerprint: strings.Repeat("0", 64)}); err == nil {
+		t.Error("Connect() accepts another certificate than the pinned one")
+	}
+	if _, err := connect(models.RemoteTarget{Token: "0123456789abcdef"}); err == nil {
+		t.Error("Connect() trusts a self-signed certificate")
+	}
+
+	// Changed settings close the sessions of revoked tokens and of changed
+	// roles, and keep the others
+	laptop, _ := connect(models.RemoteTarget{Token: "0123456789abcdef", Fingerprint: status.Fingerprint})
+	monitor, _ := connect(models.RemoteTarget{Token: "fedcba9876543210", Fingerprint: status.Fingerprint})
+	kiosk, _ := connect(models.RemoteTarget{Token: "00112233445566778899", Fingerprint: status.Fingerprint})
+	for _, c := range []*Client{laptop, monitor, kiosk} {
+		if c == nil {
+			t.Fatal("Connect() failed")
+		}
+	}
+	changed := *config.RemoteAccess
+	changed.Tokens = []models.RemoteToken{
+		{Name: "monitor", Token: "fedcba9876543210", Role: models.IPCRoleAdmin},
+		{Name: "kiosk", Token: "00112233445566778899"},
+	}
+	if err := server.remote.apply(&changed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := laptop.WhoAmI(); err == nil {
+		t.Error("session of a revoked token kept")
+	}
+	if _, err := monitor.WhoAmI(); err == nil {
+		t.Error("session whose role changed kept")
+	}
+	if _, err := kiosk.WhoAmI(); err != nil {
+		t.Errorf("unchanged session closed: %v", err)
+	}
+
+	// Disabling remote access closes the listener and the sessions
+	if err := server.remote.apply(nil); err != nil || server.remote.status() != nil {
+		t.Errorf("disable: %v, %+v", err, server.remote.status())
+	}
+	if _, err := connect(models.RemoteTarget{Token: "0123456789abcdef", Fingerprint: status.Fingerprint}); err == nil {
+		t.Error("Connect() succeeds with remote access disabled")
+	}
+	if _, err := kiosk.WhoAmI(); err == nil {
+		t.Error("session kept with remote access disabled")
+	}
+}
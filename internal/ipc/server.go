@@ -1,46 +1,99 @@
 package ipc
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"sync"
+	"time"
 
+	"pfm/internal/deps"
 	"pfm/internal/engine"
+	"pfm/internal/i18n"
+	"pfm/internal/logging"
+	"pfm/internal/logship"
 	"pfm/internal/models"
+	"pfm/internal/notify"
+	"pfm/internal/scheduler"
 	"pfm/internal/storage"
+	"pfm/internal/validation"
 )
 
 // Server handles IPC communications from GUI clients
 type Server struct {
-	mu       sync.Mutex
-	engine   *engine.Engine
-	store    *storage.Store
-	listener net.Listener
-	handler  *RPCHandler
-	logger   *log.Logger
-	running  bool
+	mu        sync.Mutex
+	engine    *engine.Engine
+	store     *storage.Store
+	scheduler *scheduler.Scheduler
+	deps      *deps.Manager
+	notifier  *notify.Notifier
+	shipper   *logship.Shipper
+	events    *eventHub
+	remote    *remoteListener
+	listener  net.Listener
+	handler   *RPCHandler
+	logger    *slog.Logger
+	running   bool
+	cancel    context.CancelFunc
+	unsub     func()
 }
 
 // NewServer creates a new IPC server
 func NewServer(e *engine.Engine, s *storage.Store) *Server {
-	return &Server{
-		engine: e,
-		store:  s,
-		logger: log.Default(),
+	server := &Server{
+		engine:    e,
+		store:     s,
+		scheduler: scheduler.New(e, s),
+		deps:      deps.New(e, s),
+		notifier:  notify.New(),
+		shipper:   logship.New(),
+		events:    newEventHub(),
+		logger:    logging.For("ipc"),
 	}
+	server.remote = &remoteListener{server: server}
+	return server
 }
 
 // SetLogger sets the logger for the server
-func (s *Server) SetLogger(logger *log.Logger) {
+func (s *Server) SetLogger(logger *slog.Logger) {
 	s.logger = logger
 }
 
+// SetScheduler sets the scheduler informed of manual starts and stops
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// SetDeps sets the dependency manager informed of manual starts and stops
+func (s *Server) SetDeps(m *deps.Manager) {
+	s.deps = m
+}
+
+// SetNotifier sets the notifier reconfigured when the settings change
+func (s *Server) SetNotifier(n *notify.Notifier) {
+	s.notifier = n
+}
+
+// SetLogShipper sets the log shipper reconfigured when the settings change
+func (s *Server) SetLogShipper(shipper *logship.Shipper) {
+	s.shipper = shipper
+}
+
+// Publish adds an engine event to the stream of subscribed clients
+func (s *Server) Publish(ev models.Event) {
+	s.events.publishEvent(ev)
+}
+
 // Start starts the IPC server
 func (s *Server) Start() error {
 	s.mu.Lock()
@@ -52,10 +105,9 @@ func (s *Server) Start() error {
 
 	socketPath := GetSocketPath()
 
-	// Ensure directory exists (for Unix socket)
+	// The socket directory must not let other users replace the socket
 	if runtime.GOOS != "windows" {
-		dir := filepath.Dir(socketPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := ensureSocketDir(filepath.Dir(socketPath)); err != nil {
 			return err
 		}
 	}
@@ -67,16 +119,35 @@ func (s *Server) Start() error {
 		return err
 	}
 
-	// Create and register RPC handler
+	// Each connection is served by a copy of the handler with the role of
+	// its user
 	s.handler = &RPCHandler{
-		engine: s.engine,
-		store:  s.store,
-		logger: s.logger,
+		engine:    s.engine,
+		store:     s.store,
+		scheduler: s.scheduler,
+		deps:      s.deps,
+		notifier:  s.notifier,
+		shipper:   s.shipper,
+		events:    s.events,
+		remote:    s.remote,
+		logger:    s.logger,
 	}
-	rpc.Register(s.handler)
+
+	// Stream new log entries, rule status changes and statistics to
+	// subscribed clients
+	s.unsub = s.engine.GetLogManager().Subscribe(s.events.publishLog)
+	s.store.SetRuleStatusCallback(s.events.publishStatus)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.events.run(ctx, s.engine)
 
 	s.running = true
-	s.logger.Printf("[IPC Server] Listening on %s", s.listener.Addr().String())
+	s.logger.Info("Listening", "addr", s.listener.Addr().String())
+
+	// A remote access failure leaves the local socket usable
+	if err := s.remote.apply(s.store.GetConfig().RemoteAccess); err != nil {
+		s.logger.Error("Remote access unavailable", "error", err)
+	}
 
 	// Accept connections
 	go s.acceptLoop()
@@ -95,13 +166,61 @@ func (s *Server) acceptLoop() {
 			if !running {
 				return
 			}
-			s.logger.Printf("[IPC Server] Accept error: %v", err)
+			s.logger.Warn("Accept error", "error", err)
 			continue
 		}
-		go jsonrpc.ServeConn(conn)
+		go s.serve(conn)
 	}
 }
 
+// serve answers the calls of a connection with the role of its user
+func (s *Server) serve(conn net.Conn) {
+	var peer *models.IPCPeer
+	var err error
+	if _, loopback := conn.(*net.TCPConn); loopback {
+		// Loopback TCP on Windows, clients prove who they are with the
+		// secret of the service
+		var served net.Conn
+		if peer, served, err = authenticateLoopback(conn, loopbackSecret()); err == nil {
+			conn = served
+		}
+	} else if peer, err = identifyPeer(conn); err == nil {
+		peer.Role = s.roleOf(peer)
+	}
+	if err != nil {
+		// Including Unix sockets where the kernel does not give the user
+		// of the client
+		s.logger.Warn("Cannot identify client, connection refused", "error", err)
+		conn.Close()
+		return
+	}
+	if peer.Role == models.IPCRoleNone {
+		// Still served so the client is told why its calls fail
+		s.logger.Warn("Client without access connected", "user", peer.User, "uid", peer.UID)
+	} else {
+		s.logger.Debug("Client connected", "user", peer.User, "uid", peer.UID, "role", peer.Role)
+	}
+	s.serveAs(conn, peer)
+}
+
+// serveAs answers the calls of a connection with the role of a client
+func (s *Server) serveAs(conn net.Conn, peer *models.IPCPeer) {
+	handler := *s.handler
+	handler.peer = peer
+	server := rpc.NewServer()
+	server.Register(&handler)
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// roleOf returns the role of a client, root and the user of the service
+// being admin
+func (s *Server) roleOf(peer *models.IPCPeer) models.IPCRole {
+	if peer.UID == 0 || peer.UID == os.Geteuid() {
+		return models.IPCRoleAdmin
+	}
+	return s.store.GetConfig().GetIPCAccess().Role(peer)
+}
+
 // Stop stops the IPC server
 func (s *Server) Stop() error {
 	s.mu.Lock()
@@ -115,29 +234,75 @@ func (s *Server) Stop() error {
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	s.remote.close()
+	s.cancel()
+	s.unsub()
+	s.store.SetRuleStatusCallback(nil)
+	s.events.close()
 
 	// Clean up platform-specific resources
 	cleanupListener(GetSocketPath())
 
-	s.logger.Printf("[IPC Server] Stopped")
+	s.logger.Info("Stopped")
 	return nil
 }
 
+// SocketEnv overrides the socket path, e.g. to run a service as a user
+const SocketEnv = "PFM_SOCKET"
+
 // GetSocketPath returns the platform-specific socket path
 func GetSocketPath() string {
-	if runtime.GOOS == "windows" {
+	if path := os.Getenv(SocketEnv); path != "" {
+		return path
+	}
+	switch runtime.GOOS {
+	case "windows":
 		return `\\.\pipe\pfm`
+	case "linux":
+		// Fixed path shared by the service (running as root) and the GUI
+		// and CLI (running as users)
+		return "/run/pfm/pfm.sock"
+	default:
+		return "/var/run/pfm/pfm.sock"
 	}
-	// Unix systems - use fixed /tmp path to ensure consistency
-	// between service (running as root) and GUI (running as user)
-	return "/tmp/pfm.sock"
 }
 
 // RPCHandler handles RPC method calls
 type RPCHandler struct {
-	engine *engine.Engine
-	store  *storage.Store
-	logger *log.Logger
+	engine    *engine.Engine
+	store     *storage.Store
+	scheduler *scheduler.Scheduler
+	deps      *deps.Manager
+	notifier  *notify.Notifier
+	shipper   *logship.Shipper
+	events    *eventHub
+	remote    *remoteListener
+	logger    *slog.Logger
+	peer      *models.IPCPeer // Client of the connection, nil = admin
+}
+
+// ErrPermissionDenied is returned for calls the role of the client does
+// not allow
+var ErrPermissionDenied = errors.New("permission denied")
+
+// role returns the role of the client
+func (h *RPCHandler) role() models.IPCRole {
+	if h.peer == nil {
+		return models.IPCRoleAdmin
+	}
+	return h.peer.Role
+}
+
+// allow checks that the client has a role
+func (h *RPCHandler) allow(required models.IPCRole) error {
+	if h.role().Allows(required) {
+		return nil
+	}
+	user := h.peer.User
+	if user == "" {
+		user = strconv.Itoa(h.peer.UID)
+	}
+	return fmt.Errorf("%w: user %s has the %s role, %s required", ErrPermissionDenied, user, h.peer.Role, required)
 }
 
 // Empty is used for RPC methods with no arguments
@@ -147,16 +312,31 @@ type Empty struct{}
 
 // GetRules returns all rules
 func (h *RPCHandler) GetRules(args *Empty, reply *[]*models.Rule) error {
-	*reply = h.store.GetRules()
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	rules := h.store.GetRules()
+	if !h.role().Allows(models.IPCRoleAdmin) {
+		for i, rule := range rules {
+			rules[i] = rule.Redacted()
+		}
+	}
+	*reply = rules
 	return nil
 }
 
 // GetRule returns a single rule by ID
 func (h *RPCHandler) GetRule(id *string, reply *models.Rule) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
 	rule, err := h.store.GetRule(*id)
 	if err != nil {
 		return err
 	}
+	if !h.role().Allows(models.IPCRoleAdmin) {
+		rule = rule.Redacted()
+	}
 	*reply = *rule
 	return nil
 }
@@ -168,6 +348,12 @@ type CreateRuleArgs struct {
 
 // CreateRule creates a new rule
 func (h *RPCHandler) CreateRule(args *CreateRuleArgs, reply *string) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	if err := h.checkRule(args.Rule).Err(); err != nil {
+		return err
+	}
 	if err := h.store.CreateRule(args.Rule); err != nil {
 		return err
 	}
@@ -177,24 +363,29 @@ func (h *RPCHandler) CreateRule(args *CreateRuleArgs, reply *string) error {
 
 // UpdateRule updates an existing rule
 func (h *RPCHandler) UpdateRule(rule *models.Rule, reply *bool) error {
-	// Stop if running
-	if h.engine.IsRunning(rule.ID) {
-		h.engine.StopRule(rule.ID)
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	if err := h.checkRule(rule).Err(); err != nil {
+		*reply = false
+		return err
 	}
-
 	if err := h.store.UpdateRule(rule); err != nil {
 		*reply = false
 		return err
 	}
 
-	// Restart if was enabled
-	if rule.Enabled {
-		if err := h.engine.StartRule(rule); err != nil {
-			h.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
+	// Apply to the running service, only what changed is rebuilt
+	if rule.Enabled && h.scheduler.ShouldRun(rule) {
+		if err := h.engine.UpdateRule(rule); err != nil {
+			h.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
 			*reply = false
 			return err
 		}
 		h.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+	} else if h.engine.IsActive(rule.ID) {
+		h.engine.StopRule(rule.ID)
+		h.store.UpdateRuleStatus(rule.ID, h.scheduler.IdleStatus(rule), "")
 	}
 
 	*reply = true
@@ -203,8 +394,11 @@ func (h *RPCHandler) UpdateRule(rule *models.Rule, reply *bool) error {
 
 // DeleteRule deletes a rule
 func (h *RPCHandler) DeleteRule(id *string, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	// Stop if running
-	if h.engine.IsRunning(*id) {
+	if h.engine.IsActive(*id) {
 		h.engine.StopRule(*id)
 	}
 
@@ -212,49 +406,75 @@ func (h *RPCHandler) DeleteRule(id *string, reply *bool) error {
 		*reply = false
 		return err
 	}
+	logging.SetRuleDebug(*id, false)
 	*reply = true
 	return nil
 }
 
 // StartRule starts a rule
 func (h *RPCHandler) StartRule(id *string, reply *bool) error {
-	h.logger.Printf("[IPC] StartRule called for id: %s", *id)
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	h.logger.Debug("StartRule called", "rule", *id)
 
 	rule, err := h.store.GetRule(*id)
 	if err != nil {
-		h.logger.Printf("[IPC] StartRule: GetRule failed: %v", err)
+		h.logger.Warn("StartRule: rule not found", "rule", *id, "error", err)
 		*reply = false
 		return err
 	}
 
-	h.logger.Printf("[IPC] StartRule: Starting rule '%s' on port %d -> %s:%d", rule.Name, rule.LocalPort, rule.TargetHost, rule.TargetPort)
+	if rule.IsExpired(time.Now()) {
+		*reply = false
+		return models.ErrRuleExpired
+	}
+
+	h.logger.Info("Starting rule", "rule", rule.ID, "name", rule.Name, "port", rule.LocalPort,
+		"targetHost", rule.TargetHost, "targetPort", rule.TargetPort)
+
+	// Starting a scheduled rule outside its window overrides the schedule
+	h.scheduler.Override(rule, true)
+	h.deps.Forget(*id)
 
 	if err := h.engine.StartRule(rule); err != nil {
-		h.logger.Printf("[IPC] StartRule: engine.StartRule failed: %v", err)
-		h.store.UpdateRuleStatus(*id, models.RuleStatusError, err.Error())
+		h.logger.Error("Failed to start rule", "rule", rule.ID, "error", err)
+		h.store.UpdateRuleStatus(*id, models.FailureStatus(err), err.Error())
 		*reply = false
 		return err
 	}
 
-	h.logger.Printf("[IPC] StartRule: Rule '%s' started successfully", rule.Name)
+	h.logger.Info("Rule started", "rule", rule.ID, "name", rule.Name)
 	// Update status and save enabled state for auto-restart
 	rule.Enabled = true
 	h.store.UpdateRule(rule)
 	h.store.UpdateRuleStatus(*id, models.RuleStatusRunning, "")
+	h.deps.OnStatusChange(*id, string(models.RuleStatusRunning))
 	*reply = true
 	return nil
 }
 
 // StopRule stops a rule
 func (h *RPCHandler) StopRule(id *string, reply *bool) error {
-	if err := h.engine.StopRule(*id); err != nil {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	rule, err := h.store.GetRule(*id)
+	scheduled := err == nil && rule.Schedule != nil
+	held := h.deps.Forget(*id) // not running, waiting for a dependency
+	// Not running, waiting for its traffic quota to start over
+	quota := err == nil && rule.Status == models.RuleStatusQuota
+
+	if err := h.engine.StopRule(*id); err != nil && !((scheduled || held || quota) && err == models.ErrServiceNotRunning) {
 		*reply = false
 		return err
 	}
 
-	// Update status and save disabled state
-	rule, err := h.store.GetRule(*id)
-	if err == nil {
+	if scheduled {
+		// The schedule stays in force, the stop lasts until its next transition
+		h.scheduler.Override(rule, false)
+	} else if rule != nil {
+		// Save disabled state
 		rule.Enabled = false
 		h.store.UpdateRule(rule)
 	}
@@ -263,11 +483,38 @@ func (h *RPCHandler) StopRule(id *string, reply *bool) error {
 	return nil
 }
 
+// ExtendRuleArgs holds arguments for ExtendRule
+type ExtendRuleArgs struct {
+	ID       string        `json:"id"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ExtendRule moves the expiry of a rule later, restarting it if it had expired
+func (h *RPCHandler) ExtendRule(args *ExtendRuleArgs, reply *models.Rule) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	rule, err := h.scheduler.Extend(args.ID, args.Duration)
+	if rule != nil {
+		*reply = *rule
+	}
+	return err
+}
+
 // ==================== Chain Operations ====================
 
 // GetChains returns all chains
 func (h *RPCHandler) GetChains(args *Empty, reply *[]*models.Chain) error {
-	*reply = h.store.GetChains()
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	chains := h.store.GetChains()
+	if !h.role().Allows(models.IPCRoleAdmin) {
+		for i, chain := range chains {
+			chains[i] = chain.Redacted()
+		}
+	}
+	*reply = chains
 	return nil
 }
 
@@ -278,6 +525,9 @@ type CreateChainArgs struct {
 
 // CreateChain creates a new chain
 func (h *RPCHandler) CreateChain(args *CreateChainArgs, reply *string) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	if err := h.store.CreateChain(args.Chain); err != nil {
 		return err
 	}
@@ -289,6 +539,9 @@ func (h *RPCHandler) CreateChain(args *CreateChainArgs, reply *string) error {
 
 // UpdateChain updates an existing chain
 func (h *RPCHandler) UpdateChain(chain *models.Chain, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	if err := h.store.UpdateChain(chain); err != nil {
 		*reply = false
 		return err
@@ -301,6 +554,9 @@ func (h *RPCHandler) UpdateChain(chain *models.Chain, reply *bool) error {
 
 // DeleteChain deletes a chain
 func (h *RPCHandler) DeleteChain(id *string, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	if err := h.store.DeleteChain(*id); err != nil {
 		*reply = false
 		return err
@@ -315,24 +571,99 @@ func (h *RPCHandler) DeleteChain(id *string, reply *bool) error {
 
 // GetConfig returns the application configuration
 func (h *RPCHandler) GetConfig(args *Empty, reply *models.AppConfig) error {
-	*reply = *h.store.GetConfig()
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	config := h.store.GetConfig()
+	if !h.role().Allows(models.IPCRoleAdmin) {
+		config = config.Redacted()
+	}
+	*reply = *config
 	return nil
 }
 
 // UpdateConfig updates the application configuration
 func (h *RPCHandler) UpdateConfig(config *models.AppConfig, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	if h.peer != nil && h.peer.Remote != "" {
+		if setting := localOnlyChange(h.store.GetConfig(), config); setting != "" {
+			*reply = false
+			return fmt.Errorf("%w: %s can only be changed on the machine of the service", ErrPermissionDenied, setting)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		*reply = false
+		return err
+	}
 	if err := h.store.UpdateConfig(config); err != nil {
 		*reply = false
 		return err
 	}
+	h.engine.SetDrainTimeout(config.GetDrainTimeout())
+	h.engine.SetLogRetention(config.GetLogMaxSize(), config.GetLogMaxAge())
+	h.notifier.Configure(config.Notifiers)
+	h.shipper.Configure(config.LogOutputs)
+	logging.SetLevel(config.LogLevel)
+	i18n.SetLocale(config.Locale)
+	if h.remote != nil {
+		if err := h.remote.apply(config.RemoteAccess); err != nil {
+			*reply = false
+			return fmt.Errorf("settings saved, remote access unavailable: %w", err)
+		}
+	}
 	*reply = true
 	return nil
 }
 
+// localOnlyChange returns the first setting changed that remote clients may
+// not change, empty when there is none: commands and files run and written
+// as the user of the service, and who may manage it
+func localOnlyChange(current, updated *models.AppConfig) string {
+	switch {
+	case !reflect.DeepEqual(commandNotifiers(current), commandNotifiers(updated)):
+		return "command notifiers"
+	case !reflect.DeepEqual(fileOutputPaths(current), fileOutputPaths(updated)):
+		return "file log outputs"
+	case !reflect.DeepEqual(current.IPCAccess, updated.IPCAccess):
+		return "ipcAccess"
+	case !reflect.DeepEqual(current.RemoteAccess, updated.RemoteAccess):
+		return "remoteAccess"
+	default:
+		return ""
+	}
+}
+
+// commandNotifiers returns the notifiers running a command
+func commandNotifiers(config *models.AppConfig) []models.NotifierConfig {
+	var result []models.NotifierConfig
+	for _, n := range config.Notifiers {
+		if n.Type == models.NotifierCommand {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// fileOutputPaths returns the paths of the file log outputs
+func fileOutputPaths(config *models.AppConfig) []string {
+	var result []string
+	for _, o := range config.LogOutputs {
+		if o.Type == models.LogOutputFile {
+			result = append(result, o.Path)
+		}
+	}
+	return result
+}
+
 // ==================== Status Operations ====================
 
 // GetStatus returns the service status
 func (h *RPCHandler) GetStatus(args *Empty, reply *models.ServiceStatus) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
 	rules := h.store.GetRules()
 	runningIDs := h.engine.GetRunningRuleIDs()
 
@@ -341,14 +672,195 @@ func (h *RPCHandler) GetStatus(args *Empty, reply *models.ServiceStatus) error {
 		RulesActive: len(runningIDs),
 		RulesTotal:  len(rules),
 		Version:     "1.0.15",
+		Draining:    h.engine.GetDrainStatus(),
+		Backoff:     h.engine.GetBackoffStatus(),
+		Schedules:   h.scheduler.Status(),
+		Expiry:      h.scheduler.ExpiryStatus(),
+		Quotas:      h.scheduler.QuotaStatus(),
+		Boot:        h.deps.Report(),
+		DebugRules:  logging.DebugRules(),
+	}
+	if h.remote != nil {
+		reply.Remote = h.remote.status()
+	}
+	return nil
+}
+
+// ==================== Stats Operations ====================
+
+// WhoAmI returns the user and role of the client, whatever its role
+func (h *RPCHandler) WhoAmI(args *Empty, reply *models.IPCPeer) error {
+	if h.peer == nil {
+		*reply = models.IPCPeer{UID: -1, Role: models.IPCRoleAdmin}
+		return nil
+	}
+	*reply = *h.peer
+	return nil
+}
+
+// GetRuleStatsArgs holds arguments for GetRuleStats
+type GetRuleStatsArgs struct {
+	RuleID string `json:"ruleId"`
+}
+
+// GetRuleStats returns statistics for a specific rule
+func (h *RPCHandler) GetRuleStats(args *GetRuleStatsArgs, reply *models.RuleStats) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	*reply = *h.engine.GetRuleStats(args.RuleID)
+	return nil
+}
+
+// GetAllRuleStats returns statistics for all rules
+func (h *RPCHandler) GetAllRuleStats(args *Empty, reply *map[string]*models.RuleStats) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	*reply = h.engine.GetAllRuleStats()
+	return nil
+}
+
+// GetStatsHistoryArgs holds arguments for GetStatsHistory
+type GetStatsHistoryArgs struct {
+	RuleID     string                   `json:"ruleId"`
+	Resolution models.HistoryResolution `json:"resolution"`
+}
+
+// GetStatsHistory returns the recent traffic samples of a rule
+func (h *RPCHandler) GetStatsHistory(args *GetStatsHistoryArgs, reply *models.StatsHistory) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	history, err := h.engine.GetStatsHistory(args.RuleID, args.Resolution)
+	if err != nil {
+		return err
+	}
+	*reply = *history
+	return nil
+}
+
+// GetAccounting returns the persistent traffic totals selected by a query
+func (h *RPCHandler) GetAccounting(args *models.AccountingQuery, reply *[]models.TrafficUsage) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	usage, err := h.engine.GetAccounting(*args)
+	if err != nil {
+		return err
+	}
+	*reply = usage
+	return nil
+}
+
+// ExportAccountingArgs holds arguments for ExportAccounting
+type ExportAccountingArgs struct {
+	Query  models.AccountingQuery `json:"query"`
+	Format string                 `json:"format"` // csv or json
+}
+
+// ExportAccounting returns the traffic totals selected by a query as CSV or JSON
+func (h *RPCHandler) ExportAccounting(args *ExportAccountingArgs, reply *[]byte) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	data, err := h.engine.ExportAccounting(args.Query, args.Format)
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+// ==================== Connection Operations ====================
+
+// CloseConnectionArgs holds arguments for CloseConnection
+type CloseConnectionArgs struct {
+	RuleID string `json:"ruleId"`
+	ConnID string `json:"connId"`
+}
+
+// CloseClientConnectionsArgs holds arguments for CloseClientConnections
+type CloseClientConnectionsArgs struct {
+	RuleID string `json:"ruleId"`
+	Client string `json:"client"`
+}
+
+// GetActiveConnections returns the connections currently handled by a rule
+func (h *RPCHandler) GetActiveConnections(ruleID *string, reply *[]models.ActiveConnection) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
 	}
+	conns, err := h.engine.GetActiveConnections(*ruleID)
+	if err != nil {
+		return err
+	}
+	*reply = conns
 	return nil
 }
 
+// CloseConnection forcibly closes an active connection
+func (h *RPCHandler) CloseConnection(args *CloseConnectionArgs, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	if err := h.engine.CloseConnection(args.RuleID, args.ConnID); err != nil {
+		return err
+	}
+	*reply = true
+	return nil
+}
+
+// CloseClientConnections forcibly closes all active connections from a client
+func (h *RPCHandler) CloseClientConnections(args *CloseClientConnectionsArgs, reply *int) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	n, err := h.engine.CloseClientConnections(args.RuleID, args.Client)
+	if err != nil {
+		return err
+	}
+	*reply = n
+	return nil
+}
+
+// ==================== Validation ====================
+
+// ValidateArgs holds arguments for Validate
+type ValidateArgs struct {
+	Rule *models.Rule `json:"rule,omitempty"` // nil validates all stored rules and chains
+}
+
+// Validate runs the pre-flight checks on a rule, or on the whole configuration
+func (h *RPCHandler) Validate(args *ValidateArgs, reply *models.ValidationReport) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	if args.Rule == nil {
+		*reply = *validation.CheckAll(h.store.GetRules(), h.store.GetChains(), h.validationOptions())
+		return nil
+	}
+	*reply = *h.checkRule(args.Rule)
+	return nil
+}
+
+// checkRule validates a rule against the stored rules and chains
+func (h *RPCHandler) checkRule(rule *models.Rule) *models.ValidationReport {
+	return validation.CheckRule(rule, h.store.GetRules(), h.store.GetChains(), h.validationOptions())
+}
+
+// validationOptions probes listen ports, except those of running rules
+func (h *RPCHandler) validationOptions() validation.Options {
+	return validation.Options{ProbePorts: true, OwnPort: h.engine.IsListening}
+}
+
 // ==================== Import/Export Operations ====================
 
 // ExportData exports all data as JSON
 func (h *RPCHandler) ExportData(args *Empty, reply *[]byte) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	data, err := h.store.ExportData()
 	if err != nil {
 		return err
@@ -365,16 +877,23 @@ type ImportDataArgs struct {
 
 // ImportData imports data from JSON
 func (h *RPCHandler) ImportData(args *ImportDataArgs, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	var data models.AppData
 	if err := json.Unmarshal(args.Data, &data); err != nil {
 		*reply = false
 		return err
 	}
+	if err := validation.CheckImport(h.store.GetAllData(), &data, args.Merge, h.validationOptions()).Err(); err != nil {
+		*reply = false
+		return err
+	}
 	// Stop all current rules if not merging
 	if !args.Merge {
 		currentRules := h.store.GetRules()
 		for _, rule := range currentRules {
-			if h.engine.IsRunning(rule.ID) {
+			if h.engine.IsActive(rule.ID) {
 				h.engine.StopRule(rule.ID)
 			}
 		}
@@ -390,12 +909,12 @@ func (h *RPCHandler) ImportData(args *ImportDataArgs, reply *bool) error {
 	// Start enabled rules
 	importedRules := h.store.GetRules()
 	for _, rule := range importedRules {
-		if rule.Enabled {
+		if rule.Enabled && h.scheduler.ShouldRun(rule) {
 			if h.engine.IsRunning(rule.ID) {
 				h.engine.StopRule(rule.ID)
 			}
 			if err := h.engine.StartRule(rule); err != nil {
-				h.store.UpdateRuleStatus(rule.ID, models.RuleStatusError, err.Error())
+				h.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
 			} else {
 				h.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
 			}
@@ -410,6 +929,9 @@ func (h *RPCHandler) ImportData(args *ImportDataArgs, reply *bool) error {
 
 // GetLogs returns recent log entries
 func (h *RPCHandler) GetLogs(args *GetLogsArgs, reply *[]*models.LogEntry) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
 	logs := h.engine.GetLogs(args.Count)
 	result := make([]*models.LogEntry, len(logs))
 	for i, l := range logs {
@@ -421,6 +943,8 @@ func (h *RPCHandler) GetLogs(args *GetLogsArgs, reply *[]*models.LogEntry) error
 			RuleName:  l.RuleName,
 			Message:   l.Message,
 			Details:   l.Details,
+			Key:       l.Key,
+			Params:    l.Params,
 		}
 	}
 	*reply = result
@@ -429,6 +953,9 @@ func (h *RPCHandler) GetLogs(args *GetLogsArgs, reply *[]*models.LogEntry) error
 
 // GetLogsSince returns log entries since a specific ID
 func (h *RPCHandler) GetLogsSince(args *GetLogsSinceArgs, reply *[]*models.LogEntry) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
 	logs := h.engine.GetLogsSince(args.SinceID)
 	result := make([]*models.LogEntry, len(logs))
 	for i, l := range logs {
@@ -440,6 +967,8 @@ func (h *RPCHandler) GetLogsSince(args *GetLogsSinceArgs, reply *[]*models.LogEn
 			RuleName:  l.RuleName,
 			Message:   l.Message,
 			Details:   l.Details,
+			Key:       l.Key,
+			Params:    l.Params,
 		}
 	}
 	*reply = result
@@ -448,6 +977,9 @@ func (h *RPCHandler) GetLogsSince(args *GetLogsSinceArgs, reply *[]*models.LogEn
 
 // GetLogsByRule returns log entries for a specific rule
 func (h *RPCHandler) GetLogsByRule(args *GetLogsByRuleArgs, reply *[]*models.LogEntry) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
 	logs := h.engine.GetLogsByRule(args.RuleID)
 	result := make([]*models.LogEntry, len(logs))
 	for i, l := range logs {
@@ -459,15 +991,105 @@ func (h *RPCHandler) GetLogsByRule(args *GetLogsByRuleArgs, reply *[]*models.Log
 			RuleName:  l.RuleName,
 			Message:   l.Message,
 			Details:   l.Details,
+			Key:       l.Key,
+			Params:    l.Params,
 		}
 	}
 	*reply = result
 	return nil
 }
 
-// ClearLogs clears all log entries
+// QueryLogs returns a page of the persisted log entries matching the query
+func (h *RPCHandler) QueryLogs(args *models.LogQuery, reply *models.LogPage) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	page, err := h.engine.QueryLogs(*args)
+	if err != nil {
+		return err
+	}
+	*reply = *page
+	return nil
+}
+
+// SetRuleDebugArgs holds arguments for SetRuleDebug
+type SetRuleDebugArgs struct {
+	RuleID  string `json:"ruleId"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SetRuleDebug turns debug logging of a rule on or off until the service
+// restarts
+func (h *RPCHandler) SetRuleDebug(args *SetRuleDebugArgs, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
+	rule, err := h.store.GetRule(args.RuleID)
+	if err != nil {
+		*reply = false
+		return err
+	}
+	logging.SetRuleDebug(rule.ID, args.Enabled)
+	h.logger.Info("Rule debug logging changed", "rule", rule.ID, "name", rule.Name, "enabled", args.Enabled)
+	*reply = true
+	return nil
+}
+
+// ClearLogs clears the log entries in memory
 func (h *RPCHandler) ClearLogs(args *Empty, reply *bool) error {
+	if err := h.allow(models.IPCRoleAdmin); err != nil {
+		return err
+	}
 	h.engine.ClearLogs()
 	*reply = true
 	return nil
 }
+
+// GetConnectionsArgs holds arguments for GetConnections
+type GetConnectionsArgs struct {
+	RuleID string `json:"ruleId"`
+	Limit  int    `json:"limit"`
+}
+
+// GetConnections returns the most recent access records of a rule
+func (h *RPCHandler) GetConnections(args *GetConnectionsArgs, reply *[]models.ConnectionRecord) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	records, err := h.engine.GetConnections(args.RuleID, args.Limit)
+	if err != nil {
+		return err
+	}
+	*reply = records
+	return nil
+}
+
+// ==================== Event Stream ====================
+
+// WaitEventsArgs holds arguments for WaitEvents
+type WaitEventsArgs struct {
+	Stream  string        `json:"stream"`  // Stream of the last batch, empty for a new subscription
+	AfterID int64         `json:"afterId"` // LastID of the last batch
+	Timeout time.Duration `json:"timeout"` // How long to wait for an event (default: 30s, at most 60s)
+}
+
+// WaitEvents returns the stream events after AfterID, waiting for one to be
+// published if there is none yet
+func (h *RPCHandler) WaitEvents(args *WaitEventsArgs, reply *models.EventBatch) error {
+	if err := h.allow(models.IPCRoleReadOnly); err != nil {
+		return err
+	}
+	timeout := args.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	} else if timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+
+	batch := h.events.wait(args.Stream, args.AfterID, timeout)
+	if batch == nil {
+		return errors.New("service is stopping")
+	}
+	*reply = *batch
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package logging provides the leveled, structured loggers of the service.
+// Entries below the configured level are dropped, except those of rules
+// whose debug logging was turned on at runtime.
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RuleKey is the attribute identifying the rule an entry is about
+const RuleKey = "rule"
+
+var (
+	level  = new(slog.LevelVar)
+	output = &switchWriter{}
+	root   = slog.New(&handler{inner: slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug})})
+
+	debugMu    sync.RWMutex
+	debugRules = make(map[string]bool)
+)
+
+// For returns the logger of a component, e.g. "engine"
+func For(component string) *slog.Logger {
+	return root.With("component", component)
+}
+
+// SetOutput sets where entries are written. By default they go to the
+// output of the standard logger.
+func SetOutput(w io.Writer) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	output.w = w
+}
+
+// SetLevel sets the lowest level written: debug, info, warn or error, an
+// unknown level being info
+func SetLevel(s string) {
+	switch strings.ToLower(s) {
+	case "debug":
+		level.Set(slog.LevelDebug)
+	case "warn":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		level.Set(slog.LevelInfo)
+	}
+}
+
+// SetRuleDebug turns debug logging of a rule on or off
+func SetRuleDebug(ruleID string, enabled bool) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	if enabled {
+		debugRules[ruleID] = true
+	} else {
+		delete(debugRules, ruleID)
+	}
+}
+
+// RuleDebug reports whether debug logging of a rule is on
+func RuleDebug(ruleID string) bool {
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	return debugRules[ruleID]
+}
+
+// DebugRules returns the rules whose debug logging is on
+func DebugRules() []string {
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	ids := make([]string, 0, len(debugRules))
+	for id := range debugRules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Enabled reports whether an entry of a rule (empty for none) at level l
+// is written
+func Enabled(l slog.Level, ruleID string) bool {
+	return l >= level.Level() || (ruleID != "" && RuleDebug(ruleID))
+}
+
+// anyRuleDebug reports whether debug logging of some rule is on
+func anyRuleDebug() bool {
+	debugMu.RLock()
+	defer debugMu.RUnlock()
+	return len(debugRules) > 0
+}
+
+// handler filters entries by the configured level and the rules in debug
+type handler struct {
+	inner slog.Handler
+	rule  string // Rule of the logger, set by With(RuleKey, id)
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	if l >= level.Level() {
+		return true
+	}
+	if h.rule != "" {
+		return RuleDebug(h.rule)
+	}
+	// The rule may be among the attributes, checked by Handle
+	return anyRuleDebug()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < level.Level() {
+		rule := h.rule
+		if rule == "" {
+			r.Attrs(func(a slog.Attr) bool {
+				if a.Key == RuleKey {
+					rule = a.Value.String()
+					return false
+				}
+				return true
+			})
+		}
+		if rule == "" || !RuleDebug(rule) {
+			return nil
+		}
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	rule := h.rule
+	for _, a := range attrs {
+		if a.Key == RuleKey {
+			rule = a.Value.String()
+		}
+	}
+	return &handler{inner: h.inner.WithAttrs(attrs), rule: rule}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), rule: h.rule}
+}
+
+// switchWriter writes to the output set by SetOutput, or the output of the
+// standard logger
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return log.Writer().Write(p)
+	}
+	return s.w.Write(p)
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// capture sends the entries to a buffer until the test ends
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		SetOutput(nil)
+		SetLevel("info")
+		for _, id := range DebugRules() {
+			SetRuleDebug(id, false)
+		}
+	})
+	return &buf
+}
+
+func TestLevel(t *testing.T) {
+	buf := capture(t)
+	logger := For("test")
+
+	SetLevel("warn")
+	logger.Info("hidden")
+	logger.Warn("shown", "rule", "r1")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown") ||
+		!strings.Contains(out, "component=test") || !strings.Contains(out, "rule=r1") {
+		t.Errorf("output at warn = %q", out)
+	}
+
+	buf.Reset()
+	SetLevel("debug")
+	logger.Debug("details")
+	if !strings.Contains(buf.String(), "msg=details") {
+		t.Errorf("output at debug = %q", buf.String())
+	}
+
+	// Unknown levels are info
+	buf.Reset()
+	SetLevel("trace")
+	logger.Debug("details")
+	logger.Info("info")
+	if out := buf.String(); strings.Contains(out, "details") || !strings.Contains(out, "msg=info") {
+		t.Errorf("output at unknown level = %q", out)
+	}
+}
+
+func TestRuleDebug(t *testing.T) {
+	buf := capture(t)
+	logger := For("test")
+
+	SetRuleDebug("r1", true)
+	logger.Debug("attr r1", "rule", "r1")
+	logger.Debug("attr r2", "rule", "r2")
+	logger.With("rule", "r1").Debug("with r1")
+	logger.With("rule", "r2").Debug("with r2")
+	logger.Debug("no rule")
+
+	out := buf.String()
+	for _, want := range []string{"attr r1", "with r1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q: %q", want, out)
+		}
+	}
+	for _, hidden := range []string{"attr r2", "with r2", "no rule"} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("output contains %q: %q", hidden, out)
+		}
+	}
+
+	if !Enabled(slog.LevelDebug, "r1") || Enabled(slog.LevelDebug, "r2") || Enabled(slog.LevelDebug, "") {
+		t.Error("Enabled() does not follow the rules in debug")
+	}
+	if ids := DebugRules(); len(ids) != 1 || ids[0] != "r1" {
+		t.Errorf("DebugRules() = %v", ids)
+	}
+
+	SetRuleDebug("r1", false)
+	buf.Reset()
+	logger.Debug("attr r1", "rule", "r1")
+	if buf.Len() != 0 || len(DebugRules()) != 0 {
+		t.Errorf("debug logging still on: %q, %v", buf.String(), DebugRules())
+	}
+}
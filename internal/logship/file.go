@@ -0,0 +1,92 @@
+package logship
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pfm/internal/models"
+)
+
+// fileWriter appends records as JSON lines, rotating the file to
+// <path>.1 when it reaches its maximum size
+type fileWriter struct {
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// fileRecord is the line written for a record
+type fileRecord struct {
+	Kind string `json:"kind"` // log or access
+	*models.LogEntry
+	Access *models.ConnectionRecord `json:"access,omitempty"`
+}
+
+func newFileWriter(c models.LogOutput) (*fileWriter, error) {
+	w := &fileWriter{path: c.Path, maxSize: c.GetMaxSize()}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *fileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *fileWriter) write(r *record) error {
+	line := fileRecord{Kind: "log", LogEntry: r.entry}
+	if r.access != nil {
+		line = fileRecord{Kind: "access", Access: r.access}
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if w.size > 0 && w.size+int64(len(data)) > w.maxSize {
+		w.file.Close()
+		w.file = nil
+		os.Remove(w.path + ".1")
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", w.path, err)
+		}
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	return err
+}
+
+func (w *fileWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,88 @@
+package logship
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+
+	"pfm/internal/models"
+)
+
+// journalSocket is the socket of the systemd journal native protocol
+var journalSocket = "/run/systemd/journal/socket"
+
+// journaldWriter sends records to the systemd journal with their fields
+// as journal fields (PFM_RULE_ID, PFM_CLIENT, ...)
+type journaldWriter struct {
+	tag  string
+	conn *net.UnixConn
+}
+
+func newJournaldWriter(c models.LogOutput) (*journaldWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldWriter{tag: c.GetTag(), conn: conn}, nil
+}
+
+func (w *journaldWriter) write(r *record) error {
+	fields := [][2]string{
+		{"PRIORITY", strconv.Itoa(severity(r.level()))},
+		{"SYSLOG_IDENTIFIER", w.tag},
+	}
+	if e := r.entry; e != nil {
+		msg := e.Message
+		if e.Details != "" {
+			msg += ": " + e.Details
+		}
+		fields = append(fields,
+			[2]string{"MESSAGE", msg},
+			[2]string{"PFM_KIND", "log"},
+			[2]string{"PFM_LOG_ID", strconv.FormatInt(e.ID, 10)},
+			[2]string{"PFM_RULE_ID", e.RuleID},
+			[2]string{"PFM_RULE_NAME", e.RuleName},
+			[2]string{"PFM_DETAILS", e.Details},
+		)
+	} else {
+		a := r.access
+		fields = append(fields,
+			[2]string{"MESSAGE", accessSummary(a)},
+			[2]string{"PFM_KIND", "access"},
+			[2]string{"PFM_RULE_ID", a.RuleID},
+			[2]string{"PFM_RULE_NAME", a.RuleName},
+			[2]string{"PFM_NETWORK", a.Network},
+			[2]string{"PFM_CLIENT", a.ClientAddr},
+			[2]string{"PFM_TARGET", a.Target},
+			[2]string{"PFM_BYTES_IN", strconv.FormatInt(a.BytesIn, 10)},
+			[2]string{"PFM_BYTES_OUT", strconv.FormatInt(a.BytesOut, 10)},
+			[2]string{"PFM_DURATION_MS", strconv.FormatInt(a.DurationMs, 10)},
+			[2]string{"PFM_ERROR", a.Error},
+		)
+	}
+
+	var b bytes.Buffer
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		b.WriteString(f[0])
+		if strings.Contains(f[1], "\n") {
+			// Values with newlines are sent with their length
+			b.WriteByte('\n')
+			binary.Write(&b, binary.LittleEndian, uint64(len(f[1])))
+		} else {
+			b.WriteByte('=')
+		}
+		b.WriteString(f[1])
+		b.WriteByte('\n')
+	}
+	_, err := w.conn.Write(b.Bytes())
+	return err
+}
+
+func (w *journaldWriter) close() error {
+	return w.conn.Close()
+}
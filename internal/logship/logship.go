@@ -0,0 +1,174 @@
+// Package logship ships log entries and connection records to syslog,
+// journald and JSON lines files
+package logship
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"pfm/internal/logging"
+	"pfm/internal/models"
+)
+
+// queueSize is the number of records an output holds while writing,
+// further records are dropped
+const queueSize = 1000
+
+// Shipper sends log entries and connection records to the configured
+// outputs. Each output writes in the background, in order.
+type Shipper struct {
+	mu      sync.Mutex
+	outputs []*output
+	logger  *slog.Logger
+	wg      sync.WaitGroup
+}
+
+// record is a log entry or a connection record
+type record struct {
+	entry  *models.LogEntry
+	access *models.ConnectionRecord
+}
+
+// level returns the level of a record, connection records being info, or
+// warn when they failed
+func (r *record) level() models.LogLevel {
+	if r.entry != nil {
+		return r.entry.Level
+	}
+	if r.access.Error != "" {
+		return models.LogLevelWarn
+	}
+	return models.LogLevelInfo
+}
+
+// writer delivers records to one destination
+type writer interface {
+	write(r *record) error
+	close() error
+}
+
+// output is a configured destination and its queue
+type output struct {
+	config  models.LogOutput
+	queue   chan *record
+	writer  writer
+	dropped int // records dropped since the queue was last free, guarded by Shipper.mu
+}
+
+// New creates a shipper without outputs
+func New() *Shipper {
+	return &Shipper{logger: logging.For("logship")}
+}
+
+// SetLogger sets the logger for the shipper
+func (s *Shipper) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Configure replaces the outputs. Records queued for the previous outputs
+// are still written.
+func (s *Shipper) Configure(configs []models.LogOutput) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.outputs {
+		close(o.queue)
+	}
+	s.outputs = nil
+	for _, c := range configs {
+		if !c.Enabled {
+			continue
+		}
+		w, err := newWriter(c)
+		if err != nil {
+			s.logger.Warn("Output disabled", "output", c.Name, "error", err)
+			continue
+		}
+		o := &output{config: c, queue: make(chan *record, queueSize), writer: w}
+		s.outputs = append(s.outputs, o)
+		s.wg.Add(1)
+		go s.run(o)
+	}
+}
+
+// newWriter creates the writer of an output
+func newWriter(c models.LogOutput) (writer, error) {
+	switch c.Type {
+	case models.LogOutputSyslog:
+		return newSyslogWriter(c)
+	case models.LogOutputJournald:
+		return newJournaldWriter(c)
+	default:
+		return newFileWriter(c)
+	}
+}
+
+// Log queues a log entry for the outputs whose level it reaches
+func (s *Shipper) Log(entry models.LogEntry) {
+	s.ship(&record{entry: &entry})
+}
+
+// Access queues a connection record for the outputs shipping access logs
+func (s *Shipper) Access(rec models.ConnectionRecord) {
+	s.ship(&record{access: &rec})
+}
+
+func (s *Shipper) ship(r *record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.outputs {
+		if r.access != nil && !o.config.Access {
+			continue
+		}
+		if r.level().Severity() < o.config.GetLevel().Severity() {
+			continue
+		}
+		select {
+		case o.queue <- r:
+			if o.dropped > 0 {
+				s.logger.Warn("Records dropped while busy", "output", o.config.Name, "dropped", o.dropped)
+				o.dropped = 0
+			}
+		default:
+			o.dropped++
+		}
+	}
+}
+
+// Close stops accepting records and waits up to timeout for the queued
+// ones to be written
+func (s *Shipper) Close(timeout time.Duration) {
+	s.Configure(nil)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		s.logger.Warn("Gave up waiting for queued records")
+	}
+}
+
+// run writes the records of an output until its queue is closed. Failures
+// are logged once until the output works again.
+func (s *Shipper) run(o *output) {
+	defer s.wg.Done()
+	defer o.writer.close()
+
+	failing := false
+	for r := range o.queue {
+		err := o.writer.write(r)
+		switch {
+		case err != nil && !failing:
+			s.logger.Warn("Failed to write", "output", o.config.Name, "error", err)
+			failing = true
+		case err == nil && failing:
+			s.logger.Info("Writing again", "output", o.config.Name)
+			failing = false
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package logship
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+var (
+	entry = models.LogEntry{
+		ID: 42, Timestamp: "2026-03-04T05:06:07Z", Level: models.LogLevelWarn,
+		RuleID: "r1", RuleName: `web "prod"`, Message: "连接失败", Details: "connection refused",
+	}
+	debug  = models.LogEntry{ID: 43, Level: models.LogLevelDebug, Message: "noise"}
+	access = models.ConnectionRecord{
+		RuleID: "r1", RuleName: "web", Network: "tcp", ClientAddr: "10.0.0.9:5555", Target: "10.0.0.1:80",
+		EndTime: time.Date(2026, 3, 4, 5, 6, 8, 0, time.UTC), BytesIn: 100, BytesOut: 2000, DurationMs: 15,
+	}
+)
+
+// ship sends the test records through one output and waits for them
+func ship(t *testing.T, c models.LogOutput) {
+	t.Helper()
+	c.Name, c.Enabled = "test", true
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	s := New()
+	s.Configure([]models.LogOutput{c})
+	s.Log(entry)
+	s.Log(debug)
+	s.Access(access)
+	s.Close(5 * time.Second)
+}
+
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) pfm \d+ (\S+) \[pfm@32473 (.*)\] (.*)$`)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+
+	ship(t, models.LogOutput{Type: models.LogOutputSyslog, Address: pc.LocalAddr().String(), Facility: "local0", Access: true})
+
+	var msgs []string
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(msgs) < 2 {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("received %d messages: %v", len(msgs), err)
+		}
+		msgs = append(msgs, string(buf[:n]))
+	}
+
+	m := rfc5424.FindStringSubmatch(msgs[0])
+	if m == nil {
+		t.Fatalf("not an RFC 5424 message: %q", msgs[0])
+	}
+	// local0 (16) * 8 + warning (4)
+	if m[1] != "132" || m[2] != "2026-03-04T05:06:07Z" || m[4] != "log" {
+		t.Errorf("header = %q", msgs[0])
+	}
+	if m[5] != `id="42" ruleId="r1" ruleName="web \"prod\""` || m[6] != "连接失败: connection refused" {
+		t.Errorf("structured data %q, message %q", m[5], m[6])
+	}
+
+	m = rfc5424.FindStringSubmatch(msgs[1])
+	if m == nil || m[1] != "134" || m[4] != "access" || !strings.Contains(m[5], `client="10.0.0.9:5555"`) || !strings.Contains(m[5], `bytesOut="2000"`) {
+		t.Errorf("access message = %q", msgs[1])
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Octet counting: "LEN SP MSG"
+		var msgs []string
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				break
+			}
+			msgs = append(msgs, string(msg))
+		}
+		received <- msgs
+	}()
+
+	// Access records are only shipped when asked for
+	ship(t, models.LogOutput{Type: models.LogOutputSyslog, Network: "tcp", Address: ln.Addr().String()})
+
+	select {
+	case msgs := <-received:
+		if len(msgs) != 1 || !rfc5424.MatchString(msgs[0]) {
+			t.Errorf("received %q", msgs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing received")
+	}
+}
+
+func TestJournald(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	dir, err := os.MkdirTemp("", "pfm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "journal")
+	pc, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+	defer func(old string) { journalSocket = old }(journalSocket)
+	journalSocket = socket
+
+	entry := entry
+	entry.Details = "line 1\nline 2"
+	c := models.LogOutput{Name: "test", Type: models.LogOutputJournald, Enabled: true, Access: true}
+	s := New()
+	s.Configure([]models.LogOutput{c})
+	s.Log(entry)
+	s.Access(access)
+	s.Close(5 * time.Second)
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	msg := string(buf[:n])
+	for _, want := range []string{"PRIORITY=4\n", "SYSLOG_IDENTIFIER=pfm\n", "PFM_RULE_ID=r1\n", "PFM_LOG_ID=42\n", "MESSAGE\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("journal message %q lacks %q", msg, want)
+		}
+	}
+	// Values with newlines are sent with their length
+	if !strings.Contains(msg, "PFM_DETAILS\n\x0d\x00\x00\x00\x00\x00\x00\x00line 1\nline 2\n") {
+		t.Errorf("multi-line field not length-prefixed: %q", msg)
+	}
+
+	n, _, err = pc.ReadFrom(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "PFM_CLIENT=10.0.0.9:5555\n") {
+		t.Errorf("access message = %q, %v", buf[:n], err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ship", "pfm.jsonl")
+	ship(t, models.LogOutput{Type: models.LogOutputFile, Path: path, Level: models.LogLevelDebug, Access: true})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("%d lines written, want 3: %s", len(lines), data)
+	}
+	var first, last struct {
+		Kind    string                   `json:"kind"`
+		ID      int64                    `json:"id"`
+		Message string                   `json:"message"`
+		Access  *models.ConnectionRecord `json:"access"`
+	}
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[2]), &last)
+	if first.Kind != "log" || first.ID != 42 || first.Message != entry.Message {
+		t.Errorf("log line = %s", lines[0])
+	}
+	if last.Kind != "access" || last.Access == nil || last.Access.Target != access.Target {
+		t.Errorf("access line = %s", lines[2])
+	}
+}
@@ -0,0 +1,165 @@
+package logship
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pfm/internal/models"
+)
+
+const (
+	// dialTimeout bounds connecting to a syslog server
+	dialTimeout = 5 * time.Second
+
+	// writeTimeout bounds writing one message
+	writeTimeout = 5 * time.Second
+
+	// sdID is the structured data ID of the rule and connection fields,
+	// using the enterprise number reserved for documentation
+	sdID = "pfm@32473"
+)
+
+// syslogWriter sends RFC 5424 messages, reconnecting after a failure.
+// TCP messages are framed by octet counting (RFC 6587).
+type syslogWriter struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogWriter(c models.LogOutput) (*syslogWriter, error) {
+	facility, _ := c.GetFacility()
+	network := c.GetNetwork()
+	if network == "unix" {
+		network = "unixgram"
+	}
+	hostname, _ := os.Hostname()
+	return &syslogWriter{
+		network:  network,
+		address:  c.Address,
+		facility: facility,
+		tag:      c.GetTag(),
+		hostname: hostname,
+	}, nil
+}
+
+func (w *syslogWriter) write(r *record) error {
+	msg := w.format(r)
+	if w.network == "tcp" {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	// Retry once on a new connection, the old one may have been closed by
+	// the server
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if w.conn, err = net.DialTimeout(w.network, w.address, dialTimeout); err != nil {
+				return err
+			}
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err = w.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *syslogWriter) close() error {
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
+
+// format returns the RFC 5424 message of a record:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (w *syslogWriter) format(r *record) string {
+	pri := w.facility*8 + severity(r.level())
+
+	var ts time.Time
+	var msgID, msg string
+	var params [][2]string
+	if e := r.entry; e != nil {
+		ts, _ = time.Parse(time.RFC3339, e.Timestamp)
+		msgID, msg = "log", e.Message
+		if e.Details != "" {
+			msg += ": " + e.Details
+		}
+		params = [][2]string{{"id", strconv.FormatInt(e.ID, 10)}, {"ruleId", e.RuleID}, {"ruleName", e.RuleName}}
+	} else {
+		a := r.access
+		ts, msgID, msg = a.EndTime, "access", accessSummary(a)
+		params = [][2]string{
+			{"ruleId", a.RuleID}, {"ruleName", a.RuleName}, {"network", a.Network},
+			{"client", a.ClientAddr}, {"target", a.Target},
+			{"bytesIn", strconv.FormatInt(a.BytesIn, 10)}, {"bytesOut", strconv.FormatInt(a.BytesOut, 10)},
+			{"durationMs", strconv.FormatInt(a.DurationMs, 10)}, {"error", a.Error},
+		}
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s [%s", pri, ts.Format(time.RFC3339Nano),
+		headerField(w.hostname), headerField(w.tag), os.Getpid(), msgID, sdID)
+	for _, p := range params {
+		if p[1] != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, p[0], sdEscaper.Replace(p[1]))
+		}
+	}
+	b.WriteString("] ")
+	b.WriteString(msg)
+	return b.String()
+}
+
+// sdEscaper escapes structured data parameter values
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// headerField returns a header field, or the nil value "-" when empty
+func headerField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// severity returns the syslog severity of a level
+func severity(l models.LogLevel) int {
+	switch l {
+	case models.LogLevelDebug:
+		return 7
+	case models.LogLevelWarn:
+		return 4
+	case models.LogLevelError:
+		return 3
+	}
+	return 6
+}
+
+// accessSummary returns a one line description of a connection record
+func accessSummary(a *models.ConnectionRecord) string {
+	text := fmt.Sprintf("%s %s -> %s, in %d bytes, out %d bytes, %dms",
+		a.Network, a.ClientAddr, a.Target, a.BytesIn, a.BytesOut, a.DurationMs)
+	if a.Error != "" {
+		text += ": " + a.Error
+	}
+	return text
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"strconv"
+)
+
+// IPCRole is what a client may do through the management API
+type IPCRole string
+
+const (
+	IPCRoleNone     IPCRole = "none"     // No access
+	IPCRoleReadOnly IPCRole = "readonly" // Status, rules, statistics and logs, without credentials
+	IPCRoleAdmin    IPCRole = "admin"    // Everything
+)
+
+// Allows reports whether the role grants the access of another role
+func (r IPCRole) Allows(required IPCRole) bool {
+	return r.rank() >= required.rank()
+}
+
+func (r IPCRole) rank() int {
+	switch r {
+	case IPCRoleReadOnly:
+		return 1
+	case IPCRoleAdmin:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// IPCAccess maps the local users connecting to the management socket to
+// roles. Users and groups are given by name or numeric ID. Root and the
+// user of the service are always admin.
+type IPCAccess struct {
+	AdminUsers     []string `json:"adminUsers,omitempty"`
+	AdminGroups    []string `json:"adminGroups,omitempty"`
+	ReadOnlyUsers  []string `json:"readOnlyUsers,omitempty"`
+	ReadOnlyGroups []string `json:"readOnlyGroups,omitempty"`
+	DefaultRole    IPCRole  `json:"defaultRole,omitempty"` // Role of the other users (default: none)
+}
+
+// DefaultIPCAccess is used when AppConfig.IPCAccess is not set: members of
+// the pfm group and of the groups allowed to administer the system are
+// admin, other users have no access
+func DefaultIPCAccess() *IPCAccess {
+	groups := []string{"pfm", "wheel", "sudo"}
+	if runtime.GOOS == "darwin" {
+		groups = []string{"pfm", "admin"}
+	}
+	return &IPCAccess{AdminGroups: groups, DefaultRole: IPCRoleNone}
+}
+
+// Validate validates the access policy
+func (a *IPCAccess) Validate() error {
+	if !validRole(a.DefaultRole) {
+		return &ValidationError{Field: "ipcAccess.defaultRole", Index: -1, Message: fmt.Sprintf("unknown role %q, must be none, readonly or admin", a.DefaultRole)}
+	}
+	return nil
+}
+
+// IPCPeer identifies a client of the management API
+type IPCPeer struct {
+	UID    int      `json:"uid"`              // -1 for remote clients
+	User   string   `json:"user,omitempty"`   // Name of the certificate or token of remote clients
+	Groups []string `json:"groups,omitempty"` // Names and IDs of the groups of the user
+	Role   IPCRole  `json:"role"`
+	Remote string   `json:"remote,omitempty"` // Address of remote clients
+}
+
+// Role returns the role of a peer, the highest of the users and groups it
+// matches
+func (a *IPCAccess) Role(peer *IPCPeer) IPCRole {
+	user := []string{strconv.Itoa(peer.UID)}
+	if peer.User != "" {
+		user = append(user, peer.User)
+	}
+	switch {
+	case matchAny(a.AdminUsers, user) || matchAny(a.AdminGroups, peer.Groups):
+		return IPCRoleAdmin
+	case matchAny(a.ReadOnlyUsers, user) || matchAny(a.ReadOnlyGroups, peer.Groups):
+		return IPCRoleReadOnly
+	case a.DefaultRole == "":
+		return IPCRoleNone
+	default:
+		return a.DefaultRole
+	}
+}
+
+// matchAny reports whether one of the names is listed
+func matchAny(list, names []string) bool {
+	for _, entry := range list {
+		for _, name := range names {
+			if entry == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactedValue replaces the credentials returned to read-only clients
+const RedactedValue = "********"
+
+// Redacted returns a copy of the rule without its credentials
+func (r *Rule) Redacted() *Rule {
+	clone := r.Clone()
+	if clone.Auth != nil && clone.Auth.Password != "" {
+		clone.Auth.Password = RedactedValue
+	}
+	return clone
+}
+
+// Redacted returns a copy of the chain without its credentials
+func (c *Chain) Redacted() *Chain {
+	clone := c.Clone()
+	for i := range clone.Hops {
+		if auth := clone.Hops[i].Auth; auth != nil && auth.Password != "" {
+			auth.Password = RedactedValue
+		}
+	}
+	return clone
+}
+
+// Redacted returns a copy of the configuration without its credentials:
+// passwords, HTTP headers of the notifiers, Slack webhook URLs and the
+// query of other webhook URLs, which often holds a token
+func (c *AppConfig) Redacted() *AppConfig {
+	clone := *c
+	if c.APIAuth != nil {
+		auth := *c.APIAuth
+		if auth.Password != "" {
+			auth.Password = RedactedValue
+		}
+		clone.APIAuth = &auth
+	}
+	clone.Notifiers = make([]NotifierConfig, len(c.Notifiers))
+	for i, n := range c.Notifiers {
+		if len(n.Headers) > 0 {
+			headers := make(map[string]string, len(n.Headers))
+			for name := range n.Headers {
+				headers[name] = RedactedValue
+			}
+			n.Headers = headers
+		}
+		if n.Type == NotifierSlack && n.URL != "" {
+			n.URL = RedactedValue
+		} else if u, err := url.Parse(n.URL); err == nil && (u.RawQuery != "" || u.User != nil) {
+			u.User = nil
+			u.RawQuery = ""
+			n.URL = u.String()
+		}
+		n.Args = append([]string(nil), n.Args...)
+		clone.Notifiers[i] = n
+	}
+	if c.IPCAccess != nil {
+		access := *c.IPCAccess
+		clone.IPCAccess = &access
+	}
+	if c.RemoteAccess != nil {
+		remote := *c.RemoteAccess
+		remote.Tokens = make([]RemoteToken, len(c.RemoteAccess.Tokens))
+		for i, t := range c.RemoteAccess.Tokens {
+			t.Token = RedactedValue
+			remote.Tokens[i] = t
+		}
+		clone.RemoteAccess = &remote
+	}
+	return &clone
+}
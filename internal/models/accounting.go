@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AccountingPeriod represents the period traffic totals are rolled up to
+type AccountingPeriod string
+
+const (
+	AccountingDay   AccountingPeriod = "day"
+	AccountingMonth AccountingPeriod = "month"
+)
+
+// Layout returns the time layout of the period keys, e.g. "2006-01-02"
+func (p AccountingPeriod) Layout() string {
+	if p == AccountingMonth {
+		return "2006-01"
+	}
+	return "2006-01-02"
+}
+
+// TrafficUsage represents the traffic of a rule during a day or a month,
+// in local time
+type TrafficUsage struct {
+	RuleID      string `json:"ruleId"`
+	RuleName    string `json:"ruleName"` // Last known name, kept for deleted rules
+	Period      string `json:"period"`   // "2006-01-02" or "2006-01"
+	BytesIn     int64  `json:"bytesIn"`
+	BytesOut    int64  `json:"bytesOut"`
+	Connections int64  `json:"connections"`
+}
+
+// AccountingQuery selects traffic totals. From and To are inclusive period
+// keys, empty for no bound, and an empty RuleID selects all rules.
+type AccountingQuery struct {
+	Period AccountingPeriod `json:"period"`
+	RuleID string           `json:"ruleId,omitempty"`
+	From   string           `json:"from,omitempty"`
+	To     string           `json:"to,omitempty"`
+}
+
+// Validate validates the query, an empty period being AccountingDay
+func (q *AccountingQuery) Validate() error {
+	switch q.Period {
+	case "":
+		q.Period = AccountingDay
+	case AccountingDay, AccountingMonth:
+	default:
+		return fmt.Errorf("unknown accounting period %q, want day or month", q.Period)
+	}
+	for _, bound := range []string{q.From, q.To} {
+		if bound == "" {
+			continue
+		}
+		if _, err := time.Parse(q.Period.Layout(), bound); err != nil {
+			return fmt.Errorf("invalid %s %q, want %s", q.Period, bound, q.Period.Layout())
+		}
+	}
+	return nil
+}
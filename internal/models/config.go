@@ -1,5 +1,12 @@
 package models
 
+import (
+	"fmt"
+	"time"
+
+	"pfm/internal/i18n"
+)
+
 // AppConfig represents the application configuration
 type AppConfig struct {
 	// General settings
@@ -7,6 +14,9 @@ type AppConfig struct {
 	AutoStart      bool   `json:"autoStart"`      // Start rules on app launch
 	StartMinimized bool   `json:"startMinimized"` // Start minimized to tray
 
+	// Language settings
+	Locale string `json:"locale,omitempty"` // Language of messages: en or zh, empty = language of the system
+
 	// Tray settings
 	TrayEnabled bool `json:"trayEnabled"` // Show system tray icon
 
@@ -27,6 +37,109 @@ type AppConfig struct {
 	// Metrics settings
 	MetricsEnabled bool   `json:"metricsEnabled"`
 	MetricsAddr    string `json:"metricsAddr"` // e.g., ":9000"
+
+	// Engine settings
+	DrainTimeout int `json:"drainTimeout,omitempty"` // Seconds to let TCP connections finish when a rule stops (UDP sessions end with the listener), 0 = default, <0 = close immediately
+	BootParallel int `json:"bootParallel,omitempty"` // Rules started at once at boot, 0 = default
+
+	// Log settings
+	LogMaxSize int `json:"logMaxSize,omitempty"` // MB of log files kept in the data directory, 0 = default
+	LogMaxAge  int `json:"logMaxAge,omitempty"`  // Days log files are kept, 0 = default
+
+	// Log shipping settings
+	LogOutputs []LogOutput `json:"logOutputs,omitempty"` // Destinations the rule and access logs are shipped to
+
+	// Notification settings
+	Notifiers []NotifierConfig `json:"notifiers,omitempty"` // Sinks receiving rule and service events
+
+	// Management socket settings
+	IPCAccess *IPCAccess `json:"ipcAccess,omitempty"` // Roles of the local users, nil = DefaultIPCAccess
+
+	// Remote management settings (service only)
+	RemoteAccess *RemoteAccess `json:"remoteAccess,omitempty"`
+}
+
+// Validate validates the settings that are checked before being applied
+func (c *AppConfig) Validate() error {
+	if c.Locale != "" && !i18n.Supported(c.Locale) {
+		return &ValidationError{Field: "locale", Index: -1, Message: fmt.Sprintf("unsupported locale %q", c.Locale)}
+	}
+	if c.LogLevel != "" && LogLevel(c.LogLevel).Severity() < 0 {
+		return &ValidationError{Field: "logLevel", Index: -1, Message: fmt.Sprintf("unknown log level %q", c.LogLevel)}
+	}
+	if c.IPCAccess != nil {
+		if err := c.IPCAccess.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.RemoteAccess != nil {
+		if err := c.RemoteAccess.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range c.Notifiers {
+		if err := c.Notifiers[i].Validate(); err != nil {
+			return fmt.Errorf("notifier %d: %w", i+1, err)
+		}
+	}
+	for i := range c.LogOutputs {
+		if err := c.LogOutputs[i].Validate(); err != nil {
+			return fmt.Errorf("log output %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// GetIPCAccess returns the roles of the local users of the management socket
+func (c *AppConfig) GetIPCAccess() *IPCAccess {
+	if c.IPCAccess == nil {
+		return DefaultIPCAccess()
+	}
+	return c.IPCAccess
+}
+
+// DefaultDrainTimeout is used when AppConfig.DrainTimeout is not set
+const DefaultDrainTimeout = 30 * time.Second
+
+// GetDrainTimeout returns how long stopped rules wait for their connections
+// to finish, 0 means connections are closed immediately
+func (c *AppConfig) GetDrainTimeout() time.Duration {
+	switch {
+	case c.DrainTimeout < 0:
+		return 0
+	case c.DrainTimeout == 0:
+		return DefaultDrainTimeout
+	default:
+		return time.Duration(c.DrainTimeout) * time.Second
+	}
+}
+
+// DefaultBootParallel is used when AppConfig.BootParallel is not set
+const DefaultBootParallel = 4
+
+// GetBootParallel returns how many rules are started at once at boot
+func (c *AppConfig) GetBootParallel() int {
+	if c.BootParallel <= 0 {
+		return DefaultBootParallel
+	}
+	return c.BootParallel
+}
+
+// GetLogMaxSize returns how many bytes of log files are kept
+func (c *AppConfig) GetLogMaxSize() int64 {
+	if c.LogMaxSize <= 0 {
+		return DefaultLogMaxSize << 20
+	}
+	return int64(c.LogMaxSize) << 20
+}
+
+// GetLogMaxAge returns how long log files are kept
+func (c *AppConfig) GetLogMaxAge() time.Duration {
+	days := c.LogMaxAge
+	if days <= 0 {
+		days = DefaultLogMaxAge
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 // DefaultAppConfig returns the default application configuration
@@ -72,17 +185,107 @@ type ServiceStatus struct {
 	RulesActive int    `json:"rulesActive"`
 	RulesTotal  int    `json:"rulesTotal"`
 	Version     string `json:"version"`
+
+	Draining  []DrainStatus    `json:"draining,omitempty"`  // Stopped TCP rules whose connections are finishing
+	Backoff   []BackoffStatus  `json:"backoff,omitempty"`   // Failed rules waiting to be restarted
+	Schedules []ScheduleStatus `json:"schedules,omitempty"` // Enabled rules with a schedule
+	Expiry    []ExpiryStatus   `json:"expiry,omitempty"`    // Rules with an expiry, and rules deleted at expiry
+	Quotas    []QuotaStatus    `json:"quotas,omitempty"`    // Rules with a traffic quota
+	Boot      *BootReport      `json:"boot,omitempty"`      // Rules started at boot
+
+	DebugRules []string `json:"debugRules,omitempty"` // Rules with debug logging turned on
+
+	Remote *RemoteStatus `json:"remote,omitempty"` // Remote access listener, when enabled
+}
+
+// DrainStatus represents the progress of a stopped TCP rule letting its connections finish
+type DrainStatus struct {
+	RuleID    string    `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	StartTime time.Time `json:"startTime"`
+	Deadline  time.Time `json:"deadline"`
+	Initial   int       `json:"initial"`   // Connections open when the drain started
+	Remaining int       `json:"remaining"` // Connections still open
+}
+
+// BackoffStatus represents a failed rule waiting to be restarted
+type BackoffStatus struct {
+	RuleID     string    `json:"ruleId"`
+	RuleName   string    `json:"ruleName"`
+	Attempt    int       `json:"attempt"`              // Retry about to be made, starting at 1
+	MaxRetries int       `json:"maxRetries,omitempty"` // 0: unlimited
+	NextRetry  time.Time `json:"nextRetry"`
+	LastError  string    `json:"lastError"`
+}
+
+// ScheduleStatus represents the schedule state of a rule
+type ScheduleStatus struct {
+	RuleID         string    `json:"ruleId"`
+	RuleName       string    `json:"ruleName"`
+	Active         bool      `json:"active"`                   // Inside a window
+	NextTransition time.Time `json:"nextTransition,omitempty"` // Zero if none within a week
+	Override       string    `json:"override,omitempty"`       // "running" or "stopped" when manually overridden
+}
+
+// ExpiryStatus represents the expiry of a temporary rule
+type ExpiryStatus struct {
+	RuleID    string    `json:"ruleId"`
+	RuleName  string    `json:"ruleName"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Expired   bool      `json:"expired"`
+	Deleted   bool      `json:"deleted,omitempty"` // Removed at expiry
+}
+
+// BootOutcome represents what happened to a rule at boot
+type BootOutcome string
+
+const (
+	BootStarted BootOutcome = "started"
+	BootFailed  BootOutcome = "failed"
+	BootSkipped BootOutcome = "skipped" // A dependency is not running
+)
+
+// BootReport summarises the startup of the enabled rules
+type BootReport struct {
+	StartTime time.Time    `json:"startTime"`
+	Duration  int64        `json:"durationMs"`
+	Started   int          `json:"started"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	Results   []BootResult `json:"results"` // In start order
+}
+
+// BootResult represents the startup of one rule
+type BootResult struct {
+	RuleID   string      `json:"ruleId"`
+	RuleName string      `json:"ruleName"`
+	Level    int         `json:"level"` // Dependency depth, rules of a level start in parallel
+	Outcome  BootOutcome `json:"outcome"`
+	Duration int64       `json:"durationMs"`
+	Error    string      `json:"error,omitempty"`
 }
 
 // RuleStats represents statistics for a rule
 type RuleStats struct {
-	RuleID       string `json:"ruleId"`
-	BytesIn      int64  `json:"bytesIn"`
-	BytesOut     int64  `json:"bytesOut"`
-	Connections  int64  `json:"connections"`
-	ActiveConns  int    `json:"activeConns"`
-	Errors       int64  `json:"errors"`
-	LastActivity string `json:"lastActivity,omitempty"`
+	RuleID       string       `json:"ruleId"`
+	BytesIn      int64        `json:"bytesIn"`
+	BytesOut     int64        `json:"bytesOut"`
+	Connections  int64        `json:"connections"`
+	ActiveConns  int          `json:"activeConns"`
+	Errors       int64        `json:"errors"`
+	RateIn       int64        `json:"rateIn"`  // Bytes per second over the last second
+	RateOut      int64        `json:"rateOut"` // Bytes per second over the last second
+	LastActivity string       `json:"lastActivity,omitempty"`
+	Mirror       *MirrorStats `json:"mirror,omitempty"`
+}
+
+// MirrorStats represents statistics of the traffic copied to a mirror target
+type MirrorStats struct {
+	Sessions int64 `json:"sessions"` // Mirrored connections (TCP) or client sessions (UDP)
+	Skipped  int64 `json:"skipped"`  // Connections or datagrams left out by sampling
+	BytesOut int64 `json:"bytesOut"` // Bytes written to the mirror target
+	Dropped  int64 `json:"dropped"`  // Chunks dropped because the mirror could not keep up
+	Errors   int64 `json:"errors"`   // Dial and write failures
 }
 
 // LogLevel represents the severity level of a log entry
@@ -102,6 +305,42 @@ type LogEntry struct {
 	Level     LogLevel `json:"level"`
 	RuleID    string   `json:"ruleId,omitempty"`
 	RuleName  string   `json:"ruleName,omitempty"`
-	Message   string   `json:"message"`
+	Message   string   `json:"message"` // In the locale of the service
 	Details   string   `json:"details,omitempty"`
+
+	Key    string            `json:"key,omitempty"`    // Message key, to render the message in another locale
+	Params map[string]string `json:"params,omitempty"` // Parameters of the message
+}
+
+// ConnectionRecord represents one connection handled by a rule (access log)
+type ConnectionRecord struct {
+	ID         string    `json:"id"`
+	RuleID     string    `json:"ruleId"`
+	RuleName   string    `json:"ruleName,omitempty"`
+	Network    string    `json:"network"`          // tcp or udp
+	ClientAddr string    `json:"clientAddr"`       // Address of the connecting client
+	Target     string    `json:"target,omitempty"` // Target chosen for the connection, empty if none was dialed
+	Route      string    `json:"route,omitempty"`  // Hop chain used to reach the target
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	DurationMs int64     `json:"durationMs"`
+	BytesIn    int64     `json:"bytesIn"`  // Bytes received from the client
+	BytesOut   int64     `json:"bytesOut"` // Bytes sent to the client
+	Error      string    `json:"error,omitempty"`
+}
+
+// ActiveConnection represents a connection currently handled by a rule
+type ActiveConnection struct {
+	ID         string    `json:"id"`
+	RuleID     string    `json:"ruleId"`
+	Network    string    `json:"network"`
+	ClientAddr string    `json:"clientAddr"`
+	Target     string    `json:"target,omitempty"`
+	Route      string    `json:"route,omitempty"`
+	StartTime  time.Time `json:"startTime"`
+	AgeSeconds int64     `json:"ageSeconds"`
+	BytesIn    int64     `json:"bytesIn"`
+	BytesOut   int64     `json:"bytesOut"`
+	RateIn     int64     `json:"rateIn"`  // Bytes per second received from the client
+	RateOut    int64     `json:"rateOut"` // Bytes per second sent to the client
 }
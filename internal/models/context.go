@@ -0,0 +1,97 @@
+package models
+
+import "fmt"
+
+// DefaultContext is the service on this machine, reached through the
+// default socket, used when no context is selected
+const DefaultContext = "local"
+
+// Context names a service the CLI manages: a service on this machine,
+// reached through its socket, or a remote one
+type Context struct {
+	Name   string        `json:"name"`
+	Socket string        `json:"socket,omitempty"` // Socket path of a local service, empty = default
+	Remote *RemoteTarget `json:"remote,omitempty"` // Remote service, instead of the socket
+}
+
+// Validate validates the context
+func (c *Context) Validate() error {
+	if c.Name == "" {
+		return &ValidationError{Field: "name", Index: -1, Message: "is required"}
+	}
+	if c.Remote != nil {
+		if c.Socket != "" {
+			return &ValidationError{Field: "socket", Index: -1, Message: "a context is either local or remote"}
+		}
+		if err := c.Remote.Validate(); err != nil {
+			return fmt.Errorf("context %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Endpoint returns where the service of the context is reached, empty for
+// the default socket
+func (c *Context) Endpoint() string {
+	if c.Remote != nil {
+		return c.Remote.Addr
+	}
+	return c.Socket
+}
+
+// ContextConfig holds the contexts of the CLI and the one in use
+type ContextConfig struct {
+	Current  string    `json:"current,omitempty"` // Empty = DefaultContext
+	Contexts []Context `json:"contexts"`
+}
+
+// Get returns a context by name. DefaultContext exists even when it is not
+// defined.
+func (c *ContextConfig) Get(name string) (*Context, error) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], nil
+		}
+	}
+	if name == DefaultContext {
+		return &Context{Name: DefaultContext}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrContextNotFound, name)
+}
+
+// All returns the defined contexts, with DefaultContext first when it is
+// not defined
+func (c *ContextConfig) All() []Context {
+	for _, ctx := range c.Contexts {
+		if ctx.Name == DefaultContext {
+			return c.Contexts
+		}
+	}
+	return append([]Context{{Name: DefaultContext}}, c.Contexts...)
+}
+
+// Set adds a context or replaces the one with the same name
+func (c *ContextConfig) Set(ctx Context) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == ctx.Name {
+			c.Contexts[i] = ctx
+			return
+		}
+	}
+	c.Contexts = append(c.Contexts, ctx)
+}
+
+// Delete removes a context, and reports whether it existed. The current
+// context falls back to DefaultContext when it is removed.
+func (c *ContextConfig) Delete(name string) bool {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.Current == name {
+				c.Current = ""
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContextConfig(t *testing.T) {
+	var config ContextConfig
+
+	// The local context exists without being defined
+	if ctx, err := config.Get(DefaultContext); err != nil || ctx.Endpoint() != "" {
+		t.Errorf("Get(local) = %+v, %v", ctx, err)
+	}
+	if _, err := config.Get("box"); !errors.Is(err, ErrContextNotFound) {
+		t.Errorf("Get(box) error = %v", err)
+	}
+
+	box := Context{Name: "box", Remote: &RemoteTarget{Addr: "box:19850", Token: "0123456789abcdef"}}
+	config.Set(box)
+	config.Set(Context{Name: "dev", Socket: "/tmp/dev.sock"})
+	config.Current = "box"
+	if all := config.All(); len(all) != 3 || all[0].Name != DefaultContext || all[1].Endpoint() != "box:19850" {
+		t.Errorf("All() = %+v", all)
+	}
+
+	box.Remote = &RemoteTarget{Addr: "box:19851", Token: "0123456789abcdef"}
+	config.Set(box)
+	if ctx, _ := config.Get("box"); ctx.Endpoint() != "box:19851" || len(config.Contexts) != 2 {
+		t.Errorf("Set() did not replace the context: %+v", config.Contexts)
+	}
+
+	if !config.Delete("box") || config.Current != "" || config.Delete("box") {
+		t.Errorf("Delete() left %+v, current %q", config.Contexts, config.Current)
+	}
+}
+
+func TestContextValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     Context
+		wantErr bool
+	}{
+		{"local", Context{Name: "local"}, false},
+		{"socket", Context{Name: "dev", Socket: "/tmp/dev.sock"}, false},
+		{"remote", Context{Name: "box", Remote: &RemoteTarget{Addr: "box:19850", Token: "t"}}, false},
+		{"no name", Context{}, true},
+		{"socket and remote", Context{Name: "box", Socket: "/tmp/dev.sock", Remote: &RemoteTarget{Addr: "box:19850", Token: "t"}}, true},
+		{"no credentials", Context{Name: "box", Remote: &RemoteTarget{Addr: "box:19850"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ctx.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
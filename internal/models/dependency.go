@@ -0,0 +1,107 @@
+package models
+
+// DependencyLevels orders rules by their dependencies. Rules in a level only
+// depend on rules in earlier levels, dependencies on rules outside the given
+// set are ignored. Rules in or behind a dependency cycle cannot be ordered
+// and are returned separately.
+func DependencyLevels(rules []*Rule) (levels [][]*Rule, blocked []*Rule) {
+	inSet := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		inSet[r.ID] = true
+	}
+
+	pending := make(map[string]int, len(rules)) // unresolved dependencies
+	for _, r := range rules {
+		seen := make(map[string]bool)
+		for _, dep := range r.DependsOn {
+			if inSet[dep] && !seen[dep] {
+				seen[dep] = true
+				pending[r.ID]++
+			}
+		}
+	}
+
+	remaining := rules
+	for len(remaining) > 0 {
+		var level, rest []*Rule
+		for _, r := range remaining {
+			if pending[r.ID] == 0 {
+				level = append(level, r)
+			} else {
+				rest = append(rest, r)
+			}
+		}
+		if len(level) == 0 {
+			return levels, rest
+		}
+		for _, r := range level {
+			for _, other := range rest {
+				if other.DependsOnRule(r.ID) {
+					pending[other.ID]--
+				}
+			}
+		}
+		levels = append(levels, level)
+		remaining = rest
+	}
+	return levels, nil
+}
+
+// DependsOnRule reports whether the rule directly depends on the rule id
+func (r *Rule) DependsOnRule(id string) bool {
+	for _, dep := range r.DependsOn {
+		if dep == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependents returns the rules directly depending on the rule id
+func Dependents(rules []*Rule, id string) []*Rule {
+	var result []*Rule
+	for _, r := range rules {
+		if r.DependsOnRule(id) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// DependencyCycle returns the rule IDs of a dependency cycle starting and
+// ending at the rule id, or nil if there is none
+func DependencyCycle(rules []*Rule, id string) []string {
+	byID := make(map[string]*Rule, len(rules))
+	for _, r := range rules {
+		byID[r.ID] = r
+	}
+
+	visited := make(map[string]bool)
+	var path []string
+	var visit func(cur string) bool
+	visit = func(cur string) bool {
+		r := byID[cur]
+		if r == nil {
+			return false
+		}
+		path = append(path, cur)
+		for _, dep := range r.DependsOn {
+			if dep == id {
+				path = append(path, dep)
+				return true
+			}
+			if !visited[dep] {
+				visited[dep] = true
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(id) {
+		return path
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyLevels(t *testing.T) {
+	rule := func(id string, deps ...string) *Rule {
+		return &Rule{ID: id, DependsOn: deps}
+	}
+	ids := func(rules []*Rule) []string {
+		var result []string
+		for _, r := range rules {
+			result = append(result, r.ID)
+		}
+		return result
+	}
+
+	rules := []*Rule{
+		rule("web", "tunnel", "db"),
+		rule("tunnel"),
+		rule("db", "tunnel", "tunnel"),
+		rule("other", "missing"),
+		rule("x", "y"),
+		rule("y", "x"),
+		rule("z", "x"),
+	}
+	levels, blocked := DependencyLevels(rules)
+
+	var got [][]string
+	for _, level := range levels {
+		got = append(got, ids(level))
+	}
+	want := [][]string{{"tunnel", "other"}, {"db"}, {"web"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("levels = %v, want %v", got, want)
+	}
+	if b := ids(blocked); !reflect.DeepEqual(b, []string{"x", "y", "z"}) {
+		t.Errorf("blocked = %v", b)
+	}
+
+	if c := DependencyCycle(rules, "x"); !reflect.DeepEqual(c, []string{"x", "y", "x"}) {
+		t.Errorf("DependencyCycle(x) = %v", c)
+	}
+	if c := DependencyCycle(rules, "z"); c != nil {
+		t.Errorf("DependencyCycle(z) = %v, want none", c)
+	}
+	if d := ids(Dependents(rules, "tunnel")); !reflect.DeepEqual(d, []string{"web", "db"}) {
+		t.Errorf("Dependents(tunnel) = %v", d)
+	}
+}
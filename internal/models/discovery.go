@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DiscoveryType represents where discovered targets come from
+type DiscoveryType string
+
+const (
+	DiscoverySRV    DiscoveryType = "srv"    // DNS SRV record, e.g. "_http._tcp.web.internal"
+	DiscoveryDNS    DiscoveryType = "dns"    // All A/AAAA records of a name
+	DiscoveryDocker DiscoveryType = "docker" // Running containers by name or label
+)
+
+// DefaultDockerHost is the Docker Engine API used when neither the rule nor
+// DOCKER_HOST names one
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// DefaultDiscoveryInterval is how often targets are resolved again
+const DefaultDiscoveryInterval = 30 * time.Second
+
+// TargetDiscovery represents targets resolved from DNS or Docker instead of
+// listed in the rule. SRV records of the lowest priority are used first, the
+// others only when none of those is reachable, and weights balance the load.
+// Docker targets are the container addresses, refreshed on container events.
+type TargetDiscovery struct {
+	Type       DiscoveryType `json:"type"`
+	Name       string        `json:"name,omitempty"`       // Record name, or container name for docker
+	Port       int           `json:"port,omitempty"`       // Target port for dns, container port for docker (SRV records carry their own)
+	Interval   int           `json:"interval,omitempty"`   // Seconds between refreshes (default: 30)
+	Resolver   string        `json:"resolver,omitempty"`   // DNS server "host:port" (default: system resolver)
+	Label      string        `json:"label,omitempty"`      // Container label "key" or "key=value" for docker
+	Network    string        `json:"network,omitempty"`    // Container network for docker (default: first one)
+	DockerHost string        `json:"dockerHost,omitempty"` // Docker Engine API, "unix://path" or "tcp://host:port" (default: DOCKER_HOST)
+}
+
+// Validate validates the discovery settings
+func (d *TargetDiscovery) Validate() error {
+	switch d.Type {
+	case DiscoverySRV, DiscoveryDNS:
+		if strings.TrimSuffix(d.Name, ".") == "" {
+			return &ValidationError{Field: "discovery.name", Index: -1, Message: "is required"}
+		}
+	case DiscoveryDocker:
+		if d.Name == "" && d.Label == "" {
+			return &ValidationError{Field: "discovery", Index: -1, Message: "a container name or label is required"}
+		}
+		if d.DockerHost != "" {
+			if _, _, err := ParseDockerHost(d.DockerHost); err != nil {
+				return &ValidationError{Field: "discovery.dockerHost", Index: -1, Message: err.Error()}
+			}
+		}
+	default:
+		return &ValidationError{Field: "discovery.type", Index: -1, Message: "must be srv, dns or docker"}
+	}
+	if d.Type != DiscoverySRV && (d.Port <= 0 || d.Port > 65535) {
+		return &ValidationError{Field: "discovery.port", Index: -1, Message: "must be between 1 and 65535"}
+	}
+	if d.Interval < 0 {
+		return &ValidationError{Field: "discovery.interval", Index: -1, Message: "cannot be negative"}
+	}
+	if d.Resolver != "" {
+		if _, _, err := net.SplitHostPort(d.Resolver); err != nil {
+			return &ValidationError{Field: "discovery.resolver", Index: -1, Message: "must be host:port"}
+		}
+	}
+	return nil
+}
+
+// GetInterval returns the refresh interval
+func (d *TargetDiscovery) GetInterval() time.Duration {
+	if d.Interval <= 0 {
+		return DefaultDiscoveryInterval
+	}
+	return time.Duration(d.Interval) * time.Second
+}
+
+// GetDockerHost returns the Docker Engine API address
+func (d *TargetDiscovery) GetDockerHost() string {
+	if d.DockerHost != "" {
+		return d.DockerHost
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return DefaultDockerHost
+}
+
+// MatchContainer reports whether a container with the given names (as listed
+// by Docker, with a leading "/") and labels is selected
+func (d *TargetDiscovery) MatchContainer(names []string, labels map[string]string) bool {
+	if d.Name != "" {
+		found := false
+		for _, name := range names {
+			if strings.TrimPrefix(name, "/") == d.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if d.Label != "" {
+		key, value, hasValue := strings.Cut(d.Label, "=")
+		v, ok := labels[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseDockerHost splits a Docker host into the network and address to dial
+func ParseDockerHost(host string) (network, addr string, err error) {
+	scheme, addr, ok := strings.Cut(host, "://")
+	if !ok || addr == "" {
+		return "", "", fmt.Errorf("must be unix://path or tcp://host:port")
+	}
+	switch scheme {
+	case "unix":
+		return "unix", addr, nil
+	case "tcp", "http":
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return "", "", fmt.Errorf("must be tcp://host:port")
+		}
+		return "tcp", addr, nil
+	}
+	return "", "", fmt.Errorf("unsupported scheme %q", scheme)
+}
+
+// Source describes where the targets are discovered, for messages
+func (d *TargetDiscovery) Source() string {
+	switch {
+	case d.Type != DiscoveryDocker:
+		return fmt.Sprintf("%s %s", d.Type, d.Name)
+	case d.Name != "" && d.Label != "":
+		return fmt.Sprintf("docker container %s with label %s", d.Name, d.Label)
+	case d.Name != "":
+		return fmt.Sprintf("docker container %s", d.Name)
+	}
+	return fmt.Sprintf("docker containers with label %s", d.Label)
+}
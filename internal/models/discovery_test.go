@@ -0,0 +1,56 @@
+package models
+
+import "testing"
+
+func TestTargetDiscovery_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		discovery TargetDiscovery
+		wantErr   bool
+	}{
+		{"srv", TargetDiscovery{Type: DiscoverySRV, Name: "_http._tcp.web.internal"}, false},
+		{"srv without name", TargetDiscovery{Type: DiscoverySRV, Name: "."}, true},
+		{"dns", TargetDiscovery{Type: DiscoveryDNS, Name: "web.internal", Port: 80, Resolver: "10.0.0.53:53"}, false},
+		{"dns without port", TargetDiscovery{Type: DiscoveryDNS, Name: "web.internal"}, true},
+		{"dns bad resolver", TargetDiscovery{Type: DiscoveryDNS, Name: "web.internal", Port: 80, Resolver: "10.0.0.53"}, true},
+		{"docker by label", TargetDiscovery{Type: DiscoveryDocker, Label: "app=web", Port: 80}, false},
+		{"docker tcp host", TargetDiscovery{Type: DiscoveryDocker, Name: "web", Port: 80, DockerHost: "tcp://10.0.0.1:2375"}, false},
+		{"docker without selector", TargetDiscovery{Type: DiscoveryDocker, Port: 80}, true},
+		{"docker bad host", TargetDiscovery{Type: DiscoveryDocker, Name: "web", Port: 80, DockerHost: "npipe:////./pipe/docker"}, true},
+		{"negative interval", TargetDiscovery{Type: DiscoverySRV, Name: "_http._tcp.web", Interval: -1}, true},
+		{"unknown type", TargetDiscovery{Type: "consul", Name: "web"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.discovery.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTargetDiscovery_MatchContainer(t *testing.T) {
+	labels := map[string]string{"com.docker.compose.service": "web"}
+	tests := []struct {
+		name, cname, label string
+		want               bool
+	}{
+		{"name", "app-web-1", "", true},
+		{"name is not a prefix", "app-web", "", false},
+		{"label", "", "com.docker.compose.service=web", true},
+		{"label key", "", "com.docker.compose.service", true},
+		{"label value", "", "com.docker.compose.service=db", false},
+		{"name and label", "app-web-1", "com.docker.compose.service=web", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &TargetDiscovery{Type: DiscoveryDocker, Name: tt.cname, Label: tt.label}
+			if got := d.MatchContainer([]string{"/app-web-1"}, labels); got != tt.want {
+				t.Errorf("MatchContainer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Common errors
@@ -13,6 +14,9 @@ var (
 	ErrNoTargets       = errors.New("at least one target is required")
 	ErrRuleNotFound    = errors.New("rule not found")
 	ErrRuleExists      = errors.New("rule already exists")
+	ErrChainRequired   = errors.New("a proxy chain is required for this rule type")
+	ErrRuleExpired     = errors.New("rule has expired, extend it first")
+	ErrQuotaExceeded   = errors.New("traffic quota exceeded")
 
 	// Chain errors
 	ErrChainNameEmpty = errors.New("chain name cannot be empty")
@@ -26,6 +30,12 @@ var (
 	ErrServiceRunning    = errors.New("service is already running")
 	ErrServiceStart      = errors.New("failed to start service")
 	ErrServiceStop       = errors.New("failed to stop service")
+
+	// Connection errors
+	ErrConnectionNotFound = errors.New("connection not found")
+
+	// Context errors
+	ErrContextNotFound = errors.New("context not found")
 )
 
 // ValidationError represents a validation error with field details
@@ -60,3 +70,31 @@ func (e *EngineError) Error() string {
 func (e *EngineError) Unwrap() error {
 	return e.Err
 }
+
+// BackoffError is returned when a rule failed to start and the engine will
+// retry according to its restart policy
+type BackoffError struct {
+	Err       error
+	Attempt   int
+	NextRetry time.Time
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("%v (retry %d at %s)", e.Err, e.Attempt, e.NextRetry.Format("15:04:05"))
+}
+
+func (e *BackoffError) Unwrap() error {
+	return e.Err
+}
+
+// FailureStatus returns the status of a rule that failed to start with err
+func FailureStatus(err error) RuleStatus {
+	var backoff *BackoffError
+	if errors.As(err, &backoff) {
+		return RuleStatusBackoff
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		return RuleStatusQuota
+	}
+	return RuleStatusError
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// EventType represents what an event is about
+type EventType string
+
+const (
+	EventRuleStatus   EventType = "rule.status"   // Status: the new rule status
+	EventTargetHealth EventType = "target.health" // Status: up or down
+	EventQuota        EventType = "quota"         // Status: warning, exceeded or reset
+	EventDaemon       EventType = "daemon"        // Status: started or stopped
+)
+
+// Event represents something operators may want to be notified about
+type Event struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	RuleID     string    `json:"ruleId,omitempty"`
+	RuleName   string    `json:"ruleName,omitempty"`
+	Status     string    `json:"status"`
+	Target     string    `json:"target,omitempty"` // Target address of a health event
+	Message    string    `json:"message,omitempty"`
+	Suppressed int       `json:"suppressed,omitempty"` // Events dropped by rate limiting since the previous delivery
+}
+
+// Summary returns a one line description of the event
+func (e *Event) Summary() string {
+	var text string
+	switch e.Type {
+	case EventRuleStatus:
+		text = fmt.Sprintf("Rule %s is %s", e.RuleName, e.Status)
+	case EventTargetHealth:
+		text = fmt.Sprintf("Target %s of rule %s is %s", e.Target, e.RuleName, e.Status)
+	case EventQuota:
+		text = fmt.Sprintf("Traffic quota of rule %s: %s", e.RuleName, e.Status)
+	case EventDaemon:
+		text = fmt.Sprintf("Service %s", e.Status)
+	default:
+		text = fmt.Sprintf("%s %s", e.Type, e.Status)
+	}
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	if e.Suppressed > 0 {
+		text += fmt.Sprintf(" (%d more events suppressed)", e.Suppressed)
+	}
+	return text
+}
+
+// NotifierType represents how a notification sink delivers events
+type NotifierType string
+
+const (
+	NotifierWebhook NotifierType = "webhook" // POSTs the event as JSON
+	NotifierSlack   NotifierType = "slack"   // POSTs a Slack incoming webhook message
+	NotifierCommand NotifierType = "command" // Runs a local command with the event on stdin
+)
+
+// DefaultNotifyRateLimit is the number of events a sink delivers per minute
+// when its RateLimit is not set
+const DefaultNotifyRateLimit = 10
+
+// NotifierConfig represents a notification sink
+type NotifierConfig struct {
+	Name      string            `json:"name"`
+	Type      NotifierType      `json:"type"`
+	Enabled   bool              `json:"enabled"`
+	URL       string            `json:"url,omitempty"`       // webhook and slack
+	Headers   map[string]string `json:"headers,omitempty"`   // Extra HTTP headers, e.g. Authorization
+	Command   string            `json:"command,omitempty"`   // command
+	Args      []string          `json:"args,omitempty"`      // command
+	Events    []EventType       `json:"events,omitempty"`    // Event types delivered (empty: all)
+	RateLimit int               `json:"rateLimit,omitempty"` // Events per minute (default: 10)
+}
+
+// Validate validates the sink configuration
+func (c *NotifierConfig) Validate() error {
+	if c.Name == "" {
+		return &ValidationError{Field: "notifiers.name", Index: -1, Message: "is required"}
+	}
+	switch c.Type {
+	case NotifierWebhook, NotifierSlack:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ValidationError{Field: "notifiers.url", Index: -1, Message: fmt.Sprintf("invalid URL %q", c.URL)}
+		}
+	case NotifierCommand:
+		if c.Command == "" {
+			return &ValidationError{Field: "notifiers.command", Index: -1, Message: "is required"}
+		}
+	default:
+		return &ValidationError{Field: "notifiers.type", Index: -1, Message: "must be webhook, slack or command"}
+	}
+	for i, t := range c.Events {
+		switch t {
+		case EventRuleStatus, EventTargetHealth, EventQuota, EventDaemon:
+		default:
+			return &ValidationError{Field: "notifiers.events", Index: i, Message: fmt.Sprintf("unknown event type %q", t)}
+		}
+	}
+	if c.RateLimit < 0 {
+		return &ValidationError{Field: "notifiers.rateLimit", Index: -1, Message: "cannot be negative"}
+	}
+	return nil
+}
+
+// Wants reports whether the sink delivers events of type t
+func (c *NotifierConfig) Wants(t EventType) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, want := range c.Events {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRateLimit returns the number of events delivered per minute
+func (c *NotifierConfig) GetRateLimit() int {
+	if c.RateLimit <= 0 {
+		return DefaultNotifyRateLimit
+	}
+	return c.RateLimit
+}
+
+// StreamKind represents what a stream event carries
+type StreamKind string
+
+const (
+	StreamKindEvent StreamKind = "event" // Event: a rule status change, target health, quota or service event
+	StreamKindStats StreamKind = "stats" // Stats: the rules whose counters changed since the previous stats event
+	StreamKindLog   StreamKind = "log"   // Log: a new log entry
+)
+
+// StreamEvent represents one entry of the event stream the service pushes
+// to subscribed clients. IDs increase by one within a stream.
+type StreamEvent struct {
+	ID    int64                 `json:"id"`
+	Kind  StreamKind            `json:"kind"`
+	Event *Event                `json:"event,omitempty"`
+	Stats map[string]*RuleStats `json:"stats,omitempty"`
+	Log   *LogEntry             `json:"log,omitempty"`
+}
+
+// EventBatch represents the stream events delivered to a subscriber at once
+type EventBatch struct {
+	Stream string        `json:"stream"` // Identifies the stream, changes when the service restarts
+	LastID int64         `json:"lastId"` // ID to resume after
+	Reset  bool          `json:"reset"`  // Events were lost since the requested ID, reload the state
+	Events []StreamEvent `json:"events"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestNotifierConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  NotifierConfig
+		wantErr bool
+	}{
+		{"webhook", NotifierConfig{Name: "a", Type: NotifierWebhook, URL: "https://example.com/hook"}, false},
+		{"command", NotifierConfig{Name: "a", Type: NotifierCommand, Command: "/usr/local/bin/alert"}, false},
+		{"no name", NotifierConfig{Type: NotifierSlack, URL: "https://hooks.slack.com/x"}, true},
+		{"bad url", NotifierConfig{Name: "a", Type: NotifierSlack, URL: "hooks.slack.com/x"}, true},
+		{"no command", NotifierConfig{Name: "a", Type: NotifierCommand}, true},
+		{"unknown event", NotifierConfig{Name: "a", Type: NotifierCommand, Command: "x", Events: []EventType{"rule"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryResolution selects the sample interval of a traffic history
+type HistoryResolution string
+
+const (
+	HistorySecond HistoryResolution = "second" // 1-second samples for the last 10 minutes
+	HistoryMinute HistoryResolution = "minute" // 1-minute samples for the last 24 hours
+)
+
+// ParseHistoryResolution parses a resolution, "" being HistorySecond.
+// "1s" and "1m" are accepted as well.
+func ParseHistoryResolution(s string) (HistoryResolution, error) {
+	switch s {
+	case "", "second", "1s":
+		return HistorySecond, nil
+	case "minute", "1m":
+		return HistoryMinute, nil
+	}
+	return "", fmt.Errorf("unknown history resolution %q, want second or minute", s)
+}
+
+// Interval returns the time covered by a sample
+func (r HistoryResolution) Interval() time.Duration {
+	if r == HistoryMinute {
+		return time.Minute
+	}
+	return time.Second
+}
+
+// StatsSample holds the traffic of a rule during one sample interval
+type StatsSample struct {
+	Time        time.Time `json:"time"`        // Start of the interval
+	RateIn      int64     `json:"rateIn"`      // Bytes per second
+	RateOut     int64     `json:"rateOut"`     // Bytes per second
+	ActiveConns int       `json:"activeConns"` // Peak of the interval
+	NewConns    int64     `json:"newConns"`
+	Errors      int64     `json:"errors"`
+}
+
+// StatsHistory holds the recent traffic samples of a rule, oldest first.
+// Intervals in which the rule was not running have no sample.
+type StatsHistory struct {
+	RuleID     string            `json:"ruleId"`
+	Resolution HistoryResolution `json:"resolution"`
+	Samples    []StatsSample     `json:"samples"`
+}
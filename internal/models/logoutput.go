@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// LogOutputType represents where a log output ships entries to
+type LogOutputType string
+
+const (
+	LogOutputSyslog   LogOutputType = "syslog"   // RFC 5424 messages over UDP, TCP or a unix socket
+	LogOutputJournald LogOutputType = "journald" // systemd journal native protocol (Linux)
+	LogOutputFile     LogOutputType = "file"     // JSON lines file
+)
+
+// DefaultLogOutputMaxSize is the size in MB at which a file output is
+// rotated when its MaxSize is not set
+const DefaultLogOutputMaxSize = 100
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// LogOutput represents a destination the rule logs, and optionally the
+// access logs, are shipped to
+type LogOutput struct {
+	Name    string        `json:"name"`
+	Type    LogOutputType `json:"type"`
+	Enabled bool          `json:"enabled"`
+	Level   LogLevel      `json:"level,omitempty"`  // Lowest level shipped (default: info)
+	Access  bool          `json:"access,omitempty"` // Also ship connection records
+
+	// syslog
+	Network  string `json:"network,omitempty"`  // udp, tcp or unix (default: udp)
+	Address  string `json:"address,omitempty"`  // host:port, or the socket path for unix
+	Facility string `json:"facility,omitempty"` // e.g. daemon, local0 (default: daemon)
+	Tag      string `json:"tag,omitempty"`      // APP-NAME, or SYSLOG_IDENTIFIER for journald (default: pfm)
+
+	// file
+	Path    string `json:"path,omitempty"`
+	MaxSize int    `json:"maxSize,omitempty"` // MB at which the file is rotated (default: 100)
+}
+
+// Validate validates the output configuration
+func (o *LogOutput) Validate() error {
+	if o.Name == "" {
+		return &ValidationError{Field: "logOutputs.name", Index: -1, Message: "is required"}
+	}
+	if o.Level != "" && o.Level.Severity() < 0 {
+		return &ValidationError{Field: "logOutputs.level", Index: -1, Message: fmt.Sprintf("unknown log level %q", o.Level)}
+	}
+	switch o.Type {
+	case LogOutputSyslog:
+		switch o.Network {
+		case "", "udp", "tcp", "unix":
+		default:
+			return &ValidationError{Field: "logOutputs.network", Index: -1, Message: "must be udp, tcp or unix"}
+		}
+		if o.Address == "" {
+			return &ValidationError{Field: "logOutputs.address", Index: -1, Message: "is required"}
+		}
+		if _, ok := o.GetFacility(); !ok {
+			return &ValidationError{Field: "logOutputs.facility", Index: -1, Message: fmt.Sprintf("unknown syslog facility %q", o.Facility)}
+		}
+	case LogOutputJournald:
+	case LogOutputFile:
+		if !filepath.IsAbs(o.Path) {
+			return &ValidationError{Field: "logOutputs.path", Index: -1, Message: "must be an absolute path"}
+		}
+		if o.MaxSize < 0 {
+			return &ValidationError{Field: "logOutputs.maxSize", Index: -1, Message: "cannot be negative"}
+		}
+	default:
+		return &ValidationError{Field: "logOutputs.type", Index: -1, Message: "must be syslog, journald or file"}
+	}
+	return nil
+}
+
+// GetLevel returns the lowest level shipped
+func (o *LogOutput) GetLevel() LogLevel {
+	if o.Level == "" {
+		return LogLevelInfo
+	}
+	return o.Level
+}
+
+// GetNetwork returns the syslog transport
+func (o *LogOutput) GetNetwork() string {
+	if o.Network == "" {
+		return "udp"
+	}
+	return o.Network
+}
+
+// GetFacility returns the syslog facility code
+func (o *LogOutput) GetFacility() (int, bool) {
+	if o.Facility == "" {
+		return syslogFacilities["daemon"], true
+	}
+	code, ok := syslogFacilities[o.Facility]
+	return code, ok
+}
+
+// GetTag returns the application name sent with the entries
+func (o *LogOutput) GetTag() string {
+	if o.Tag == "" {
+		return "pfm"
+	}
+	return o.Tag
+}
+
+// GetMaxSize returns the size in bytes at which a file output is rotated
+func (o *LogOutput) GetMaxSize() int64 {
+	if o.MaxSize <= 0 {
+		return DefaultLogOutputMaxSize << 20
+	}
+	return int64(o.MaxSize) << 20
+}
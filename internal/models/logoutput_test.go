@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestLogOutputValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  LogOutput
+		wantErr bool
+	}{
+		{"syslog", LogOutput{Name: "a", Type: LogOutputSyslog, Address: "logs:514"}, false},
+		{"journald", LogOutput{Name: "a", Type: LogOutputJournald, Level: LogLevelError}, false},
+		{"no address", LogOutput{Name: "a", Type: LogOutputSyslog}, true},
+		{"network", LogOutput{Name: "a", Type: LogOutputSyslog, Network: "sctp", Address: "logs:514"}, true},
+		{"facility", LogOutput{Name: "a", Type: LogOutputSyslog, Address: "logs:514", Facility: "local9"}, true},
+		{"relative path", LogOutput{Name: "a", Type: LogOutputFile, Path: "pfm.jsonl"}, true},
+		{"level", LogOutput{Name: "a", Type: LogOutputJournald, Level: "trace"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.output.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLogQueryLimit and MaxLogQueryLimit bound the entries of a page
+	DefaultLogQueryLimit = 100
+	MaxLogQueryLimit     = 1000
+
+	// DefaultLogMaxSize and DefaultLogMaxAge are used when
+	// AppConfig.LogMaxSize and AppConfig.LogMaxAge are not set
+	DefaultLogMaxSize = 50 // MB
+	DefaultLogMaxAge  = 30 // days
+)
+
+// Severity orders log levels, debug being the lowest
+func (l LogLevel) Severity() int {
+	switch l {
+	case LogLevelDebug:
+		return 0
+	case LogLevelInfo:
+		return 1
+	case LogLevelWarn:
+		return 2
+	case LogLevelError:
+		return 3
+	}
+	return -1
+}
+
+// LogQuery selects persisted log entries, newest first. From and To are
+// RFC 3339 times or local dates ("2006-01-02", the whole day), empty for no
+// bound. Level is the lowest level returned and Search matches the message,
+// details or rule name, ignoring case.
+type LogQuery struct {
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+	Level    LogLevel `json:"level,omitempty"`
+	RuleID   string   `json:"ruleId,omitempty"`
+	Search   string   `json:"search,omitempty"`
+	BeforeID int64    `json:"beforeId,omitempty"` // Only entries older than this ID, for the next page
+	Limit    int      `json:"limit,omitempty"`    // Entries per page (default: 100, at most 1000)
+}
+
+// Validate validates the query and applies the default limit
+func (q *LogQuery) Validate() error {
+	if q.Level != "" && q.Level.Severity() < 0 {
+		return fmt.Errorf("unknown log level %q, want debug, info, warn or error", q.Level)
+	}
+	if _, _, err := q.Range(); err != nil {
+		return err
+	}
+	switch {
+	case q.Limit <= 0:
+		q.Limit = DefaultLogQueryLimit
+	case q.Limit > MaxLogQueryLimit:
+		q.Limit = MaxLogQueryLimit
+	}
+	return nil
+}
+
+// Range returns the time bounds of the query, zero for no bound
+func (q *LogQuery) Range() (from, to time.Time, err error) {
+	if from, err = parseLogBound(q.From, false); err != nil {
+		return
+	}
+	to, err = parseLogBound(q.To, true)
+	return
+}
+
+// Matches reports whether an entry satisfies the level, rule and search
+// filters of the query. The time range is checked separately.
+func (q *LogQuery) Matches(e *LogEntry) bool {
+	if q.BeforeID > 0 && e.ID >= q.BeforeID {
+		return false
+	}
+	if q.Level != "" && e.Level.Severity() < q.Level.Severity() {
+		return false
+	}
+	if q.RuleID != "" && e.RuleID != q.RuleID {
+		return false
+	}
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(e.Message), search) &&
+			!strings.Contains(strings.ToLower(e.Details), search) &&
+			!strings.Contains(strings.ToLower(e.RuleName), search) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLogBound parses a bound of a log query. A date as upper bound
+// includes the whole day.
+func parseLogBound(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want 2006-01-02 or RFC 3339", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// LogPage represents one page of a log query
+type LogPage struct {
+	Entries      []LogEntry `json:"entries"`
+	NextBeforeID int64      `json:"nextBeforeId,omitempty"` // BeforeID of the next page, 0 on the last page
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// QuotaPeriod represents how often a traffic quota starts over
+type QuotaPeriod string
+
+const (
+	QuotaDay   QuotaPeriod = "day"
+	QuotaWeek  QuotaPeriod = "week" // Starts on Monday
+	QuotaMonth QuotaPeriod = "month"
+	QuotaTotal QuotaPeriod = "total" // Never starts over
+)
+
+// QuotaAction represents what happens to a rule that used up its quota
+type QuotaAction string
+
+const (
+	QuotaStop     QuotaAction = "stop"
+	QuotaThrottle QuotaAction = "throttle"
+)
+
+// DefaultQuotaWarn are the warning thresholds of a quota that sets none
+var DefaultQuotaWarn = []int{80, 90}
+
+// Quota limits the traffic of a rule, in both directions, during a period.
+// Periods follow the local time of the service.
+type Quota struct {
+	Bytes        int64       `json:"bytes"`                  // 每个周期允许的流量
+	Period       QuotaPeriod `json:"period"`                 // day, week, month or total
+	Warn         []int       `json:"warn,omitempty"`         // Warning thresholds in percent (default: 80, 90)
+	Action       QuotaAction `json:"action,omitempty"`       // stop or throttle (default: stop)
+	ThrottleRate int64       `json:"throttleRate,omitempty"` // Bytes per second once throttled
+}
+
+// Validate validates the quota
+func (q *Quota) Validate() error {
+	if q.Bytes <= 0 {
+		return &ValidationError{Field: "quota.bytes", Index: -1, Message: "must be positive"}
+	}
+	switch q.Period {
+	case QuotaDay, QuotaWeek, QuotaMonth, QuotaTotal:
+	default:
+		return &ValidationError{Field: "quota.period", Index: -1, Message: "must be day, week, month or total"}
+	}
+	for i, w := range q.Warn {
+		if w <= 0 || w >= 100 {
+			return &ValidationError{Field: "quota.warn", Index: i, Message: "must be between 1 and 99"}
+		}
+	}
+	switch q.GetAction() {
+	case QuotaStop:
+	case QuotaThrottle:
+		if q.ThrottleRate <= 0 {
+			return &ValidationError{Field: "quota.throttleRate", Index: -1, Message: "is required to throttle"}
+		}
+	default:
+		return &ValidationError{Field: "quota.action", Index: -1, Message: fmt.Sprintf("unknown action %q", q.Action)}
+	}
+	return nil
+}
+
+// GetAction returns the action taken once the quota is used up
+func (q *Quota) GetAction() QuotaAction {
+	if q.Action == "" {
+		return QuotaStop
+	}
+	return q.Action
+}
+
+// GetWarn returns the warning thresholds in ascending order
+func (q *Quota) GetWarn() []int {
+	if len(q.Warn) == 0 {
+		return DefaultQuotaWarn
+	}
+	warn := append([]int(nil), q.Warn...)
+	sort.Ints(warn)
+	return warn
+}
+
+// PeriodKey returns the key of the period containing t, e.g. "2006-01-02",
+// "2006-W01" or "2006-01". Traffic counted under another key is stale.
+func (q *Quota) PeriodKey(t time.Time) string {
+	t = t.Local()
+	switch q.Period {
+	case QuotaDay:
+		return t.Format("2006-01-02")
+	case QuotaWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case QuotaMonth:
+		return t.Format("2006-01")
+	}
+	return string(QuotaTotal)
+}
+
+// NextReset returns when the period containing t ends, false for a total
+// quota
+func (q *Quota) NextReset(t time.Time) (time.Time, bool) {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch q.Period {
+	case QuotaDay:
+		return day.AddDate(0, 0, 1), true
+	case QuotaWeek:
+		// Days until the next Monday, Sunday being 0
+		return day.AddDate(0, 0, 7-(int(t.Weekday())+6)%7), true
+	case QuotaMonth:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.Local), true
+	}
+	return time.Time{}, false
+}
+
+// QuotaStatus represents the traffic a rule used against its quota
+type QuotaStatus struct {
+	RuleID   string      `json:"ruleId"`
+	RuleName string      `json:"ruleName"`
+	Period   QuotaPeriod `json:"period"`
+	Limit    int64       `json:"limit"`
+	Used     int64       `json:"used"`
+	Percent  int         `json:"percent"`
+	Exceeded bool        `json:"exceeded"`
+	Action   QuotaAction `json:"action"`
+	ResetAt  *time.Time  `json:"resetAt,omitempty"` // End of the current period, none for a total quota
+}
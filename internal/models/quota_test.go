@@ -0,0 +1,66 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	// Wednesday 2026-03-04
+	now := time.Date(2026, 3, 4, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		period    QuotaPeriod
+		key       string
+		reset     time.Time
+		resetting bool
+	}{
+		{QuotaDay, "2026-03-04", time.Date(2026, 3, 5, 0, 0, 0, 0, time.Local), true},
+		{QuotaWeek, "2026-W10", time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local), true},
+		{QuotaMonth, "2026-03", time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local), true},
+		{QuotaTotal, "total", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		q := &Quota{Bytes: 1, Period: tt.period}
+		if got := q.PeriodKey(now); got != tt.key {
+			t.Errorf("%s: PeriodKey() = %q, want %q", tt.period, got, tt.key)
+		}
+		reset, ok := q.NextReset(now)
+		if ok != tt.resetting || !reset.Equal(tt.reset) {
+			t.Errorf("%s: NextReset() = %v, %v, want %v", tt.period, reset, ok, tt.reset)
+		}
+	}
+
+	// A week quota started on Sunday ends the next day
+	sunday := time.Date(2026, 3, 8, 23, 0, 0, 0, time.Local)
+	if reset, _ := (&Quota{Bytes: 1, Period: QuotaWeek}).NextReset(sunday); !reset.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("NextReset() on Sunday = %v", reset)
+	}
+}
+
+func TestQuotaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   Quota
+		wantErr bool
+	}{
+		{"stop", Quota{Bytes: 1 << 30, Period: QuotaMonth}, false},
+		{"throttle", Quota{Bytes: 1 << 30, Period: QuotaDay, Action: QuotaThrottle, ThrottleRate: 1024}, false},
+		{"no limit", Quota{Period: QuotaDay}, true},
+		{"unknown period", Quota{Bytes: 1, Period: "year"}, true},
+		{"threshold", Quota{Bytes: 1, Period: QuotaDay, Warn: []int{50, 100}}, true},
+		{"throttle without rate", Quota{Bytes: 1, Period: QuotaDay, Action: QuotaThrottle}, true},
+		{"unknown action", Quota{Bytes: 1, Period: QuotaDay, Action: "drop"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.quota.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	q := &Quota{Warn: []int{95, 50}}
+	if got := q.GetWarn(); got[0] != 50 || got[1] != 95 || q.Warn[0] != 95 {
+		t.Errorf("GetWarn() = %v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MinTokenLength is the shortest token accepted for remote access
+const MinTokenLength = 16
+
+// RemoteAccess exposes the management API of the service on a TCP port
+// secured by TLS, for clients on other machines. Clients authenticate with
+// a certificate signed by ClientCAFile or with one of the tokens.
+type RemoteAccess struct {
+	Enabled        bool          `json:"enabled"`
+	Addr           string        `json:"addr"`                     // e.g. ":19850"
+	CertFile       string        `json:"certFile,omitempty"`       // Server certificate, empty = self-signed, kept in the data directory
+	KeyFile        string        `json:"keyFile,omitempty"`        // Key of CertFile
+	ClientCAFile   string        `json:"clientCAFile,omitempty"`   // CA of the accepted client certificates, empty = tokens only
+	ClientCertRole IPCRole       `json:"clientCertRole,omitempty"` // Role of clients with a certificate (default: readonly)
+	Tokens         []RemoteToken `json:"tokens,omitempty"`
+}
+
+// RemoteToken is a secret a remote client authenticates with
+type RemoteToken struct {
+	Name  string  `json:"name"`
+	Token string  `json:"token"`          // At least MinTokenLength characters
+	Role  IPCRole `json:"role,omitempty"` // Default: readonly
+}
+
+// Validate validates the remote access settings
+func (r *RemoteAccess) Validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(r.Addr); err != nil {
+		return &ValidationError{Field: "remoteAccess.addr", Index: -1, Message: fmt.Sprintf("invalid address %q", r.Addr)}
+	}
+	if (r.CertFile == "") != (r.KeyFile == "") {
+		return &ValidationError{Field: "remoteAccess.keyFile", Index: -1, Message: "certFile and keyFile go together"}
+	}
+	if r.ClientCAFile == "" && len(r.Tokens) == 0 {
+		return &ValidationError{Field: "remoteAccess.tokens", Index: -1, Message: "a client CA or a token is required"}
+	}
+	if !validRole(r.ClientCertRole) {
+		return &ValidationError{Field: "remoteAccess.clientCertRole", Index: -1, Message: fmt.Sprintf("unknown role %q", r.ClientCertRole)}
+	}
+	names := make(map[string]bool)
+	for i, t := range r.Tokens {
+		switch {
+		case t.Name == "":
+			return &ValidationError{Field: "remoteAccess.tokens.name", Index: i, Message: "is required"}
+		case names[t.Name]:
+			return &ValidationError{Field: "remoteAccess.tokens.name", Index: i, Message: fmt.Sprintf("duplicate name %q", t.Name)}
+		case len(t.Token) < MinTokenLength:
+			return &ValidationError{Field: "remoteAccess.tokens.token", Index: i, Message: fmt.Sprintf("must have at least %d characters", MinTokenLength)}
+		case !validRole(t.Role):
+			return &ValidationError{Field: "remoteAccess.tokens.role", Index: i, Message: fmt.Sprintf("unknown role %q", t.Role)}
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
+
+// validRole reports whether a role of the settings is known, empty being
+// the default
+func validRole(role IPCRole) bool {
+	switch role {
+	case "", IPCRoleNone, IPCRoleReadOnly, IPCRoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// RemoteTarget is a remote service a client connects to
+type RemoteTarget struct {
+	Addr        string `json:"addr"`                  // host:port
+	ServerName  string `json:"serverName,omitempty"`  // Name verified in the server certificate, default: host of Addr
+	CAFile      string `json:"caFile,omitempty"`      // CA of the server certificate, empty = system roots
+	Fingerprint string `json:"fingerprint,omitempty"` // SHA-256 of the server certificate, trusted instead of a CA
+	CertFile    string `json:"certFile,omitempty"`    // Client certificate
+	KeyFile     string `json:"keyFile,omitempty"`     // Key of CertFile
+	Token       string `json:"token,omitempty"`
+}
+
+// Validate validates the connection settings
+func (t *RemoteTarget) Validate() error {
+	if _, _, err := net.SplitHostPort(t.Addr); err != nil {
+		return &ValidationError{Field: "addr", Index: -1, Message: fmt.Sprintf("invalid address %q", t.Addr)}
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return &ValidationError{Field: "keyFile", Index: -1, Message: "certFile and keyFile go together"}
+	}
+	if t.CertFile == "" && t.Token == "" {
+		return &ValidationError{Field: "token", Index: -1, Message: "a client certificate or a token is required"}
+	}
+	if fp := NormalizeFingerprint(t.Fingerprint); t.Fingerprint != "" && len(fp) != 64 {
+		return &ValidationError{Field: "fingerprint", Index: -1, Message: "must be the SHA-256 of the certificate in hex"}
+	}
+	return nil
+}
+
+// NormalizeFingerprint returns a certificate fingerprint in lowercase hex
+// without separators, as printed by the service
+func NormalizeFingerprint(fp string) string {
+	fp = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(fp)), "sha256:")
+	return strings.NewReplacer(":", "", " ", "").Replace(fp)
+}
+
+// RemoteStatus describes the remote access listener of the service
+type RemoteStatus struct {
+	Addr        string `json:"addr"`
+	Fingerprint string `json:"fingerprint"` // SHA-256 of the server certificate
+	Error       string `json:"error,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "testing"
+
+func TestRemoteAccessValidate(t *testing.T) {
+	token := RemoteToken{Name: "laptop", Token: "0123456789abcdef"}
+	tests := []struct {
+		name    string
+		access  RemoteAccess
+		wantErr bool
+	}{
+		{"disabled", RemoteAccess{}, false},
+		{"token", RemoteAccess{Enabled: true, Addr: ":19850", Tokens: []RemoteToken{token}}, false},
+		{"client CA", RemoteAccess{Enabled: true, Addr: ":19850", ClientCAFile: "/etc/pfm/ca.pem"}, false},
+		{"address", RemoteAccess{Enabled: true, Addr: "19850", Tokens: []RemoteToken{token}}, true},
+		{"no authentication", RemoteAccess{Enabled: true, Addr: ":19850"}, true},
+		{"key without certificate", RemoteAccess{Enabled: true, Addr: ":19850", KeyFile: "key.pem", Tokens: []RemoteToken{token}}, true},
+		{"short token", RemoteAccess{Enabled: true, Addr: ":19850", Tokens: []RemoteToken{{Name: "a", Token: "secret"}}}, true},
+		{"duplicate token", RemoteAccess{Enabled: true, Addr: ":19850", Tokens: []RemoteToken{token, token}}, true},
+		{"role", RemoteAccess{Enabled: true, Addr: ":19850", Tokens: []RemoteToken{{Name: "a", Token: token.Token, Role: "root"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.access.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteTargetValidate(t *testing.T) {
+	fingerprint := "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89"
+	if got := NormalizeFingerprint(fingerprint); got != "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789" {
+		t.Errorf("NormalizeFingerprint() = %q", got)
+	}
+	if err := (&RemoteTarget{Addr: "box:19850", Token: "t", Fingerprint: fingerprint}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&RemoteTarget{Addr: "box:19850", Token: "t", Fingerprint: "abcd"}).Validate(); err == nil {
+		t.Error("Validate() accepts a short fingerprint")
+	}
+	if err := (&RemoteTarget{Addr: "box:19850"}).Validate(); err == nil {
+		t.Error("Validate() accepts a target without credentials")
+	}
+}
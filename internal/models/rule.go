@@ -2,6 +2,8 @@ package models
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +16,19 @@ const (
 	RuleTypeForward RuleType = "forward" // Port forwarding
 	RuleTypeReverse RuleType = "reverse" // Reverse proxy
 	RuleTypeChain   RuleType = "chain"   // Proxy chain
+
+	// RuleTypeTransparent accepts connections redirected by iptables/nftables
+	// (REDIRECT or TPROXY) and sends them to their original destination.
+	// Linux only.
+	RuleTypeTransparent RuleType = "transparent"
+)
+
+// TransparentMode represents how traffic is diverted to a transparent rule
+type TransparentMode string
+
+const (
+	TransparentModeRedirect TransparentMode = "redirect" // nat REDIRECT, TCP only
+	TransparentModeTProxy   TransparentMode = "tproxy"   // mangle TPROXY, TCP and UDP
 )
 
 // RuleStatus represents the current status of a rule
@@ -23,6 +38,25 @@ const (
 	RuleStatusStopped RuleStatus = "stopped"
 	RuleStatusRunning RuleStatus = "running"
 	RuleStatusError   RuleStatus = "error"
+	RuleStatusBackoff RuleStatus = "backoff" // Failed, waiting to be restarted
+	RuleStatusExpired RuleStatus = "expired" // Stopped and disabled at its expiry
+	RuleStatusQuota   RuleStatus = "quota"   // Stopped until its traffic quota starts over
+)
+
+// RestartMode represents when a failed rule is restarted
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"      // Failed rules stay stopped
+	RestartOnFailure RestartMode = "on-failure" // Restart when the listener fails to start or the service dies with an error
+	RestartAlways    RestartMode = "always"     // Also restart services that exit without an error
+)
+
+const (
+	// DefaultRestartInitialDelay is the delay before the first retry
+	DefaultRestartInitialDelay = time.Second
+	// DefaultRestartMaxDelay caps the exponential backoff
+	DefaultRestartMaxDelay = 5 * time.Minute
 )
 
 // Protocol represents the network protocol
@@ -39,31 +73,42 @@ const (
 
 // Rule represents a forwarding rule
 type Rule struct {
-	ID   string   `json:"id"`
-	Name string   `json:"name"` // 用途
-	Type RuleType `json:"type"`
-	Enabled     bool        `json:"enabled"`
-	LocalPort   int         `json:"localPort"`   // 本地映射端口
-	Protocol    Protocol    `json:"protocol"`
-	TargetHost  string      `json:"targetHost"`  // 目标 IP/域名
-	TargetPort  int         `json:"targetPort"`  // 目标端口
-	Targets     []Target    `json:"targets"`     // 保留用于负载均衡场景
-	ChainID     string      `json:"chainId,omitempty"`
-	Auth        *Auth       `json:"auth,omitempty"`
-	TLS         *TLSConfig  `json:"tls,omitempty"`
-	Status      RuleStatus  `json:"status"`
-	ErrorMsg    string      `json:"errorMsg,omitempty"`
-	Description string      `json:"description,omitempty"` // 用途描述
-	Remark      string      `json:"remark,omitempty"`      // 备注
-	CreatedAt   time.Time   `json:"createdAt"`
-	UpdatedAt   time.Time   `json:"updatedAt"`
+	ID             string             `json:"id"`
+	Name           string             `json:"name"` // 用途
+	Type           RuleType           `json:"type"`
+	Enabled        bool               `json:"enabled"`
+	LocalPort      int                `json:"localPort"` // 本地映射端口
+	Protocol       Protocol           `json:"protocol"`
+	TargetHost     string             `json:"targetHost"`          // 目标 IP/域名
+	TargetPort     int                `json:"targetPort"`          // 目标端口
+	Targets        []Target           `json:"targets"`             // 保留用于负载均衡场景
+	Discovery      *TargetDiscovery   `json:"discovery,omitempty"` // 从 DNS 发现目标, 代替 Targets
+	ChainID        string             `json:"chainId,omitempty"`
+	Auth           *Auth              `json:"auth,omitempty"`
+	TLS            *TLSConfig         `json:"tls,omitempty"`
+	Transparent    *TransparentConfig `json:"transparent,omitempty"`
+	Mirror         *MirrorConfig      `json:"mirror,omitempty"`         // 流量镜像
+	Restart        *RestartPolicy     `json:"restart,omitempty"`        // 失败自动重启
+	Schedule       *Schedule          `json:"schedule,omitempty"`       // 可用时段
+	ExpiresAt      *time.Time         `json:"expiresAt,omitempty"`      // 到期时间
+	TTL            int                `json:"ttl,omitempty"`            // Seconds until expiry, converted to ExpiresAt when saved
+	DeleteOnExpiry bool               `json:"deleteOnExpiry,omitempty"` // 到期后删除
+	DependsOn      []string           `json:"dependsOn,omitempty"`      // 依赖的规则, 启动顺序在其之后
+	Quota          *Quota             `json:"quota,omitempty"`          // 流量配额
+	Status         RuleStatus         `json:"status"`
+	ErrorMsg       string             `json:"errorMsg,omitempty"`
+	Description    string             `json:"description,omitempty"` // 用途描述
+	Remark         string             `json:"remark,omitempty"`      // 备注
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
 }
 
 // Target represents a forwarding target (for load balancing)
 type Target struct {
-	Host   string `json:"host"`   // IP or hostname
-	Port   int    `json:"port"`   // Port number
-	Weight int    `json:"weight"` // Load balancing weight (default: 1)
+	Host   string `json:"host"`             // IP or hostname
+	Port   int    `json:"port"`             // Port number
+	Weight int    `json:"weight"`           // Load balancing weight (default: 1)
+	Backup bool   `json:"backup,omitempty"` // Only used when no other target is reachable
 }
 
 // Auth represents authentication configuration
@@ -82,6 +127,106 @@ type TLSConfig struct {
 	Secure     bool   `json:"secure"` // Verify server certificate
 }
 
+// TransparentConfig represents the configuration of a transparent proxy rule
+type TransparentConfig struct {
+	Mode         TransparentMode `json:"mode"`                   // redirect or tproxy
+	Mark         int             `json:"mark,omitempty"`         // fwmark used for TPROXY policy routing (default: 1)
+	DestPorts    []int           `json:"destPorts,omitempty"`    // Only divert traffic to these ports (empty: all)
+	ExcludeCIDRs []string        `json:"excludeCidrs,omitempty"` // Never divert traffic to these networks
+}
+
+// GetMode returns the transparent mode, defaulting to redirect
+func (t *TransparentConfig) GetMode() TransparentMode {
+	if t == nil || t.Mode == "" {
+		return TransparentModeRedirect
+	}
+	return t.Mode
+}
+
+// GetMark returns the fwmark used for TPROXY, defaulting to 1
+func (t *TransparentConfig) GetMark() int {
+	if t == nil || t.Mark <= 0 {
+		return 1
+	}
+	return t.Mark
+}
+
+// MirrorConfig represents a shadow target that receives a copy of the
+// client-to-server traffic of a forward rule. Its responses are discarded.
+type MirrorConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	SamplePercent int    `json:"samplePercent,omitempty"` // Share of connections (TCP) or datagrams (UDP) to copy, 1-100 (omitted: 100)
+}
+
+// GetAddr returns the mirror target address string
+func (m *MirrorConfig) GetAddr() string {
+	return net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+}
+
+// GetSamplePercent returns the sampling percentage, 100 when omitted. A
+// zero SamplePercent is the omitted value, not a share of nothing.
+func (m *MirrorConfig) GetSamplePercent() int {
+	if m.SamplePercent <= 0 || m.SamplePercent > 100 {
+		return 100
+	}
+	return m.SamplePercent
+}
+
+// RestartPolicy represents how a failed rule is restarted. The delay
+// doubles after each failed attempt, starting at InitialDelay and capped at MaxDelay.
+type RestartPolicy struct {
+	Mode         RestartMode `json:"mode"`
+	MaxRetries   int         `json:"maxRetries,omitempty"`   // Give up after this many consecutive retries (0: unlimited)
+	InitialDelay int         `json:"initialDelay,omitempty"` // Seconds before the first retry (default: 1)
+	MaxDelay     int         `json:"maxDelay,omitempty"`     // Maximum seconds between retries (default: 300)
+}
+
+// GetMode returns the restart mode, defaulting to never
+func (p *RestartPolicy) GetMode() RestartMode {
+	if p == nil || p.Mode == "" {
+		return RestartNever
+	}
+	return p.Mode
+}
+
+// ShouldRestart reports whether a service that exited with err is
+// restarted, err is nil when the service exited cleanly
+func (p *RestartPolicy) ShouldRestart(err error) bool {
+	switch p.GetMode() {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// Exhausted reports whether the given retry attempt exceeds MaxRetries
+func (p *RestartPolicy) Exhausted(attempt int) bool {
+	return p != nil && p.MaxRetries > 0 && attempt > p.MaxRetries
+}
+
+// Delay returns the backoff before the given retry attempt, starting at 1
+func (p *RestartPolicy) Delay(attempt int) time.Duration {
+	delay, max := DefaultRestartInitialDelay, DefaultRestartMaxDelay
+	if p != nil && p.InitialDelay > 0 {
+		delay = time.Duration(p.InitialDelay) * time.Second
+	}
+	if p != nil && p.MaxDelay > 0 {
+		max = time.Duration(p.MaxDelay) * time.Second
+	}
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
 // NewRule creates a new rule with default values
 func NewRule(name string, ruleType RuleType) *Rule {
 	now := time.Now()
@@ -106,6 +251,35 @@ func (r *Rule) Validate() error {
 	if r.LocalPort <= 0 {
 		return ErrListenAddrEmpty
 	}
+	if r.TTL < 0 {
+		return &ValidationError{Field: "ttl", Index: -1, Message: "cannot be negative"}
+	}
+	if r.Schedule != nil {
+		if err := r.Schedule.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Quota != nil {
+		if err := r.Quota.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := r.validateMirror(); err != nil {
+		return err
+	}
+	if err := r.validateRestart(); err != nil {
+		return err
+	}
+	if r.Discovery != nil {
+		if r.Type != RuleTypeForward && r.Type != RuleTypeReverse {
+			return &ValidationError{Field: "discovery", Index: -1, Message: "only forward and reverse rules can discover targets"}
+		}
+		return r.Discovery.Validate()
+	}
+	// Transparent rules recover the destination from the redirected connection
+	if r.Type == RuleTypeTransparent {
+		return r.validateTransparent()
+	}
 	// Check simple mode (single target)
 	if r.TargetHost != "" && r.TargetPort > 0 {
 		return nil
@@ -117,11 +291,111 @@ func (r *Rule) Validate() error {
 	return nil
 }
 
+// validateTransparent validates the transparent proxy specific settings
+func (r *Rule) validateTransparent() error {
+	if r.ChainID == "" {
+		return ErrChainRequired
+	}
+	switch r.Transparent.GetMode() {
+	case TransparentModeRedirect:
+		if r.Protocol == ProtocolUDP {
+			return &ValidationError{Field: "transparent.mode", Index: -1, Message: "udp requires tproxy mode"}
+		}
+	case TransparentModeTProxy:
+	default:
+		return &ValidationError{Field: "transparent.mode", Index: -1, Message: fmt.Sprintf("unknown mode %q", r.Transparent.Mode)}
+	}
+	if r.Transparent != nil {
+		for i, port := range r.Transparent.DestPorts {
+			if port <= 0 || port > 65535 {
+				return &ValidationError{Field: "transparent.destPorts", Index: i, Message: "port out of range"}
+			}
+		}
+		// The networks are written into an nftables table of the ip family
+		for i, cidr := range r.Transparent.ExcludeCIDRs {
+			if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() == nil {
+				return &ValidationError{Field: "transparent.excludeCidrs", Index: i, Message: fmt.Sprintf("%q is not an IPv4 CIDR", cidr)}
+			}
+		}
+	}
+	return nil
+}
+
+// validateMirror validates the traffic mirroring settings
+func (r *Rule) validateMirror() error {
+	if r.Mirror == nil || !r.Mirror.Enabled {
+		return nil
+	}
+	if r.Type != RuleTypeForward {
+		return &ValidationError{Field: "mirror", Index: -1, Message: "mirroring is only supported on forward rules"}
+	}
+	if r.Mirror.Host == "" || r.Mirror.Port <= 0 || r.Mirror.Port > 65535 {
+		return &ValidationError{Field: "mirror", Index: -1, Message: "mirror target is invalid"}
+	}
+	if r.Mirror.SamplePercent < 0 || r.Mirror.SamplePercent > 100 {
+		return &ValidationError{Field: "mirror.samplePercent", Index: -1, Message: "must be between 1 and 100"}
+	}
+	return nil
+}
+
+// validateRestart validates the restart policy
+func (r *Rule) validateRestart() error {
+	switch r.Restart.GetMode() {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return &ValidationError{Field: "restart.mode", Index: -1, Message: fmt.Sprintf("unknown mode %q", r.Restart.Mode)}
+	}
+	if r.Restart != nil && (r.Restart.MaxRetries < 0 || r.Restart.InitialDelay < 0 || r.Restart.MaxDelay < 0) {
+		return &ValidationError{Field: "restart", Index: -1, Message: "values cannot be negative"}
+	}
+	return nil
+}
+
 // GetListenAddr returns the listen address string
 func (r *Rule) GetListenAddr() string {
 	return fmt.Sprintf(":%d", r.LocalPort)
 }
 
+// InSchedule reports whether the rule may run at t, rules without a
+// schedule always may
+func (r *Rule) InSchedule(t time.Time) bool {
+	return r.Schedule == nil || r.Schedule.ActiveAt(t)
+}
+
+// IsExpired reports whether the rule has expired at t
+func (r *Rule) IsExpired(t time.Time) bool {
+	return r.ExpiresAt != nil && !t.Before(*r.ExpiresAt)
+}
+
+// ApplyTTL converts the TTL into an absolute expiry counted from now
+func (r *Rule) ApplyTTL(now time.Time) {
+	if r.TTL <= 0 {
+		return
+	}
+	expiresAt := now.Add(time.Duration(r.TTL) * time.Second)
+	r.ExpiresAt = &expiresAt
+	r.TTL = 0
+}
+
+// Extend moves the expiry d later. An expired rule, or one without an
+// expiry, gets d counted from now.
+func (r *Rule) Extend(now time.Time, d time.Duration) {
+	base := now
+	if r.ExpiresAt != nil && r.ExpiresAt.After(now) {
+		base = *r.ExpiresAt
+	}
+	expiresAt := base.Add(d)
+	r.ExpiresAt = &expiresAt
+}
+
+// GetNetwork returns the network of the listen port, "udp" or "tcp"
+func (r *Rule) GetNetwork() string {
+	if r.Protocol == ProtocolUDP {
+		return "udp"
+	}
+	return "tcp"
+}
+
 // GetTargetAddr returns the primary target address string
 func (r *Rule) GetTargetAddr() string {
 	if r.TargetHost != "" && r.TargetPort > 0 {
@@ -146,5 +420,43 @@ func (r *Rule) Clone() *Rule {
 		tls := *r.TLS
 		clone.TLS = &tls
 	}
+	if r.Transparent != nil {
+		transparent := *r.Transparent
+		transparent.DestPorts = append([]int(nil), r.Transparent.DestPorts...)
+		transparent.ExcludeCIDRs = append([]string(nil), r.Transparent.ExcludeCIDRs...)
+		clone.Transparent = &transparent
+	}
+	if r.Mirror != nil {
+		mirror := *r.Mirror
+		clone.Mirror = &mirror
+	}
+	if r.Restart != nil {
+		restart := *r.Restart
+		clone.Restart = &restart
+	}
+	if r.Schedule != nil {
+		schedule := *r.Schedule
+		schedule.Windows = nil
+		for _, w := range r.Schedule.Windows {
+			w.Days = append([]int(nil), w.Days...)
+			schedule.Windows = append(schedule.Windows, w)
+		}
+		schedule.Cron = append([]CronWindow(nil), r.Schedule.Cron...)
+		clone.Schedule = &schedule
+	}
+	if r.Discovery != nil {
+		discovery := *r.Discovery
+		clone.Discovery = &discovery
+	}
+	clone.DependsOn = append([]string(nil), r.DependsOn...)
+	if r.Quota != nil {
+		quota := *r.Quota
+		quota.Warn = append([]int(nil), r.Quota.Warn...)
+		clone.Quota = &quota
+	}
+	if r.ExpiresAt != nil {
+		expiresAt := *r.ExpiresAt
+		clone.ExpiresAt = &expiresAt
+	}
 	return &clone
 }
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRule_Validate(t *testing.T) {
@@ -73,6 +77,111 @@ func TestRule_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Valid Transparent Rule",
+			rule: &Rule{
+				Name:      "Transparent",
+				LocalPort: 12345,
+				Type:      RuleTypeTransparent,
+				Protocol:  ProtocolTCP,
+				ChainID:   "chain-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid - Transparent Without Chain",
+			rule: &Rule{
+				Name:      "Transparent",
+				LocalPort: 12345,
+				Type:      RuleTypeTransparent,
+				Protocol:  ProtocolTCP,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid - Transparent UDP Redirect",
+			rule: &Rule{
+				Name:        "Transparent",
+				LocalPort:   12345,
+				Type:        RuleTypeTransparent,
+				Protocol:    ProtocolUDP,
+				ChainID:     "chain-1",
+				Transparent: &TransparentConfig{Mode: TransparentModeRedirect},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid Transparent Excluded Networks",
+			rule: &Rule{
+				Name:        "Transparent",
+				LocalPort:   12345,
+				Type:        RuleTypeTransparent,
+				Protocol:    ProtocolTCP,
+				ChainID:     "chain-1",
+				Transparent: &TransparentConfig{ExcludeCIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid - Transparent Malformed Excluded Network",
+			rule: &Rule{
+				Name:        "Transparent",
+				LocalPort:   12345,
+				Type:        RuleTypeTransparent,
+				Protocol:    ProtocolTCP,
+				ChainID:     "chain-1",
+				Transparent: &TransparentConfig{ExcludeCIDRs: []string{"10.0.0.0/8", "10.0.0.0/33"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid - Transparent IPv6 Excluded Network",
+			rule: &Rule{
+				Name:        "Transparent",
+				LocalPort:   12345,
+				Type:        RuleTypeTransparent,
+				Protocol:    ProtocolTCP,
+				ChainID:     "chain-1",
+				Transparent: &TransparentConfig{ExcludeCIDRs: []string{"fd00::/8"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid - Transparent Unknown Restart Mode",
+			rule: &Rule{
+				Name:      "Transparent",
+				LocalPort: 12345,
+				Type:      RuleTypeTransparent,
+				Protocol:  ProtocolTCP,
+				ChainID:   "chain-1",
+				Restart:   &RestartPolicy{Mode: "sometimes"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid - Transparent With Mirror",
+			rule: &Rule{
+				Name:      "Transparent",
+				LocalPort: 12345,
+				Type:      RuleTypeTransparent,
+				Protocol:  ProtocolTCP,
+				ChainID:   "chain-1",
+				Mirror:    &MirrorConfig{Enabled: true, Host: "127.0.0.1", Port: 9000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid - Transparent With Discovery",
+			rule: &Rule{
+				Name:      "Transparent",
+				LocalPort: 12345,
+				Type:      RuleTypeTransparent,
+				Protocol:  ProtocolTCP,
+				ChainID:   "chain-1",
+				Discovery: &TargetDiscovery{Type: DiscoveryDNS, Name: "web.test", Port: 80},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,3 +212,101 @@ func TestNewRule(t *testing.T) {
 		t.Error("NewRule() CreatedAt should equal UpdatedAt")
 	}
 }
+
+func TestRestartPolicy_Delay(t *testing.T) {
+	p := &RestartPolicy{Mode: RestartOnFailure, InitialDelay: 2, MaxDelay: 10}
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+
+	var none *RestartPolicy
+	if none.ShouldRestart(errors.New("boom")) {
+		t.Error("nil policy should never restart")
+	}
+	if got := none.Delay(1); got != DefaultRestartInitialDelay {
+		t.Errorf("nil policy Delay(1) = %v", got)
+	}
+	if (&RestartPolicy{Mode: RestartOnFailure}).ShouldRestart(nil) {
+		t.Error("on-failure should not restart a clean exit")
+	}
+	if !(&RestartPolicy{Mode: RestartAlways}).ShouldRestart(nil) {
+		t.Error("always should restart a clean exit")
+	}
+	if p.Exhausted(100) || !(&RestartPolicy{MaxRetries: 3}).Exhausted(4) {
+		t.Error("Exhausted() mismatch")
+	}
+}
+
+func TestRule_Expiry(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	r := &Rule{TTL: 7200}
+	r.ApplyTTL(now)
+	if r.TTL != 0 || r.ExpiresAt == nil || !r.ExpiresAt.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("ApplyTTL() ExpiresAt = %v, TTL = %d", r.ExpiresAt, r.TTL)
+	}
+	if r.IsExpired(now) || !r.IsExpired(now.Add(2*time.Hour)) {
+		t.Error("IsExpired() mismatch")
+	}
+
+	// Extending a pending expiry adds to it
+	r.Extend(now, time.Hour)
+	if !r.ExpiresAt.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("Extend() = %v, want 15:00", r.ExpiresAt)
+	}
+
+	// Extending a past expiry counts from now
+	later := now.Add(5 * time.Hour)
+	r.Extend(later, time.Hour)
+	if !r.ExpiresAt.Equal(later.Add(time.Hour)) {
+		t.Errorf("Extend() = %v, want 18:00", r.ExpiresAt)
+	}
+
+	if (&Rule{}).IsExpired(now) {
+		t.Error("rules without an expiry never expire")
+	}
+}
+
+func TestMirrorGetAddr(t *testing.T) {
+	for _, tt := range []struct{ host, want string }{
+		{"10.0.0.5", "10.0.0.5:9000"},
+		{"mirror.local", "mirror.local:9000"},
+		{"fd00::5", "[fd00::5]:9000"},
+	} {
+		m := &MirrorConfig{Host: tt.host, Port: 9000}
+		if got := m.GetAddr(); got != tt.want {
+			t.Errorf("GetAddr() of %s = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMirrorSamplePercent(t *testing.T) {
+	rule := &Rule{Name: "mirrored", Type: RuleTypeForward, Protocol: ProtocolTCP, LocalPort: 8080, TargetHost: "127.0.0.1", TargetPort: 80,
+		Mirror: &MirrorConfig{Enabled: true, Host: "127.0.0.1", Port: 9000}}
+
+	// Omitted copies everything, and stays omitted when saved
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := rule.Mirror.GetSamplePercent(); got != 100 {
+		t.Errorf("GetSamplePercent() = %d, want 100", got)
+	}
+	data, _ := json.Marshal(rule.Mirror)
+	if strings.Contains(string(data), "samplePercent") {
+		t.Errorf("omitted samplePercent marshaled: %s", data)
+	}
+
+	rule.Mirror.SamplePercent = 25
+	if err := rule.Validate(); err != nil || rule.Mirror.GetSamplePercent() != 25 {
+		t.Errorf("Validate() error = %v, GetSamplePercent() = %d", err, rule.Mirror.GetSamplePercent())
+	}
+	for _, p := range []int{-1, 101} {
+		rule.Mirror.SamplePercent = p
+		if err := rule.Validate(); err == nil {
+			t.Errorf("Validate() accepted samplePercent %d", p)
+		}
+	}
+}
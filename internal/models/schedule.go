@@ -0,0 +1,335 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// scheduleHorizon is how far ahead the next transition is searched
+	scheduleHorizon = 8 * 24 * time.Hour
+
+	// MaxCronDuration caps how long a cron window stays open, in minutes
+	MaxCronDuration = 7 * 24 * 60
+)
+
+// Schedule represents when a rule is available. The rule runs while any of
+// its windows is open. Times are evaluated in Timezone.
+type Schedule struct {
+	Timezone string       `json:"timezone,omitempty"` // IANA name, e.g. "Asia/Shanghai" (default: local time)
+	Windows  []TimeWindow `json:"windows,omitempty"`
+	Cron     []CronWindow `json:"cron,omitempty"`
+}
+
+// TimeWindow represents a daily time range on some weekdays
+type TimeWindow struct {
+	Days  []int  `json:"days,omitempty"` // 0 = Sunday ... 6 = Saturday (empty: every day)
+	Start string `json:"start"`          // "HH:MM"
+	End   string `json:"end"`            // "HH:MM", before Start for ranges spanning midnight
+}
+
+// CronWindow represents windows opened by a cron expression
+type CronWindow struct {
+	Expr     string `json:"expr"`     // Five-field cron expression (minute hour day month weekday)
+	Duration int    `json:"duration"` // Minutes the window stays open
+}
+
+// Validate validates the schedule
+func (s *Schedule) Validate() error {
+	if len(s.Windows) == 0 && len(s.Cron) == 0 {
+		return &ValidationError{Field: "schedule", Index: -1, Message: "at least one window is required"}
+	}
+	if _, err := s.location(); err != nil {
+		return &ValidationError{Field: "schedule.timezone", Index: -1, Message: err.Error()}
+	}
+	for i, w := range s.Windows {
+		if _, _, err := w.bounds(); err != nil {
+			return &ValidationError{Field: "schedule.windows", Index: i, Message: err.Error()}
+		}
+		for _, d := range w.Days {
+			if d < 0 || d > 6 {
+				return &ValidationError{Field: "schedule.windows", Index: i, Message: "days must be between 0 and 6"}
+			}
+		}
+	}
+	for i, c := range s.Cron {
+		if _, err := parseCron(c.Expr); err != nil {
+			return &ValidationError{Field: "schedule.cron", Index: i, Message: err.Error()}
+		}
+		if c.Duration <= 0 || c.Duration > MaxCronDuration {
+			return &ValidationError{Field: "schedule.cron", Index: i, Message: fmt.Sprintf("duration must be between 1 and %d minutes", MaxCronDuration)}
+		}
+	}
+	return nil
+}
+
+// location returns the time zone of the schedule
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// ActiveAt reports whether a window is open at t
+func (s *Schedule) ActiveAt(t time.Time) bool {
+	e := s.compile()
+	if e == nil {
+		return false
+	}
+	t = t.In(e.loc).Truncate(time.Minute)
+	if e.windowsActive(t) {
+		return true
+	}
+	for i := range e.crons {
+		if e.cronOpenUntil(i, t).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextTransition returns the next time a window opens or closes after t.
+// It returns false if the schedule does not change within a week.
+func (s *Schedule) NextTransition(t time.Time) (time.Time, bool) {
+	e := s.compile()
+	if e == nil {
+		return time.Time{}, false
+	}
+	t = t.In(e.loc).Truncate(time.Minute)
+	active := s.ActiveAt(t)
+
+	openUntil := make([]time.Time, len(e.crons))
+	for i := range e.crons {
+		openUntil[i] = e.cronOpenUntil(i, t)
+	}
+	for m := t.Add(time.Minute); m.Sub(t) <= scheduleHorizon; m = m.Add(time.Minute) {
+		now := e.windowsActive(m)
+		for i, c := range e.crons {
+			if c.spec.matches(m) {
+				openUntil[i] = m.Add(c.duration)
+			}
+			if openUntil[i].After(m) {
+				now = true
+			}
+		}
+		if now != active {
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compiledSchedule is a schedule with its expressions parsed
+type compiledSchedule struct {
+	loc     *time.Location
+	windows []compiledWindow
+	crons   []compiledCron
+}
+
+type compiledWindow struct {
+	days       [7]bool
+	start, end int // minutes since midnight
+}
+
+type compiledCron struct {
+	spec     *cronSpec
+	duration time.Duration
+}
+
+// compile parses the schedule, invalid parts are ignored. It returns nil
+// if the time zone is invalid.
+func (s *Schedule) compile() *compiledSchedule {
+	if s == nil {
+		return nil
+	}
+	loc, err := s.location()
+	if err != nil {
+		return nil
+	}
+	e := &compiledSchedule{loc: loc}
+	for _, w := range s.Windows {
+		start, end, err := w.bounds()
+		if err != nil {
+			continue
+		}
+		cw := compiledWindow{start: start, end: end}
+		for d := range cw.days {
+			cw.days[d] = len(w.Days) == 0
+		}
+		for _, d := range w.Days {
+			if d >= 0 && d <= 6 {
+				cw.days[d] = true
+			}
+		}
+		e.windows = append(e.windows, cw)
+	}
+	for _, c := range s.Cron {
+		spec, err := parseCron(c.Expr)
+		if err != nil || c.Duration <= 0 || c.Duration > MaxCronDuration {
+			continue
+		}
+		e.crons = append(e.crons, compiledCron{spec: spec, duration: time.Duration(c.Duration) * time.Minute})
+	}
+	return e
+}
+
+// windowsActive reports whether a time window is open at t
+func (e *compiledSchedule) windowsActive(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := int(t.Weekday())
+	yesterday := (today + 6) % 7
+	for _, w := range e.windows {
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// The window spans midnight
+		if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// cronOpenUntil returns when the window of the i-th cron entry opened most
+// recently at or before t closes
+func (e *compiledSchedule) cronOpenUntil(i int, t time.Time) time.Time {
+	c := e.crons[i]
+	for m := t; t.Sub(m) < c.duration; m = m.Add(-time.Minute) {
+		if c.spec.matches(m) {
+			return m.Add(c.duration)
+		}
+	}
+	return time.Time{}
+}
+
+// bounds parses the start and end of the window in minutes since midnight
+func (w *TimeWindow) bounds() (int, int, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == 24*60 {
+		return 0, 0, fmt.Errorf("start cannot be 24:00, use 00:00")
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("start and end cannot be equal")
+	}
+	return start, end, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight, "24:00" is accepted
+// and only valid as an end
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// cronSpec is a parsed five-field cron expression, each field is a bit set
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// parseCron parses a standard cron expression: minute hour day-of-month
+// month day-of-week, with *, lists, ranges and steps. Sunday is 0 or 7.
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	var c cronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return &c, nil
+}
+
+// parseCronField parses one field into a bit set
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", field)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			n, err := strconv.Atoi(from)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %q", field)
+			}
+			lo, hi = n, n
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid range in %q", field)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range (%d-%d) in %q", min, max, field)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matches reports whether the minute t is selected by the expression. As in
+// standard cron, when both days are restricted either may match.
+func (c *cronSpec) matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
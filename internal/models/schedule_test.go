@@ -0,0 +1,143 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Schedule
+		wantErr bool
+	}{
+		{"window", Schedule{Windows: []TimeWindow{{Start: "09:00", End: "18:00"}}}, false},
+		{"cron", Schedule{Cron: []CronWindow{{Expr: "0 9 * * 1-5", Duration: 60}}}, false},
+		{"empty", Schedule{}, true},
+		{"bad zone", Schedule{Timezone: "Mars/Base", Windows: []TimeWindow{{Start: "09:00", End: "18:00"}}}, true},
+		{"bad clock", Schedule{Windows: []TimeWindow{{Start: "9am", End: "18:00"}}}, true},
+		{"equal bounds", Schedule{Windows: []TimeWindow{{Start: "09:00", End: "09:00"}}}, true},
+		{"end of day", Schedule{Windows: []TimeWindow{{Start: "18:00", End: "24:00"}}}, false},
+		{"start at end of day", Schedule{Windows: []TimeWindow{{Start: "24:00", End: "08:00"}}}, true},
+		{"bad day", Schedule{Windows: []TimeWindow{{Days: []int{7}, Start: "09:00", End: "18:00"}}}, true},
+		{"bad cron", Schedule{Cron: []CronWindow{{Expr: "0 25 * * *", Duration: 60}}}, true},
+		{"no duration", Schedule{Cron: []CronWindow{{Expr: "0 9 * * *"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchedule_ActiveAt(t *testing.T) {
+	// 2026-03-02 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	office := &Schedule{Timezone: "UTC", Windows: []TimeWindow{{Days: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "18:00"}}}
+	night := &Schedule{Timezone: "UTC", Windows: []TimeWindow{{Days: []int{5}, Start: "22:00", End: "02:00"}}}
+	backup := &Schedule{Timezone: "UTC", Cron: []CronWindow{{Expr: "30 23 * * *", Duration: 90}}}
+
+	tests := []struct {
+		name string
+		s    *Schedule
+		t    time.Time
+		want bool
+	}{
+		{"office monday morning", office, at(2, 8, 59), false},
+		{"office monday open", office, at(2, 9, 0), true},
+		{"office monday close", office, at(2, 18, 0), false},
+		{"office sunday", office, at(1, 12, 0), false},
+		{"night friday late", night, at(6, 23, 0), true},
+		{"night saturday early", night, at(7, 1, 59), true},
+		{"night saturday late", night, at(7, 23, 0), false},
+		{"cron before", backup, at(3, 23, 29), false},
+		{"cron open", backup, at(3, 23, 30), true},
+		{"cron past midnight", backup, at(4, 0, 59), true},
+		{"cron closed", backup, at(4, 1, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.ActiveAt(tt.t); got != tt.want {
+				t.Errorf("ActiveAt(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_Timezone(t *testing.T) {
+	s := &Schedule{Timezone: "Asia/Shanghai", Windows: []TimeWindow{{Start: "09:00", End: "10:00"}}}
+
+	// 09:30 in Shanghai is 01:30 UTC
+	if !s.ActiveAt(time.Date(2026, 3, 2, 1, 30, 0, 0, time.UTC)) {
+		t.Error("window should be open at 09:30 Shanghai time")
+	}
+	if s.ActiveAt(time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)) {
+		t.Error("window should be closed at 17:30 Shanghai time")
+	}
+}
+
+func TestSchedule_NextTransition(t *testing.T) {
+	s := &Schedule{Timezone: "UTC", Windows: []TimeWindow{{Days: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "18:00"}}}
+
+	tests := []struct {
+		name string
+		from time.Time
+		want time.Time
+	}{
+		{"opens", time.Date(2026, 3, 2, 8, 0, 30, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{"closes", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)},
+		{"over weekend", time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.NextTransition(tt.from)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("NextTransition() = %s, %v, want %s", got, ok, tt.want)
+			}
+		})
+	}
+
+	always := &Schedule{Windows: []TimeWindow{{Start: "00:00", End: "24:00"}}}
+	if _, ok := always.NextTransition(time.Now()); ok {
+		t.Error("a schedule that is always open should have no transition")
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		t       time.Time
+		want    bool
+		wantErr bool
+	}{
+		{expr: "*/15 * * * *", t: time.Date(2026, 3, 2, 10, 45, 0, 0, time.UTC), want: true},
+		{expr: "*/15 * * * *", t: time.Date(2026, 3, 2, 10, 46, 0, 0, time.UTC), want: false},
+		{expr: "0 9 * * 1-5", t: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 9 * * 1-5", t: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), want: false},
+		{expr: "0 0 * * 7", t: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), want: true},
+		// Day of month and day of week are ORed when both are restricted
+		{expr: "0 0 15 * 1", t: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 0 15 * 1", t: time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 0 15 * 1", t: time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), want: false},
+		{expr: "0 12 1,15 3 *", t: time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC), want: true},
+		{expr: "0 12 * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if err == nil && spec.matches(tt.t) != tt.want {
+			t.Errorf("parseCron(%q).matches(%s) = %v, want %v", tt.expr, tt.t, !tt.want, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IssueSeverity represents how serious a validation issue is
+type IssueSeverity string
+
+const (
+	IssueError   IssueSeverity = "error"   // The rule cannot work, changes are rejected
+	IssueWarning IssueSeverity = "warning" // The rule may not work, changes are accepted
+)
+
+// ValidationIssue represents a single problem found by pre-flight validation
+type ValidationIssue struct {
+	Severity IssueSeverity `json:"severity"`
+	RuleID   string        `json:"ruleId,omitempty"`
+	RuleName string        `json:"ruleName,omitempty"`
+	ChainID  string        `json:"chainId,omitempty"`
+	Field    string        `json:"field"`
+	Message  string        `json:"message"`
+}
+
+func (i ValidationIssue) String() string {
+	subject := i.RuleName
+	if subject == "" {
+		subject = i.RuleID
+	}
+	if i.ChainID != "" {
+		subject = "chain " + i.ChainID
+	}
+	return fmt.Sprintf("%s: %s: %s", subject, i.Field, i.Message)
+}
+
+// ValidationReport lists every problem found by pre-flight validation
+type ValidationReport struct {
+	Valid  bool              `json:"valid"` // No issue of error severity
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// Add records an issue
+func (r *ValidationReport) Add(issue ValidationIssue) {
+	r.Issues = append(r.Issues, issue)
+	if issue.Severity == IssueError {
+		r.Valid = false
+	}
+}
+
+// Err returns an error listing the issues of error severity, or nil
+func (r *ValidationReport) Err() error {
+	var msgs []string
+	for _, issue := range r.Issues {
+		if issue.Severity == IssueError {
+			msgs = append(msgs, issue.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("validation failed: %s", strings.Join(msgs, "; "))
+}
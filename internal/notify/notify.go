@@ -0,0 +1,230 @@
+// Package notify delivers rule and service events to webhooks, Slack and
+// local commands
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"pfm/internal/logging"
+	"pfm/internal/models"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// queueSize is the number of events a sink holds while delivering,
+	// further events are dropped
+	queueSize = 100
+
+	// deliveryAttempts is how many times an event is tried, waiting
+	// retryDelay, then twice as long, between attempts
+	deliveryAttempts = 3
+	retryDelay       = time.Second
+
+	// deliveryTimeout bounds one request or command run
+	deliveryTimeout = 10 * time.Second
+)
+
+// Notifier sends events to the configured sinks. Each sink delivers in the
+// background, in order, with its own rate limit.
+type Notifier struct {
+	mu     sync.Mutex
+	sinks  []*sink
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// sink is a configured destination and its delivery queue
+type sink struct {
+	config     models.NotifierConfig
+	queue      chan models.Event
+	limiter    *rate.Limiter
+	suppressed int // dropped since the last delivered event, guarded by Notifier.mu
+}
+
+// New creates a notifier without sinks
+func New() *Notifier {
+	return &Notifier{logger: logging.For("notify")}
+}
+
+// SetLogger sets the logger for the notifier
+func (n *Notifier) SetLogger(logger *slog.Logger) {
+	n.logger = logger
+}
+
+// Configure replaces the sinks. Events queued for the previous sinks are
+// still delivered.
+func (n *Notifier) Configure(configs []models.NotifierConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, s := range n.sinks {
+		close(s.queue)
+	}
+	n.sinks = nil
+	for _, c := range configs {
+		if !c.Enabled {
+			continue
+		}
+		limit := c.GetRateLimit()
+		s := &sink{
+			config:  c,
+			queue:   make(chan models.Event, queueSize),
+			limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit),
+		}
+		n.sinks = append(n.sinks, s)
+		n.wg.Add(1)
+		go n.run(s)
+	}
+}
+
+// Notify queues an event for the sinks that want it
+func (n *Notifier) Notify(ev models.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, s := range n.sinks {
+		if !s.config.Wants(ev.Type) {
+			continue
+		}
+		if !s.limiter.Allow() {
+			s.suppressed++
+			continue
+		}
+		sent := ev
+		sent.Suppressed = s.suppressed
+		select {
+		case s.queue <- sent:
+			s.suppressed = 0
+		default:
+			s.suppressed++
+		}
+	}
+}
+
+// Close stops accepting events and waits up to timeout for the queued ones
+// to be delivered
+func (n *Notifier) Close(timeout time.Duration) {
+	n.Configure(nil)
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		n.logger.Warn("Gave up waiting for queued notifications")
+	}
+}
+
+// run delivers the events of a sink until its queue is closed
+func (n *Notifier) run(s *sink) {
+	defer n.wg.Done()
+	for ev := range s.queue {
+		delay := retryDelay
+		for attempt := 1; ; attempt++ {
+			err := deliver(s.config, ev)
+			if err == nil {
+				break
+			}
+			if attempt == deliveryAttempts {
+				n.logger.Warn("Failed to notify", "notifier", s.config.Name, "event", ev.Type, "rule", ev.RuleID, "error", err)
+				break
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+}
+
+// deliver sends one event to a sink
+func deliver(c models.NotifierConfig, ev models.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	switch c.Type {
+	case models.NotifierWebhook:
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		return post(ctx, c, body)
+
+	case models.NotifierSlack:
+		body, err := json.Marshal(map[string]string{"text": "[pfm] " + ev.Summary()})
+		if err != nil {
+			return err
+		}
+		return post(ctx, c, body)
+
+	case models.NotifierCommand:
+		return runCommand(ctx, c, ev)
+	}
+	return fmt.Errorf("unknown notifier type %q", c.Type)
+}
+
+// post sends a JSON body to the URL of a sink
+func post(ctx context.Context, c models.NotifierConfig, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// runCommand runs the command of a sink with the event as JSON on stdin and
+// its main fields in the environment
+func runCommand(ctx context.Context, c models.NotifierConfig, ev models.Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"PFM_EVENT="+string(ev.Type),
+		"PFM_STATUS="+ev.Status,
+		"PFM_RULE_ID="+ev.RuleID,
+		"PFM_RULE_NAME="+ev.RuleName,
+		"PFM_TARGET="+ev.Target,
+		"PFM_MESSAGE="+ev.Message,
+		"PFM_SUMMARY="+ev.Summary(),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// recorder is an HTTP endpoint keeping the bodies it received. It fails the
+// first fail requests.
+type recorder struct {
+	mu     sync.Mutex
+	fail   int
+	bodies []string
+	header http.Header
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail > 0 {
+		r.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	r.bodies = append(r.bodies, string(body))
+	r.header = req.Header.Clone()
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+func TestNotifier(t *testing.T) {
+	webhook := &recorder{fail: 1}
+	slack := &recorder{}
+	webhookSrv := httptest.NewServer(webhook)
+	defer webhookSrv.Close()
+	slackSrv := httptest.NewServer(slack)
+	defer slackSrv.Close()
+
+	n := New()
+	n.Configure([]models.NotifierConfig{
+		{
+			Name: "hook", Type: models.NotifierWebhook, Enabled: true, URL: webhookSrv.URL,
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+			Events:  []models.EventType{models.EventRuleStatus},
+		},
+		{Name: "slack", Type: models.NotifierSlack, Enabled: true, URL: slackSrv.URL, RateLimit: 2},
+		{Name: "off", Type: models.NotifierWebhook, URL: webhookSrv.URL},
+	})
+
+	n.Notify(models.Event{Type: models.EventRuleStatus, RuleID: "r1", RuleName: "web", Status: "error", Message: "bind: address already in use"})
+	n.Notify(models.Event{Type: models.EventTargetHealth, RuleName: "web", Target: "10.0.0.1:80", Status: "down"})
+	// Over the rate limit of the Slack sink
+	n.Notify(models.Event{Type: models.EventQuota, RuleName: "web", Status: "warning"})
+	n.Notify(models.Event{Type: models.EventQuota, RuleName: "web", Status: "exceeded"})
+	n.Close(5 * time.Second)
+
+	// The webhook got the status change after a retry, not the other events
+	bodies := webhook.received()
+	if len(bodies) != 1 {
+		t.Fatalf("webhook received %d events, want 1: %v", len(bodies), bodies)
+	}
+	var ev models.Event
+	if err := json.Unmarshal([]byte(bodies[0]), &ev); err != nil {
+		t.Fatalf("webhook payload %q: %v", bodies[0], err)
+	}
+	if ev.Type != models.EventRuleStatus || ev.RuleID != "r1" || ev.Status != "error" || ev.Time.IsZero() {
+		t.Errorf("webhook event = %+v", ev)
+	}
+	if got := webhook.header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q", got)
+	}
+
+	bodies = slack.received()
+	if len(bodies) != 2 {
+		t.Fatalf("slack received %d messages, want 2: %v", len(bodies), bodies)
+	}
+	var msg struct{ Text string }
+	json.Unmarshal([]byte(bodies[1]), &msg)
+	if msg.Text != "[pfm] Target 10.0.0.1:80 of rule web is down" {
+		t.Errorf("slack text = %q", msg.Text)
+	}
+
+	// A closed notifier drops events
+	n.Notify(models.Event{Type: models.EventRuleStatus, Status: "running"})
+	if got := len(webhook.received()); got != 1 {
+		t.Errorf("closed notifier delivered %d events", got)
+	}
+}
+
+func TestNotifierCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "event")
+
+	n := New()
+	n.Configure([]models.NotifierConfig{{
+		Name: "script", Type: models.NotifierCommand, Enabled: true,
+		Command: "sh", Args: []string{"-c", `printf '%s %s ' "$PFM_EVENT" "$PFM_STATUS" > "$0"; cat >> "$0"`, out},
+	}})
+	n.Notify(models.Event{Type: models.EventDaemon, Status: "started"})
+	n.Close(5 * time.Second)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("command did not run: %v", err)
+	}
+	if !strings.HasPrefix(string(data), `daemon started {"type":"daemon"`) {
+		t.Errorf("command output = %q", data)
+	}
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"pfm/internal/i18n"
+	"pfm/internal/models"
+)
+
+const (
+	// expiryWarning is how long before its expiry a rule logs a warning
+	expiryWarning = 5 * time.Minute
+
+	// maxDeleted caps the rules deleted at expiry kept for the status
+	maxDeleted = 50
+)
+
+// checkExpiry stops expired rules, deleting those that ask for it, and
+// warns about rules expiring soon
+func (s *Scheduler) checkExpiry(now time.Time) {
+	logMgr := s.engine.GetLogManager()
+	var expired []*models.Rule
+
+	s.mu.Lock()
+	for _, rule := range s.store.GetRules() {
+		if rule.ExpiresAt == nil {
+			continue
+		}
+		if rule.IsExpired(now) {
+			// Disabled rules that are kept need nothing more
+			if rule.Enabled || rule.DeleteOnExpiry || s.engine.IsActive(rule.ID) {
+				delete(s.warned, rule.ID)
+				delete(s.active, rule.ID)
+				delete(s.overrides, rule.ID)
+				expired = append(expired, rule)
+			}
+			continue
+		}
+		if rule.Enabled && rule.ExpiresAt.Sub(now) <= expiryWarning && !s.warned[rule.ID].Equal(*rule.ExpiresAt) {
+			s.warned[rule.ID] = *rule.ExpiresAt
+			logMgr.Warn(rule.ID, rule.Name, "expiry.soon", i18n.Params{"time": rule.ExpiresAt.Local().Format("15:04:05")})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, rule := range expired {
+		s.expire(rule)
+	}
+}
+
+// expire stops an expired rule, then disables or deletes it
+func (s *Scheduler) expire(rule *models.Rule) {
+	logMgr := s.engine.GetLogManager()
+	if s.engine.IsActive(rule.ID) {
+		if err := s.engine.StopRule(rule.ID); err != nil {
+			s.logger.Error("Failed to stop expired rule", "rule", rule.ID, "name", rule.Name, "error", err)
+		}
+	}
+
+	if rule.DeleteOnExpiry {
+		if err := s.store.DeleteRule(rule.ID); err != nil {
+			s.logger.Error("Failed to delete expired rule", "rule", rule.ID, "name", rule.Name, "error", err)
+			return
+		}
+		logMgr.Warn(rule.ID, rule.Name, "expiry.deleted", nil)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.deleted = append(s.deleted, models.ExpiryStatus{
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			ExpiresAt: *rule.ExpiresAt,
+			Expired:   true,
+			Deleted:   true,
+		})
+		if len(s.deleted) > maxDeleted {
+			s.deleted = s.deleted[len(s.deleted)-maxDeleted:]
+		}
+		return
+	}
+
+	rule.Enabled = false
+	rule.Status = models.RuleStatusExpired
+	rule.ErrorMsg = ""
+	if err := s.store.UpdateRule(rule); err != nil {
+		s.logger.Error("Failed to disable expired rule", "rule", rule.ID, "name", rule.Name, "error", err)
+		return
+	}
+	logMgr.Warn(rule.ID, rule.Name, "expiry.stopped", nil)
+}
+
+// Extend moves the expiry of a rule d later. An expired rule is enabled
+// again and started if its schedule allows.
+func (s *Scheduler) Extend(id string, d time.Duration) (*models.Rule, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("extension must be positive")
+	}
+	rule, err := s.store.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expired := rule.IsExpired(now) || rule.Status == models.RuleStatusExpired
+	rule.Extend(now, d)
+	if expired {
+		rule.Enabled = true
+		rule.Status = models.RuleStatusStopped
+	}
+	if err := s.store.UpdateRule(rule); err != nil {
+		return nil, err
+	}
+	s.engine.GetLogManager().Info(rule.ID, rule.Name, "expiry.extended", i18n.Params{"time": rule.ExpiresAt.Local().Format("2006-01-02 15:04:05")})
+
+	if expired && !s.engine.IsActive(rule.ID) && s.ShouldRun(rule) {
+		if err := s.engine.StartRule(rule); err != nil {
+			s.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
+			return rule, err
+		}
+		s.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+		rule.Status = models.RuleStatusRunning
+		if s.onStart != nil {
+			s.onStart(rule.ID)
+		}
+	}
+	return rule, nil
+}
+
+// ExpiryStatus returns the rules with an expiry, and the rules deleted at
+// expiry since the scheduler was created
+func (s *Scheduler) ExpiryStatus() []models.ExpiryStatus {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := append([]models.ExpiryStatus{}, s.deleted...)
+	for _, rule := range s.store.GetRules() {
+		if rule.ExpiresAt == nil {
+			continue
+		}
+		result = append(result, models.ExpiryStatus{
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			ExpiresAt: *rule.ExpiresAt,
+			Expired:   rule.IsExpired(now) || rule.Status == models.RuleStatusExpired,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ExpiresAt.Before(result[j].ExpiresAt)
+	})
+	return result
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"pfm/internal/models"
+)
+
+// checkQuota starts the rules stopped at their traffic quota once the quota
+// starts over, is raised or removed
+func (s *Scheduler) checkQuota(now time.Time) {
+	for _, rule := range s.store.GetRules() {
+		if !rule.Enabled || rule.Status != models.RuleStatusQuota || s.engine.IsActive(rule.ID) {
+			continue
+		}
+		if s.engine.QuotaBlocks(rule) {
+			continue
+		}
+		s.mu.Lock()
+		run := s.shouldRun(rule, now)
+		s.mu.Unlock()
+		if !run {
+			s.store.UpdateRuleStatus(rule.ID, models.RuleStatusStopped, "")
+			continue
+		}
+
+		s.engine.GetLogManager().Info(rule.ID, rule.Name, "quota.resetStart", nil)
+		s.engine.Emit(models.Event{Type: models.EventQuota, RuleID: rule.ID, RuleName: rule.Name, Status: "reset"})
+		if err := s.engine.StartRule(rule); err != nil {
+			s.logger.Error("Failed to start rule", "rule", rule.ID, "name", rule.Name, "error", err)
+			s.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
+			continue
+		}
+		s.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+		if s.onStart != nil {
+			s.onStart(rule.ID)
+		}
+	}
+}
+
+// IdleStatus returns the status of an enabled rule that should not run:
+// quota while its traffic quota stops it, stopped otherwise
+func (s *Scheduler) IdleStatus(rule *models.Rule) models.RuleStatus {
+	if s.engine.QuotaBlocks(rule) {
+		return models.RuleStatusQuota
+	}
+	return models.RuleStatusStopped
+}
+
+// QuotaStatus returns the usage of all rules with a traffic quota
+func (s *Scheduler) QuotaStatus() []models.QuotaStatus {
+	result := []models.QuotaStatus{}
+	for _, rule := range s.store.GetRules() {
+		if st := s.engine.GetQuotaStatus(rule); st != nil {
+			result = append(result, *st)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RuleName < result[j].RuleName
+	})
+	return result
+}
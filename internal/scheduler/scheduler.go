@@ -0,0 +1,313 @@
+// Package scheduler starts and stops rules according to their schedule and
+// expiry
+package scheduler
+
+import (
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/logging"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+// checkInterval is how often schedules are evaluated. Windows have minute
+// granularity, rules start or stop at most this late.
+const checkInterval = 15 * time.Second
+
+// override is a manual start or stop of a scheduled rule, it lasts until
+// the next scheduled transition
+type override struct {
+	running bool
+	until   time.Time // zero: until cleared
+}
+
+// transition is a start (active) or stop of a scheduled rule
+type transition struct {
+	rule   *models.Rule
+	active bool
+}
+
+// nextTransition is the next transition of a schedule, computed in minute
+type nextTransition struct {
+	schedule *models.Schedule
+	minute   time.Time
+	next     time.Time
+	ok       bool
+}
+
+// Scheduler enforces the schedules of enabled rules. It only acts when a
+// window opens or closes, so a rule that fails or is stopped inside its
+// window is left alone until the next transition.
+type Scheduler struct {
+	engine *engine.Engine
+	store  *storage.Store
+	logger *slog.Logger
+
+	// onStart is called after the scheduler started a rule
+	onStart func(ruleID string)
+
+	// checking serializes checks, which start and stop rules without s.mu
+	checking sync.Mutex
+
+	mu        sync.Mutex
+	active    map[string]bool // last evaluated state of each scheduled rule
+	overrides map[string]override
+	next      map[string]nextTransition
+	warned    map[string]time.Time  // expiry each rule was last warned about
+	deleted   []models.ExpiryStatus // rules deleted at expiry
+	stop      chan struct{}
+}
+
+// New creates a scheduler
+func New(e *engine.Engine, s *storage.Store) *Scheduler {
+	return &Scheduler{
+		engine:    e,
+		store:     s,
+		logger:    logging.For("scheduler"),
+		active:    make(map[string]bool),
+		overrides: make(map[string]override),
+		next:      make(map[string]nextTransition),
+		warned:    make(map[string]time.Time),
+	}
+}
+
+// SetLogger sets the logger for the scheduler
+func (s *Scheduler) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// SetStartCallback sets a function called after the scheduler started a rule
+func (s *Scheduler) SetStartCallback(fn func(ruleID string)) {
+	s.onStart = fn
+}
+
+// Start evaluates the schedules now and then periodically
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	s.Check(time.Now())
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.Check(now)
+			}
+		}
+	}()
+}
+
+// Stop stops evaluating the schedules
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// Check expires rules, starts the rules whose quota started over and starts
+// and stops the rules whose window opened or closed since the last check
+func (s *Scheduler) Check(now time.Time) {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+
+	s.checkExpiry(now)
+	s.checkQuota(now)
+
+	for _, t := range s.transitions(now) {
+		s.apply(t.rule, t.active)
+	}
+}
+
+// transitions returns the rules whose window opened or closed since the
+// last check
+func (s *Scheduler) transitions(now time.Time) []transition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []transition
+	seen := make(map[string]bool)
+	for _, rule := range s.store.GetRules() {
+		if !rule.Enabled || rule.Schedule == nil {
+			continue
+		}
+		seen[rule.ID] = true
+
+		if o, ok := s.overrides[rule.ID]; ok {
+			if o.until.IsZero() || now.Before(o.until) {
+				continue
+			}
+			delete(s.overrides, rule.ID)
+			delete(s.active, rule.ID) // enforce the schedule again
+			s.engine.GetLogManager().Info(rule.ID, rule.Name, "schedule.overrideExpired", nil)
+		}
+
+		active := rule.Schedule.ActiveAt(now)
+		if last, ok := s.active[rule.ID]; ok && last == active {
+			continue
+		}
+		s.active[rule.ID] = active
+		result = append(result, transition{rule: rule, active: active})
+	}
+
+	// Forget rules that were deleted, disabled or unscheduled
+	for id := range s.active {
+		if !seen[id] {
+			delete(s.active, id)
+		}
+	}
+	for id := range s.overrides {
+		if !seen[id] {
+			delete(s.overrides, id)
+		}
+	}
+	for id := range s.next {
+		if !seen[id] {
+			delete(s.next, id)
+		}
+	}
+	return result
+}
+
+// apply starts or stops a rule as its schedule requires
+func (s *Scheduler) apply(rule *models.Rule, active bool) {
+	logMgr := s.engine.GetLogManager()
+	running := s.engine.IsActive(rule.ID)
+
+	switch {
+	case active && !running:
+		logMgr.Info(rule.ID, rule.Name, "schedule.start", nil)
+		if err := s.engine.StartRule(rule); err != nil {
+			s.logger.Error("Failed to start rule", "rule", rule.ID, "name", rule.Name, "error", err)
+			s.store.UpdateRuleStatus(rule.ID, models.FailureStatus(err), err.Error())
+			return
+		}
+		s.store.UpdateRuleStatus(rule.ID, models.RuleStatusRunning, "")
+		if s.onStart != nil {
+			s.onStart(rule.ID)
+		}
+
+	case !active:
+		if running {
+			logMgr.Info(rule.ID, rule.Name, "schedule.stop", nil)
+			if err := s.engine.StopRule(rule.ID); err != nil {
+				s.logger.Error("Failed to stop rule", "rule", rule.ID, "name", rule.Name, "error", err)
+				return
+			}
+		}
+		// Also clears a stale error left by a failure inside the window
+		s.store.UpdateRuleStatus(rule.ID, models.RuleStatusStopped, "")
+	}
+}
+
+// Override records a manual start (running) or stop of a scheduled rule.
+// The schedule takes over again at its next transition.
+func (s *Scheduler) Override(rule *models.Rule, running bool) {
+	if rule.Schedule == nil {
+		return
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := override{running: running}
+	if next, ok := s.nextTransition(rule, now); ok {
+		o.until = next
+	}
+
+	// Following the schedule is not an override
+	if rule.Schedule.ActiveAt(now) == running {
+		delete(s.overrides, rule.ID)
+		return
+	}
+	s.overrides[rule.ID] = o
+	s.engine.GetLogManager().Info(rule.ID, rule.Name, "schedule.override", nil)
+}
+
+// ShouldRun reports whether an enabled rule should be running now, taking
+// its expiry, traffic quota, schedule and manual overrides into account
+func (s *Scheduler) ShouldRun(rule *models.Rule) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shouldRun(rule, time.Now())
+}
+
+// shouldRun is ShouldRun at now. Called with s.mu held.
+func (s *Scheduler) shouldRun(rule *models.Rule, now time.Time) bool {
+	if rule.IsExpired(now) || s.engine.QuotaBlocks(rule) {
+		return false
+	}
+	if rule.Schedule == nil {
+		return true
+	}
+	if o, ok := s.overrides[rule.ID]; ok && (o.until.IsZero() || now.Before(o.until)) {
+		return o.running
+	}
+	return rule.Schedule.ActiveAt(now)
+}
+
+// Status returns the schedule state of all enabled scheduled rules
+func (s *Scheduler) Status() []models.ScheduleStatus {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.ScheduleStatus{}
+	for _, rule := range s.store.GetRules() {
+		if !rule.Enabled || rule.Schedule == nil {
+			continue
+		}
+		st := models.ScheduleStatus{
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			Active:   rule.Schedule.ActiveAt(now),
+		}
+		if next, ok := s.nextTransition(rule, now); ok {
+			st.NextTransition = next
+		}
+		if o, ok := s.overrides[rule.ID]; ok {
+			st.Override = string(models.RuleStatusStopped)
+			if o.running {
+				st.Override = string(models.RuleStatusRunning)
+			}
+		}
+		result = append(result, st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RuleName < result[j].RuleName
+	})
+	return result
+}
+
+// nextTransition returns the next transition of the schedule of a rule
+// after now. Transitions fall on whole minutes, so the result is reused
+// within the minute it was computed in. Called with s.mu held.
+func (s *Scheduler) nextTransition(rule *models.Rule, now time.Time) (time.Time, bool) {
+	minute := now.Truncate(time.Minute)
+	if c, ok := s.next[rule.ID]; ok && c.minute.Equal(minute) && reflect.DeepEqual(c.schedule, rule.Schedule) {
+		return c.next, c.ok
+	}
+	next, ok := rule.Schedule.NextTransition(now)
+	s.next[rule.ID] = nextTransition{schedule: rule.Schedule, minute: minute, next: next, ok: ok}
+	return next, ok
+}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pfm/internal/engine"
+	"pfm/internal/models"
+	"pfm/internal/storage"
+)
+
+// freePort returns a TCP port that is currently unused
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestSchedulerCheck(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	e := engine.New()
+	defer e.StopAll()
+
+	rule := models.NewRule("Office", models.RuleTypeForward)
+	rule.Protocol = models.ProtocolTCP
+	rule.LocalPort = freePort(t)
+	rule.TargetHost = "127.0.0.1"
+	rule.TargetPort = 9
+	rule.Enabled = true
+	rule.Schedule = &models.Schedule{
+		Timezone: "UTC",
+		Windows:  []models.TimeWindow{{Start: "09:00", End: "18:00"}},
+	}
+	if err := store.CreateRule(rule); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	s := New(e, store)
+	morning := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	noon := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	evening := time.Date(2026, 3, 2, 19, 0, 0, 0, time.UTC)
+
+	s.Check(morning)
+	if e.IsRunning(rule.ID) {
+		t.Fatal("rule running before its window opened")
+	}
+
+	s.Check(noon)
+	if !e.IsRunning(rule.ID) {
+		t.Fatal("rule not started when its window opened")
+	}
+
+	// Inside the window a stopped rule is left alone until the next transition
+	if err := e.StopRule(rule.ID); err != nil {
+		t.Fatalf("StopRule() error = %v", err)
+	}
+	s.Check(noon.Add(time.Minute))
+	if e.IsRunning(rule.ID) {
+		t.Error("rule restarted without a transition")
+	}
+
+	s.Check(evening)
+	if e.IsRunning(rule.ID) {
+		t.Error("rule running after its window closed")
+	}
+	if st, _ := store.GetRule(rule.ID); st.Status != models.RuleStatusStopped {
+		t.Errorf("status = %s, want stopped", st.Status)
+	}
+
+	if got := s.Status(); len(got) != 1 || got[0].RuleID != rule.ID {
+		t.Errorf("Status() = %+v", got)
+	}
+}
+
+func TestSchedulerOverride(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	s := New(engine.New(), store)
+
+	// A one-minute window opening two minutes from now
+	start := time.Now().Add(2 * time.Minute).UTC()
+	rule := &models.Rule{ID: "rule-override", Name: "Override", Enabled: true, Schedule: &models.Schedule{
+		Timezone: "UTC",
+		Windows:  []models.TimeWindow{{Start: start.Format("15:04"), End: start.Add(time.Minute).Format("15:04")}},
+	}}
+
+	if s.ShouldRun(rule) {
+		t.Fatal("ShouldRun() = true outside the window")
+	}
+	s.Override(rule, true)
+	if !s.ShouldRun(rule) {
+		t.Error("ShouldRun() = false after a manual start")
+	}
+
+	// Stopping again follows the schedule and clears the override
+	s.Override(rule, false)
+	if s.ShouldRun(rule) || len(s.overrides) != 0 {
+		t.Errorf("override not cleared: %+v", s.overrides)
+	}
+
+	if !s.ShouldRun(&models.Rule{ID: "plain", Enabled: true}) {
+		t.Error("rules without a schedule should always run")
+	}
+}
+
+func TestSchedulerNextTransition(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	s := New(engine.New(), store)
+
+	rule := &models.Rule{ID: "rule-next", Schedule: &models.Schedule{
+		Timezone: "UTC",
+		Windows:  []models.TimeWindow{{Start: "09:00", End: "18:00"}},
+	}}
+	now := time.Date(2026, 3, 2, 8, 0, 10, 0, time.UTC)
+	want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if next, ok := s.nextTransition(rule, now); !ok || !next.Equal(want) {
+		t.Fatalf("nextTransition() = %v, %v", next, ok)
+	}
+
+	// Within the minute the cached result is returned
+	s.next[rule.ID] = nextTransition{schedule: rule.Schedule, minute: now.Truncate(time.Minute), next: want.Add(time.Hour), ok: true}
+	if next, _ := s.nextTransition(rule, now.Add(30*time.Second)); !next.Equal(want.Add(time.Hour)) {
+		t.Errorf("nextTransition() = %v, want the cached result", next)
+	}
+
+	// A new minute or a changed schedule computes it again
+	if next, _ := s.nextTransition(rule, now.Add(time.Minute)); !next.Equal(want) {
+		t.Errorf("nextTransition() in the next minute = %v", next)
+	}
+	changed := rule.Clone()
+	changed.Schedule.Windows[0].Start = "10:00"
+	if next, _ := s.nextTransition(changed, now.Add(time.Minute)); !next.Equal(want.Add(time.Hour)) {
+		t.Errorf("nextTransition() of a changed schedule = %v", next)
+	}
+}
+
+func TestSchedulerExpiry(t *testing.T) {
+	store, err := storage.NewWithPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	e := engine.New()
+	defer e.StopAll()
+	s := New(e, store)
+
+	newRule := func(name string, deleteOnExpiry bool) *models.Rule {
+		rule := models.NewRule(name, models.RuleTypeForward)
+		rule.Protocol = models.ProtocolTCP
+		rule.LocalPort = freePort(t)
+		rule.TargetHost = "127.0.0.1"
+		rule.TargetPort = 9
+		rule.Enabled = true
+		rule.TTL = 3600
+		rule.DeleteOnExpiry = deleteOnExpiry
+		if err := store.CreateRule(rule); err != nil {
+			t.Fatalf("CreateRule() error = %v", err)
+		}
+		if err := e.StartRule(rule); err != nil {
+			t.Fatalf("StartRule() error = %v", err)
+		}
+		return rule
+	}
+	kept := newRule("Kept", false)
+	deleted := newRule("Deleted", true)
+
+	s.Check(time.Now().Add(59 * time.Minute))
+	if len(s.warned) != 2 || !e.IsRunning(kept.ID) {
+		t.Fatalf("rules expiring soon should only be warned about, warned = %v", s.warned)
+	}
+
+	expiry := time.Now().Add(2 * time.Hour)
+	s.Check(expiry)
+	if e.IsRunning(kept.ID) || e.IsRunning(deleted.ID) {
+		t.Fatal("expired rules still running")
+	}
+	rule, err := store.GetRule(kept.ID)
+	if err != nil || rule.Enabled || rule.Status != models.RuleStatusExpired {
+		t.Fatalf("expired rule = %+v, %v", rule, err)
+	}
+	if _, err := store.GetRule(deleted.ID); err != models.ErrRuleNotFound {
+		t.Errorf("rule with deleteOnExpiry not deleted: %v", err)
+	}
+
+	status := s.ExpiryStatus()
+	if len(status) != 2 || !status[0].Expired || !status[1].Expired {
+		t.Fatalf("ExpiryStatus() = %+v", status)
+	}
+	for _, st := range status {
+		if st.Deleted != (st.RuleID == deleted.ID) {
+			t.Errorf("ExpiryStatus() deleted mismatch: %+v", st)
+		}
+	}
+
+	// Extending brings the expired rule back
+	rule, err = s.Extend(kept.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Extend() error = %v", err)
+	}
+	if !rule.Enabled || rule.IsExpired(time.Now()) || !e.IsRunning(kept.ID) {
+		t.Errorf("extended rule = %+v, running = %v", rule, e.IsRunning(kept.ID))
+	}
+}
+
+func TestSchedulerQuota(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewWithPath(dir)
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+
+	rule := models.NewRule("Metered", models.RuleTypeForward)
+	rule.Protocol = models.ProtocolTCP
+	rule.LocalPort = freePort(t)
+	rule.TargetHost = "127.0.0.1"
+	rule.TargetPort = 9
+	rule.Enabled = true
+	rule.Status = models.RuleStatusQuota
+	rule.Quota = &models.Quota{Bytes: 100, Period: models.QuotaMonth}
+	if err := store.CreateRule(rule); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	// The quota was used up before the daemon restarted
+	path := filepath.Join(dir, "quota.json")
+	usage := fmt.Sprintf(`[{"ruleId": %q, "period": %q, "bytes": 150}]`, rule.ID, rule.Quota.PeriodKey(time.Now()))
+	if err := os.WriteFile(path, []byte(usage), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	quotas, err := engine.NewQuotas(path)
+	if err != nil {
+		t.Fatalf("NewQuotas() error = %v", err)
+	}
+	e := engine.New()
+	defer e.StopAll()
+	e.SetQuotas(quotas)
+	s := New(e, store)
+
+	if s.ShouldRun(rule) || s.IdleStatus(rule) != models.RuleStatusQuota {
+		t.Fatal("rule over its quota should not run")
+	}
+	s.Check(time.Now())
+	if e.IsRunning(rule.ID) {
+		t.Fatal("rule started over its quota")
+	}
+	if st := s.QuotaStatus(); len(st) != 1 || st[0].Used != 150 || !st[0].Exceeded {
+		t.Errorf("QuotaStatus() = %+v", st)
+	}
+
+	// Raising the quota starts the rule again
+	rule.Quota.Bytes = 1000
+	if err := store.UpdateRule(rule); err != nil {
+		t.Fatalf("UpdateRule() error = %v", err)
+	}
+	s.Check(time.Now())
+	if !e.IsRunning(rule.ID) {
+		t.Fatal("rule not started after its quota was raised")
+	}
+	if st, _ := store.GetRule(rule.ID); st.Status != models.RuleStatusRunning {
+		t.Errorf("status = %s, want running", st.Status)
+	}
+}
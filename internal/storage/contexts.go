@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"pfm/internal/models"
+)
+
+// ContextsEnv overrides the path of the contexts file
+const ContextsEnv = "PFM_CONTEXTS"
+
+// ContextsPath returns the path of the contexts file
+func ContextsPath() (string, error) {
+	if path := os.Getenv(ContextsEnv); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pfm", "contexts.json"), nil
+}
+
+// LoadContexts reads the contexts file, empty when it does not exist
+func LoadContexts() (*models.ContextConfig, error) {
+	path, err := ContextsPath()
+	if err != nil {
+		return nil, err
+	}
+	config := &models.ContextConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// SaveContexts writes the contexts file, readable by the user only as it
+// holds credentials. The file is replaced by a new one, so a file created
+// with other permissions does not keep them.
+func SaveContexts(config *models.ContextConfig) error {
+	path, err := ContextsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Created with 0600
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".contexts-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -12,10 +12,65 @@ import (
 
 // Store manages persistent storage for application data
 type Store struct {
-	mu       sync.RWMutex
-	dataDir  string
-	dataFile string
-	data     *models.AppData
+	mu           sync.RWMutex
+	dataDir      string
+	dataFile     string
+	data         *models.AppData
+	onRuleStatus RuleStatusCallback
+}
+
+// RuleStatusCallback is called with a rule status event when the status of
+// a rule changes, when it is created, and when it is deleted (status
+// "deleted")
+type RuleStatusCallback func(ev models.Event)
+
+// ruleStatus is the part of a rule reported by status events
+type ruleStatus struct {
+	name   string
+	status models.RuleStatus
+	err    string
+}
+
+// SetRuleStatusCallback sets the callback receiving rule status events
+func (s *Store) SetRuleStatusCallback(callback RuleStatusCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRuleStatus = callback
+}
+
+// updateRules runs fn with s.mu held, then reports the rule status changes
+// it made, in the order of the rules
+func (s *Store) updateRules(fn func() error) error {
+	s.mu.Lock()
+	callback := s.onRuleStatus
+	if callback == nil {
+		defer s.mu.Unlock()
+		return fn()
+	}
+
+	before := make(map[string]ruleStatus, len(s.data.Rules))
+	for _, r := range s.data.Rules {
+		before[r.ID] = ruleStatus{name: r.Name, status: r.Status, err: r.ErrorMsg}
+	}
+	err := fn()
+	now := time.Now()
+	var events []models.Event
+	for _, r := range s.data.Rules {
+		st := ruleStatus{name: r.Name, status: r.Status, err: r.ErrorMsg}
+		if prev, ok := before[r.ID]; !ok || prev.status != st.status || prev.err != st.err {
+			events = append(events, models.Event{Type: models.EventRuleStatus, Time: now, RuleID: r.ID, RuleName: r.Name, Status: string(r.Status), Message: r.ErrorMsg})
+		}
+		delete(before, r.ID)
+	}
+	for id, st := range before {
+		events = append(events, models.Event{Type: models.EventRuleStatus, Time: now, RuleID: id, RuleName: st.name, Status: "deleted"})
+	}
+	s.mu.Unlock()
+
+	for _, ev := range events {
+		callback(ev)
+	}
+	return err
 }
 
 // New creates a new Store instance
@@ -168,66 +223,64 @@ func (s *Store) GetRule(id string) (*models.Rule, error) {
 
 // CreateRule adds a new rule
 func (s *Store) CreateRule(rule *models.Rule) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check for duplicate ID
-	for _, r := range s.data.Rules {
-		if r.ID == rule.ID {
-			return models.ErrRuleExists
+	return s.updateRules(func() error {
+		// Check for duplicate ID
+		for _, r := range s.data.Rules {
+			if r.ID == rule.ID {
+				return models.ErrRuleExists
+			}
 		}
-	}
 
-	rule.CreatedAt = time.Now()
-	rule.UpdatedAt = rule.CreatedAt
-	s.data.Rules = append(s.data.Rules, rule.Clone())
-	return s.save()
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = rule.CreatedAt
+		rule.ApplyTTL(rule.CreatedAt)
+		s.data.Rules = append(s.data.Rules, rule.Clone())
+		return s.save()
+	})
 }
 
 // UpdateRule updates an existing rule
 func (s *Store) UpdateRule(rule *models.Rule) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for i, r := range s.data.Rules {
-		if r.ID == rule.ID {
-			rule.UpdatedAt = time.Now()
-			rule.CreatedAt = r.CreatedAt // Preserve creation time
-			s.data.Rules[i] = rule.Clone()
-			return s.save()
+	return s.updateRules(func() error {
+		for i, r := range s.data.Rules {
+			if r.ID == rule.ID {
+				rule.UpdatedAt = time.Now()
+				rule.CreatedAt = r.CreatedAt // Preserve creation time
+				rule.ApplyTTL(rule.UpdatedAt)
+				s.data.Rules[i] = rule.Clone()
+				return s.save()
+			}
 		}
-	}
-	return models.ErrRuleNotFound
+		return models.ErrRuleNotFound
+	})
 }
 
 // DeleteRule removes a rule by ID
 func (s *Store) DeleteRule(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for i, r := range s.data.Rules {
-		if r.ID == id {
-			s.data.Rules = append(s.data.Rules[:i], s.data.Rules[i+1:]...)
-			return s.save()
+	return s.updateRules(func() error {
+		for i, r := range s.data.Rules {
+			if r.ID == id {
+				s.data.Rules = append(s.data.Rules[:i], s.data.Rules[i+1:]...)
+				return s.save()
+			}
 		}
-	}
-	return models.ErrRuleNotFound
+		return models.ErrRuleNotFound
+	})
 }
 
 // UpdateRuleStatus updates the status of a rule
 func (s *Store) UpdateRuleStatus(id string, status models.RuleStatus, errorMsg string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, r := range s.data.Rules {
-		if r.ID == id {
-			r.Status = status
-			r.ErrorMsg = errorMsg
-			r.UpdatedAt = time.Now()
-			return s.save()
+	return s.updateRules(func() error {
+		for _, r := range s.data.Rules {
+			if r.ID == id {
+				r.Status = status
+				r.ErrorMsg = errorMsg
+				r.UpdatedAt = time.Now()
+				return s.save()
+			}
 		}
-	}
-	return models.ErrRuleNotFound
+		return models.ErrRuleNotFound
+	})
 }
 
 // ==================== Chain Operations ====================
@@ -335,44 +388,43 @@ func (s *Store) GetAllData() *models.AppData {
 
 // ImportData imports data from another source
 func (s *Store) ImportData(data *models.AppData, merge bool) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if merge {
-		// Merge rules
-		for _, newRule := range data.Rules {
-			found := false
-			for i, existingRule := range s.data.Rules {
-				if existingRule.ID == newRule.ID {
-					s.data.Rules[i] = newRule.Clone()
-					found = true
-					break
+	return s.updateRules(func() error {
+		if merge {
+			// Merge rules
+			for _, newRule := range data.Rules {
+				found := false
+				for i, existingRule := range s.data.Rules {
+					if existingRule.ID == newRule.ID {
+						s.data.Rules[i] = newRule.Clone()
+						found = true
+						break
+					}
 				}
-			}
-			if !found {
-				s.data.Rules = append(s.data.Rules, newRule.Clone())
-			}
-		}
-		// Merge chains
-		for _, newChain := range data.Chains {
-			found := false
-			for i, existingChain := range s.data.Chains {
-				if existingChain.ID == newChain.ID {
-					s.data.Chains[i] = newChain.Clone()
-					found = true
-					break
+				if !found {
+					s.data.Rules = append(s.data.Rules, newRule.Clone())
 				}
 			}
-			if !found {
-				s.data.Chains = append(s.data.Chains, newChain.Clone())
+			// Merge chains
+			for _, newChain := range data.Chains {
+				found := false
+				for i, existingChain := range s.data.Chains {
+					if existingChain.ID == newChain.ID {
+						s.data.Chains[i] = newChain.Clone()
+						found = true
+						break
+					}
+				}
+				if !found {
+					s.data.Chains = append(s.data.Chains, newChain.Clone())
+				}
 			}
+		} else {
+			// Replace all data
+			s.data = data
 		}
-	} else {
-		// Replace all data
-		s.data = data
-	}
 
-	return s.save()
+		return s.save()
+	})
 }
 
 // ExportData exports all data to JSON bytes
@@ -0,0 +1,326 @@
+// Package validation implements the pre-flight checks run before rules are
+// saved or imported. Unlike Rule.Validate, which stops at the first problem
+// of a single rule, it reports every problem of a rule set at once.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pfm/internal/models"
+)
+
+// Options control the checks that depend on the host
+type Options struct {
+	// ProbePorts checks that listen ports are not bound by other processes
+	ProbePorts bool
+	// OwnPort reports whether a port is bound by PFM itself, such ports
+	// are not probed. May be nil.
+	OwnPort func(network string, port int) bool
+}
+
+// hostnameRe matches RFC 1123 host names. Underscores are accepted as
+// well, internal DNS (e.g. Docker service names) commonly uses them.
+var hostnameRe = regexp.MustCompile(`^([a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.)*[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.?$`)
+
+// hopProtocols are the protocols supported by chain hops
+var hopProtocols = map[models.Protocol]bool{
+	"":                    true, // defaults to socks5
+	models.ProtocolSOCKS5: true,
+	models.ProtocolHTTP:   true,
+	models.ProtocolHTTPS:  true,
+	models.ProtocolSS:     true,
+}
+
+// CheckAll validates all rules and chains
+func CheckAll(rules []*models.Rule, chains []*models.Chain, opts Options) *models.ValidationReport {
+	report := &models.ValidationReport{Valid: true, Issues: []models.ValidationIssue{}}
+	for _, chain := range chains {
+		checkChain(report, chain)
+	}
+	for _, rule := range rules {
+		checkRule(report, rule, rules, chains, opts)
+	}
+	return report
+}
+
+// CheckRule validates a rule about to be created or updated against the
+// other rules and the chains
+func CheckRule(rule *models.Rule, rules []*models.Rule, chains []*models.Chain, opts Options) *models.ValidationReport {
+	report := &models.ValidationReport{Valid: true, Issues: []models.ValidationIssue{}}
+	others := make([]*models.Rule, 0, len(rules)+1)
+	for _, r := range rules {
+		if r.ID != rule.ID {
+			others = append(others, r)
+		}
+	}
+	checkRule(report, rule, append(others, rule), chains, opts)
+	return report
+}
+
+// CheckImport validates imported data as it will be once imported. Only
+// issues concerning the imported rules and chains are reported.
+func CheckImport(current, imported *models.AppData, merge bool, opts Options) *models.ValidationReport {
+	rules, chains := imported.Rules, imported.Chains
+	if merge {
+		rules = mergeRules(current.Rules, imported.Rules)
+		chains = mergeChains(current.Chains, imported.Chains)
+	}
+
+	ids := make(map[string]bool)
+	for _, r := range imported.Rules {
+		ids[r.ID] = true
+	}
+	for _, c := range imported.Chains {
+		ids[c.ID] = true
+	}
+
+	all := CheckAll(rules, chains, opts)
+	report := &models.ValidationReport{Valid: true, Issues: []models.ValidationIssue{}}
+	for _, issue := range all.Issues {
+		if ids[issue.RuleID] || ids[issue.ChainID] {
+			report.Add(issue)
+		}
+	}
+	return report
+}
+
+// checkRule adds the issues of one rule, rules includes the rule itself
+func checkRule(report *models.ValidationReport, rule *models.Rule, rules []*models.Rule, chains []*models.Chain, opts Options) {
+	add := func(severity models.IssueSeverity, field, format string, args ...any) {
+		report.Add(models.ValidationIssue{
+			Severity: severity,
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Basic checks, the listen port is covered in more detail below
+	if err := rule.Validate(); err != nil {
+		var verr *models.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			field := verr.Field
+			if verr.Index >= 0 {
+				field = fmt.Sprintf("%s[%d]", verr.Field, verr.Index)
+			}
+			add(models.IssueError, field, "%s", verr.Message)
+		case errors.Is(err, models.ErrListenAddrEmpty):
+		default:
+			add(models.IssueError, "rule", "%v", err)
+		}
+	}
+
+	if !validPort(rule.LocalPort) {
+		add(models.IssueError, "localPort", "port %d is out of range (1-65535)", rule.LocalPort)
+	}
+
+	if rule.TargetHost != "" || rule.TargetPort != 0 {
+		checkAddr(add, "target", rule.TargetHost, rule.TargetPort)
+	}
+	for i, target := range rule.Targets {
+		checkAddr(add, fmt.Sprintf("targets[%d]", i), target.Host, target.Port)
+	}
+	if d := rule.Discovery; d != nil {
+		if name := strings.TrimSuffix(d.Name, "."); d.Type != models.DiscoveryDocker && name != "" && !validHost(name) {
+			add(models.IssueError, "discovery.name", "malformed name %q", d.Name)
+		}
+		if host, port, err := net.SplitHostPort(d.Resolver); err == nil {
+			n, _ := strconv.Atoi(port)
+			checkAddr(add, "discovery.resolver", host, n)
+		}
+		if rule.TargetHost != "" || len(rule.Targets) > 0 {
+			add(models.IssueWarning, "discovery", "static targets are ignored, targets are discovered from %s", d.Source())
+		}
+	}
+	// The mirror port is covered by Rule.Validate
+	if rule.Mirror != nil && rule.Mirror.Enabled && rule.Mirror.Host != "" && !validHost(rule.Mirror.Host) {
+		add(models.IssueError, "mirror", "malformed host %q", rule.Mirror.Host)
+	}
+
+	if rule.ChainID != "" && findChain(chains, rule.ChainID) == nil {
+		add(models.IssueError, "chainId", "chain %s does not exist", rule.ChainID)
+	}
+
+	// Dependencies on other rules
+	for i, dep := range rule.DependsOn {
+		field := fmt.Sprintf("dependsOn[%d]", i)
+		other := findRule(rules, dep)
+		switch {
+		case dep == rule.ID:
+			add(models.IssueError, field, "a rule cannot depend on itself")
+		case other == nil:
+			add(models.IssueError, field, "rule %s does not exist", dep)
+		case rule.Enabled && !other.Enabled:
+			add(models.IssueWarning, field, "rule %q is disabled, this rule will not start", other.Name)
+		}
+	}
+	if cycle := models.DependencyCycle(rules, rule.ID); len(cycle) > 2 {
+		names := make([]string, len(cycle))
+		for i, id := range cycle {
+			names[i] = id
+			if r := findRule(rules, id); r != nil {
+				names[i] = r.Name
+			}
+		}
+		add(models.IssueError, "dependsOn", "dependency cycle: %s", strings.Join(names, " -> "))
+	}
+
+	// Listen port conflicts between rules
+	network := rule.GetNetwork()
+	for _, other := range rules {
+		if other.ID == rule.ID || other.LocalPort != rule.LocalPort || other.GetNetwork() != network {
+			continue
+		}
+		severity := models.IssueWarning
+		if rule.Enabled && other.Enabled {
+			severity = models.IssueError
+		}
+		add(severity, "localPort", "%s port %d is also used by rule %q", network, rule.LocalPort, other.Name)
+	}
+
+	// Listen port bound by another process
+	if opts.ProbePorts && validPort(rule.LocalPort) && (opts.OwnPort == nil || !opts.OwnPort(network, rule.LocalPort)) {
+		if err := probePort(network, rule.LocalPort); err != nil {
+			severity := models.IssueWarning
+			if rule.Enabled {
+				severity = models.IssueError
+			}
+			add(severity, "localPort", "%s port %d is not available: %v", network, rule.LocalPort, err)
+		}
+	}
+}
+
+// checkChain adds the issues of a chain
+func checkChain(report *models.ValidationReport, chain *models.Chain) {
+	add := func(field, format string, args ...any) {
+		report.Add(models.ValidationIssue{
+			Severity: models.IssueError,
+			ChainID:  chain.ID,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if chain.Name == "" {
+		add("name", "%v", models.ErrChainNameEmpty)
+	}
+	if len(chain.Hops) == 0 {
+		add("hops", "%v", models.ErrNoHops)
+	}
+	for i, hop := range chain.Hops {
+		field := fmt.Sprintf("hops[%d]", i)
+		if !hopProtocols[hop.Protocol] {
+			add(field+".protocol", "unsupported hop protocol %q", hop.Protocol)
+		}
+		host, portStr, err := net.SplitHostPort(hop.Addr)
+		if err != nil {
+			add(field+".addr", "invalid address %q", hop.Addr)
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || !validPort(port) {
+			add(field+".addr", "port %s is out of range (1-65535)", portStr)
+		}
+		if !validHost(host) {
+			add(field+".addr", "malformed host %q", host)
+		}
+	}
+}
+
+// checkAddr reports a malformed host or port
+func checkAddr(add func(severity models.IssueSeverity, field, format string, args ...any), field, host string, port int) {
+	if !validHost(host) {
+		add(models.IssueError, field, "malformed host %q", host)
+	}
+	if !validPort(port) {
+		add(models.IssueError, field, "port %d is out of range (1-65535)", port)
+	}
+}
+
+// validHost reports whether host is an IP address or a well-formed host name
+func validHost(host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return false
+	}
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	return len(host) <= 253 && hostnameRe.MatchString(host)
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// probePort tries to bind a listen port
+func probePort(network string, port int) error {
+	addr := fmt.Sprintf(":%d", port)
+	if network == "udp" {
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return err
+		}
+		return pc.Close()
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
+
+func findRule(rules []*models.Rule, id string) *models.Rule {
+	for _, r := range rules {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func findChain(chains []*models.Chain, id string) *models.Chain {
+	for _, c := range chains {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// mergeRules returns the rules resulting from a merge import
+func mergeRules(current, imported []*models.Rule) []*models.Rule {
+	result := make([]*models.Rule, 0, len(current)+len(imported))
+	replaced := make(map[string]bool)
+	for _, r := range imported {
+		replaced[r.ID] = true
+	}
+	for _, r := range current {
+		if !replaced[r.ID] {
+			result = append(result, r)
+		}
+	}
+	return append(result, imported...)
+}
+
+// mergeChains returns the chains resulting from a merge import
+func mergeChains(current, imported []*models.Chain) []*models.Chain {
+	result := make([]*models.Chain, 0, len(current)+len(imported))
+	replaced := make(map[string]bool)
+	for _, c := range imported {
+		replaced[c.ID] = true
+	}
+	for _, c := range current {
+		if !replaced[c.ID] {
+			result = append(result, c)
+		}
+	}
+	return append(result, imported...)
+}
@@ -0,0 +1,190 @@
+package validation
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"pfm/internal/models"
+)
+
+func forwardRule(id string, port int, enabled bool) *models.Rule {
+	return &models.Rule{
+		ID:         id,
+		Name:       "rule-" + id,
+		Type:       models.RuleTypeForward,
+		Enabled:    enabled,
+		LocalPort:  port,
+		Protocol:   models.ProtocolTCP,
+		TargetHost: "example.com",
+		TargetPort: 80,
+	}
+}
+
+// hasIssue reports whether the report has an issue of the given severity on field
+func hasIssue(report *models.ValidationReport, severity models.IssueSeverity, field, contains string) bool {
+	for _, issue := range report.Issues {
+		if issue.Severity == severity && issue.Field == field && strings.Contains(issue.Message, contains) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckAllReportsEveryProblem(t *testing.T) {
+	chain := &models.Chain{
+		ID:   "c1",
+		Name: "proxies",
+		Hops: []models.Hop{
+			{Name: "a", Addr: "10.0.0.1:1080", Protocol: models.ProtocolSOCKS5},
+			{Name: "b", Addr: "10.0.0.2:70000", Protocol: "vmess"},
+		},
+	}
+
+	bad := forwardRule("r1", 70000, true)
+	bad.TargetHost = "bad host!"
+	bad.ChainID = "missing"
+
+	report := CheckAll([]*models.Rule{bad}, []*models.Chain{chain}, Options{})
+	if report.Valid {
+		t.Fatal("expected the report to be invalid")
+	}
+
+	for _, want := range []struct{ field, contains string }{
+		{"localPort", "out of range"},
+		{"target", "malformed host"},
+		{"chainId", "does not exist"},
+		{"hops[1].protocol", "unsupported"},
+		{"hops[1].addr", "out of range"},
+	} {
+		if !hasIssue(report, models.IssueError, want.field, want.contains) {
+			t.Errorf("missing %s issue %q in %+v", want.field, want.contains, report.Issues)
+		}
+	}
+	if err := report.Err(); err == nil {
+		t.Error("Err() should return an error")
+	}
+}
+
+func TestDuplicatePorts(t *testing.T) {
+	a := forwardRule("a", 8080, true)
+	b := forwardRule("b", 8080, true)
+	c := forwardRule("c", 8080, false)
+	d := forwardRule("d", 8080, true)
+	d.Protocol = models.ProtocolUDP
+
+	report := CheckRule(b, []*models.Rule{a}, nil, Options{})
+	if !hasIssue(report, models.IssueError, "localPort", "also used") {
+		t.Errorf("enabled duplicate should be an error: %+v", report.Issues)
+	}
+
+	report = CheckRule(c, []*models.Rule{a}, nil, Options{})
+	if !report.Valid || !hasIssue(report, models.IssueWarning, "localPort", "also used") {
+		t.Errorf("disabled duplicate should be a warning: %+v", report.Issues)
+	}
+
+	report = CheckRule(d, []*models.Rule{a}, nil, Options{})
+	if len(report.Issues) != 0 {
+		t.Errorf("tcp and udp on the same port should not conflict: %+v", report.Issues)
+	}
+
+	// Updating a rule does not conflict with its stored version
+	report = CheckRule(a, []*models.Rule{a}, nil, Options{})
+	if len(report.Issues) != 0 {
+		t.Errorf("rule should not conflict with itself: %+v", report.Issues)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	a := forwardRule("a", 8001, true)
+	b := forwardRule("b", 8002, true)
+	c := forwardRule("c", 8003, true)
+	off := forwardRule("off", 8004, false)
+	a.DependsOn = []string{"b"}
+	b.DependsOn = []string{"c"}
+	c.DependsOn = []string{"a", "off", "ghost", "c"}
+
+	report := CheckAll([]*models.Rule{a, b, c, off}, nil, Options{})
+	for _, want := range []struct {
+		severity        models.IssueSeverity
+		field, contains string
+	}{
+		{models.IssueError, "dependsOn", "rule-a -> rule-b -> rule-c -> rule-a"},
+		{models.IssueWarning, "dependsOn[1]", "disabled"},
+		{models.IssueError, "dependsOn[2]", "does not exist"},
+		{models.IssueError, "dependsOn[3]", "itself"},
+	} {
+		if !hasIssue(report, want.severity, want.field, want.contains) {
+			t.Errorf("missing %s issue %q in %+v", want.field, want.contains, report.Issues)
+		}
+	}
+}
+
+func TestPortProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	rule := forwardRule("a", port, true)
+	report := CheckRule(rule, nil, nil, Options{ProbePorts: true})
+	if !hasIssue(report, models.IssueError, "localPort", "not available") {
+		t.Errorf("bound port should be reported: %+v", report.Issues)
+	}
+
+	// Ports bound by running rules are ours
+	own := func(network string, p int) bool { return network == "tcp" && p == port }
+	report = CheckRule(rule, nil, nil, Options{ProbePorts: true, OwnPort: own})
+	if len(report.Issues) != 0 {
+		t.Errorf("own port should not be probed: %+v", report.Issues)
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	current := &models.AppData{
+		Rules: []*models.Rule{forwardRule("a", 8080, true), forwardRule("b", 9090, true)},
+	}
+	imported := &models.AppData{
+		Rules: []*models.Rule{forwardRule("c", 8080, true)},
+	}
+
+	// Merged, the imported rule conflicts with rule a
+	report := CheckImport(current, imported, true, Options{})
+	if report.Valid {
+		t.Errorf("merge import should conflict: %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.RuleID != "c" {
+			t.Errorf("issue about a rule that is not imported: %+v", issue)
+		}
+	}
+
+	// Replacing the data, the current rules go away
+	report = CheckImport(current, imported, false, Options{})
+	if !report.Valid || len(report.Issues) != 0 {
+		t.Errorf("overwrite import should be valid: %+v", report.Issues)
+	}
+}
+
+func TestValidHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"example.com":                         true,
+		"localhost":                           true,
+		"10.0.0.1":                            true,
+		"::1":                                 true,
+		"[::1]":                               true,
+		"my-host.lan.":                        true,
+		"":                                    false,
+		"bad host":                            false,
+		"-leading.com":                        false,
+		"my_service":                          true,
+		"a..b":                                false,
+		"toolong" + strings.Repeat(".a", 130): false,
+	} {
+		if got := validHost(host); got != want {
+			t.Errorf("validHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}